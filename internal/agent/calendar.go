@@ -0,0 +1,227 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// calendarFields lists the StartCalendarInterval keys in display order with
+// their valid ranges. Weekday 0 and 7 both mean Sunday.
+var calendarFields = []struct {
+	key      string
+	min, max int
+}{
+	{"Month", 1, 12},
+	{"Day", 1, 31},
+	{"Weekday", 0, 7},
+	{"Hour", 0, 23},
+	{"Minute", 0, 59},
+}
+
+// calendarSearchLimit bounds the next-fire search. Eight years covers every
+// combination that can fire at all, including February 29th on a weekday.
+const calendarSearchLimit = 8 * 365 * 24 * time.Hour
+
+// ParseCalendarIntervals decodes a raw StartCalendarInterval value, which is
+// either a single dictionary or an array of dictionaries. Entries that
+// cannot be decoded are skipped and reported in the returned error; range
+// problems are left to CalendarInterval.Validate.
+func ParseCalendarIntervals(raw interface{}) ([]CalendarInterval, error) {
+	var dicts []interface{}
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		dicts = []interface{}{v}
+	case []interface{}:
+		dicts = v
+	default:
+		return nil, fmt.Errorf("StartCalendarInterval must be a dictionary or an array of dictionaries, got %T", raw)
+	}
+
+	var intervals []CalendarInterval
+	var errs []error
+	for i, d := range dicts {
+		dict, ok := d.(map[string]interface{})
+		if !ok {
+			errs = append(errs, fmt.Errorf("StartCalendarInterval[%d]: expected a dictionary, got %T", i, d))
+			continue
+		}
+		ci, err := decodeCalendarInterval(dict)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("StartCalendarInterval[%d]: %w", i, err))
+			continue
+		}
+		intervals = append(intervals, ci)
+	}
+	return intervals, errors.Join(errs...)
+}
+
+// decodeCalendarInterval decodes one StartCalendarInterval dictionary.
+func decodeCalendarInterval(dict map[string]interface{}) (CalendarInterval, error) {
+	var ci CalendarInterval
+	for key, value := range dict {
+		n, ok := toInt(value)
+		if !ok {
+			return ci, fmt.Errorf("%s must be an integer, got %T", key, value)
+		}
+		switch key {
+		case "Minute":
+			ci.Minute = &n
+		case "Hour":
+			ci.Hour = &n
+		case "Day":
+			ci.Day = &n
+		case "Weekday":
+			ci.Weekday = &n
+		case "Month":
+			ci.Month = &n
+		default:
+			return ci, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	return ci, nil
+}
+
+// toInt converts the integer types produced by plist and JSON decoding.
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	case float64:
+		if n != float64(int(n)) {
+			return 0, false
+		}
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
+
+// field returns the value for a calendarFields key.
+func (c CalendarInterval) field(key string) *int {
+	switch key {
+	case "Minute":
+		return c.Minute
+	case "Hour":
+		return c.Hour
+	case "Day":
+		return c.Day
+	case "Weekday":
+		return c.Weekday
+	case "Month":
+		return c.Month
+	}
+	return nil
+}
+
+// Validate checks every set field against its launchd range.
+func (c CalendarInterval) Validate() error {
+	var errs []error
+	for _, f := range calendarFields {
+		if v := c.field(f.key); v != nil && (*v < f.min || *v > f.max) {
+			errs = append(errs, fmt.Errorf("%s %d out of range %d-%d", f.key, *v, f.min, f.max))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// String formats the interval as "Month=1 Hour=9 Minute=0". An empty
+// interval fires every minute.
+func (c CalendarInterval) String() string {
+	var parts []string
+	for _, f := range calendarFields {
+		if v := c.field(f.key); v != nil {
+			parts = append(parts, fmt.Sprintf("%s=%d", f.key, *v))
+		}
+	}
+	if len(parts) == 0 {
+		return "every minute"
+	}
+	return strings.Join(parts, " ")
+}
+
+// matchesDay reports whether t's date matches Month, Day and Weekday. As in
+// cron, when both Day and Weekday are set either one matching is enough.
+func (c CalendarInterval) matchesDay(t time.Time) bool {
+	if c.Month != nil && int(t.Month()) != *c.Month {
+		return false
+	}
+	dayOK := c.Day == nil || t.Day() == *c.Day
+	weekdayOK := c.Weekday == nil || int(t.Weekday()) == *c.Weekday%7
+	if c.Day != nil && c.Weekday != nil {
+		return dayOK || weekdayOK
+	}
+	return dayOK && weekdayOK
+}
+
+// Next returns the first time strictly after t at which the interval fires,
+// using launchd's semantics: an omitted field is a wildcard, so {Hour=9}
+// fires every minute from 9:00 to 9:59. It returns false if the interval
+// never fires (for example Day=31 Month=2) or is invalid.
+func (c CalendarInterval) Next(t time.Time) (time.Time, bool) {
+	if c.Validate() != nil {
+		return time.Time{}, false
+	}
+	loc := t.Location()
+	limit := t.Add(calendarSearchLimit)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.Hour != nil && t.Hour() != *c.Hour {
+			if t.Hour() > *c.Hour {
+				t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			} else {
+				t = time.Date(t.Year(), t.Month(), t.Day(), *c.Hour, 0, 0, 0, loc)
+			}
+			continue
+		}
+		if c.Minute != nil && t.Minute() != *c.Minute {
+			if t.Minute() > *c.Minute {
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			} else {
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), *c.Minute, 0, 0, loc)
+			}
+			continue
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// NextFireTimes returns up to n times after t at which any of the intervals
+// fires, in order and without duplicates.
+func NextFireTimes(intervals []CalendarInterval, t time.Time, n int) []time.Time {
+	seen := make(map[time.Time]bool)
+	var times []time.Time
+	for _, ci := range intervals {
+		next := t
+		for i := 0; i < n; i++ {
+			fire, ok := ci.Next(next)
+			if !ok {
+				break
+			}
+			if !seen[fire] {
+				seen[fire] = true
+				times = append(times, fire)
+			}
+			next = fire
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	if len(times) > n {
+		times = times[:n]
+	}
+	return times
+}
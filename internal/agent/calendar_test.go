@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"testing"
+	"time"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestParseCalendarIntervals(t *testing.T) {
+	t.Run("single dictionary", func(t *testing.T) {
+		got, err := ParseCalendarIntervals(map[string]interface{}{"Hour": uint64(9), "Minute": uint64(30)})
+		if err != nil {
+			t.Fatalf("ParseCalendarIntervals() error = %v", err)
+		}
+		if len(got) != 1 || *got[0].Hour != 9 || *got[0].Minute != 30 || got[0].Day != nil {
+			t.Errorf("got %+v, want Hour=9 Minute=30", got)
+		}
+	})
+
+	t.Run("array keeps valid entries", func(t *testing.T) {
+		got, err := ParseCalendarIntervals([]interface{}{
+			map[string]interface{}{"Weekday": int64(1)},
+			map[string]interface{}{"Hour": "nine"},
+			map[string]interface{}{"Minute": float64(5)},
+		})
+		if err == nil {
+			t.Error("expected error for non-integer Hour, got nil")
+		}
+		if len(got) != 2 {
+			t.Errorf("got %d intervals, want 2", len(got))
+		}
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		if _, err := ParseCalendarIntervals(map[string]interface{}{"Second": 0}); err == nil {
+			t.Error("expected error for unknown key, got nil")
+		}
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		if _, err := ParseCalendarIntervals("daily"); err == nil {
+			t.Error("expected error for string value, got nil")
+		}
+	})
+}
+
+func TestCalendarIntervalValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		ci      CalendarInterval
+		wantErr bool
+	}{
+		{"empty", CalendarInterval{}, false},
+		{"weekday 7 is sunday", CalendarInterval{Weekday: intPtr(7)}, false},
+		{"weekday 8", CalendarInterval{Weekday: intPtr(8)}, true},
+		{"hour 24", CalendarInterval{Hour: intPtr(24)}, true},
+		{"day 0", CalendarInterval{Day: intPtr(0)}, true},
+		{"month 13", CalendarInterval{Month: intPtr(13)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.ci.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCalendarIntervalNext(t *testing.T) {
+	// Friday 2026-01-02 10:15.
+	from := time.Date(2026, 1, 2, 10, 15, 30, 0, time.UTC)
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		ci   CalendarInterval
+		want time.Time
+	}{
+		{"every minute", CalendarInterval{}, at(1, 2, 10, 16)},
+		{"daily at 9:00 rolls over", CalendarInterval{Hour: intPtr(9), Minute: intPtr(0)}, at(1, 3, 9, 0)},
+		{"hour only fires every minute of the hour", CalendarInterval{Hour: intPtr(11)}, at(1, 2, 11, 0)},
+		{"minute only fires every hour", CalendarInterval{Minute: intPtr(10)}, at(1, 2, 11, 10)},
+		{"weekday 7 is sunday", CalendarInterval{Weekday: intPtr(7), Hour: intPtr(0), Minute: intPtr(0)}, at(1, 4, 0, 0)},
+		{"day or weekday", CalendarInterval{Day: intPtr(20), Weekday: intPtr(1), Hour: intPtr(0), Minute: intPtr(0)}, at(1, 5, 0, 0)},
+		{"month and day", CalendarInterval{Month: intPtr(3), Day: intPtr(1), Hour: intPtr(6), Minute: intPtr(0)}, at(3, 1, 6, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.ci.Next(from)
+			if !ok {
+				t.Fatal("Next() returned no fire time")
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := (CalendarInterval{Month: intPtr(2), Day: intPtr(31)}).Next(from); ok {
+		t.Error("February 31st should never fire")
+	}
+	if got, ok := (CalendarInterval{Month: intPtr(2), Day: intPtr(29), Hour: intPtr(0), Minute: intPtr(0)}).Next(from); !ok || got.Year() != 2028 {
+		t.Errorf("February 29th: got %v, want 2028", got)
+	}
+}
+
+func TestNextFireTimes(t *testing.T) {
+	from := time.Date(2026, 1, 2, 10, 15, 0, 0, time.UTC)
+	intervals := []CalendarInterval{
+		{Hour: intPtr(12), Minute: intPtr(0)},
+		{Hour: intPtr(11), Minute: intPtr(0)},
+		{Hour: intPtr(12), Minute: intPtr(0)}, // duplicate
+	}
+
+	got := NextFireTimes(intervals, from, 3)
+	want := []time.Time{
+		time.Date(2026, 1, 2, 11, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 3, 11, 0, 0, 0, time.UTC),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d times, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("time %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -1,10 +1,15 @@
 package agent
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/lu-zhengda/lanchr/internal/platform"
+	"github.com/lu-zhengda/lanchr/internal/plist"
 )
 
 // Severity indicates how serious a finding is.
@@ -70,6 +75,7 @@ func (d *Doctor) Check() ([]Finding, error) {
 
 	var findings []Finding
 
+	findings = append(findings, d.checkInvalidPlists(services)...)
 	findings = append(findings, d.checkMissingBinaries(services)...)
 	findings = append(findings, d.checkPermissions(services)...)
 	findings = append(findings, d.checkDuplicateLabels(services)...)
@@ -77,6 +83,8 @@ func (d *Doctor) Check() ([]Finding, error) {
 	findings = append(findings, d.checkCrashedServices(services)...)
 	findings = append(findings, d.checkStaleLogPaths(services)...)
 	findings = append(findings, d.checkMissingLabels(services)...)
+	findings = append(findings, d.checkPlistLinks(services)...)
+	findings = append(findings, d.checkCalendarIntervals(services)...)
 
 	// Sort by severity (critical first).
 	sortFindings(findings)
@@ -84,7 +92,35 @@ func (d *Doctor) Check() ([]Finding, error) {
 	return findings, nil
 }
 
+// checkInvalidPlists reports plists that cannot be read or decoded, with
+// the line and column of the problem where the decoder gives them.
+func (d *Doctor) checkInvalidPlists(services []Service) []Finding {
+	var findings []Finding
+	for _, svc := range services {
+		if svc.Status != StatusInvalid || svc.ParseError == nil {
+			continue
+		}
+		message := fmt.Sprintf("plist cannot be read: %v", svc.ParseError)
+		var perr *plist.ParseError
+		if errors.As(svc.ParseError, &perr) {
+			message = fmt.Sprintf("plist cannot be decoded: %v", perr.Err)
+			if pos := perr.Position(); pos != "" {
+				message = fmt.Sprintf("plist cannot be decoded at %s: %v", pos, perr.Err)
+			}
+		}
+		findings = append(findings, Finding{
+			Severity:   SeverityCritical,
+			Label:      svc.Label,
+			PlistPath:  svc.PlistPath,
+			Message:    message,
+			Suggestion: fmt.Sprintf("Fix the plist, then check it with: plutil -lint %s", d.scanner.TargetPath(svc.PlistPath)),
+		})
+	}
+	return findings
+}
+
 // checkMissingBinaries reports services whose program path does not exist on disk.
+// Paths are resolved against the scanner's root when analyzing offline.
 func (d *Doctor) checkMissingBinaries(services []Service) []Finding {
 	var findings []Finding
 	for _, svc := range services {
@@ -92,7 +128,7 @@ func (d *Doctor) checkMissingBinaries(services []Service) []Finding {
 		if binary == "" {
 			continue
 		}
-		if _, err := os.Stat(binary); os.IsNotExist(err) {
+		if _, err := os.Stat(d.scanner.HostPath(binary)); os.IsNotExist(err) {
 			findings = append(findings, Finding{
 				Severity:   SeverityCritical,
 				Label:      svc.Label,
@@ -201,7 +237,7 @@ func (d *Doctor) checkStaleLogPaths(services []Service) []Finding {
 				continue
 			}
 			dir := filepath.Dir(logPath)
-			if _, err := os.Stat(dir); os.IsNotExist(err) {
+			if _, err := os.Stat(d.scanner.HostPath(dir)); os.IsNotExist(err) {
 				findings = append(findings, Finding{
 					Severity:   SeverityWarning,
 					Label:      svc.Label,
@@ -232,6 +268,92 @@ func (d *Doctor) checkMissingLabels(services []Service) []Finding {
 	return findings
 }
 
+// checkPlistLinks reports symlinked plists whose target is missing, or lies
+// outside the tree that owns the link: the user's home for user agents, the
+// app bundle for SMAppService plists, and /Library or /System otherwise.
+// A daemon linked to a file outside those trees can be rewritten by whoever
+// owns the target.
+func (d *Doctor) checkPlistLinks(services []Service) []Finding {
+	var findings []Finding
+	for _, svc := range services {
+		if svc.PlistTarget == "" {
+			continue
+		}
+		if svc.PlistDangling {
+			findings = append(findings, Finding{
+				Severity:   SeverityCritical,
+				Label:      svc.Label,
+				PlistPath:  svc.PlistPath,
+				Message:    fmt.Sprintf("plist is a dangling symlink to %s", svc.PlistTarget),
+				Suggestion: fmt.Sprintf("Remove the link or restore its target: rm %s", svc.PlistPath),
+			})
+			continue
+		}
+		trees := d.linkTrees(svc)
+		if !underAny(svc.PlistTarget, trees) {
+			findings = append(findings, Finding{
+				Severity:   SeverityWarning,
+				Label:      svc.Label,
+				PlistPath:  svc.PlistPath,
+				Message:    fmt.Sprintf("plist is a symlink to %s, outside %s", svc.PlistTarget, strings.Join(trees, " and ")),
+				Suggestion: "Copy the plist into place instead of linking it, or verify who can modify the target",
+			})
+		}
+	}
+	return findings
+}
+
+// linkTrees returns the directories a symlinked plist may point into.
+func (d *Doctor) linkTrees(svc Service) []string {
+	switch {
+	case svc.Origin == platform.OriginAppBundle:
+		return []string{svc.AppBundle}
+	case svc.Domain == platform.DomainUser:
+		// <home>/Library/LaunchAgents/<name>.plist
+		home := filepath.Dir(filepath.Dir(filepath.Dir(svc.PlistPath)))
+		return []string{d.scanner.TargetPath(home)}
+	default:
+		return []string{"/Library", "/System"}
+	}
+}
+
+// underAny reports whether path is one of dirs or inside one of them.
+func underAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCalendarIntervals reports StartCalendarInterval entries with fields
+// out of range, or that can never fire.
+func (d *Doctor) checkCalendarIntervals(services []Service) []Finding {
+	var findings []Finding
+	for _, svc := range services {
+		for _, ci := range svc.CalendarInterval {
+			message := ""
+			if err := ci.Validate(); err != nil {
+				message = fmt.Sprintf("invalid StartCalendarInterval {%s}: %s", ci, strings.ReplaceAll(err.Error(), "\n", "; "))
+			} else if _, ok := ci.Next(time.Now()); !ok {
+				message = fmt.Sprintf("StartCalendarInterval {%s} never fires", ci)
+			}
+			if message == "" {
+				continue
+			}
+			findings = append(findings, Finding{
+				Severity:   SeverityWarning,
+				Label:      svc.Label,
+				PlistPath:  svc.PlistPath,
+				Message:    message,
+				Suggestion: "Fix the schedule: Minute 0-59, Hour 0-23, Day 1-31, Weekday 0-7 (0 and 7 are Sunday), Month 1-12",
+			})
+		}
+	}
+	return findings
+}
+
 // sortFindings sorts findings by severity (critical first, then warning, then ok).
 func sortFindings(findings []Finding) {
 	for i := 1; i < len(findings); i++ {
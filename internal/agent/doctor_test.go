@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+func TestInvalidPlistsAreReported(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "Users", "me", "Library", "LaunchAgents")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"com.example.good.plist": `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>com.example.good</string>
+	<key>Program</key>
+	<string>/usr/bin/true</string>
+</dict>
+</plist>
+`,
+		"com.example.bad.plist": `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>com.example.bad</strin>
+</dict>
+</plist>
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scanner := NewOfflineScanner(plist.NewParser(), root)
+	svc, err := scanner.FindByLabel("com.example.bad")
+	if err != nil {
+		t.Fatalf("FindByLabel() error = %v", err)
+	}
+	if svc.Status != StatusInvalid || svc.ParseError == nil {
+		t.Errorf("Status = %v, ParseError = %v; want invalid with an error", svc.Status, svc.ParseError)
+	}
+	if good, err := scanner.FindByLabel("com.example.good"); err != nil || good.Status != StatusUnknown {
+		t.Errorf("good service = %+v, %v", good, err)
+	}
+
+	findings, err := NewDoctor(scanner).Check()
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	var found *Finding
+	for i := range findings {
+		if findings[i].Label == "com.example.bad" && strings.HasPrefix(findings[i].Message, "plist cannot be decoded") {
+			found = &findings[i]
+		}
+	}
+	if found == nil {
+		t.Fatalf("no finding for the invalid plist in %+v", findings)
+	}
+	if found.Severity != SeverityCritical || !strings.Contains(found.Message, "at line 5, column 33") {
+		t.Errorf("finding = %+v", found)
+	}
+	if !strings.Contains(found.Suggestion, "/Users/me/Library/LaunchAgents/com.example.bad.plist") {
+		t.Errorf("suggestion = %q, want the path on the analyzed system", found.Suggestion)
+	}
+}
+
+func TestDoctorReportsPlistLinks(t *testing.T) {
+	root := linkedPlistTree(t)
+	findings, err := NewDoctor(NewOfflineScanner(plist.NewParser(), root)).Check()
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	got := make(map[string]Finding)
+	for _, f := range findings {
+		if strings.Contains(f.Message, "symlink") {
+			got[f.Label] = f
+		}
+	}
+
+	tests := []struct {
+		label    string
+		severity Severity
+		message  string
+	}{
+		{"com.me.shared", SeverityWarning, "plist is a symlink to /Library/Shared/com.me.shared.plist, outside /Users/me"},
+		{"com.me.escape", SeverityCritical, "plist is a dangling symlink to /"},
+		{"com.me.hostabs", SeverityCritical, "plist is a dangling symlink to /"},
+		{"com.me.dangling", SeverityCritical, "plist is a dangling symlink to /Users/me/Library/LaunchAgents/gone.plist"},
+		{"com.me.loop", SeverityCritical, "plist is a dangling symlink"},
+	}
+	for _, tt := range tests {
+		f, ok := got[tt.label]
+		if !ok {
+			t.Errorf("no symlink finding for %s", tt.label)
+			continue
+		}
+		if f.Severity != tt.severity || !strings.HasPrefix(f.Message, tt.message) {
+			t.Errorf("%s: got %s %q, want %s %q", tt.label, f.Severity, f.Message, tt.severity, tt.message)
+		}
+		if strings.Contains(f.Message, root) {
+			t.Errorf("%s: message %q names a host path", tt.label, f.Message)
+		}
+	}
+	// Links within the user's home are fine.
+	for _, label := range []string{"com.me.absolute", "com.me.relative"} {
+		if f, ok := got[label]; ok {
+			t.Errorf("unexpected finding for %s: %+v", label, f)
+		}
+	}
+}
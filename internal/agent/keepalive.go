@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseKeepAlive decodes a raw KeepAlive value into the form stored in
+// Service.KeepAlive: nil when unset, a bool, or KeepAliveConditions.
+// Conditions that cannot be decoded are skipped and reported in the error.
+func ParseKeepAlive(raw interface{}) (interface{}, error) {
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		return v, nil
+	case map[string]interface{}:
+		return decodeKeepAliveConditions(v)
+	default:
+		return nil, fmt.Errorf("KeepAlive must be a boolean or a dictionary, got %T", raw)
+	}
+}
+
+// decodeKeepAliveConditions decodes the KeepAlive dictionary form.
+func decodeKeepAliveConditions(dict map[string]interface{}) (KeepAliveConditions, error) {
+	var kc KeepAliveConditions
+	var errs []error
+	for key, value := range dict {
+		var err error
+		switch key {
+		case "SuccessfulExit":
+			kc.SuccessfulExit, err = boolPtr(key, value)
+		case "Crashed":
+			kc.Crashed, err = boolPtr(key, value)
+		case "NetworkState":
+			kc.NetworkState, err = boolPtr(key, value)
+		case "PathState":
+			kc.PathState, err = boolMap(key, value)
+		case "OtherJobEnabled":
+			kc.OtherJobEnabled, err = boolMap(key, value)
+		case "OtherJobActive":
+			kc.OtherJobActive, err = boolMap(key, value)
+		case "AfterInitialDemand":
+			kc.AfterInitialDemand, err = boolPtr(key, value)
+		default:
+			err = fmt.Errorf("KeepAlive: unknown condition %q", key)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return kc, errors.Join(errs...)
+}
+
+func boolPtr(key string, value interface{}) (*bool, error) {
+	b, ok := value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("KeepAlive: %s must be a boolean, got %T", key, value)
+	}
+	return &b, nil
+}
+
+func boolMap(key string, value interface{}) (map[string]bool, error) {
+	dict, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("KeepAlive: %s must be a dictionary of booleans, got %T", key, value)
+	}
+	m := make(map[string]bool, len(dict))
+	for k, v := range dict {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("KeepAlive: %s[%q] must be a boolean, got %T", key, k, v)
+		}
+		m[k] = b
+	}
+	return m, nil
+}
+
+// Summary describes the conditions in words, for example
+// "restart if crashed; run while /tmp/flag exists".
+func (kc KeepAliveConditions) Summary() string {
+	var parts []string
+	if kc.SuccessfulExit != nil {
+		if *kc.SuccessfulExit {
+			parts = append(parts, "restart after a successful exit")
+		} else {
+			parts = append(parts, "restart after a failed exit")
+		}
+	}
+	if kc.Crashed != nil {
+		if *kc.Crashed {
+			parts = append(parts, "restart if crashed")
+		} else {
+			parts = append(parts, "restart unless crashed")
+		}
+	}
+	if kc.NetworkState != nil {
+		if *kc.NetworkState {
+			parts = append(parts, "run while the network is up")
+		} else {
+			parts = append(parts, "run while the network is down")
+		}
+	}
+	for _, path := range sortedKeys(kc.PathState) {
+		if kc.PathState[path] {
+			parts = append(parts, fmt.Sprintf("run while %s exists", path))
+		} else {
+			parts = append(parts, fmt.Sprintf("run while %s does not exist", path))
+		}
+	}
+	for _, label := range sortedKeys(kc.OtherJobEnabled) {
+		if kc.OtherJobEnabled[label] {
+			parts = append(parts, fmt.Sprintf("run while job %s enabled", label))
+		} else {
+			parts = append(parts, fmt.Sprintf("run while job %s disabled", label))
+		}
+	}
+	for _, label := range sortedKeys(kc.OtherJobActive) {
+		if kc.OtherJobActive[label] {
+			parts = append(parts, fmt.Sprintf("run while job %s is running", label))
+		} else {
+			parts = append(parts, fmt.Sprintf("run while job %s is not running", label))
+		}
+	}
+	if kc.AfterInitialDemand != nil {
+		if *kc.AfterInitialDemand {
+			parts = append(parts, "keep alive only after the first demand")
+		} else {
+			parts = append(parts, "keep alive before the first demand too")
+		}
+	}
+	if len(parts) == 0 {
+		return "no conditions"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// KeepAliveSummary describes the service's KeepAlive setting in words, or
+// returns "" if it is unset.
+func (s *Service) KeepAliveSummary() string {
+	switch v := s.KeepAlive.(type) {
+	case bool:
+		if v {
+			return "always"
+		}
+		return "never"
+	case KeepAliveConditions:
+		return v.Summary()
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,72 @@
+package agent
+
+import "testing"
+
+func TestParseKeepAlive(t *testing.T) {
+	got, err := ParseKeepAlive(map[string]interface{}{
+		"Crashed":            true,
+		"NetworkState":       true,
+		"PathState":          map[string]interface{}{"/tmp/flag": true, "/tmp/stop": false},
+		"OtherJobEnabled":    map[string]interface{}{"com.example.db": true},
+		"OtherJobActive":     map[string]interface{}{"com.example.api": false},
+		"AfterInitialDemand": true,
+	})
+	if err != nil {
+		t.Fatalf("ParseKeepAlive() error = %v", err)
+	}
+	kc, ok := got.(KeepAliveConditions)
+	if !ok {
+		t.Fatalf("got %T, want KeepAliveConditions", got)
+	}
+
+	want := "restart if crashed; run while the network is up; run while /tmp/flag exists; " +
+		"run while /tmp/stop does not exist; run while job com.example.db enabled; " +
+		"run while job com.example.api is not running; keep alive only after the first demand"
+	if s := kc.Summary(); s != want {
+		t.Errorf("Summary() =\n  %q\nwant\n  %q", s, want)
+	}
+}
+
+func TestParseKeepAlive_Invalid(t *testing.T) {
+	got, err := ParseKeepAlive(map[string]interface{}{
+		"SuccessfulExit": false,
+		"Crashed":        "yes",
+		"Sometimes":      true,
+	})
+	if err == nil {
+		t.Fatal("expected error for invalid conditions, got nil")
+	}
+	// Valid conditions are still decoded.
+	if s := got.(KeepAliveConditions).Summary(); s != "restart after a failed exit" {
+		t.Errorf("Summary() = %q", s)
+	}
+
+	// AfterInitialDemand is a boolean, not a dictionary of jobs.
+	if _, err := ParseKeepAlive(map[string]interface{}{
+		"AfterInitialDemand": map[string]interface{}{"com.example.api": true},
+	}); err == nil {
+		t.Error("expected error for a dictionary AfterInitialDemand, got nil")
+	}
+
+	if _, err := ParseKeepAlive("true"); err == nil {
+		t.Error("expected error for string KeepAlive, got nil")
+	}
+}
+
+func TestServiceKeepAliveSummary(t *testing.T) {
+	tests := []struct {
+		keepAlive interface{}
+		want      string
+	}{
+		{nil, ""},
+		{true, "always"},
+		{false, "never"},
+		{KeepAliveConditions{}, "no conditions"},
+	}
+	for _, tt := range tests {
+		svc := Service{KeepAlive: tt.keepAlive}
+		if got := svc.KeepAliveSummary(); got != tt.want {
+			t.Errorf("KeepAliveSummary(%v) = %q, want %q", tt.keepAlive, got, tt.want)
+		}
+	}
+}
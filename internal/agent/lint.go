@@ -0,0 +1,339 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lu-zhengda/lanchr/internal/platform"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// LintRule is one plist check. IDs are stable so that they can be
+// referenced from scripts and suppressed by tooling.
+type LintRule struct {
+	ID          string
+	Severity    Severity
+	Description string
+	check       func(doc *plist.Document, t platform.ServiceType) []LintIssue
+}
+
+// LintIssue is a problem a rule found in a plist. Key is the key path the
+// issue refers to, if any.
+type LintIssue struct {
+	Rule     string
+	Severity Severity
+	Key      string
+	Message  string
+}
+
+// LintRules lists every lint rule in the order they run. Critical issues
+// make the plist invalid or the job misbehave; warnings are suspicious but
+// may be intended.
+var LintRules = []LintRule{
+	{
+		ID:          "label-missing",
+		Severity:    SeverityCritical,
+		Description: "Label is required",
+		check:       lintLabelMissing,
+	},
+	{
+		ID:          "program-missing",
+		Severity:    SeverityCritical,
+		Description: "Program, ProgramArguments or BundleProgram is required",
+		check:       lintProgramMissing,
+	},
+	{
+		ID:          "wrong-type",
+		Severity:    SeverityCritical,
+		Description: "a key has a value of the wrong type",
+		check:       lintWrongType,
+	},
+	{
+		ID:          "program-mismatch",
+		Severity:    SeverityWarning,
+		Description: "Program differs from ProgramArguments[0]",
+		check:       lintProgramMismatch,
+	},
+	{
+		ID:          "unsplit-arguments",
+		Severity:    SeverityCritical,
+		Description: "ProgramArguments is a single element that looks like a whole command line",
+		check:       lintUnsplitArguments,
+	},
+	{
+		ID:          "unexpanded-path",
+		Severity:    SeverityCritical,
+		Description: "a path uses ~ or $VAR, which launchd does not expand",
+		check:       lintUnexpandedPaths,
+	},
+	{
+		ID:          "calendar-range",
+		Severity:    SeverityCritical,
+		Description: "a StartCalendarInterval field is out of range",
+		check:       lintCalendarRange,
+	},
+	{
+		ID:          "agent-username",
+		Severity:    SeverityWarning,
+		Description: "UserName is set in a LaunchAgent, where launchd ignores it",
+		check:       lintAgentUserName,
+	},
+	{
+		ID:          "label-format",
+		Severity:    SeverityWarning,
+		Description: "Label is not in reverse-DNS form",
+		check:       lintLabelFormat,
+	},
+	{
+		ID:          "interval-keepalive",
+		Severity:    SeverityWarning,
+		Description: "StartInterval is combined with KeepAlive=true",
+		check:       lintIntervalKeepAlive,
+	},
+}
+
+// Lint runs every rule against doc. t says whether the plist is loaded as
+// an agent or a daemon.
+func Lint(doc *plist.Document, t platform.ServiceType) []LintIssue {
+	var issues []LintIssue
+	for _, rule := range LintRules {
+		for _, issue := range rule.check(doc, t) {
+			issue.Rule = rule.ID
+			issue.Severity = rule.Severity
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// CountLintIssues returns the number of critical and warning issues.
+func CountLintIssues(issues []LintIssue) (errors, warnings int) {
+	for _, issue := range issues {
+		if issue.Severity == SeverityCritical {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	return errors, warnings
+}
+
+// lintString returns the string at key, if there is one.
+func lintString(doc *plist.Document, key ...string) (string, bool) {
+	v, _ := doc.Lookup(plist.KeyPath(key))
+	s, ok := v.(string)
+	return s, ok
+}
+
+// lintArray returns the array at key, if there is one.
+func lintArray(doc *plist.Document, key string) []interface{} {
+	v, _ := doc.Lookup(plist.KeyPath{key})
+	arr, _ := v.([]interface{})
+	return arr
+}
+
+// lintArg0 returns ProgramArguments[0], if it is a string.
+func lintArg0(doc *plist.Document) (string, bool) {
+	return lintString(doc, "ProgramArguments", "0")
+}
+
+func lintLabelMissing(doc *plist.Document, _ platform.ServiceType) []LintIssue {
+	label, isString := lintString(doc, "Label")
+	if _, ok := doc.Root.Get("Label"); ok && (!isString || label != "") {
+		return nil // a non-string Label is reported by wrong-type
+	}
+	return []LintIssue{{Key: "Label", Message: "Label is required"}}
+}
+
+func lintProgramMissing(doc *plist.Document, _ platform.ServiceType) []LintIssue {
+	for _, key := range []string{"Program", "ProgramArguments", "BundleProgram"} {
+		if _, ok := doc.Root.Get(key); ok {
+			return nil
+		}
+	}
+	return []LintIssue{{Key: "Program", Message: "either Program or ProgramArguments is required"}}
+}
+
+func lintWrongType(doc *plist.Document, _ platform.ServiceType) []LintIssue {
+	var issues []LintIssue
+	for _, key := range doc.Root.Keys() {
+		v, _ := doc.Root.Get(key)
+		if err := plist.CheckValue(plist.KeyPath{key}, v); err != nil {
+			issues = append(issues, LintIssue{Key: key, Message: err.Error()})
+		}
+	}
+	return issues
+}
+
+func lintProgramMismatch(doc *plist.Document, _ platform.ServiceType) []LintIssue {
+	program, ok := lintString(doc, "Program")
+	if !ok {
+		return nil
+	}
+	arg0, ok := lintArg0(doc)
+	if !ok || arg0 == program {
+		return nil
+	}
+	return []LintIssue{{
+		Key:     "Program",
+		Message: fmt.Sprintf("Program %s differs from ProgramArguments[0] %s; launchd runs Program and passes ProgramArguments[0] only as argv[0]", program, arg0),
+	}}
+}
+
+func lintUnsplitArguments(doc *plist.Document, _ platform.ServiceType) []LintIssue {
+	arr := lintArray(doc, "ProgramArguments")
+	if len(arr) != 1 {
+		return nil
+	}
+	arg, ok := arr[0].(string)
+	if !ok || !strings.ContainsAny(arg, " \t") || !looksLikeCommandLine(arg) {
+		return nil
+	}
+	return []LintIssue{{
+		Key:     "ProgramArguments",
+		Message: fmt.Sprintf("ProgramArguments has a single element %q; launchd does not split on spaces, so each argument must be its own element", arg),
+	}}
+}
+
+// looksLikeCommandLine reports whether arg, which contains spaces, is a
+// command and its arguments rather than a path with spaces in it, such as
+// "/Applications/Google Chrome.app/Contents/MacOS/Google Chrome": it is not
+// a file itself, and either starts with one or has a flag in it.
+func looksLikeCommandLine(arg string) bool {
+	if _, err := os.Stat(arg); err == nil {
+		return false
+	}
+	fields := strings.Fields(arg)
+	if _, err := os.Stat(fields[0]); err == nil {
+		return true
+	}
+	for _, field := range fields[1:] {
+		if strings.HasPrefix(field, "-") {
+			return true
+		}
+	}
+	return false
+}
+
+// unexpandedVar matches a shell variable reference such as $HOME or ${HOME}.
+var unexpandedVar = regexp.MustCompile(`\$(\{[A-Za-z_][A-Za-z0-9_]*\}|[A-Za-z_][A-Za-z0-9_]*)`)
+
+// lintPathKeys are the keys holding a single path.
+var lintPathKeys = []string{"Program", "WorkingDirectory", "RootDirectory", "StandardInPath", "StandardOutPath", "StandardErrorPath"}
+
+func lintUnexpandedPaths(doc *plist.Document, _ platform.ServiceType) []LintIssue {
+	var issues []LintIssue
+	check := func(key, path string) {
+		var what string
+		switch {
+		case strings.HasPrefix(path, "~"):
+			what = "~"
+		case unexpandedVar.MatchString(path):
+			what = unexpandedVar.FindString(path)
+		default:
+			return
+		}
+		issues = append(issues, LintIssue{
+			Key:     key,
+			Message: fmt.Sprintf("%s %q uses %s, which launchd does not expand; use an absolute path", key, path, what),
+		})
+	}
+
+	for _, key := range lintPathKeys {
+		if path, ok := lintString(doc, key); ok {
+			check(key, path)
+		}
+	}
+	// EnableGlobbing expands ~ in ProgramArguments, and later arguments are
+	// often meant for a shell.
+	if globbing, _ := doc.Root.Get("EnableGlobbing"); globbing != true {
+		if arg0, ok := lintArg0(doc); ok {
+			check("ProgramArguments.0", arg0)
+		}
+	}
+	for _, key := range []string{"WatchPaths", "QueueDirectories"} {
+		for i, el := range lintArray(doc, key) {
+			if path, ok := el.(string); ok {
+				check(key+"."+strconv.Itoa(i), path)
+			}
+		}
+	}
+	return issues
+}
+
+func lintCalendarRange(doc *plist.Document, _ platform.ServiceType) []LintIssue {
+	raw, ok := doc.Root.Get("StartCalendarInterval")
+	if !ok || plist.CheckValue(plist.KeyPath{"StartCalendarInterval"}, raw) != nil {
+		return nil // a wrong top-level type is reported by wrong-type
+	}
+	intervals, err := ParseCalendarIntervals(plist.ToGeneric(raw))
+	var issues []LintIssue
+	if err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			issues = append(issues, LintIssue{Key: "StartCalendarInterval", Message: line})
+		}
+	}
+	for _, ci := range intervals {
+		if err := ci.Validate(); err != nil {
+			issues = append(issues, LintIssue{
+				Key:     "StartCalendarInterval",
+				Message: fmt.Sprintf("StartCalendarInterval {%s}: %s", ci, strings.ReplaceAll(err.Error(), "\n", "; ")),
+			})
+		}
+	}
+	return issues
+}
+
+func lintAgentUserName(doc *plist.Document, t platform.ServiceType) []LintIssue {
+	if t != platform.TypeAgent {
+		return nil
+	}
+	if _, ok := doc.Root.Get("UserName"); !ok {
+		return nil
+	}
+	return []LintIssue{{
+		Key:     "UserName",
+		Message: "UserName only applies to daemons; a LaunchAgent always runs as the logged-in user",
+	}}
+}
+
+// reverseDNSLabel matches labels such as com.example.agent.
+var reverseDNSLabel = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*(\.[A-Za-z0-9_-]+)+$`)
+
+func lintLabelFormat(doc *plist.Document, _ platform.ServiceType) []LintIssue {
+	label, ok := lintString(doc, "Label")
+	if !ok || label == "" || reverseDNSLabel.MatchString(label) {
+		return nil
+	}
+	return []LintIssue{{
+		Key:     "Label",
+		Message: fmt.Sprintf("Label %q is not in reverse-DNS form (for example com.example.%s)", label, label),
+	}}
+}
+
+func lintIntervalKeepAlive(doc *plist.Document, _ platform.ServiceType) []LintIssue {
+	keepAlive, _ := doc.Root.Get("KeepAlive")
+	if _, ok := doc.Root.Get("StartInterval"); !ok || keepAlive != true {
+		return nil
+	}
+	return []LintIssue{{
+		Key:     "StartInterval",
+		Message: "StartInterval has no effect with KeepAlive=true, which restarts the job as soon as it exits",
+	}}
+}
+
+// LintParseError is the rule ID reported for a plist that cannot be decoded
+// at all, in which case no other rule runs.
+const LintParseError = "parse-error"
+
+// LintFile reads the plist at path and lints it.
+func LintFile(path string, t platform.ServiceType) []LintIssue {
+	doc, err := plist.ReadDocument(path)
+	if err != nil {
+		return []LintIssue{{Rule: LintParseError, Severity: SeverityCritical, Message: err.Error()}}
+	}
+	return Lint(doc, t)
+}
@@ -0,0 +1,171 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lu-zhengda/lanchr/internal/platform"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// lintDoc builds a document from alternating keys and values.
+func lintDoc(kv ...interface{}) *plist.Document {
+	doc := plist.NewDocument()
+	for i := 0; i < len(kv); i += 2 {
+		doc.Root.Set(kv[i].(string), kv[i+1])
+	}
+	return doc
+}
+
+func calendarDict(kv ...interface{}) *plist.Dict {
+	d := plist.NewDict()
+	for i := 0; i < len(kv); i += 2 {
+		d.Set(kv[i].(string), kv[i+1])
+	}
+	return d
+}
+
+// lintRuleIDs returns the rule IDs of issues, in order.
+func lintRuleIDs(issues []LintIssue) []string {
+	var ids []string
+	for _, issue := range issues {
+		ids = append(ids, issue.Rule)
+	}
+	return ids
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  *plist.Document
+		typ  platform.ServiceType
+		want []string
+	}{
+		{
+			name: "clean agent",
+			doc: lintDoc("Label", "com.example.agent",
+				"ProgramArguments", []interface{}{"/usr/local/bin/agent", "--flag with space"},
+				"StartInterval", int64(300)),
+			typ: platform.TypeAgent,
+		},
+		{
+			name: "missing label and program",
+			doc:  lintDoc("RunAtLoad", true),
+			typ:  platform.TypeAgent,
+			want: []string{"label-missing", "program-missing"},
+		},
+		{
+			name: "wrong types",
+			doc: lintDoc("Label", "com.example.agent", "Program", "/bin/true",
+				"StartInterval", "600", "EnvironmentVariables", calendarDict("DEBUG", int64(1))),
+			typ:  platform.TypeAgent,
+			want: []string{"wrong-type", "wrong-type"},
+		},
+		{
+			name: "program mismatch",
+			doc: lintDoc("Label", "com.example.agent", "Program", "/usr/bin/python3",
+				"ProgramArguments", []interface{}{"/usr/bin/env", "python3"}),
+			typ:  platform.TypeAgent,
+			want: []string{"program-mismatch"},
+		},
+		{
+			name: "unsplit arguments",
+			doc: lintDoc("Label", "com.example.agent",
+				"ProgramArguments", []interface{}{"/usr/local/bin/sync --quiet"}),
+			typ:  platform.TypeAgent,
+			want: []string{"unsplit-arguments"},
+		},
+		{
+			name: "unsplit interpreter and script",
+			doc: lintDoc("Label", "com.example.agent",
+				"ProgramArguments", []interface{}{"/bin/sh /usr/local/libexec/sync.sh"}),
+			typ:  platform.TypeAgent,
+			want: []string{"unsplit-arguments"},
+		},
+		{
+			name: "path with spaces",
+			doc: lintDoc("Label", "com.example.agent",
+				"ProgramArguments", []interface{}{"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome"}),
+			typ: platform.TypeAgent,
+		},
+		{
+			name: "unexpanded paths",
+			doc: lintDoc("Label", "com.example.agent", "Program", "~/bin/agent",
+				"StandardErrorPath", "${TMPDIR}/agent.log", "WatchPaths", []interface{}{"/tmp/in", "$HOME/in"}),
+			typ:  platform.TypeAgent,
+			want: []string{"unexpanded-path", "unexpanded-path", "unexpanded-path"},
+		},
+		{
+			name: "globbing expands arguments",
+			doc: lintDoc("Label", "com.example.agent", "EnableGlobbing", true,
+				"ProgramArguments", []interface{}{"~/bin/agent"}),
+			typ: platform.TypeAgent,
+		},
+		{
+			name: "calendar out of range",
+			doc: lintDoc("Label", "com.example.agent", "Program", "/bin/true",
+				"StartCalendarInterval", []interface{}{calendarDict("Hour", int64(9)), calendarDict("Minute", int64(60))}),
+			typ:  platform.TypeAgent,
+			want: []string{"calendar-range"},
+		},
+		{
+			name: "UserName in agent",
+			doc:  lintDoc("Label", "com.example.agent", "Program", "/bin/true", "UserName", "root"),
+			typ:  platform.TypeAgent,
+			want: []string{"agent-username"},
+		},
+		{
+			name: "UserName in daemon",
+			doc:  lintDoc("Label", "com.example.daemon", "Program", "/bin/true", "UserName", "root"),
+			typ:  platform.TypeDaemon,
+		},
+		{
+			name: "label format",
+			doc:  lintDoc("Label", "my agent", "Program", "/bin/true"),
+			typ:  platform.TypeAgent,
+			want: []string{"label-format"},
+		},
+		{
+			name: "interval with keepalive",
+			doc: lintDoc("Label", "com.example.agent", "Program", "/bin/true",
+				"StartInterval", int64(60), "KeepAlive", true),
+			typ:  platform.TypeAgent,
+			want: []string{"interval-keepalive"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := Lint(tt.doc, tt.typ)
+			if got := lintRuleIDs(issues); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lint() rules = %v, want %v", got, tt.want)
+				for _, issue := range issues {
+					t.Logf("  %s: %s", issue.Rule, issue.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestLintFile_ParseError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.plist")
+	if err := os.WriteFile(path, []byte("<plist><dict><key>Label</key>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	issues := LintFile(path, platform.TypeAgent)
+	if len(issues) != 1 || issues[0].Rule != LintParseError || issues[0].Severity != SeverityCritical {
+		t.Errorf("LintFile() = %+v, want one critical %s issue", issues, LintParseError)
+	}
+}
+
+func TestLintRules_UniqueIDs(t *testing.T) {
+	seen := map[string]bool{LintParseError: true}
+	for _, rule := range LintRules {
+		if seen[rule.ID] {
+			t.Errorf("duplicate rule ID %q", rule.ID)
+		}
+		seen[rule.ID] = true
+	}
+}
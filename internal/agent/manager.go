@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/lu-zhengda/lanchr/internal/launchctl"
@@ -35,12 +36,9 @@ func (m *Manager) Enable(label string) error {
 		return fmt.Errorf("cannot enable %q: service is SIP-protected", label)
 	}
 
-	target := platform.ServiceTarget(svc.Domain, svc.Label)
+	target := svc.ServiceTarget()
 	if err := m.launchctl.Enable(target); err != nil {
-		if launchctl.IsPermissionDenied(err) {
-			return fmt.Errorf("failed to enable %q: operation requires sudo (system daemon): %w", label, err)
-		}
-		return fmt.Errorf("failed to enable %q: %w", label, err)
+		return launchctlError("enable", label, target, err)
 	}
 	return nil
 }
@@ -56,12 +54,9 @@ func (m *Manager) Disable(label string) error {
 		return fmt.Errorf("cannot disable %q: service is SIP-protected", label)
 	}
 
-	target := platform.ServiceTarget(svc.Domain, svc.Label)
+	target := svc.ServiceTarget()
 	if err := m.launchctl.Disable(target); err != nil {
-		if launchctl.IsPermissionDenied(err) {
-			return fmt.Errorf("failed to disable %q: operation requires sudo (system daemon): %w", label, err)
-		}
-		return fmt.Errorf("failed to disable %q: %w", label, err)
+		return launchctlError("disable", label, target, err)
 	}
 	return nil
 }
@@ -77,31 +72,28 @@ func (m *Manager) Restart(label string) error {
 		return fmt.Errorf("cannot restart %q: service is SIP-protected", label)
 	}
 
-	target := platform.ServiceTarget(svc.Domain, svc.Label)
+	target := svc.ServiceTarget()
 	if err := m.launchctl.Kickstart(target, true); err != nil {
-		if launchctl.IsPermissionDenied(err) {
-			return fmt.Errorf("failed to restart %q: operation requires sudo: %w", label, err)
-		}
-		return fmt.Errorf("failed to restart %q: %w", label, err)
+		return launchctlError("restart", label, target, err)
 	}
 	return nil
 }
 
-// Load bootstraps a plist into the appropriate domain.
+// Load bootstraps a plist into the appropriate domain, which its path on
+// the scanned system decides: daemons go to system, agents to the user's
+// GUI domain.
 func (m *Manager) Load(plistPath string) error {
 	pl, err := m.parser.Parse(plistPath)
 	if err != nil {
 		return fmt.Errorf("failed to parse plist %s: %w", plistPath, err)
 	}
 
-	domain := platform.DomainFromPath(plistPath)
-	domainTarget := platform.DomainTarget(domain)
+	target := m.scanner.TargetPath(plistPath)
+	domain := platform.DomainFromPath(target)
+	domainTarget := platform.LaunchdDomainTarget(domain, platform.TypeFromPath(target))
 
 	if err := m.launchctl.Bootstrap(domainTarget, plistPath); err != nil {
-		if launchctl.IsPermissionDenied(err) {
-			return fmt.Errorf("failed to load %q: operation requires sudo: %w", pl.Label, err)
-		}
-		return fmt.Errorf("failed to load %q: %w", pl.Label, err)
+		return launchctlError("load", pl.Label, domainTarget, err)
 	}
 	return nil
 }
@@ -117,12 +109,9 @@ func (m *Manager) Unload(label string) error {
 		return fmt.Errorf("cannot unload %q: service is SIP-protected", label)
 	}
 
-	target := platform.ServiceTarget(svc.Domain, svc.Label)
+	target := svc.ServiceTarget()
 	if err := m.launchctl.Bootout(target); err != nil {
-		if launchctl.IsPermissionDenied(err) {
-			return fmt.Errorf("failed to unload %q: operation requires sudo: %w", label, err)
-		}
-		return fmt.Errorf("failed to unload %q: %w", label, err)
+		return launchctlError("unload", label, target, err)
 	}
 	return nil
 }
@@ -136,14 +125,16 @@ func (m *Manager) Info(label string) (*Service, error) {
 	}
 
 	// Try to enrich with launchctl print data.
-	target := platform.ServiceTarget(svc.Domain, svc.Label)
+	target := svc.ServiceTarget()
 	info, err := m.launchctl.PrintService(target)
 	if err == nil && info != nil {
+		svc.Runtime = info
 		if info.PID > 0 {
 			svc.PID = info.PID
-			svc.Status = StatusRunning
 		}
-		if info.State == "running" {
+		// An invalid plist stays invalid, even if an older version of it is
+		// running.
+		if (info.PID > 0 || info.State == "running") && svc.Status != StatusInvalid {
 			svc.Status = StatusRunning
 		}
 		if info.Program != "" && svc.Program == "" {
@@ -159,3 +150,31 @@ func (m *Manager) Info(label string) (*Service, error) {
 
 	return svc, nil
 }
+
+// launchctlError turns a launchctl failure into an actionable message for
+// action ("enable", "load", ...) on label. target is the service or domain
+// target the command was run against.
+func launchctlError(action, label, target string, err error) error {
+	switch {
+	case errors.Is(err, launchctl.ErrSIPProtected):
+		return fmt.Errorf("cannot %s %q: service is protected by System Integrity Protection: %w", action, label, err)
+	case launchctl.IsPermissionDenied(err):
+		return fmt.Errorf("failed to %s %q: operation requires sudo (system daemon): %w", action, label, err)
+	case errors.Is(err, launchctl.ErrAlreadyLoaded):
+		return fmt.Errorf("failed to %s %q: service is already loaded in %s (run 'lanchr unload %s' first) or the plist is invalid (check with 'plutil -lint'): %w", action, label, target, label, err)
+	case errors.Is(err, launchctl.ErrServiceNotFound) && action == "unload":
+		return fmt.Errorf("failed to %s %q: service is not loaded in %s: %w", action, label, target, err)
+	case errors.Is(err, launchctl.ErrServiceNotFound):
+		return fmt.Errorf("failed to %s %q: service is not loaded in %s (bootstrap it with 'lanchr load <plist>'): %w", action, label, target, err)
+	case errors.Is(err, launchctl.ErrServiceDisabled):
+		return fmt.Errorf("failed to %s %q: service is disabled (run 'lanchr enable %s' first): %w", action, label, label, err)
+	case errors.Is(err, launchctl.ErrInProgress):
+		return fmt.Errorf("failed to %s %q: launchd is still processing a previous request; wait a moment and retry: %w", action, label, err)
+	case errors.Is(err, launchctl.ErrDomainNotFound):
+		return fmt.Errorf("failed to %s %q: launchd domain %s does not exist (is the user logged in?): %w", action, label, target, err)
+	case errors.Is(err, launchctl.ErrUnavailable):
+		return fmt.Errorf("cannot %s %q: launchd is not available when analyzing a tree offline (--root): %w", action, label, err)
+	default:
+		return fmt.Errorf("failed to %s %q: %w", action, label, err)
+	}
+}
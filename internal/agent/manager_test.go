@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lu-zhengda/lanchr/internal/launchctl"
+	"github.com/lu-zhengda/lanchr/internal/platform"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// newSimulatedManager returns a manager and scanner for uid 501, whose
+// LaunchAgents directory is returned, driving a simulated launchd.
+func newSimulatedManager(t *testing.T) (*Manager, *Scanner, string) {
+	t.Helper()
+	home := t.TempDir()
+	liveHome, _ := os.UserHomeDir()
+	platform.SetUser(501, home)
+	t.Cleanup(func() { platform.SetUser(os.Getuid(), liveHome) })
+
+	sim := launchctl.NewSimulator()
+	parser := plist.NewParser()
+	scanner := NewScanner(parser, sim)
+	return NewManager(sim, scanner, parser), scanner, filepath.Join(home, "Library", "LaunchAgents")
+}
+
+// writeAgent writes pl to dir, creating dir if needed, and returns its
+// path.
+func writeAgent(t *testing.T, dir string, pl *plist.LaunchAgentPlist) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, pl.Label+".plist")
+	if err := plist.NewWriter().WriteWithoutValidation(pl, path); err != nil {
+		t.Fatalf("failed to write plist: %v", err)
+	}
+	return path
+}
+
+func findService(t *testing.T, scanner *Scanner, label string) *Service {
+	t.Helper()
+	svc, err := scanner.FindByLabel(label)
+	if err != nil {
+		t.Fatalf("FindByLabel(%q) error = %v", label, err)
+	}
+	return svc
+}
+
+func TestManagerLifecycle(t *testing.T) {
+	manager, scanner, dir := newSimulatedManager(t)
+	path := writeAgent(t, dir, &plist.LaunchAgentPlist{Label: "com.example.managed", Program: "/usr/bin/true", RunAtLoad: true})
+
+	if svc := findService(t, scanner, "com.example.managed"); svc.Status != StatusStopped {
+		t.Errorf("got status %s before load, want stopped", svc.Status)
+	}
+
+	// Load bootstraps into the user's GUI domain and RunAtLoad starts it.
+	if err := manager.Load(path); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	svc := findService(t, scanner, "com.example.managed")
+	if svc.Status != StatusRunning || svc.LoadedDomain != "gui/501" {
+		t.Errorf("got %s in %q after load, want running in gui/501", svc.Status, svc.LoadedDomain)
+	}
+	firstPID := svc.PID
+	if err := manager.Load(path); err == nil || !strings.Contains(err.Error(), "already loaded") {
+		t.Errorf("second Load() error = %v, want already loaded", err)
+	}
+
+	// Restart gives it a new PID.
+	if err := manager.Restart("com.example.managed"); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	if svc := findService(t, scanner, "com.example.managed"); svc.PID == firstPID || svc.Status != StatusRunning {
+		t.Errorf("got %s pid %d after restart, want running with a pid other than %d", svc.Status, svc.PID, firstPID)
+	}
+
+	// A disabled service is reported as such and cannot be restarted.
+	if err := manager.Disable("com.example.managed"); err != nil {
+		t.Fatalf("Disable() error = %v", err)
+	}
+	if svc := findService(t, scanner, "com.example.managed"); !svc.Disabled {
+		t.Error("expected the service to be disabled")
+	}
+	if err := manager.Restart("com.example.managed"); err == nil || !strings.Contains(err.Error(), "lanchr enable") {
+		t.Errorf("Restart() of a disabled service error = %v, want a hint to enable it", err)
+	}
+	if err := manager.Enable("com.example.managed"); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	if svc := findService(t, scanner, "com.example.managed"); svc.Disabled {
+		t.Error("expected the service to be enabled")
+	}
+
+	// Unload stops it; unloading again reports that it is not loaded.
+	if err := manager.Unload("com.example.managed"); err != nil {
+		t.Fatalf("Unload() error = %v", err)
+	}
+	if svc := findService(t, scanner, "com.example.managed"); svc.Status != StatusStopped || svc.LoadedDomain != "" {
+		t.Errorf("got %s in %q after unload, want stopped and not loaded", svc.Status, svc.LoadedDomain)
+	}
+	if err := manager.Unload("com.example.managed"); err == nil || !strings.Contains(err.Error(), "not loaded") {
+		t.Errorf("second Unload() error = %v, want not loaded", err)
+	}
+}
+
+func TestManagerDisabledServiceIsNotLoaded(t *testing.T) {
+	manager, scanner, dir := newSimulatedManager(t)
+	path := writeAgent(t, dir, &plist.LaunchAgentPlist{Label: "com.example.off", Program: "/usr/bin/true"})
+
+	if err := manager.Disable("com.example.off"); err != nil {
+		t.Fatalf("Disable() error = %v", err)
+	}
+	if svc := findService(t, scanner, "com.example.off"); svc.Status != StatusDisabled {
+		t.Errorf("got status %s, want disabled", svc.Status)
+	}
+	if err := manager.Load(path); err == nil {
+		t.Error("expected Load() of a disabled service to fail, got nil")
+	}
+}
+
+func TestManagerInfo(t *testing.T) {
+	manager, _, dir := newSimulatedManager(t)
+	path := writeAgent(t, dir, &plist.LaunchAgentPlist{Label: "com.example.info", Program: "/usr/bin/true", RunAtLoad: true})
+	if err := manager.Load(path); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	svc, err := manager.Info("com.example.info")
+	if err != nil {
+		t.Fatalf("Info() error = %v", err)
+	}
+	if svc.Runtime == nil || svc.Runtime.State != "running" || svc.Runtime.Path != path {
+		t.Errorf("got runtime %+v, want running from %s", svc.Runtime, path)
+	}
+	if svc.BlameLine != "speculative" {
+		t.Errorf("got blame %q, want %q", svc.BlameLine, "speculative")
+	}
+
+	if _, err := manager.Info("com.example.missing"); err == nil {
+		t.Error("expected error for an unknown service, got nil")
+	}
+}
+
+func TestManagerLoadDomain(t *testing.T) {
+	tests := []struct {
+		dir    string
+		target string
+	}{
+		{"Library/LaunchDaemons", "system"},
+		{"Library/LaunchAgents", "gui/501"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			home := t.TempDir()
+			liveHome, _ := os.UserHomeDir()
+			platform.SetUser(501, home)
+			t.Cleanup(func() { platform.SetUser(os.Getuid(), liveHome) })
+
+			// The plist is classified by where it is on the system, here
+			// a tree standing in for /.
+			root := t.TempDir()
+			path := writeAgent(t, filepath.Join(root, tt.dir), &plist.LaunchAgentPlist{Label: "com.example.job", Program: "/usr/bin/true"})
+			sim := launchctl.NewSimulator()
+			parser := plist.NewParser()
+			manager := NewManager(sim, NewOfflineScanner(parser, root), parser)
+			if err := manager.Load(path); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if _, err := sim.PrintService(tt.target + "/com.example.job"); err != nil {
+				t.Errorf("the job is not loaded in %s: %v", tt.target, err)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -18,6 +19,8 @@ import (
 type Scanner struct {
 	parser    *plist.Parser
 	launchctl launchctl.Executor
+	root      string // non-empty when analyzing a macOS tree offline
+	overlay   string // non-empty when simulated files shadow the system's
 }
 
 // NewScanner creates a new service scanner.
@@ -28,32 +31,178 @@ func NewScanner(parser *plist.Parser, executor launchctl.Executor) *Scanner {
 	}
 }
 
+// NewOfflineScanner creates a scanner for a macOS tree mounted or extracted
+// at root. Only plist data is available; runtime state is reported as
+// StatusUnknown.
+func NewOfflineScanner(parser *plist.Parser, root string) *Scanner {
+	return &Scanner{
+		parser:    parser,
+		launchctl: launchctl.NewOfflineExecutor(),
+		root:      root,
+	}
+}
+
+// NewSimulatedScanner creates a scanner for a simulation whose files are
+// written to the scratch tree at overlay instead of the running system. A
+// plist in overlay shadows the one at the same path on the system.
+func NewSimulatedScanner(parser *plist.Parser, executor launchctl.Executor, overlay string) *Scanner {
+	return &Scanner{
+		parser:    parser,
+		launchctl: executor,
+		overlay:   overlay,
+	}
+}
+
+// Root returns the root of the analyzed tree, or "" for the running system.
+func (s *Scanner) Root() string {
+	return s.root
+}
+
+// HostPath maps an absolute path as seen by the analyzed system (such as a
+// plist's Program) to the path it can be read at on this machine.
+func (s *Scanner) HostPath(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	if s.overlay != "" {
+		// Simulated files, such as imported assets, shadow the system's.
+		if _, err := os.Lstat(filepath.Join(s.overlay, path)); err == nil {
+			return filepath.Join(s.overlay, path)
+		}
+	}
+	if s.root == "" {
+		return path
+	}
+	return filepath.Join(s.root, path)
+}
+
+// TargetPath is the inverse of HostPath: it maps a path under the analyzed
+// root back to the path the analyzed system would see.
+func (s *Scanner) TargetPath(path string) string {
+	base := s.root
+	if base == "" {
+		base = s.overlay
+	}
+	if base == "" || path == "" {
+		return path
+	}
+	rel, err := filepath.Rel(base, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// plistDirectories returns the directories to scan for plists.
+func (s *Scanner) plistDirectories() []platform.PlistDir {
+	if s.root != "" {
+		return platform.PlistDirectoriesUnder(s.root)
+	}
+	return platform.PlistDirectories()
+}
+
 // plistResult holds the result of parsing a single plist file.
 type plistResult struct {
-	pl   *plist.LaunchAgentPlist
-	path string
-	dir  platform.PlistDir
-	err  error
+	pl       *plist.LaunchAgentPlist
+	path     string
+	dir      platform.PlistDir
+	target   string // resolved target if path is a symlink
+	dangling bool   // path is a symlink whose target does not exist
+	err      error
+}
+
+// maxLinkHops bounds symlink resolution, like the kernel's MAXSYMLINKS.
+const maxLinkHops = 32
+
+// resolveLink follows path while it is a symlink. It reports whether the
+// final target exists.
+func (s *Scanner) resolveLink(path string) (string, bool) {
+	if s.root != "" {
+		return s.resolveUnderRoot(s.TargetPath(path))
+	}
+	target := path
+	for i := 0; i < maxLinkHops; i++ {
+		info, err := os.Lstat(target)
+		if err != nil {
+			return target, false
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return target, true
+		}
+		link, err := os.Readlink(target)
+		if err != nil {
+			return target, false
+		}
+		if filepath.IsAbs(link) {
+			target = link
+		} else {
+			target = filepath.Join(filepath.Dir(target), link)
+		}
+	}
+	return target, false
+}
+
+// resolveUnderRoot resolves path, as seen by the analyzed system, the way
+// that system would: one component at a time, following links in the
+// directories along the way too, with absolute link targets starting over
+// at the root and ".." stopping at it. Offline analysis thus never escapes
+// into the host filesystem. It returns the path on the host, and whether
+// it exists.
+func (s *Scanner) resolveUnderRoot(path string) (string, bool) {
+	rest := strings.Split(path, "/")
+	resolved := "/"
+	hops := 0
+	for len(rest) > 0 {
+		name := rest[0]
+		rest = rest[1:]
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, name)
+		info, err := os.Lstat(s.HostPath(next))
+		if err != nil {
+			return s.HostPath(filepath.Join(append([]string{next}, rest...)...)), false
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		link, err := os.Readlink(s.HostPath(next))
+		hops++
+		if err != nil || hops > maxLinkHops {
+			return s.HostPath(filepath.Join(append([]string{next}, rest...)...)), false
+		}
+		if filepath.IsAbs(link) {
+			resolved = "/"
+		}
+		rest = append(strings.Split(link, "/"), rest...)
+	}
+	return s.HostPath(resolved), true
 }
 
 // ScanAll returns all services found across all plist directories,
 // enriched with live runtime state from launchctl.
 func (s *Scanner) ScanAll() ([]Service, error) {
-	dirs := platform.PlistDirectories()
+	dirs := s.plistDirectories()
 
 	// Step 1: Discover and parse all plist files in parallel.
 	plistResults := s.scanPlistDirs(dirs)
 
-	// Step 2: Get live state from launchctl list.
-	listEntries, err := s.launchctl.List()
-	if err != nil {
+	// Step 2: Get live state from the services table of each launchd domain.
+	// Offline, there is no runtime state: every service is StatusUnknown.
+	domains, err := s.scanDomains()
+	offline := errors.Is(err, launchctl.ErrUnavailable)
+	if err != nil && !offline {
 		return nil, fmt.Errorf("failed to list services: %w", err)
 	}
-
-	// Build a lookup map from label to list entry.
-	entryMap := make(map[string]launchctl.ListEntry, len(listEntries))
-	for _, entry := range listEntries {
-		entryMap[entry.Label] = entry
+	initialStatus := StatusStopped
+	if offline {
+		initialStatus = StatusUnknown
 	}
 
 	// Step 3: Get disabled states.
@@ -79,6 +228,29 @@ func (s *Scanner) ScanAll() ([]Service, error) {
 
 	for _, result := range plistResults {
 		if result.err != nil {
+			// Keep plists that cannot be decoded, so that they are listed
+			// and reported rather than silently missing.
+			svc := Service{
+				Label:         strings.TrimSuffix(filepath.Base(result.path), ".plist"),
+				Domain:        result.dir.Domain,
+				Type:          result.dir.Type,
+				Status:        StatusInvalid,
+				PID:           -1,
+				PlistPath:     result.path,
+				Origin:        result.dir.Origin,
+				AppBundle:     s.TargetPath(result.dir.AppBundle),
+				PlistTarget:   result.target,
+				PlistDangling: result.dangling,
+				ParseError:    result.err,
+			}
+			expected := platform.LaunchdDomainTarget(result.dir.Domain, result.dir.Type)
+			if target, entry, ok := domains.lookup(svc.Label, expected); ok {
+				svc.LoadedDomain = target
+				svc.PID = entry.PID
+				svc.LastExitStatus = entry.Status
+			}
+			seenLabels[svc.Label] = true
+			services = append(services, svc)
 			continue
 		}
 
@@ -93,13 +265,13 @@ func (s *Scanner) ScanAll() ([]Service, error) {
 			Label:             label,
 			Domain:            result.dir.Domain,
 			Type:              result.dir.Type,
-			Status:            StatusStopped,
+			Status:            initialStatus,
 			PID:               -1,
 			PlistPath:         result.path,
 			Program:           pl.Program,
 			ProgramArgs:       pl.ProgramArguments,
+			BundleProgram:     pl.BundleProgram,
 			RunAtLoad:         pl.RunAtLoad,
-			KeepAlive:         pl.KeepAlive,
 			StartInterval:     pl.StartInterval,
 			WatchPaths:        pl.WatchPaths,
 			QueueDirectories:  pl.QueueDirectories,
@@ -116,10 +288,20 @@ func (s *Scanner) ScanAll() ([]Service, error) {
 			ProcessType:       pl.ProcessType,
 			MachServices:      pl.MachServices,
 			Sockets:           pl.Sockets,
+			Origin:            result.dir.Origin,
+			AppBundle:         s.TargetPath(result.dir.AppBundle),
+			PlistTarget:       result.target,
+			PlistDangling:     result.dangling,
 		}
 
-		// Correlate with launchctl list entry.
-		if entry, ok := entryMap[label]; ok {
+		// Undecodable entries are skipped here; lint reports them.
+		svc.CalendarInterval, _ = ParseCalendarIntervals(pl.StartCalendarInterval)
+		svc.KeepAlive, _ = ParseKeepAlive(pl.KeepAlive)
+
+		// Correlate with the domain the service is actually loaded in.
+		expected := platform.LaunchdDomainTarget(result.dir.Domain, result.dir.Type)
+		if target, entry, ok := domains.lookup(label, expected); ok {
+			svc.LoadedDomain = target
 			svc.PID = entry.PID
 			svc.LastExitStatus = entry.Status
 
@@ -142,35 +324,44 @@ func (s *Scanner) ScanAll() ([]Service, error) {
 		services = append(services, svc)
 	}
 
-	// Step 5: Add services found in launchctl list but with no plist on disk.
-	for _, entry := range listEntries {
-		if seenLabels[entry.Label] {
-			continue
-		}
+	// Step 5: Add services loaded in a domain but with no plist on disk.
+	for _, d := range domains {
+		for _, entry := range d.info.Services {
+			if seenLabels[entry.Label] {
+				continue
+			}
+			seenLabels[entry.Label] = true
+
+			svc := Service{
+				Label:          entry.Label,
+				Domain:         platform.DomainUser,
+				Type:           platform.TypeAgent,
+				PID:            entry.PID,
+				LastExitStatus: entry.Status,
+				Status:         StatusStopped,
+				LoadedDomain:   d.target,
+			}
 
-		svc := Service{
-			Label:          entry.Label,
-			Domain:         platform.DomainUser,
-			Type:           platform.TypeAgent,
-			PID:            entry.PID,
-			LastExitStatus: entry.Status,
-			Status:         StatusStopped,
-		}
+			if d.target == "system" {
+				svc.Domain = platform.DomainSystem
+				svc.Type = platform.TypeDaemon
+			}
 
-		if entry.PID > 0 {
-			svc.Status = StatusRunning
-		} else if entry.Status != 0 {
-			svc.Status = StatusError
-		}
+			if entry.PID > 0 {
+				svc.Status = StatusRunning
+			} else if entry.Status != 0 {
+				svc.Status = StatusError
+			}
 
-		if disabledMap[entry.Label] {
-			svc.Disabled = true
-			if svc.Status == StatusStopped {
-				svc.Status = StatusDisabled
+			if disabledMap[entry.Label] {
+				svc.Disabled = true
+				if svc.Status == StatusStopped {
+					svc.Status = StatusDisabled
+				}
 			}
-		}
 
-		services = append(services, svc)
+			services = append(services, svc)
+		}
 	}
 
 	return services, nil
@@ -207,29 +398,118 @@ func (s *Scanner) FindByLabel(label string) (*Service, error) {
 	return nil, fmt.Errorf("service %q not found", label)
 }
 
+// loadedDomain pairs a launchd domain target with its parsed services table.
+type loadedDomain struct {
+	target string
+	info   *launchctl.DomainInfo
+}
+
+// loadedDomains is the runtime view of every queried launchd domain, in
+// lookup order.
+type loadedDomains []loadedDomain
+
+// lookup finds label in the preferred domain first, then in the others.
+// It returns the domain target the service was found in.
+func (d loadedDomains) lookup(label, preferred string) (string, launchctl.ListEntry, bool) {
+	for _, ld := range d {
+		if ld.target != preferred {
+			continue
+		}
+		if entry, ok := ld.info.Lookup(label); ok {
+			return ld.target, entry, true
+		}
+	}
+	for _, ld := range d {
+		if ld.target == preferred {
+			continue
+		}
+		if entry, ok := ld.info.Lookup(label); ok {
+			return ld.target, entry, true
+		}
+	}
+	return "", launchctl.ListEntry{}, false
+}
+
+// scanDomains prints every launchd domain lanchr knows about. Each is
+// best-effort, since some are inaccessible without privileges and root
+// has no GUI domain of its own; it fails only if none could be printed,
+// with the first error.
+func (s *Scanner) scanDomains() (loadedDomains, error) {
+	var (
+		domains  loadedDomains
+		firstErr error
+	)
+	for _, target := range platform.LaunchdDomainTargets() {
+		info, err := s.launchctl.PrintDomain(target)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		domains = append(domains, loadedDomain{target: target, info: info})
+	}
+	if len(domains) == 0 {
+		return nil, firstErr
+	}
+	return domains, nil
+}
+
 // scanPlistDirs reads and parses all plist files from the given directories using
 // a worker pool bounded by the number of CPUs.
 func (s *Scanner) scanPlistDirs(dirs []platform.PlistDir) []plistResult {
 	type parseJob struct {
-		path string
-		dir  platform.PlistDir
+		path     string
+		dir      platform.PlistDir
+		resolved string // path to read, after following symlinks
+		target   string
+		dangling bool
 	}
 
 	// Collect all plist file paths.
 	var jobs []parseJob
 	for _, dir := range dirs {
-		entries, err := os.ReadDir(dir.Path)
-		if err != nil {
-			continue
+		// Offline, a linked directory is followed within the tree only.
+		listed := dir.Path
+		if s.root != "" {
+			var ok bool
+			if listed, ok = s.resolveUnderRoot(s.TargetPath(dir.Path)); !ok {
+				continue
+			}
+		}
+		// Simulated plists come first and shadow the system's.
+		sources := []string{listed}
+		if s.overlay != "" {
+			sources = []string{filepath.Join(s.overlay, dir.Path), listed}
 		}
-		for _, entry := range entries {
-			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".plist") {
+		shadowed := make(map[string]bool)
+		for _, source := range sources {
+			entries, err := os.ReadDir(source)
+			if err != nil {
 				continue
 			}
-			jobs = append(jobs, parseJob{
-				path: filepath.Join(dir.Path, entry.Name()),
-				dir:  dir,
-			})
+			for _, entry := range entries {
+				if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".plist") || shadowed[entry.Name()] {
+					continue
+				}
+				shadowed[entry.Name()] = true
+				path := filepath.Join(dir.Path, entry.Name())
+				if source != listed {
+					path = filepath.Join(source, entry.Name())
+				}
+				job := parseJob{
+					path:     path,
+					dir:      dir,
+					resolved: filepath.Join(source, entry.Name()),
+				}
+				if entry.Type()&os.ModeSymlink != 0 {
+					target, ok := s.resolveLink(path)
+					job.resolved = target
+					job.target = s.TargetPath(target)
+					job.dangling = !ok
+				}
+				jobs = append(jobs, job)
+			}
 		}
 	}
 
@@ -251,13 +531,19 @@ func (s *Scanner) scanPlistDirs(dirs []platform.PlistDir) []plistResult {
 		go func() {
 			defer wg.Done()
 			for job := range jobCh {
-				pl, err := s.parser.Parse(job.path)
-				resultCh <- plistResult{
-					pl:   pl,
-					path: job.path,
-					dir:  job.dir,
-					err:  err,
+				result := plistResult{
+					path:     job.path,
+					dir:      job.dir,
+					target:   job.target,
+					dangling: job.dangling,
+				}
+				if job.dangling {
+					// Keep dangling links so they can be reported.
+					result.pl = &plist.LaunchAgentPlist{}
+				} else {
+					result.pl, result.err = s.parser.Parse(job.resolved)
 				}
+				resultCh <- result
 			}
 		}()
 	}
@@ -0,0 +1,262 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lu-zhengda/lanchr/internal/launchctl"
+	"github.com/lu-zhengda/lanchr/internal/platform"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+func TestOfflineScannerTree(t *testing.T) {
+	root := t.TempDir()
+	agent := func(label string) *plist.LaunchAgentPlist {
+		return &plist.LaunchAgentPlist{Label: label, Program: "/usr/local/bin/" + label}
+	}
+	writeAgent(t, filepath.Join(root, "Users", "alice", "Library", "LaunchAgents"), agent("com.alice.sync"))
+	writeAgent(t, filepath.Join(root, "Users", "bob", "Library", "LaunchAgents"), agent("com.bob.backup"))
+	writeAgent(t, filepath.Join(root, "Library", "LaunchAgents"), agent("com.example.agent"))
+	writeAgent(t, filepath.Join(root, "Library", "LaunchDaemons"), agent("com.example.daemon"))
+	writeAgent(t, filepath.Join(root, "System", "Library", "LaunchDaemons"), agent("com.apple.system"))
+	writeAgent(t, filepath.Join(root, "Library", "Apple", "System", "Library", "LaunchDaemons"), agent("com.apple.rsr"))
+	writeAgent(t, filepath.Join(root, "Applications", "Example.app", "Contents", "Library", "LaunchDaemons"),
+		&plist.LaunchAgentPlist{Label: "com.example.helper", BundleProgram: "Contents/MacOS/Helper"})
+	writeAgent(t, filepath.Join(root, "Applications", "Utilities", "Tool.app", "Contents", "Library", "LaunchAgents"), agent("com.example.tool"))
+
+	scanner := NewOfflineScanner(plist.NewParser(), root)
+	services, err := scanner.ScanAll()
+	if err != nil {
+		t.Fatalf("ScanAll() error = %v", err)
+	}
+
+	tests := []struct {
+		label     string
+		domain    platform.Domain
+		typ       platform.ServiceType
+		origin    platform.Origin
+		appBundle string
+	}{
+		{"com.alice.sync", platform.DomainUser, platform.TypeAgent, platform.OriginLaunchDir, ""},
+		{"com.bob.backup", platform.DomainUser, platform.TypeAgent, platform.OriginLaunchDir, ""},
+		{"com.example.agent", platform.DomainGlobal, platform.TypeAgent, platform.OriginLaunchDir, ""},
+		{"com.example.daemon", platform.DomainGlobal, platform.TypeDaemon, platform.OriginLaunchDir, ""},
+		{"com.apple.system", platform.DomainSystem, platform.TypeDaemon, platform.OriginLaunchDir, ""},
+		{"com.apple.rsr", platform.DomainSystem, platform.TypeDaemon, platform.OriginApple, ""},
+		{"com.example.helper", platform.DomainGlobal, platform.TypeDaemon, platform.OriginAppBundle, "/Applications/Example.app"},
+		{"com.example.tool", platform.DomainGlobal, platform.TypeAgent, platform.OriginAppBundle, "/Applications/Utilities/Tool.app"},
+	}
+	byLabel := make(map[string]Service)
+	for _, svc := range services {
+		byLabel[svc.Label] = svc
+		// Nothing outside the tree, such as the host's own agents, is read.
+		if !strings.HasPrefix(svc.PlistPath, root+string(filepath.Separator)) {
+			t.Errorf("%s was read from %s, outside the root", svc.Label, svc.PlistPath)
+		}
+	}
+	if len(services) != len(tests) {
+		t.Errorf("got %d services, want %d", len(services), len(tests))
+	}
+	for _, tt := range tests {
+		svc, ok := byLabel[tt.label]
+		if !ok {
+			t.Errorf("%s was not found", tt.label)
+			continue
+		}
+		if svc.Domain != tt.domain || svc.Type != tt.typ || svc.Origin != tt.origin {
+			t.Errorf("%s: got %s %s from %s, want %s %s from %s", tt.label, svc.Domain, svc.Type, svc.Origin, tt.domain, tt.typ, tt.origin)
+		}
+		if svc.AppBundle != tt.appBundle {
+			t.Errorf("%s: got app bundle %q, want %q", tt.label, svc.AppBundle, tt.appBundle)
+		}
+		// There is no launchd to ask for the runtime state.
+		if svc.Status != StatusUnknown || svc.PID != -1 {
+			t.Errorf("%s: got %s pid %d, want unknown", tt.label, svc.Status, svc.PID)
+		}
+	}
+
+	// Paths of the analyzed system map to and from the host.
+	helper := byLabel["com.example.helper"]
+	if got, want := scanner.HostPath(helper.BinaryPath()), filepath.Join(root, "Applications", "Example.app", "Contents", "MacOS", "Helper"); got != want {
+		t.Errorf("got host path %s, want %s", got, want)
+	}
+	if got := scanner.TargetPath(helper.PlistPath); got != "/Applications/Example.app/Contents/Library/LaunchDaemons/com.example.helper.plist" {
+		t.Errorf("got target path %s", got)
+	}
+	if got := scanner.TargetPath("/elsewhere/x.plist"); got != "/elsewhere/x.plist" {
+		t.Errorf("got target path %s for a path outside the root, want it unchanged", got)
+	}
+
+	if svc, err := scanner.FindByLabel("com.bob.backup"); err != nil || svc.Program != "/usr/local/bin/com.bob.backup" {
+		t.Errorf("FindByLabel() = %+v, %v", svc, err)
+	}
+}
+
+// symlink creates a link at path to target, creating its directory.
+func symlink(t *testing.T, target, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// linkedPlistTree builds a tree whose user agents are links of every kind,
+// and returns its root.
+func linkedPlistTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	home := filepath.Join(root, "Users", "me")
+	agents := filepath.Join(home, "Library", "LaunchAgents")
+	writeAgent(t, filepath.Join(home, "dotfiles"), &plist.LaunchAgentPlist{Label: "com.me.absolute", Program: "/usr/bin/true"})
+	writeAgent(t, filepath.Join(home, "dotfiles"), &plist.LaunchAgentPlist{Label: "com.me.relative", Program: "/usr/bin/true"})
+	writeAgent(t, filepath.Join(root, "Library", "Shared"), &plist.LaunchAgentPlist{Label: "com.me.shared", Program: "/usr/bin/true"})
+
+	// A file outside the tree that links must not reach.
+	outside := t.TempDir()
+	writeAgent(t, outside, &plist.LaunchAgentPlist{Label: "com.host.secret", Program: "/usr/bin/true"})
+	escape := strings.Repeat("../", strings.Count(agents, "/")+2) + strings.TrimPrefix(outside, "/") + "/com.host.secret.plist"
+
+	symlink(t, "/Users/me/dotfiles/com.me.absolute.plist", filepath.Join(agents, "com.me.absolute.plist"))
+	symlink(t, "../../dotfiles/com.me.relative.plist", filepath.Join(agents, "com.me.relative.plist"))
+	symlink(t, "../../../../Library/Shared/com.me.shared.plist", filepath.Join(agents, "com.me.shared.plist"))
+	symlink(t, escape, filepath.Join(agents, "com.me.escape.plist"))
+	symlink(t, filepath.Join(outside, "com.host.secret.plist"), filepath.Join(agents, "com.me.hostabs.plist"))
+	symlink(t, "gone.plist", filepath.Join(agents, "com.me.dangling.plist"))
+	symlink(t, "com.me.loop.plist", filepath.Join(agents, "com.me.loop.plist"))
+
+	// A user whose whole LaunchAgents directory links out of the tree.
+	symlink(t, outside, filepath.Join(root, "Users", "eve", "Library", "LaunchAgents"))
+	return root
+}
+
+func TestOfflineScannerLinks(t *testing.T) {
+	root := linkedPlistTree(t)
+	scanner := NewOfflineScanner(plist.NewParser(), root)
+	services, err := scanner.ScanAll()
+	if err != nil {
+		t.Fatalf("ScanAll() error = %v", err)
+	}
+	byFile := make(map[string]Service)
+	for _, svc := range services {
+		byFile[filepath.Base(svc.PlistPath)] = svc
+		if svc.Label == "com.host.secret" {
+			t.Errorf("%s was read from outside the root", svc.PlistPath)
+		}
+	}
+
+	tests := []struct {
+		file         string
+		wantLabel    string
+		wantTarget   string
+		wantDangling bool
+	}{
+		{"com.me.absolute.plist", "com.me.absolute", "/Users/me/dotfiles/com.me.absolute.plist", false},
+		{"com.me.relative.plist", "com.me.relative", "/Users/me/dotfiles/com.me.relative.plist", false},
+		{"com.me.shared.plist", "com.me.shared", "/Library/Shared/com.me.shared.plist", false},
+		// ".." stops at the root, as it does at / on the analyzed system.
+		{"com.me.escape.plist", "com.me.escape", "", true},
+		{"com.me.hostabs.plist", "com.me.hostabs", "", true},
+		{"com.me.dangling.plist", "com.me.dangling", "/Users/me/Library/LaunchAgents/gone.plist", true},
+		{"com.me.loop.plist", "com.me.loop", "/Users/me/Library/LaunchAgents/com.me.loop.plist", true},
+	}
+	for _, tt := range tests {
+		svc, ok := byFile[tt.file]
+		if !ok {
+			t.Errorf("%s was not found", tt.file)
+			continue
+		}
+		if svc.Label != tt.wantLabel || svc.PlistDangling != tt.wantDangling {
+			t.Errorf("%s: got label %s, dangling %v; want %s, %v", tt.file, svc.Label, svc.PlistDangling, tt.wantLabel, tt.wantDangling)
+		}
+		if tt.wantTarget != "" && svc.PlistTarget != tt.wantTarget {
+			t.Errorf("%s: got target %s, want %s", tt.file, svc.PlistTarget, tt.wantTarget)
+		}
+		if !strings.HasPrefix(svc.PlistTarget, "/") || strings.HasPrefix(svc.PlistTarget, root) {
+			t.Errorf("%s: got target %s, want a path on the analyzed system", tt.file, svc.PlistTarget)
+		}
+	}
+}
+
+func TestScannerLinks(t *testing.T) {
+	dir := t.TempDir()
+	writeAgent(t, filepath.Join(dir, "dotfiles"), &plist.LaunchAgentPlist{Label: "com.me.target", Program: "/usr/bin/true"})
+	target := filepath.Join(dir, "dotfiles", "com.me.target.plist")
+	symlink(t, target, filepath.Join(dir, "agents", "absolute.plist"))
+	symlink(t, "../dotfiles/com.me.target.plist", filepath.Join(dir, "agents", "relative.plist"))
+	symlink(t, "relative.plist", filepath.Join(dir, "agents", "chained.plist"))
+	symlink(t, "gone.plist", filepath.Join(dir, "agents", "dangling.plist"))
+	symlink(t, "loop.plist", filepath.Join(dir, "agents", "loop.plist"))
+
+	scanner := NewScanner(plist.NewParser(), nil)
+	tests := []struct {
+		link string
+		want string
+		ok   bool
+	}{
+		{"absolute.plist", target, true},
+		{"relative.plist", target, true},
+		{"chained.plist", target, true},
+		{"dangling.plist", filepath.Join(dir, "agents", "gone.plist"), false},
+		{"loop.plist", filepath.Join(dir, "agents", "loop.plist"), false},
+	}
+	for _, tt := range tests {
+		got, ok := scanner.resolveLink(filepath.Join(dir, "agents", tt.link))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("resolveLink(%s) = %s, %v; want %s, %v", tt.link, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// domainRunner answers "launchctl print <domain>" for the domains it has
+// and fails the rest, as launchctl does for domains that do not exist.
+type domainRunner map[string]string
+
+func (r domainRunner) Run(_ context.Context, name string, args ...string) ([]byte, error) {
+	if len(args) == 2 && args[0] == "print" {
+		if out, ok := r[args[1]]; ok {
+			return []byte(out), nil
+		}
+	}
+	return nil, &launchctl.RunError{
+		ExitCode: 113,
+		Stderr:   []byte("Could not find domain for port\n"),
+		Err:      fmt.Errorf("exit status 113"),
+	}
+}
+
+func TestScannerDomainsAreBestEffort(t *testing.T) {
+	// Root has no GUI domain, so only the system domain prints.
+	home := t.TempDir()
+	liveHome, _ := os.UserHomeDir()
+	platform.SetUser(0, home)
+	t.Cleanup(func() { platform.SetUser(os.Getuid(), liveHome) })
+
+	runner := domainRunner{"system": "system = {\n\tservices = {\n\t\t    321      -    \tcom.example.daemon\n\t}\n}\n"}
+	scanner := NewScanner(plist.NewParser(), launchctl.NewExecutorWithRunner(runner))
+	services, err := scanner.ScanAll()
+	if err != nil {
+		t.Fatalf("ScanAll() error = %v", err)
+	}
+	found := false
+	for _, svc := range services {
+		if svc.Label == "com.example.daemon" {
+			found = svc.PID == 321 && svc.LoadedDomain == "system"
+		}
+	}
+	if !found {
+		t.Errorf("com.example.daemon is not listed as loaded in system: %+v", services)
+	}
+
+	// Without any domain, there is nothing to list.
+	scanner = NewScanner(plist.NewParser(), launchctl.NewExecutorWithRunner(domainRunner{}))
+	if _, err := scanner.ScanAll(); err == nil {
+		t.Error("expected ScanAll() to fail when no domain can be printed, got nil")
+	}
+}
@@ -1,8 +1,11 @@
 package agent
 
 import (
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/lu-zhengda/lanchr/internal/launchctl"
 	"github.com/lu-zhengda/lanchr/internal/platform"
 )
 
@@ -14,6 +17,8 @@ const (
 	StatusRunning
 	StatusError
 	StatusDisabled
+	StatusUnknown // runtime state unavailable (offline analysis)
+	StatusInvalid // plist cannot be read or decoded; see Service.ParseError
 )
 
 // String returns a human-readable status name.
@@ -27,6 +32,10 @@ func (s Status) String() string {
 		return "error"
 	case StatusDisabled:
 		return "disabled"
+	case StatusUnknown:
+		return "unknown"
+	case StatusInvalid:
+		return "invalid"
 	default:
 		return "unknown"
 	}
@@ -43,6 +52,8 @@ func (s Status) Indicator() string {
 		return "!"
 	case StatusDisabled:
 		return "x"
+	case StatusInvalid:
+		return "#"
 	default:
 		return "?"
 	}
@@ -59,10 +70,13 @@ type CalendarInterval struct {
 
 // KeepAliveConditions holds structured KeepAlive conditions.
 type KeepAliveConditions struct {
-	SuccessfulExit  *bool
-	Crashed         *bool
-	PathState       map[string]bool
-	OtherJobEnabled map[string]bool
+	SuccessfulExit     *bool
+	Crashed            *bool
+	PathState          map[string]bool
+	OtherJobEnabled    map[string]bool
+	OtherJobActive     map[string]bool
+	AfterInitialDemand *bool
+	NetworkState       *bool
 }
 
 // Service represents a macOS launch agent or daemon with both plist data
@@ -77,6 +91,7 @@ type Service struct {
 	PlistPath         string
 	Program           string
 	ProgramArgs       []string
+	BundleProgram     string // relative to AppBundle (SMAppService plists)
 	RunAtLoad         bool
 	KeepAlive         interface{} // bool or KeepAliveConditions
 	StartInterval     int
@@ -97,6 +112,27 @@ type Service struct {
 	MachServices      map[string]interface{}
 	Sockets           map[string]interface{}
 	BlameLine         string
+
+	// PlistTarget is the resolved target when PlistPath is a symlink, and
+	// PlistDangling reports that the target does not exist.
+	PlistTarget   string
+	PlistDangling bool
+
+	// ParseError is why the plist could not be read, for StatusInvalid.
+	// A *plist.ParseError carries the position of the problem.
+	ParseError error
+
+	// Origin is where the plist was discovered, and AppBundle the .app that
+	// embeds it for OriginAppBundle.
+	Origin    platform.Origin
+	AppBundle string
+
+	// LoadedDomain is the launchd domain target ("gui/501", "system") the
+	// service was found loaded in, or "" if it is not loaded.
+	LoadedDomain string
+
+	// Runtime is the parsed "launchctl print" output, set by Manager.Info.
+	Runtime *launchctl.ServiceInfo
 }
 
 // IsApple returns true if the service label starts with "com.apple.".
@@ -104,19 +140,24 @@ func (s *Service) IsApple() bool {
 	return strings.HasPrefix(s.Label, "com.apple.")
 }
 
-// IsSIPProtected returns true if the service plist is under /System/Library.
+// IsSIPProtected returns true if the service plist is under /System/Library
+// or /Library/Apple.
 func (s *Service) IsSIPProtected() bool {
 	return platform.IsSIPProtected(s.PlistPath)
 }
 
 // ServiceTarget returns the launchctl service target for this service.
 func (s *Service) ServiceTarget() string {
-	return platform.ServiceTarget(s.Domain, s.Label)
+	return s.DomainTarget() + "/" + s.Label
 }
 
-// DomainTarget returns the launchctl domain target for this service.
+// DomainTarget returns the launchctl domain target for this service: the
+// domain it is loaded in, or the one it would be bootstrapped into.
 func (s *Service) DomainTarget() string {
-	return platform.DomainTarget(s.Domain)
+	if s.LoadedDomain != "" {
+		return s.LoadedDomain
+	}
+	return platform.LaunchdDomainTarget(s.Domain, s.Type)
 }
 
 // HasPlist returns true if the service has a known plist path.
@@ -132,5 +173,14 @@ func (s *Service) BinaryPath() string {
 	if len(s.ProgramArgs) > 0 {
 		return s.ProgramArgs[0]
 	}
+	if s.BundleProgram != "" && s.AppBundle != "" {
+		return filepath.Join(s.AppBundle, s.BundleProgram)
+	}
 	return ""
 }
+
+// NextRuns returns the next n times the service's StartCalendarInterval
+// schedule fires, or nil if it has none.
+func (s *Service) NextRuns(n int) []time.Time {
+	return NextFireTimes(s.CalendarInterval, time.Now(), n)
+}
@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/lu-zhengda/lanchr/internal/launchctl"
+	"github.com/lu-zhengda/lanchr/internal/manifest"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+var (
+	manifestFiles []string
+	applyNoLoad   bool
+)
+
+const manifestHelp = `Manifests are YAML files, one service per document:
+
+  label: com.example.sync
+  program: ~/bin/sync
+  args: [--quiet]
+  schedule: weekdays at 9:00      # or "every 30m", "daily at 2am", "at login", ...
+  keep_alive: on-failure          # true, always, on-failure or on-crash
+  env: {SYNC_TARGET: s3://bucket}
+  env_file: sync.env              # KEY=VALUE lines, relative to the manifest
+  log_dir: ~/Library/Logs/sync    # <label>.out.log and <label>.err.log
+
+The manifest is the source of truth: plist keys it does not set are removed.`
+
+var planCmd = &cobra.Command{
+	Use:   "plan -f <file|dir>...",
+	Short: "Show the plist changes manifests would make",
+	Long: `Compile service manifests and show how the installed plists differ from
+them, without changing anything. "lanchr apply" makes the changes.
+
+` + manifestHelp,
+	Example: `  lanchr plan -f manifests/
+  lanchr plan -f sync.yaml --json`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		steps, err := planManifests()
+		if err != nil {
+			return err
+		}
+		if jsonFlag {
+			return printJSON(toJSONPlan(steps, nil))
+		}
+		printPlan(steps)
+		return nil
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply -f <file|dir>...",
+	Short: "Create or update plists from service manifests",
+	Long: `Compile service manifests, write the plists that are missing or differ, and
+reload the services whose plist changed: changed services are booted out and
+bootstrapped again, and any service that is not loaded, such as one written
+with --no-load, is bootstrapped. Loaded, unchanged services are left alone. Run "lanchr plan" first to review the changes.
+
+` + manifestHelp,
+	Example: `  lanchr apply -f manifests/
+  lanchr apply -f sync.yaml --no-load`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		steps, err := planManifests()
+		if err != nil {
+			return err
+		}
+
+		results := make([]applyResult, len(steps))
+		failed := 0
+		for i, step := range steps {
+			reloaded, err := applyStep(step)
+			results[i] = applyResult{reloaded: reloaded, err: err}
+			if err != nil {
+				failed++
+			}
+		}
+
+		if jsonFlag {
+			if err := printJSON(toJSONPlan(steps, results)); err != nil {
+				return err
+			}
+		} else {
+			for i, step := range steps {
+				switch {
+				case results[i].err != nil:
+					fmt.Printf("! %s: %v\n", step.Manifest.Label, results[i].err)
+				case step.Action == manifest.ActionNone && results[i].reloaded:
+					fmt.Printf("Loaded %s (%s)\n", step.Manifest.Label, step.Path)
+				case step.Action == manifest.ActionNone:
+					continue
+				case results[i].reloaded:
+					fmt.Printf("%s %s (%s, reloaded)\n", actionVerb(step.Action), step.Manifest.Label, step.Path)
+				default:
+					fmt.Printf("%s %s (%s)\n", actionVerb(step.Action), step.Manifest.Label, step.Path)
+				}
+			}
+			counts := countActions(steps)
+			fmt.Printf("Applied: %d created, %d updated, %d unchanged.\n",
+				counts[manifest.ActionCreate], counts[manifest.ActionUpdate], counts[manifest.ActionNone])
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("failed to apply %d %s", failed, plural(failed, "service", "services"))
+		}
+		return nil
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{planCmd, applyCmd} {
+		cmd.Flags().StringArrayVarP(&manifestFiles, "file", "f", nil, "Manifest file or directory of .yaml files (repeatable)")
+		cmd.MarkFlagRequired("file")
+	}
+	applyCmd.Flags().BoolVar(&applyNoLoad, "no-load", false, "Write plists without bootstrapping or reloading services")
+
+	// Planning only reads files, and writing plists alone needs no
+	// launchd.
+	localCommands["plan"] = always
+	localCommands["apply"] = func([]string) bool { return applyNoLoad }
+}
+
+// applyResult is the outcome of applying one step.
+type applyResult struct {
+	reloaded bool
+	err      error
+}
+
+// planManifests loads the manifests given with -f and plans them.
+func planManifests() ([]manifest.Step, error) {
+	manifests, err := manifest.Load(manifestFiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("no manifests found in %v", manifestFiles)
+	}
+	return manifest.Plan(manifests)
+}
+
+// applyStep writes the plist of a step that changes one and, unless
+// --no-load is set, reloads its service if it changed and loads it if it
+// is not loaded, which launchd decides rather than the plan, so that
+// services written by "apply --no-load" are loaded later. Disabled
+// services are only booted out. It reports whether the service was
+// (re)loaded.
+func applyStep(step manifest.Step) (bool, error) {
+	// With --simulate, the plist goes to the simulation's scratch tree,
+	// which holds an unchanged one only if an earlier simulation wrote it.
+	path, err := simulatedPath(step.Path)
+	if err != nil {
+		return false, err
+	}
+	if step.Action != manifest.ActionNone || isFile(path) {
+		step.Path = path
+	}
+	if step.Action != manifest.ActionNone {
+		if err := writeStep(step); err != nil {
+			return false, err
+		}
+	}
+	if applyNoLoad {
+		return false, nil
+	}
+
+	scanner, manager, _ := buildDeps()
+	loaded := false
+	if svc, err := scanner.FindByLabel(step.Manifest.Label); err == nil {
+		loaded = svc.LoadedDomain != ""
+	}
+	if loaded && (step.Action == manifest.ActionUpdate || step.Plist.Disabled) {
+		if err := manager.Unload(step.Manifest.Label); err != nil && !errors.Is(err, launchctl.ErrServiceNotFound) {
+			return false, err
+		}
+		loaded = false
+	}
+	if loaded || step.Plist.Disabled {
+		return false, nil
+	}
+	if err := manager.Load(step.Path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// writeStep writes the plist of a step, and the directories of its logs.
+func writeStep(step manifest.Step) error {
+	if err := os.MkdirAll(filepath.Dir(step.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(step.Path), err)
+	}
+	if err := plist.NewWriter().WriteDocument(step.Doc, step.Path); err != nil {
+		return fmt.Errorf("failed to write plist: %w", err)
+	}
+	// launchd creates log files but not their directories.
+	for _, log := range []string{step.Plist.StandardOutPath, step.Plist.StandardErrorPath} {
+		if log == "" {
+			continue
+		}
+		dir, err := simulatedPath(filepath.Dir(log))
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create log directory %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// actionVerb returns the past tense of an action for apply output.
+func actionVerb(a manifest.Action) string {
+	switch a {
+	case manifest.ActionCreate:
+		return "Created"
+	case manifest.ActionUpdate:
+		return "Updated"
+	}
+	return "Unchanged"
+}
+
+// printPlan prints each service with a change marker (+ create, ~ update,
+// = unchanged) and the keys that differ.
+func printPlan(steps []manifest.Step) {
+	for _, step := range steps {
+		marker := map[manifest.Action]string{
+			manifest.ActionCreate: "+",
+			manifest.ActionUpdate: "~",
+			manifest.ActionNone:   "=",
+		}[step.Action]
+		fmt.Printf("%s %s (%s %s)\n", marker, step.Manifest.Label, step.Action, step.Path)
+		for _, c := range step.Changes {
+			switch {
+			case c.Old == nil:
+				fmt.Printf("    + %s: %s\n", c.Key, formatPlanValue(c.New))
+			case c.New == nil:
+				fmt.Printf("    - %s: %s\n", c.Key, formatPlanValue(c.Old))
+			default:
+				fmt.Printf("    ~ %s: %s -> %s\n", c.Key, formatPlanValue(c.Old), formatPlanValue(c.New))
+			}
+		}
+	}
+	counts := countActions(steps)
+	fmt.Printf("\nPlan: %d to create, %d to update, %d unchanged.\n",
+		counts[manifest.ActionCreate], counts[manifest.ActionUpdate], counts[manifest.ActionNone])
+}
+
+// countActions returns the number of steps per action.
+func countActions(steps []manifest.Step) map[manifest.Action]int {
+	counts := make(map[manifest.Action]int)
+	for _, s := range steps {
+		counts[s.Action]++
+	}
+	return counts
+}
+
+// formatPlanValue renders a plist value compactly, as JSON.
+func formatPlanValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyLoadsWhatNoLoadWrote(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	actAs(t, 501, home)
+	useLaunchctl(t, fakeLaunchctl{"print gui/501": "gui/501 = {\n\tservices = {\n\t}\n}\n"})
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	manifest := filepath.Join(t.TempDir(), "applied.yaml")
+	if err := os.WriteFile(manifest, []byte("label: com.example.applied\nprogram: /usr/bin/true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runLanchr(t, "apply", "-f", manifest, "--no-load"); err != nil {
+		t.Fatalf("lanchr apply --no-load: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, "Library", "LaunchAgents", "com.example.applied.plist")); err != nil {
+		t.Fatalf("apply --no-load did not write the plist: %v", err)
+	}
+
+	apply := func() jsonPlanService {
+		t.Helper()
+		out, err := runLanchr(t, "--simulate", "--json", "apply", "-f", manifest)
+		if err != nil {
+			t.Fatalf("lanchr apply: %v", err)
+		}
+		var plan jsonPlan
+		if err := json.Unmarshal([]byte(out), &plan); err != nil || len(plan.Services) != 1 {
+			t.Fatalf("failed to parse plan %q: %v", out, err)
+		}
+		return plan.Services[0]
+	}
+
+	// The plist is unchanged, but the service is not loaded yet.
+	if svc := apply(); svc.Action != "unchanged" || svc.Reloaded == nil || !*svc.Reloaded {
+		t.Errorf("got %s, reloaded %v; want an unchanged service that was loaded", svc.Action, svc.Reloaded)
+	}
+	// Once loaded, it is left alone.
+	if svc := apply(); svc.Reloaded == nil || *svc.Reloaded || svc.Error != "" {
+		t.Errorf("got reloaded %v, error %q; want the loaded service left alone", svc.Reloaded, svc.Error)
+	}
+}
@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/lu-zhengda/lanchr/internal/platform"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+	"github.com/lu-zhengda/lanchr/internal/systemd"
+)
+
+var (
+	convertLabel  string
+	convertOutput string
+
+	convertTo        string
+	convertOutputDir string
+	convertName      string
+	convertSystem    bool
+)
+
+var convertCmd = &cobra.Command{
+	Use:   "convert --to systemd <label|plist>",
+	Short: "Convert between launchd plists and other service formats",
+	Long: `Convert between launchd plists and other service formats.
+
+With --to systemd, convert a launchd plist, or the plist of the service with
+the given label, into systemd units: a .service, plus a .timer for
+StartInterval or StartCalendarInterval and a .path unit for WatchPaths or
+QueueDirectories. KeepAlive becomes a Restart= policy. The units are printed
+to standard output, or written into the directory given with -o. Keys that
+systemd cannot express, or only approximately, are reported as warnings on
+standard error.
+
+Plists in a LaunchDaemons directory, and --system, produce system units that
+keep UserName and GroupName; otherwise the units are for "systemctl --user".
+
+The systemd subcommand converts the other way.`,
+	Example: `  lanchr convert --to systemd com.example.backup
+  lanchr convert --to systemd ~/Library/LaunchAgents/com.example.web.plist -o ~/.config/systemd/user
+  lanchr convert --to systemd /Library/LaunchDaemons/com.example.db.plist --json`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if convertTo != "systemd" {
+			if convertTo == "" {
+				return fmt.Errorf("--to is required; the only target is systemd")
+			}
+			return fmt.Errorf("unsupported target %q; the only target is systemd", convertTo)
+		}
+		path, system, err := convertSource(args[0])
+		if err != nil {
+			return err
+		}
+		doc, err := plist.ReadDocument(path)
+		if err != nil {
+			return fmt.Errorf("failed to read plist: %w", err)
+		}
+		pl, err := doc.Plist()
+		if err != nil {
+			return fmt.Errorf("failed to parse plist: %w", err)
+		}
+
+		units, warnings := systemd.FromPlist(pl, systemd.ExportOptions{
+			Name:   convertName,
+			System: system || convertSystem,
+		})
+		for _, key := range doc.Unmodeled() {
+			warnings = append(warnings, systemd.Warning{Unit: pl.Label, Directive: key, Message: "is not recognized and was skipped"})
+		}
+
+		var written []string
+		if convertOutputDir != "" {
+			if err := os.MkdirAll(convertOutputDir, 0755); err != nil {
+				return fmt.Errorf("failed to create output directory: %w", err)
+			}
+			for _, u := range units {
+				unitPath := filepath.Join(convertOutputDir, u.Name)
+				if err := os.WriteFile(unitPath, u.Encode(), 0644); err != nil {
+					return fmt.Errorf("failed to write unit: %w", err)
+				}
+				written = append(written, unitPath)
+			}
+		}
+
+		if jsonFlag {
+			return printJSON(toJSONConvertUnits(pl.Label, units, written, warnings))
+		}
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+		if convertOutputDir != "" {
+			for _, p := range written {
+				fmt.Printf("Created %s\n", p)
+			}
+			return nil
+		}
+		for i, u := range units {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("# %s\n", u.Name)
+			os.Stdout.Write(u.Encode())
+		}
+		return nil
+	},
+}
+
+var convertSystemdCmd = &cobra.Command{
+	Use:   "systemd <unit.service> [unit.timer]",
+	Short: "Convert a systemd service, and its timer, into a launchd plist",
+	Long: `Convert a systemd service unit, and optionally the timer that triggers it, into
+a launchd plist, printed to standard output or written with -o.
+
+ExecStart, WorkingDirectory, Environment and EnvironmentFile, User and Group,
+Restart and RestartSec, StandardOutput and StandardError (file:, append:,
+null), Nice, UMask and Limit* map onto plist keys, and [Install] WantedBy
+becomes RunAtLoad. A timer's OnCalendar becomes StartCalendarInterval and
+OnUnitActiveSec becomes StartInterval. Directives that launchd cannot express,
+or only approximately, are reported as warnings on standard error.
+
+Given only a timer, the service it triggers is read from the same directory.`,
+	Example: `  lanchr convert systemd backup.service backup.timer -o ~/Library/LaunchAgents/com.me.backup.plist -l com.me.backup
+  lanchr convert systemd web.service --json`,
+	Args:         cobra.RangeArgs(1, 2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		service, timer, err := readSystemdUnits(args)
+		if err != nil {
+			return err
+		}
+
+		label := convertLabel
+		if label == "" {
+			label = "com.lanchr." + strings.TrimSuffix(service.Name, ".service")
+		}
+		pl, warnings, err := systemd.ToPlist(service, timer, label)
+		if err != nil {
+			return fmt.Errorf("failed to convert: %w", err)
+		}
+		doc := plist.DocumentFromPlist(pl)
+
+		if convertOutput != "" {
+			if err := plist.NewWriter().WriteDocument(doc, convertOutput); err != nil {
+				return fmt.Errorf("failed to write plist: %w", err)
+			}
+		}
+
+		if jsonFlag {
+			return printJSON(toJSONConvert(label, doc, convertOutput, warnings))
+		}
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+		if convertOutput != "" {
+			fmt.Printf("Created %s\n", convertOutput)
+			return nil
+		}
+		data, err := doc.Encode()
+		if err != nil {
+			return fmt.Errorf("failed to encode plist: %w", err)
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	},
+}
+
+func init() {
+	convertSystemdCmd.Flags().StringVarP(&convertLabel, "label", "l", "", "Service label (default: com.lanchr.<unit name>)")
+	convertSystemdCmd.Flags().StringVarP(&convertOutput, "output", "o", "", "Write the plist to this path instead of standard output")
+	convertCmd.AddCommand(convertSystemdCmd)
+	// Units are always given as files.
+	localCommands["convert systemd"] = always
+
+	convertCmd.Flags().StringVar(&convertTo, "to", "", "Target format: systemd")
+	convertCmd.Flags().StringVarP(&convertOutputDir, "output-dir", "o", "", "Write the units into this directory instead of standard output")
+	convertCmd.Flags().StringVar(&convertName, "name", "", "Base name of the units (default: the label)")
+	convertCmd.Flags().BoolVar(&convertSystem, "system", false, "Generate system units instead of user units")
+	// Plists given as files need no lookup by label.
+	localCommands["convert"] = allFiles
+}
+
+// convertSource resolves a plist file or service label to a plist path,
+// and reports whether it is a daemon.
+func convertSource(arg string) (path string, daemon bool, err error) {
+	if isFile(arg) {
+		return arg, lintServiceType(arg) == platform.TypeDaemon, nil
+	}
+	scanner, _, _ := buildDeps()
+	svc, err := scanner.FindByLabel(arg)
+	if err != nil {
+		return "", false, fmt.Errorf("%s is neither a plist file nor a known service: %w", arg, err)
+	}
+	if svc.PlistPath == "" {
+		return "", false, fmt.Errorf("service %q has no plist on disk", arg)
+	}
+	return svc.PlistPath, svc.Type == platform.TypeDaemon, nil
+}
+
+// readSystemdUnits reads the service and optional timer named by args, in
+// either order. A lone timer brings the service it triggers, from its
+// Unit= or its own name, out of the same directory.
+func readSystemdUnits(args []string) (service, timer *systemd.Unit, err error) {
+	for _, path := range args {
+		u, err := systemd.ReadUnit(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch u.Kind() {
+		case "service":
+			if service != nil {
+				return nil, nil, fmt.Errorf("more than one service given")
+			}
+			service = u
+		case "timer":
+			if timer != nil {
+				return nil, nil, fmt.Errorf("more than one timer given")
+			}
+			timer = u
+			if service == nil && len(args) == 1 {
+				name := strings.TrimSuffix(u.Name, ".timer") + ".service"
+				for _, d := range u.Directives {
+					if d.Section == "Timer" && d.Key == "Unit" {
+						name = d.Value
+					}
+				}
+				if service, err = systemd.ReadUnit(filepath.Join(filepath.Dir(path), name)); err != nil {
+					return nil, nil, err
+				}
+			}
+		default:
+			return nil, nil, fmt.Errorf("%s is not a .service or .timer unit", path)
+		}
+	}
+	if service == nil {
+		return nil, nil, fmt.Errorf("no .service unit given")
+	}
+	return service, timer, nil
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/lu-zhengda/lanchr/internal/cron"
 	"github.com/lu-zhengda/lanchr/internal/plist"
 )
 
@@ -23,6 +24,7 @@ var (
 	createWorkingDir string
 	createEnv        []string
 	createTemplate   string
+	createParams     []string
 	createOutput     string
 	createLoad       bool
 )
@@ -30,18 +32,48 @@ var (
 var createCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Scaffold a new launch agent plist from templates",
-	Long:  "Create a new launch agent plist using built-in templates. Supports simple, interval, calendar, keepalive, watcher, and monitor-* templates.",
+	Long:  "Create a new launch agent plist using built-in templates (simple, interval, calendar, keepalive, watcher, and monitor-*) or user templates from ~/.config/lanchr/templates (see \"lanchr template\").",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var pl plist.LaunchAgentPlist
+		var (
+			pl plist.LaunchAgentPlist
+			// doc holds a user template's rendered plist, including keys
+			// LaunchAgentPlist does not model.
+			doc *plist.Document
+		)
 
 		// Start from a template if specified.
 		isMonitorTemplate := strings.HasPrefix(createTemplate, "monitor-")
 		if createTemplate != "" {
-			tmpl, err := plist.GetTemplate(createTemplate)
+			tmpl, err := plist.LookupTemplate(createTemplate, plist.DefaultTemplateDir())
 			if err != nil {
 				return err
 			}
-			pl = tmpl.Plist
+			params, err := parseTemplateParams(createParams)
+			if err != nil {
+				return err
+			}
+			if err := tmpl.CheckRequirements(); err != nil {
+				return err
+			}
+			rendered, err := tmpl.Render(createLabel, params)
+			if err != nil {
+				return err
+			}
+			if tmpl.Custom() {
+				isMonitorTemplate = false
+				decoded, err := rendered.Plist()
+				if err != nil {
+					return fmt.Errorf("template %q: %w", tmpl.Name, err)
+				}
+				pl, doc = *decoded, rendered
+				if createLabel == "" {
+					createLabel = pl.Label
+				}
+			} else {
+				pl = tmpl.Plist
+			}
+		} else if len(createParams) > 0 {
+			return fmt.Errorf("--param requires --template")
 		}
 
 		// For monitor templates, label defaults to the template name if not provided.
@@ -53,8 +85,8 @@ var createCmd = &cobra.Command{
 			return fmt.Errorf("--label is required")
 		}
 
-		// For monitor templates, program comes from the template itself.
-		if !isMonitorTemplate && createProgram == "" {
+		// Monitor and user templates may bring their own program.
+		if createProgram == "" && pl.ProgramPath() == "" {
 			return fmt.Errorf("--program is required")
 		}
 
@@ -67,7 +99,7 @@ var createCmd = &cobra.Command{
 		if createArgs != "" {
 			prog := createProgram
 			if prog == "" {
-				prog = pl.Program
+				prog = pl.ProgramPath()
 			}
 			pl.ProgramArguments = append([]string{prog}, strings.Split(createArgs, ",")...)
 		}
@@ -77,12 +109,9 @@ var createCmd = &cobra.Command{
 		}
 
 		if createCalendar != "" {
-			// Parse cron-like spec: "minute hour day month weekday"
-			cal, err := parseCalendarSpec(createCalendar)
-			if err != nil {
+			if err := parseCalendarSpec(createCalendar, &pl); err != nil {
 				return fmt.Errorf("invalid calendar spec: %w", err)
 			}
-			pl.StartCalendarInterval = cal
 		}
 
 		if createRunAtLoad {
@@ -124,10 +153,23 @@ var createCmd = &cobra.Command{
 			home, _ := os.UserHomeDir()
 			outputPath = filepath.Join(home, "Library", "LaunchAgents", pl.Label+".plist")
 		}
+		// With --simulate, the plist goes to the simulation's scratch tree.
+		outputPath, err := simulatedPath(outputPath)
+		if err != nil {
+			return err
+		}
 
 		// Write the plist.
 		writer := plist.NewWriter()
-		if err := writer.Write(&pl, outputPath); err != nil {
+		if doc != nil {
+			if errs := writer.Validate(&pl); len(errs) > 0 {
+				return fmt.Errorf("failed to write plist: failed to validate plist: %s", errs[0].Message)
+			}
+			doc.Apply(&pl)
+			if err := writer.WriteDocument(doc, outputPath); err != nil {
+				return fmt.Errorf("failed to write plist: %w", err)
+			}
+		} else if err := writer.Write(&pl, outputPath); err != nil {
 			return fmt.Errorf("failed to write plist: %w", err)
 		}
 
@@ -167,67 +209,35 @@ func init() {
 	createCmd.Flags().StringVarP(&createProgram, "program", "p", "", "Executable path")
 	createCmd.Flags().StringVarP(&createArgs, "args", "a", "", "Program arguments (comma-separated)")
 	createCmd.Flags().IntVar(&createInterval, "interval", 0, "StartInterval in seconds")
-	createCmd.Flags().StringVar(&createCalendar, "calendar", "", "StartCalendarInterval (cron-like: \"minute hour\")")
+	createCmd.Flags().StringVar(&createCalendar, "calendar", "", "StartCalendarInterval as a cron expression (e.g. \"*/15 9-17 * * MON-FRI\", @daily)")
 	createCmd.Flags().BoolVar(&createRunAtLoad, "run-at-load", false, "Set RunAtLoad to true")
 	createCmd.Flags().BoolVar(&createKeepAlive, "keep-alive", false, "Set KeepAlive to true")
 	createCmd.Flags().StringVar(&createStdout, "stdout", "", "StandardOutPath")
 	createCmd.Flags().StringVar(&createStderr, "stderr", "", "StandardErrorPath")
 	createCmd.Flags().StringVar(&createWorkingDir, "working-dir", "", "WorkingDirectory")
 	createCmd.Flags().StringArrayVar(&createEnv, "env", nil, "Environment variables (KEY=VAL, repeatable)")
-	createCmd.Flags().StringVar(&createTemplate, "template", "", "Built-in template (simple, interval, calendar, keepalive, watcher, monitor-cpu, monitor-ports, monitor-security, monitor-disk)")
+	createCmd.Flags().StringVar(&createTemplate, "template", "", "Template name: built-in (simple, interval, calendar, keepalive, watcher, monitor-cpu, monitor-ports, monitor-security, monitor-disk) or user (see \"lanchr template list\")")
+	createCmd.Flags().StringArrayVar(&createParams, "param", nil, "Template parameter (NAME=VALUE, repeatable)")
 	createCmd.Flags().StringVarP(&createOutput, "output", "o", "", "Output path (default: ~/Library/LaunchAgents/<label>.plist)")
 	createCmd.Flags().BoolVar(&createLoad, "load", false, "Bootstrap the plist after creation")
 }
 
-// parseCalendarSpec parses a simplified cron-like spec: "minute hour".
-func parseCalendarSpec(spec string) (map[string]interface{}, error) {
-	parts := strings.Fields(spec)
-	result := make(map[string]interface{})
-
-	if len(parts) >= 1 && parts[0] != "*" {
-		var minute int
-		_, err := fmt.Sscanf(parts[0], "%d", &minute)
-		if err != nil {
-			return nil, fmt.Errorf("invalid minute: %s", parts[0])
-		}
-		result["Minute"] = minute
+// parseCalendarSpec parses a cron expression into a StartCalendarInterval
+// value. Fields left off the end match everything, so "0 9" is every day at
+// 9:00; @reboot sets RunAtLoad instead.
+func parseCalendarSpec(spec string, pl *plist.LaunchAgentPlist) error {
+	expr := strings.TrimSpace(spec)
+	if n := len(strings.Fields(expr)); n > 0 && n < 5 && !strings.HasPrefix(expr, "@") {
+		expr += strings.Repeat(" *", 5-n)
 	}
-
-	if len(parts) >= 2 && parts[1] != "*" {
-		var hour int
-		_, err := fmt.Sscanf(parts[1], "%d", &hour)
-		if err != nil {
-			return nil, fmt.Errorf("invalid hour: %s", parts[1])
-		}
-		result["Hour"] = hour
+	cal, err := cron.Parse(expr)
+	if err != nil {
+		return err
 	}
-
-	if len(parts) >= 3 && parts[2] != "*" {
-		var day int
-		_, err := fmt.Sscanf(parts[2], "%d", &day)
-		if err != nil {
-			return nil, fmt.Errorf("invalid day: %s", parts[2])
-		}
-		result["Day"] = day
-	}
-
-	if len(parts) >= 4 && parts[3] != "*" {
-		var month int
-		_, err := fmt.Sscanf(parts[3], "%d", &month)
-		if err != nil {
-			return nil, fmt.Errorf("invalid month: %s", parts[3])
-		}
-		result["Month"] = month
-	}
-
-	if len(parts) >= 5 && parts[4] != "*" {
-		var weekday int
-		_, err := fmt.Sscanf(parts[4], "%d", &weekday)
-		if err != nil {
-			return nil, fmt.Errorf("invalid weekday: %s", parts[4])
-		}
-		result["Weekday"] = weekday
+	if cal.Reboot {
+		pl.RunAtLoad = true
+		return nil
 	}
-
-	return result, nil
+	pl.StartCalendarInterval = cal.StartCalendarInterval()
+	return nil
 }
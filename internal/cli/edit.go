@@ -41,8 +41,24 @@ var editCmd = &cobra.Command{
 			editor = "vi"
 		}
 
+		// With --simulate, the editor opens a copy in the simulation's
+		// scratch tree.
+		plistPath, err := simulatedPath(svc.PlistPath)
+		if err != nil {
+			return err
+		}
+		if plistPath != svc.PlistPath && !isFile(plistPath) {
+			data, err := os.ReadFile(svc.PlistPath)
+			if err != nil {
+				return fmt.Errorf("failed to read plist: %w", err)
+			}
+			if err := os.WriteFile(plistPath, data, 0644); err != nil {
+				return fmt.Errorf("failed to copy plist: %w", err)
+			}
+		}
+
 		// Open the plist in the editor.
-		editExec := exec.Command(editor, svc.PlistPath)
+		editExec := exec.Command(editor, plistPath)
 		editExec.Stdin = os.Stdin
 		editExec.Stdout = os.Stdout
 		editExec.Stderr = os.Stderr
@@ -52,7 +68,7 @@ var editCmd = &cobra.Command{
 		}
 
 		// Validate the plist after editing.
-		validateCmd := exec.Command("plutil", "-lint", svc.PlistPath)
+		validateCmd := exec.Command("plutil", "-lint", plistPath)
 		validateOut, err := validateCmd.CombinedOutput()
 		validationOK := err == nil
 
@@ -73,7 +89,7 @@ var editCmd = &cobra.Command{
 
 			// Bootout then bootstrap.
 			_ = manager.Unload(label)
-			if err := manager.Load(svc.PlistPath); err != nil {
+			if err := manager.Load(plistPath); err != nil {
 				return fmt.Errorf("failed to reload service: %w", err)
 			}
 			reloaded = true
@@ -88,7 +104,7 @@ var editCmd = &cobra.Command{
 				OK:           true,
 				Action:       "edit",
 				Label:        label,
-				PlistPath:    svc.PlistPath,
+				PlistPath:    plistPath,
 				ValidationOK: validationOK,
 				Reloaded:     reloaded,
 			})
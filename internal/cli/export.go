@@ -1,46 +1,173 @@
 package cli
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/lu-zhengda/lanchr/internal/agent"
+	"github.com/lu-zhengda/lanchr/internal/platform"
 	"github.com/lu-zhengda/lanchr/internal/plist"
 )
 
+var (
+	exportMatch   string
+	exportAllUser bool
+	exportAssets  bool
+	exportSign    string
+)
+
 var exportCmd = &cobra.Command{
-	Use:   "export <label> [file]",
-	Short: "Export a launch agent/daemon to a portable JSON bundle",
-	Long:  "Export a launch agent/daemon (its plist file + metadata) to a portable JSON bundle.\nIf no output file is specified, the bundle is written to stdout.",
-	Args:  cobra.RangeArgs(1, 2),
+	Use:   "export [label] [file]",
+	Short: "Export launch agents/daemons to a portable JSON bundle",
+	Long: `Export a launch agent/daemon (its plist file + metadata) to a portable JSON bundle.
+With --match or --all-user, export every matching service into one bundle
+instead, so a whole setup can move to another Mac in one file.
+
+With --assets, the scripts and binaries the plists reference (the program, and
+absolute paths among the arguments, outside the macOS system directories) are
+embedded too, with SHA-256 checksums that import verifies.
+
+Paths in the home directory the services belong to are exported as {{HOME}},
+which import replaces with the importing user's home.
+
+With --sign, the bundle is signed with an ed25519 key from "lanchr keygen", so
+that "import --verify" can refuse bundles that were tampered with.
+
+If no output file is specified, the bundle is written to stdout.`,
+	Example: `  lanchr export com.example.agent agent.json
+  lanchr export --match 'com.acme.*' acme.json
+  lanchr export --all-user --assets laptop.json
+  lanchr export --match 'com.acme.*' --sign ~/.config/lanchr/signing.key acme.json`,
+	Args: cobra.RangeArgs(0, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		scanner, _, _ := buildDeps()
 
-		label := args[0]
-		svc, err := scanner.FindByLabel(label)
-		if err != nil {
-			return fmt.Errorf("failed to find service %q: %w", label, err)
+		multi := exportMatch != "" || exportAllUser
+		if !multi && len(args) == 0 {
+			return fmt.Errorf("a label, --match or --all-user is required")
+		}
+		if multi && len(args) > 1 {
+			return fmt.Errorf("with --match or --all-user, the only argument is the output file")
 		}
 
-		if svc.PlistPath == "" {
-			return fmt.Errorf("service %q has no plist on disk; cannot export", label)
+		// Read the key first, so that a bad one fails before any work.
+		var key ed25519.PrivateKey
+		if exportSign != "" {
+			var err error
+			if key, err = plist.ReadSigningKey(exportSign); err != nil {
+				return err
+			}
+		}
+
+		var (
+			services []agent.Service
+			output   string
+			warnings []string
+		)
+		if multi {
+			var err error
+			if services, warnings, err = exportMatching(scanner); err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				output = args[0]
+			}
+		} else {
+			label := args[0]
+			svc, err := scanner.FindByLabel(label)
+			if err != nil {
+				return fmt.Errorf("failed to find service %q: %w", label, err)
+			}
+			if svc.PlistPath == "" {
+				return fmt.Errorf("service %q has no plist on disk; cannot export", label)
+			}
+			services = []agent.Service{*svc}
+			if len(args) == 2 {
+				output = args[1]
+			}
 		}
 
+		bundle := plist.NewBundle()
 		parser := plist.NewParser()
-		pl, err := parser.Parse(svc.PlistPath)
-		if err != nil {
-			return fmt.Errorf("failed to parse plist for %q: %w", label, err)
+		for _, svc := range services {
+			pl, err := parser.Parse(svc.PlistPath)
+			if err != nil {
+				if !multi {
+					return fmt.Errorf("failed to parse plist for %q: %w", svc.Label, err)
+				}
+				warnings = append(warnings, fmt.Sprintf("skipped %s: failed to parse plist: %v", svc.Label, err))
+				continue
+			}
+
+			// Record the path the plist has on the analyzed system, not under
+			// --root, with the home directory replaced by a placeholder.
+			home := exportHome(scanner, svc)
+			toBundle := func(path string) string {
+				return plist.ReplacePath(path, home, plist.HomePlaceholder)
+			}
+			entry := bundle.AddService(pl, toBundle(scanner.TargetPath(svc.PlistPath)), svc.Domain.String(), svc.Type.String())
+			if data, err := os.ReadFile(svc.PlistPath); err == nil {
+				entry.PlistData = data
+			}
+			if home != "" {
+				doc, err := entry.Document()
+				if err == nil {
+					doc.MapStrings(toBundle)
+					err = entry.SetDocument(doc)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to export %s: %w", svc.Label, err)
+				}
+			}
+
+			if exportAssets {
+				for _, path := range plist.AssetPaths(pl) {
+					if path != pl.ProgramPath() && !isFile(scanner.HostPath(path)) {
+						// Arguments may name directories, or files the job creates.
+						continue
+					}
+					if err := bundle.AddAsset(toBundle(path), scanner.HostPath(path)); err != nil {
+						warnings = append(warnings, fmt.Sprintf("%s: not embedding %s: %v", svc.Label, path, err))
+					}
+				}
+			}
+		}
+		if len(bundle.Services) == 0 {
+			return fmt.Errorf("no services could be exported")
+		}
+
+		if key != nil {
+			if err := bundle.Sign(key); err != nil {
+				return fmt.Errorf("failed to sign export bundle: %w", err)
+			}
 		}
 
-		bundle := plist.NewExportBundle(pl, svc.PlistPath, svc.Domain.String(), svc.Type.String())
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
 
 		// Write to file or stdout.
-		if len(args) == 2 {
-			outputPath := args[1]
-			if err := plist.WriteBundleToFile(outputPath, bundle); err != nil {
+		if output != "" {
+			if err := plist.WriteBundleToFile(output, bundle); err != nil {
 				return fmt.Errorf("failed to write export bundle: %w", err)
 			}
-			fmt.Fprintf(os.Stderr, "Exported %s to %s\n", label, outputPath)
+			what := bundle.Services[0].Label
+			if len(bundle.Services) > 1 {
+				what = fmt.Sprintf("%d services", len(bundle.Services))
+			}
+			if n := len(bundle.Assets); n > 0 {
+				what += fmt.Sprintf(" with %d %s", n, plural(n, "asset", "assets"))
+			}
+			verb := "Exported"
+			if key != nil {
+				verb = "Exported and signed"
+			}
+			fmt.Fprintf(os.Stderr, "%s %s to %s\n", verb, what, output)
 		} else {
 			if err := plist.WriteBundle(os.Stdout, bundle); err != nil {
 				return fmt.Errorf("failed to write export bundle: %w", err)
@@ -50,3 +177,77 @@ var exportCmd = &cobra.Command{
 		return nil
 	},
 }
+
+func init() {
+	exportCmd.Flags().StringVar(&exportMatch, "match", "", "Export every service whose label matches this glob, such as 'com.acme.*'")
+	exportCmd.Flags().BoolVar(&exportAllUser, "all-user", false, "Export every service in ~/Library/LaunchAgents")
+	exportCmd.Flags().BoolVar(&exportAssets, "assets", false, "Embed the scripts and binaries the plists reference")
+	exportCmd.Flags().StringVar(&exportSign, "sign", "", "Sign the bundle with this ed25519 private key (see \"lanchr keygen\")")
+}
+
+// exportMatching returns the services with a plist that --match and
+// --all-user select, sorted by label, and warnings about services named
+// exactly by --match that have none. Services merely loaded, such as most
+// in a gui domain, are skipped silently.
+func exportMatching(scanner *agent.Scanner) ([]agent.Service, []string, error) {
+	if exportMatch != "" {
+		if _, err := filepath.Match(exportMatch, ""); err != nil {
+			return nil, nil, fmt.Errorf("invalid --match pattern %q: %w", exportMatch, err)
+		}
+	}
+	all, err := scanner.ScanAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan services: %w", err)
+	}
+
+	var (
+		services []agent.Service
+		warnings []string
+	)
+	for _, svc := range all {
+		if exportAllUser && svc.Domain != platform.DomainUser {
+			continue
+		}
+		if exportMatch != "" {
+			if ok, _ := filepath.Match(exportMatch, svc.Label); !ok {
+				continue
+			}
+		}
+		if svc.PlistPath == "" {
+			if exportMatch == svc.Label {
+				warnings = append(warnings, fmt.Sprintf("skipped %s: it has no plist on disk", svc.Label))
+			}
+			continue
+		}
+		services = append(services, svc)
+	}
+	if len(services) == 0 {
+		if len(warnings) > 0 {
+			return nil, nil, fmt.Errorf("no services with a plist match: %s", strings.Join(warnings, "; "))
+		}
+		return nil, nil, fmt.Errorf("no services with a plist match")
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Label < services[j].Label })
+	return services, warnings, nil
+}
+
+// exportHome returns the home directory that a service's paths are
+// relative to: the one its plist is in for a user agent, and otherwise the
+// current user's, unless analyzing a mounted tree. It returns "" if there
+// is none.
+func exportHome(scanner *agent.Scanner, svc agent.Service) string {
+	if svc.Domain == platform.DomainUser {
+		dir := filepath.Dir(scanner.TargetPath(svc.PlistPath))
+		if filepath.Base(dir) == "LaunchAgents" && filepath.Base(filepath.Dir(dir)) == "Library" {
+			return filepath.Dir(filepath.Dir(dir))
+		}
+	}
+	if rootDir != "" {
+		return ""
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || home == "/" {
+		return ""
+	}
+	return home
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lu-zhengda/lanchr/internal/agent"
+	"github.com/lu-zhengda/lanchr/internal/launchctl"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+func TestExportMatchingSkipsLoadedServicesWithoutPlist(t *testing.T) {
+	home := t.TempDir()
+	writeAgentPlist(t, filepath.Join(home, "Library", "LaunchAgents"), "com.example.agent")
+	actAs(t, 501, home)
+	runner := fakeLaunchctl{"print gui/501": "gui/501 = {\n\tservices = {\n\t\t    100      -    \tcom.example.agent\n\t\t    200      -    \tcom.example.loaded\n\t}\n}\n"}
+	scanner := agent.NewScanner(plist.NewParser(), launchctl.NewExecutorWithRunner(runner))
+	t.Cleanup(func() { exportAllUser, exportMatch = false, "" })
+
+	// Services only loaded in the gui domain are not worth a warning.
+	exportAllUser = true
+	services, warnings, err := exportMatching(scanner)
+	if err != nil {
+		t.Fatalf("exportMatching() error = %v", err)
+	}
+	if len(services) != 1 || services[0].Label != "com.example.agent" || len(warnings) != 0 {
+		t.Errorf("got %d services and warnings %q, want com.example.agent alone without warnings", len(services), warnings)
+	}
+
+	// A service named exactly is reported.
+	exportAllUser, exportMatch = false, "com.example.loaded"
+	if _, _, err := exportMatching(scanner); err == nil || !strings.Contains(err.Error(), "com.example.loaded: it has no plist on disk") {
+		t.Errorf("exportMatching() of a service without plist = %v, want it reported", err)
+	}
+}
@@ -4,18 +4,54 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/lu-zhengda/lanchr/internal/plist"
 )
 
-var importLoad bool
+var (
+	importLoad        bool
+	importAssets      bool
+	importDomain      string
+	importLabelPrefix string
+	importRenames     []string
+	importReplace     bool
+	importMapPaths    []string
+	importVerify      string
+)
 
 var importCmd = &cobra.Command{
 	Use:   "import <file>",
-	Short: "Import a launch agent/daemon from an export bundle",
-	Long:  "Import a launch agent/daemon from a JSON export bundle. Copies the plist to\n~/Library/LaunchAgents/ and optionally loads it.",
-	Args:  cobra.ExactArgs(1),
+	Short: "Import launch agents/daemons from an export bundle",
+	Long: `Import the launch agents/daemons of a JSON export bundle. Copies each plist to
+~/Library/LaunchAgents/, or with --domain to /Library/LaunchAgents/ (global)
+or /Library/LaunchDaemons/ (daemon) owned by root:wheel, and optionally loads
+it. Nothing is written if any of the plists already exists, unless --replace
+is given; the replaced plists are kept as timestamped backups next to them.
+
+--rename OLD=NEW changes one label, and --label-prefix OLD=NEW the start of
+every label in the OLD namespace (com.acme.web becomes com.me.web with
+com.acme=com.me). Paths the bundle records as {{HOME}} are placed in your home
+directory, and --map-path OLD=NEW moves the others, in the plists and the
+embedded assets alike.
+
+With --verify, the bundle must be signed ("export --sign") by one of the keys
+in the given trusted keys file, and unchanged since; otherwise nothing is
+imported. The file has one "ed25519 <key> [comment]" line per key, as written
+by "lanchr keygen".
+
+Scripts and binaries embedded with "export --assets" are installed only with
+--assets. They are written to their original paths first, after checking
+their SHA-256 checksums, and only if each is the program or an argument of a
+service in the bundle. A file that already exists there is left alone, with a
+warning if it differs.`,
+	Example: `  lanchr import agent.json --load
+  lanchr import laptop.json --assets --verify ~/.config/lanchr/trusted_keys
+  lanchr import acme.json --label-prefix com.acme=com.me --map-path /opt/acme=/usr/local/acme
+  sudo lanchr import db.json --domain daemon --replace --load
+  lanchr import /Volumes/Shared/acme.json --verify /Volumes/Shared/trusted_keys`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		bundlePath := args[0]
 
@@ -23,62 +59,358 @@ var importCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("failed to read export bundle: %w", err)
 		}
+		var signedBy string
+		if importVerify != "" {
+			trusted, err := plist.ReadTrustedKeys(importVerify)
+			if err != nil {
+				return err
+			}
+			signer, err := bundle.VerifySignature(trusted)
+			if err != nil {
+				return fmt.Errorf("refusing to import %s: %w", bundlePath, err)
+			}
+			signedBy = signer.Name()
+		}
 
-		// Determine output path: always place in user's LaunchAgents.
 		home, err := os.UserHomeDir()
 		if err != nil {
 			return fmt.Errorf("failed to get home directory: %w", err)
 		}
-
-		outputDir := filepath.Join(home, "Library", "LaunchAgents")
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			return fmt.Errorf("failed to create LaunchAgents directory: %w", err)
+		outputDir, err := importOutputDir(home)
+		if err != nil {
+			return err
 		}
+		// With --simulate, the plists go to the simulation's scratch tree.
+		writeDir, err := simulatedPath(outputDir)
+		if err != nil {
+			return err
+		}
+		mapPath, err := importPathMapper(home)
+		if err != nil {
+			return err
+		}
+		relabel, err := importRelabeler(bundle)
+		if err != nil {
+			return err
+		}
+
+		// Rewrite the plists, then check where they go before writing
+		// anything.
+		var warnings []string
+		referenced := make(map[string]bool)
+		docs := make([]*plist.Document, len(bundle.Services))
+		results := make([]jsonImportService, len(bundle.Services))
+		labels := make(map[string]string)
+		for i, svc := range bundle.Services {
+			doc, err := svc.Document()
+			if err != nil {
+				return err
+			}
+			doc.MapStrings(mapPath)
+			label := relabel(svc.Label)
+			if err := plist.CheckLabel(label); err != nil {
+				return fmt.Errorf("cannot import %s: %w", svc.Label, err)
+			}
+			doc.Root.Set("Label", label)
+			docs[i] = doc
+			pl, err := doc.Plist()
+			if err != nil {
+				return fmt.Errorf("failed to decode plist of %s in export bundle: %w", svc.Label, err)
+			}
+			for _, path := range plist.AssetPaths(pl) {
+				referenced[path] = true
+			}
 
-		outputPath := filepath.Join(outputDir, bundle.Label+".plist")
+			results[i] = jsonImportService{Label: label, PlistPath: filepath.Join(writeDir, label+".plist")}
+			if label != svc.Label {
+				results[i].OriginalLabel = svc.Label
+			}
+			if prev, ok := labels[label]; ok {
+				return fmt.Errorf("%s and %s both become %s; use --rename to tell them apart", prev, svc.Label, label)
+			}
+			labels[label] = svc.Label
 
-		// Check if the file already exists.
-		if _, err := os.Stat(outputPath); err == nil {
-			return fmt.Errorf("plist already exists at %s; remove it first or use a different label", outputPath)
+			_, simulated := os.Stat(results[i].PlistPath)
+			_, installed := os.Stat(filepath.Join(outputDir, label+".plist"))
+			if (simulated == nil || installed == nil) && !importReplace {
+				return fmt.Errorf("plist already exists at %s; use --replace, or a different label", results[i].PlistPath)
+			}
+			if svc.Type == "daemon" && importDomain != "daemon" {
+				warnings = append(warnings, fmt.Sprintf("%s was exported as a daemon; use --domain daemon to install it as one", svc.Label))
+			}
+		}
+		// An asset may only be a file the services run, so that a bundle
+		// cannot drop files anywhere else.
+		if importAssets {
+			for i := range bundle.Assets {
+				bundle.Assets[i].Path = mapPath(bundle.Assets[i].Path)
+				if !referenced[bundle.Assets[i].Path] {
+					return fmt.Errorf("refusing to install %s: it is not the program or an argument of any service in the bundle", bundle.Assets[i].Path)
+				}
+			}
+		} else if n := len(bundle.Assets); n > 0 {
+			warnings = append(warnings, fmt.Sprintf("the bundle embeds %d %s that %s not installed; use --assets to install them", n, plural(n, "file", "files"), plural(n, "was", "were")))
+		}
+		if err := os.MkdirAll(writeDir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", writeDir, err)
 		}
 
-		// Write the plist from the bundle.
-		writer := plist.NewWriter()
-		if err := writer.WriteWithoutValidation(&bundle.Plist, outputPath); err != nil {
-			return fmt.Errorf("failed to write plist: %w", err)
+		// Install the assets first, so that the services find them when
+		// they are loaded.
+		var assets []jsonImportAsset
+		if importAssets {
+			for _, asset := range bundle.Assets {
+				status, err := installAsset(asset)
+				if err != nil {
+					return partialImportError(err, assets, nil)
+				}
+				if status == "differs" {
+					warnings = append(warnings, fmt.Sprintf("%s already exists with different content; it was left as is", asset.Path))
+				}
+				assets = append(assets, jsonImportAsset{Path: asset.Path, Status: status})
+			}
 		}
 
-		// Optionally load the plist.
-		loaded := false
+		// Write the plists, from the original files where the bundle has
+		// them so that keys lanchr does not model are kept.
+		for i, doc := range docs {
+			backup, err := doc.WriteFileWithBackup(results[i].PlistPath)
+			if err != nil {
+				return partialImportError(fmt.Errorf("failed to write plist: %w", err), assets, results[:i])
+			}
+			results[i].Backup = backup
+			if importDomain != "user" && !simulateFlag {
+				// launchd ignores plists in /Library that root does not own.
+				if err := os.Chown(results[i].PlistPath, 0, 0); err != nil {
+					return partialImportError(fmt.Errorf("failed to make %s owned by root:wheel: %w", results[i].PlistPath, err), assets, results[:i+1])
+				}
+			}
+		}
+
+		// Optionally load the plists, unloading the services they replaced.
+		var loadErrs int
 		if importLoad {
 			_, manager, _ := buildDeps()
-			if err := manager.Load(outputPath); err != nil {
-				if !jsonFlag {
-					fmt.Printf("Imported %s to %s\n", bundle.Label, outputPath)
+			for i := range results {
+				if results[i].Backup != "" {
+					_ = manager.Unload(results[i].Label)
 				}
-				return fmt.Errorf("failed to load plist: %w", err)
+				if err := manager.Load(results[i].PlistPath); err != nil {
+					warnings = append(warnings, fmt.Sprintf("failed to load %s: %v", results[i].Label, err))
+					loadErrs++
+					continue
+				}
+				results[i].Loaded = true
 			}
-			loaded = true
 		}
 
 		if jsonFlag {
-			return printJSON(jsonImport{
-				OK:        true,
-				Action:    "import",
-				Label:     bundle.Label,
-				PlistPath: outputPath,
-				Loaded:    loaded,
-			})
+			out := jsonImport{
+				OK:       loadErrs == 0,
+				Action:   "import",
+				SignedBy: signedBy,
+				Services: results,
+				Assets:   assets,
+				Warnings: warnings,
+			}
+			if len(results) == 1 {
+				out.Label = results[0].Label
+				out.PlistPath = results[0].PlistPath
+				out.Loaded = &results[0].Loaded
+			}
+			if err := printJSON(out); err != nil {
+				return err
+			}
+		} else {
+			if signedBy != "" {
+				fmt.Printf("Verified the signature of %s\n", signedBy)
+			}
+			for _, a := range assets {
+				if a.Status == "written" {
+					fmt.Printf("Installed %s\n", a.Path)
+				}
+			}
+			for _, r := range results {
+				what := r.Label
+				if r.OriginalLabel != "" {
+					what = fmt.Sprintf("%s as %s", r.OriginalLabel, r.Label)
+				}
+				fmt.Printf("Imported %s to %s\n", what, r.PlistPath)
+				if r.Backup != "" {
+					fmt.Printf("Saved the replaced plist as %s\n", r.Backup)
+				}
+				if r.Loaded {
+					fmt.Printf("Loaded %s\n", r.Label)
+				}
+			}
+			for _, w := range warnings {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+			}
 		}
 
-		fmt.Printf("Imported %s to %s\n", bundle.Label, outputPath)
-		if loaded {
-			fmt.Printf("Loaded %s\n", bundle.Label)
+		if loadErrs > 0 {
+			return fmt.Errorf("failed to load %d %s", loadErrs, plural(loadErrs, "plist", "plists"))
 		}
 		return nil
 	},
 }
 
 func init() {
-	importCmd.Flags().BoolVar(&importLoad, "load", false, "Bootstrap the plist after import")
+	importCmd.Flags().BoolVar(&importLoad, "load", false, "Bootstrap the plists after import")
+	importCmd.Flags().BoolVar(&importAssets, "assets", false, "Install the scripts and binaries embedded in the bundle")
+	importCmd.Flags().StringVar(&importDomain, "domain", "user", "Where to install: user (~/Library/LaunchAgents), global (/Library/LaunchAgents) or daemon (/Library/LaunchDaemons)")
+	importCmd.Flags().StringVar(&importLabelPrefix, "label-prefix", "", "Replace the label prefix OLD with NEW, as OLD=NEW")
+	importCmd.Flags().StringArrayVar(&importRenames, "rename", nil, "Rename the service labeled OLD to NEW, as OLD=NEW (repeatable)")
+	importCmd.Flags().BoolVar(&importReplace, "replace", false, "Replace existing plists, keeping backups of them")
+	importCmd.Flags().StringVar(&importVerify, "verify", "", "Only import bundles signed by a key in this trusted keys file")
+	importCmd.Flags().StringArrayVar(&importMapPaths, "map-path", nil, "Replace the path OLD with NEW in plists and asset paths, as OLD=NEW (repeatable)")
+}
+
+// importOutputDir returns the directory --domain installs plists in. The
+// directories under /Library require root.
+func importOutputDir(home string) (string, error) {
+	var dir string
+	switch importDomain {
+	case "user":
+		return filepath.Join(home, "Library", "LaunchAgents"), nil
+	case "global":
+		dir = "/Library/LaunchAgents"
+	case "daemon":
+		dir = "/Library/LaunchDaemons"
+	default:
+		return "", fmt.Errorf("invalid --domain %q: must be user, global or daemon", importDomain)
+	}
+	if os.Geteuid() != 0 {
+		return "", fmt.Errorf("--domain %s installs into %s, which requires root; run with sudo", importDomain, dir)
+	}
+	return dir, nil
+}
+
+// splitMapping splits an OLD=NEW flag value.
+func splitMapping(flag, value string) (string, string, error) {
+	old, new, ok := strings.Cut(value, "=")
+	if !ok || old == "" || new == "" {
+		return "", "", fmt.Errorf("invalid --%s %q: expected OLD=NEW", flag, value)
+	}
+	return old, new, nil
+}
+
+// importPathMapper returns the function that rewrites the paths of a
+// bundle: HomePlaceholder becomes home, then the --map-path mappings
+// apply in order.
+func importPathMapper(home string) (func(string) string, error) {
+	type mapping struct{ old, new string }
+	var mappings []mapping
+	for _, value := range importMapPaths {
+		old, new, err := splitMapping("map-path", value)
+		if err != nil {
+			return nil, err
+		}
+		if old != "/" {
+			old = strings.TrimSuffix(old, "/")
+		}
+		mappings = append(mappings, mapping{old, new})
+	}
+	return func(s string) string {
+		s = plist.ReplacePath(s, plist.HomePlaceholder, home)
+		for _, m := range mappings {
+			s = plist.ReplacePath(s, m.old, m.new)
+		}
+		return s
+	}, nil
+}
+
+// importRelabeler returns the function that gives a bundle's services
+// their new labels: --rename, or else --label-prefix.
+func importRelabeler(bundle *plist.ExportBundle) (func(string) string, error) {
+	renames := make(map[string]string)
+	for _, value := range importRenames {
+		old, new, err := splitMapping("rename", value)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, svc := range bundle.Services {
+			found = found || svc.Label == old
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid --rename %q: the bundle has no service %s", value, old)
+		}
+		renames[old] = new
+	}
+	var oldPrefix, newPrefix string
+	if importLabelPrefix != "" {
+		var err error
+		if oldPrefix, newPrefix, err = splitMapping("label-prefix", importLabelPrefix); err != nil {
+			return nil, err
+		}
+	}
+	return func(label string) string {
+		if new, ok := renames[label]; ok {
+			return new
+		}
+		// The prefix ends at a dot: com.acme does not match com.acmecorp.
+		rest, ok := strings.CutPrefix(label, oldPrefix)
+		if oldPrefix != "" && ok && (rest == "" || rest[0] == '.' || strings.HasSuffix(oldPrefix, ".")) {
+			return newPrefix + rest
+		}
+		return label
+	}, nil
+}
+
+// partialImportError adds to err, which stopped an import, the assets and
+// plists it had already written, so that they can be removed or restored
+// from their backups. None of the services was loaded.
+func partialImportError(err error, assets []jsonImportAsset, written []jsonImportService) error {
+	var done []string
+	for _, a := range assets {
+		if a.Status == "written" {
+			done = append(done, "installed "+a.Path)
+		}
+	}
+	for _, r := range written {
+		if r.Backup != "" {
+			done = append(done, fmt.Sprintf("wrote %s (the replaced plist is saved as %s)", r.PlistPath, r.Backup))
+		} else {
+			done = append(done, "wrote "+r.PlistPath)
+		}
+	}
+	if len(done) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w\nthe import stopped after it had:\n  %s", err, strings.Join(done, "\n  "))
+}
+
+// installAsset writes an embedded asset to its path unless a file is
+// already there. It returns "written", "unchanged" when the file has the
+// asset's content, or "differs".
+func installAsset(asset plist.BundleAsset) (string, error) {
+	path, err := simulatedPath(asset.Path)
+	if err != nil {
+		return "", err
+	}
+	// A simulated asset shadows the installed one.
+	for _, existing := range []string{path, asset.Path} {
+		if _, err := os.Lstat(existing); err == nil {
+			if asset.Matches(existing) {
+				return "unchanged", nil
+			}
+			return "differs", nil
+		}
+	}
+	mode, err := asset.FileMode()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create the directory of %s: %w", asset.Path, err)
+	}
+	if err := os.WriteFile(path, asset.Data, mode); err != nil {
+		return "", fmt.Errorf("failed to install %s: %w", asset.Path, err)
+	}
+	// WriteFile's mode is subject to the umask.
+	if err := os.Chmod(path, mode); err != nil {
+		return "", fmt.Errorf("failed to install %s: %w", asset.Path, err)
+	}
+	return "written", nil
 }
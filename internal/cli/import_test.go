@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// writeBundle writes a bundle of one agent running {{HOME}}/bin/tool, with
+// the tool embedded at assetPath, and returns the bundle's path.
+func writeBundle(t *testing.T, assetPath string) string {
+	t.Helper()
+	dir := t.TempDir()
+	tool := filepath.Join(dir, "tool")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\necho tool\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	pl := &plist.LaunchAgentPlist{Label: "com.example.tool", Program: plist.HomePlaceholder + "/bin/tool"}
+	bundle := plist.NewExportBundle(pl, plist.HomePlaceholder+"/Library/LaunchAgents/com.example.tool.plist", "user", "agent")
+	if err := bundle.AddAsset(assetPath, tool); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "bundle.json")
+	if err := plist.WriteBundleToFile(path, bundle); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// importHome gives the test a home directory to import into, and returns
+// it.
+func importHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	useLaunchctl(t, fakeLaunchctl{})
+	return home
+}
+
+func TestImportAssets(t *testing.T) {
+	home := importHome(t)
+	bundle := writeBundle(t, plist.HomePlaceholder+"/bin/tool")
+	tool := filepath.Join(home, "bin", "tool")
+
+	// Assets are only installed on request.
+	out, err := runLanchr(t, "--json", "import", bundle)
+	if err != nil {
+		t.Fatalf("lanchr import: %v", err)
+	}
+	var result jsonImport
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("failed to parse JSON %q: %v", out, err)
+	}
+	if _, err := os.Stat(tool); err == nil {
+		t.Error("the asset was installed without --assets")
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "use --assets") {
+		t.Errorf("got warnings %q, want a hint to use --assets", result.Warnings)
+	}
+	// A single service is also reported at the top level.
+	wantPlist := filepath.Join(home, "Library", "LaunchAgents", "com.example.tool.plist")
+	if result.Label != "com.example.tool" || result.PlistPath != wantPlist || result.Loaded == nil || *result.Loaded {
+		t.Errorf("got label %q, plist_path %q, loaded %v; want the imported service", result.Label, result.PlistPath, result.Loaded)
+	}
+
+	if _, err := runLanchr(t, "import", "--assets", "--replace", bundle); err != nil {
+		t.Fatalf("lanchr import --assets: %v", err)
+	}
+	if info, err := os.Stat(tool); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("the asset was not installed with its mode: %v, %v", info, err)
+	}
+}
+
+func TestImportRejectsAssets(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"relative", "bin/tool", "not absolute and clean"},
+		{"unclean", plist.HomePlaceholder + "/bin/../../../etc/tool", "not absolute and clean"},
+		{"unreferenced", plist.HomePlaceholder + "/.profile", "not the program or an argument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := importHome(t)
+			bundle := writeBundle(t, tt.path)
+			_, err := runLanchr(t, "import", "--assets", bundle)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+			// Nothing was written.
+			if entries, _ := os.ReadDir(home); len(entries) != 0 {
+				t.Errorf("import wrote %v", entries)
+			}
+		})
+	}
+}
+
+// writeServicesBundle writes a bundle of agents with the given labels and
+// returns its path.
+func writeServicesBundle(t *testing.T, labels ...string) string {
+	t.Helper()
+	bundle := plist.NewBundle()
+	for _, label := range labels {
+		pl := &plist.LaunchAgentPlist{Label: label, Program: "/usr/bin/true"}
+		bundle.AddService(pl, "/Library/LaunchAgents/"+label+".plist", "global", "agent")
+	}
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	if err := plist.WriteBundleToFile(path, bundle); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImportRejectsLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"rename", []string{"--rename", "com.example.a=../../.ssh/rc"}},
+		{"prefix", []string{"--label-prefix", "com.example=com/example"}},
+		{"dots", []string{"--label-prefix", "com.example=.."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := importHome(t)
+			bundle := writeServicesBundle(t, "com.example.a")
+			_, err := runLanchr(t, append([]string{"import", bundle}, tt.args...)...)
+			if err == nil || !strings.Contains(err.Error(), "cannot name a plist file") {
+				t.Fatalf("got error %v, want the label rejected", err)
+			}
+			if entries, _ := os.ReadDir(home); len(entries) != 0 {
+				t.Errorf("import wrote %v", entries)
+			}
+		})
+	}
+}
+
+func TestImportReportsPartialWrite(t *testing.T) {
+	home := importHome(t)
+	agents := filepath.Join(home, "Library", "LaunchAgents")
+	// The second plist cannot be written: a directory is in its way.
+	if err := os.MkdirAll(filepath.Join(agents, "com.example.b.plist", "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := writeServicesBundle(t, "com.example.a", "com.example.b")
+	_, err := runLanchr(t, "import", "--replace", bundle)
+	if err == nil {
+		t.Fatal("expected the import to fail, got nil")
+	}
+	written := filepath.Join(agents, "com.example.a.plist")
+	if !strings.Contains(err.Error(), "the import stopped after it had") || !strings.Contains(err.Error(), "wrote "+written) {
+		t.Errorf("got error %v, want it to list %s", err, written)
+	}
+}
+
+func TestImportVerify(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "signing.key")
+	if _, err := plist.GenerateSigningKey(keyPath, "ops"); err != nil {
+		t.Fatal(err)
+	}
+	key, err := plist.ReadSigningKey(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sign signs the bundle at path, applying tamper to it afterwards.
+	sign := func(path string, tamper func(b *plist.ExportBundle)) {
+		t.Helper()
+		b, err := plist.ReadBundleFromFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+		tamper(b)
+		if err := plist.WriteBundleToFile(path, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A bundle changed after signing, with a checksum to match, is
+	// refused before anything is written.
+	home := importHome(t)
+	bundle := writeBundle(t, plist.HomePlaceholder+"/bin/tool")
+	sign(bundle, func(b *plist.ExportBundle) {
+		b.Assets[0].Data = []byte("#!/bin/sh\necho evil\n")
+		sum := sha256.Sum256(b.Assets[0].Data)
+		b.Assets[0].SHA256 = hex.EncodeToString(sum[:])
+	})
+	_, err = runLanchr(t, "import", "--assets", "--verify", keyPath+".pub", bundle)
+	if err == nil || !strings.Contains(err.Error(), "refusing to import") {
+		t.Fatalf("got error %v, want the bundle refused", err)
+	}
+	if entries, _ := os.ReadDir(home); len(entries) != 0 {
+		t.Errorf("import wrote %v", entries)
+	}
+
+	// The untouched bundle is imported.
+	bundle = writeBundle(t, plist.HomePlaceholder+"/bin/tool")
+	sign(bundle, func(*plist.ExportBundle) {})
+	out, err := runLanchr(t, "--json", "import", "--assets", "--verify", keyPath+".pub", bundle)
+	if err != nil {
+		t.Fatalf("lanchr import --verify: %v", err)
+	}
+	var result jsonImport
+	if err := json.Unmarshal([]byte(out), &result); err != nil || result.SignedBy != "ops" {
+		t.Errorf("got %+v, %v; want a bundle signed by ops", result, err)
+	}
+	if _, err := os.Stat(filepath.Join(home, "bin", "tool")); err != nil {
+		t.Errorf("the asset was not installed: %v", err)
+	}
+}
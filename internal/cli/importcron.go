@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/lu-zhengda/lanchr/internal/cron"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+var (
+	importCronPrefix    string
+	importCronOutputDir string
+	importCronLogDir    string
+	importCronDryRun    bool
+	importCronLoad      bool
+)
+
+var importCronCmd = &cobra.Command{
+	Use:   "import-cron [file|-]",
+	Short: "Convert crontab entries into launch agents",
+	Long: `Read a user crontab, as "crontab -l" prints it, from a file or standard input
+and create one launch agent per entry.
+
+Each agent runs its command through $SHELL -c (/bin/sh by default) on the
+entry's schedule, expanded into StartCalendarInterval entries; @reboot becomes
+RunAtLoad. Variables set in the crontab become the environment of the entries
+below them. launchd does not mail output, so it is logged instead; with
+MAILTO="" it is discarded, as cron does.
+
+Labels are <prefix>.<command name>, numbered when names repeat. Nothing is
+written if any line cannot be converted or any plist already exists.`,
+	Example: `  crontab -l | lanchr import-cron --dry-run
+  lanchr import-cron my.crontab --log-dir ~/Library/Logs/cron --load`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var in io.Reader = os.Stdin
+		source := "standard input"
+		if len(args) == 1 && args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to read crontab: %w", err)
+			}
+			defer f.Close()
+			in, source = f, args[0]
+		}
+
+		entries, err := cron.ParseCrontab(in)
+		if err != nil {
+			return fmt.Errorf("failed to parse crontab:\n%w", err)
+		}
+		if len(entries) == 0 {
+			return fmt.Errorf("no cron jobs found in %s", source)
+		}
+
+		outputDir := importCronOutputDir
+		if outputDir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("failed to get home directory: %w", err)
+			}
+			outputDir = filepath.Join(home, "Library", "LaunchAgents")
+		}
+
+		jobs, warnings := cronJobs(entries, outputDir)
+		for _, job := range jobs {
+			if _, err := os.Stat(job.path); err == nil {
+				return fmt.Errorf("plist already exists at %s; remove it first or use a different --prefix", job.path)
+			}
+		}
+
+		if !importCronDryRun {
+			// With --simulate, the plists go to the simulation's scratch
+			// tree.
+			dir, err := simulatedPath(outputDir)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", dir, err)
+			}
+			writer := plist.NewWriter()
+			for i, job := range jobs {
+				if jobs[i].path, err = simulatedPath(job.path); err != nil {
+					return err
+				}
+				if err := writer.Write(job.plist, jobs[i].path); err != nil {
+					return fmt.Errorf("line %d: failed to write plist: %w", job.entry.Line, err)
+				}
+			}
+		}
+
+		var loadErrs int
+		if importCronLoad && !importCronDryRun {
+			_, manager, _ := buildDeps()
+			for i := range jobs {
+				if err := manager.Load(jobs[i].path); err != nil {
+					warnings = append(warnings, fmt.Sprintf("failed to load %s: %v", jobs[i].plist.Label, err))
+					loadErrs++
+					continue
+				}
+				jobs[i].loaded = true
+			}
+		}
+
+		if jsonFlag {
+			if err := printJSON(toJSONImportCron(jobs, warnings)); err != nil {
+				return err
+			}
+		} else {
+			verb := "Created"
+			if importCronDryRun {
+				verb = "Would create"
+			}
+			for _, job := range jobs {
+				fmt.Printf("%s %s (line %d: %s)\n", verb, job.path, job.entry.Line, job.entry.Schedule)
+				if job.loaded {
+					fmt.Printf("Loaded %s\n", job.plist.Label)
+				}
+			}
+			for _, w := range warnings {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+			}
+			if !importCronDryRun {
+				fmt.Println("Remove the imported entries with \"crontab -e\" so that they do not run twice.")
+			}
+		}
+
+		if loadErrs > 0 {
+			return fmt.Errorf("failed to load %d %s", loadErrs, plural(loadErrs, "agent", "agents"))
+		}
+		return nil
+	},
+}
+
+func init() {
+	importCronCmd.Flags().StringVar(&importCronPrefix, "prefix", "com.lanchr.cron", "Label prefix")
+	importCronCmd.Flags().StringVarP(&importCronOutputDir, "output-dir", "o", "", "Directory for the plists (default: ~/Library/LaunchAgents)")
+	importCronCmd.Flags().StringVar(&importCronLogDir, "log-dir", "", "Log to <dir>/<label>.out.log and .err.log (default: /tmp/<label>.stdout.log and .stderr.log)")
+	importCronCmd.Flags().BoolVar(&importCronDryRun, "dry-run", false, "Show the agents that would be created without writing them")
+	importCronCmd.Flags().BoolVar(&importCronLoad, "load", false, "Bootstrap the agents after creation")
+
+	// Writing plists alone needs no launchd.
+	localCommands["import-cron"] = func([]string) bool { return !importCronLoad }
+}
+
+// cronJob is a crontab entry converted into a launch agent.
+type cronJob struct {
+	entry  cron.Entry
+	plist  *plist.LaunchAgentPlist
+	path   string
+	loaded bool
+}
+
+// cronJobs converts crontab entries into launch agents in outputDir. It
+// also returns warnings about cron behavior that launchd does not have.
+func cronJobs(entries []cron.Entry, outputDir string) ([]cronJob, []string) {
+	var (
+		jobs     []cronJob
+		warnings []string
+		used     = make(map[string]int)
+		mailed   = make(map[string]bool)
+	)
+	for _, e := range entries {
+		name := cronJobName(e.Command)
+		used[name]++
+		if used[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, used[name])
+		}
+		label := importCronPrefix + "." + name
+
+		pl := e.Plist(label)
+		if importCronLogDir != "" && pl.StandardOutPath == "" {
+			pl.StandardOutPath = filepath.Join(importCronLogDir, label+".out.log")
+			pl.StandardErrorPath = filepath.Join(importCronLogDir, label+".err.log")
+		}
+		plist.ApplyDefaults(pl)
+
+		if to := e.Env["MAILTO"]; to != "" && !mailed[to] {
+			mailed[to] = true
+			warnings = append(warnings, fmt.Sprintf("launchd does not mail job output; output cron mailed to %s is logged instead", to))
+		}
+		jobs = append(jobs, cronJob{entry: e, plist: pl, path: filepath.Join(outputDir, label+".plist")})
+	}
+	return jobs, warnings
+}
+
+// cronJobName derives a label component from the name of the command's
+// program: "/usr/local/bin/backup.sh --all" becomes "backup".
+func cronJobName(command string) string {
+	fields := strings.Fields(command)
+	for len(fields) > 0 && strings.Contains(fields[0], "=") {
+		// Skip leading VAR=value assignments.
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return "job"
+	}
+	base := filepath.Base(fields[0])
+	if ext := filepath.Ext(base); ext != "" && ext != base {
+		base = strings.TrimSuffix(base, ext)
+	}
+	if name := labelComponent(base); name != "" {
+		return name
+	}
+	return "job"
+}
+
+// labelComponent lowercases s and replaces everything but letters, digits
+// and dashes with dashes, for use between the dots of a label.
+func labelComponent(s string) string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '-'
+	}, s), "-")
+}
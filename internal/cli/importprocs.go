@@ -0,0 +1,331 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/lu-zhengda/lanchr/internal/envfile"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+	"github.com/lu-zhengda/lanchr/internal/procfile"
+	"github.com/lu-zhengda/lanchr/internal/supervisord"
+)
+
+var (
+	importProcPrefix    string
+	importProcOutputDir string
+	importProcLogDir    string
+	importProcEnvFile   string
+	importProcDryRun    bool
+	importProcLoad      bool
+
+	importProcfileAutorestart string
+	importProcfilePort        int
+)
+
+var importProcfileCmd = &cobra.Command{
+	Use:   "import-procfile [Procfile]",
+	Short: "Convert the processes of a Procfile into launch agents",
+	Long: `Read a Procfile ("name: command" lines, as used by foreman and Heroku) and
+create one launch agent per process.
+
+Each agent runs its command through /bin/sh -c in the Procfile's directory and
+starts at load. The directory's .env file, or the one given with --env-file,
+becomes the environment, and PORT is set as foreman sets it: --port for the
+first process, plus 100 for each one after it. PATH is the current one unless
+the env file sets it, since launchd's default PATH is minimal. --autorestart
+maps onto KeepAlive as supervisord's option of that name does.
+
+Labels are <prefix>.<process name>, with the prefix defaulting to
+com.lanchr.<directory name>. Nothing is written if any plist already exists.`,
+	Example: `  lanchr import-procfile --dry-run
+  lanchr import-procfile ~/src/shop/Procfile --prefix com.me.shop --log-dir ~/Library/Logs/shop --load`,
+	Args:              cobra.MaximumNArgs(1),
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := "Procfile"
+		if len(args) == 1 {
+			path = args[0]
+		}
+		procs, err := procfile.Read(path)
+		if err != nil {
+			return err
+		}
+		if len(procs) == 0 {
+			return fmt.Errorf("no processes found in %s", path)
+		}
+		keepAlive, err := supervisord.KeepAlive(importProcfileAutorestart)
+		if err != nil {
+			return fmt.Errorf("invalid --autorestart: %w", err)
+		}
+		dir, env, err := importProcEnv(path)
+		if err != nil {
+			return err
+		}
+
+		prefix := importProcPrefixFor(dir)
+		var jobs []procJob
+		for i, p := range procs {
+			procEnv := make(map[string]string, len(env)+1)
+			for k, v := range env {
+				procEnv[k] = v
+			}
+			if _, ok := procEnv["PORT"]; !ok {
+				procEnv["PORT"] = strconv.Itoa(importProcfilePort + 100*i)
+			}
+			label := prefix + "." + procLabelComponent(p.Name)
+			pl := p.Plist(label, dir, procEnv)
+			pl.KeepAlive = keepAlive
+			applyProcLogs(pl)
+			jobs = append(jobs, procJob{
+				name:    p.Name,
+				source:  fmt.Sprintf("%s:%d", path, p.Line),
+				command: p.Command,
+				plist:   pl,
+			})
+		}
+		return runProcImport("import-procfile", jobs, nil)
+	},
+}
+
+var importSupervisordCmd = &cobra.Command{
+	Use:   "import-supervisord [supervisord.conf]",
+	Short: "Convert the programs of a supervisord config into launch agents",
+	Long: `Read a supervisord configuration, with the files its [include] section names,
+and create one launch agent per [program:x] process; numprocs creates several.
+
+command runs directly, as supervisord runs it, with a bare program name
+resolved on PATH now. directory becomes WorkingDirectory, defaulting to the
+configuration's directory; autostart becomes RunAtLoad; autorestart becomes
+KeepAlive (true: always, unexpected: after a failing exit); stopwaitsecs
+becomes ExitTimeOut; umask, stdout_logfile, stderr_logfile and
+redirect_stderr carry over. The directory's .env file, or --env-file, is the
+base environment, overridden by the environment options of [supervisord] and
+the program. Options launchd cannot express are reported as warnings.
+
+Labels are <prefix>.<process name>, with the prefix defaulting to
+com.lanchr.<directory name>. Nothing is written if any program cannot be
+converted or any plist already exists.`,
+	Example: `  lanchr import-supervisord /etc/supervisor/supervisord.conf --dry-run
+  lanchr import-supervisord deploy/supervisord.conf --prefix com.me.app --load`,
+	Args:              cobra.MaximumNArgs(1),
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := "supervisord.conf"
+		if len(args) == 1 {
+			path = args[0]
+		}
+		cfg, err := supervisord.Read(path)
+		if err != nil {
+			return err
+		}
+		if len(cfg.Programs) == 0 {
+			return fmt.Errorf("no [program:x] sections found in %s", path)
+		}
+		dir, env, err := importProcEnv(path)
+		if err != nil {
+			return err
+		}
+
+		prefix := importProcPrefixFor(dir)
+		var jobs []procJob
+		for _, p := range cfg.Programs {
+			label := prefix + "." + procLabelComponent(p.Name)
+			pl, err := p.Plist(label, dir, env)
+			if err != nil {
+				return fmt.Errorf("%s:%d: [program:%s]: %w", p.File, p.Line, p.Program, err)
+			}
+			applyProcLogs(pl)
+			if p.RedirectStderr {
+				pl.StandardErrorPath = pl.StandardOutPath
+			}
+			jobs = append(jobs, procJob{
+				name:    p.Name,
+				source:  fmt.Sprintf("%s:%d", p.File, p.Line),
+				command: p.Command,
+				plist:   pl,
+			})
+		}
+		var warnings []string
+		for _, w := range cfg.Warnings {
+			warnings = append(warnings, w.String())
+		}
+		return runProcImport("import-supervisord", jobs, warnings)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{importProcfileCmd, importSupervisordCmd} {
+		cmd.Flags().StringVar(&importProcPrefix, "prefix", "", "Label prefix (default: com.lanchr.<directory name>)")
+		cmd.Flags().StringVarP(&importProcOutputDir, "output-dir", "o", "", "Directory for the plists (default: ~/Library/LaunchAgents)")
+		cmd.Flags().StringVar(&importProcLogDir, "log-dir", "", "Log to <dir>/<label>.out.log and .err.log (default: /tmp/<label>.stdout.log and .stderr.log)")
+		cmd.Flags().StringVar(&importProcEnvFile, "env-file", "", "Environment file (default: .env next to the config, if any)")
+		cmd.Flags().BoolVar(&importProcDryRun, "dry-run", false, "Show the agents that would be created without writing them")
+		cmd.Flags().BoolVar(&importProcLoad, "load", false, "Bootstrap the agents after creation")
+	}
+	// Writing plists alone needs no launchd.
+	localCommands["import-procfile"] = func([]string) bool { return !importProcLoad }
+	localCommands["import-supervisord"] = func([]string) bool { return !importProcLoad }
+	importProcfileCmd.Flags().StringVar(&importProcfileAutorestart, "autorestart", "unexpected", "Restart processes: true (always), unexpected (after a failing exit) or false")
+	importProcfileCmd.Flags().IntVar(&importProcfilePort, "port", 5000, "PORT of the first process, unless the env file sets PORT")
+}
+
+// procJob is a Procfile or supervisord process converted into a launch
+// agent.
+type procJob struct {
+	name string
+	// source is the "file:line" the process is defined at.
+	source  string
+	command string
+	plist   *plist.LaunchAgentPlist
+	path    string
+	loaded  bool
+}
+
+// importProcEnv returns the absolute directory of the config at path and
+// the environment for its processes: the env file, with PATH defaulting to
+// the current one.
+func importProcEnv(path string) (string, map[string]string, error) {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	envPath := importProcEnvFile
+	if envPath == "" && isFile(filepath.Join(dir, ".env")) {
+		envPath = filepath.Join(dir, ".env")
+	}
+	env := make(map[string]string)
+	if envPath != "" {
+		if env, err = envfile.Read(envPath); err != nil {
+			return "", nil, err
+		}
+	}
+	if _, ok := env["PATH"]; !ok && os.Getenv("PATH") != "" {
+		env["PATH"] = os.Getenv("PATH")
+	}
+	return dir, env, nil
+}
+
+// importProcPrefixFor returns --prefix, or com.lanchr.<name of dir>.
+func importProcPrefixFor(dir string) string {
+	if importProcPrefix != "" {
+		return importProcPrefix
+	}
+	if name := labelComponent(filepath.Base(dir)); name != "" {
+		return "com.lanchr." + name
+	}
+	return "com.lanchr.app"
+}
+
+// procLabelComponent turns a process name into a label component.
+func procLabelComponent(name string) string {
+	if c := labelComponent(name); c != "" {
+		return c
+	}
+	return "process"
+}
+
+// applyProcLogs gives a job without log paths its own, in --log-dir or
+// under /tmp.
+func applyProcLogs(pl *plist.LaunchAgentPlist) {
+	if importProcLogDir != "" && pl.StandardOutPath == "" {
+		pl.StandardOutPath = filepath.Join(importProcLogDir, pl.Label+".out.log")
+		pl.StandardErrorPath = filepath.Join(importProcLogDir, pl.Label+".err.log")
+	}
+	plist.ApplyDefaults(pl)
+}
+
+// runProcImport writes, and with --load bootstraps, the agents of an
+// import, then reports them. Nothing is written if two processes map to
+// one label or any plist already exists.
+func runProcImport(action string, jobs []procJob, warnings []string) error {
+	outputDir := importProcOutputDir
+	if outputDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to get home directory: %w", err)
+		}
+		outputDir = filepath.Join(home, "Library", "LaunchAgents")
+	}
+
+	labels := make(map[string]string)
+	for i := range jobs {
+		label := jobs[i].plist.Label
+		if prev, ok := labels[label]; ok {
+			return fmt.Errorf("%s and %s both map to the label %s; rename one", prev, jobs[i].source, label)
+		}
+		labels[label] = jobs[i].source
+		jobs[i].path = filepath.Join(outputDir, label+".plist")
+		if _, err := os.Stat(jobs[i].path); err == nil {
+			return fmt.Errorf("plist already exists at %s; remove it first or use a different --prefix", jobs[i].path)
+		}
+	}
+
+	if !importProcDryRun {
+		// With --simulate, the plists and log directory go to the
+		// simulation's scratch tree.
+		for _, dir := range []string{outputDir, importProcLogDir} {
+			if dir == "" {
+				continue
+			}
+			dir, err := simulatedPath(dir)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", dir, err)
+			}
+		}
+		writer := plist.NewWriter()
+		for i, job := range jobs {
+			path, err := simulatedPath(job.path)
+			if err != nil {
+				return err
+			}
+			jobs[i].path = path
+			if err := writer.Write(job.plist, path); err != nil {
+				return fmt.Errorf("%s: failed to write plist: %w", job.source, err)
+			}
+		}
+	}
+
+	var loadErrs int
+	if importProcLoad && !importProcDryRun {
+		_, manager, _ := buildDeps()
+		for i := range jobs {
+			if err := manager.Load(jobs[i].path); err != nil {
+				warnings = append(warnings, fmt.Sprintf("failed to load %s: %v", jobs[i].plist.Label, err))
+				loadErrs++
+				continue
+			}
+			jobs[i].loaded = true
+		}
+	}
+
+	if jsonFlag {
+		if err := printJSON(toJSONImportProcs(action, jobs, warnings)); err != nil {
+			return err
+		}
+	} else {
+		verb := "Created"
+		if importProcDryRun {
+			verb = "Would create"
+		}
+		for _, job := range jobs {
+			fmt.Printf("%s %s (%s: %s)\n", verb, job.path, job.source, job.name)
+			if job.loaded {
+				fmt.Printf("Loaded %s\n", job.plist.Label)
+			}
+		}
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+	}
+
+	if loadErrs > 0 {
+		return fmt.Errorf("failed to load %d %s", loadErrs, plural(loadErrs, "agent", "agents"))
+	}
+	return nil
+}
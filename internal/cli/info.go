@@ -2,9 +2,13 @@ package cli
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/lu-zhengda/lanchr/internal/agent"
+	"github.com/lu-zhengda/lanchr/internal/launchctl"
+	"github.com/lu-zhengda/lanchr/internal/platform"
 )
 
 var infoCmd = &cobra.Command{
@@ -34,7 +38,9 @@ var infoCmd = &cobra.Command{
 		printField("Type", svc.Type.String())
 		printField("State", svc.Status.String())
 
-		if svc.PID > 0 {
+		if svc.Status == agent.StatusUnknown {
+			printField("PID", "n/a (offline)")
+		} else if svc.PID > 0 {
 			printField("PID", fmt.Sprintf("%d", svc.PID))
 		} else {
 			printField("PID", "-")
@@ -44,8 +50,28 @@ var infoCmd = &cobra.Command{
 			printField("Plist Path", svc.PlistPath)
 		}
 
+		if svc.ParseError != nil {
+			printField("Parse Error", svc.ParseError.Error())
+		}
+
+		if svc.PlistDangling {
+			printField("Symlink Target", svc.PlistTarget+" (missing)")
+		} else if svc.PlistTarget != "" {
+			printField("Symlink Target", svc.PlistTarget)
+		}
+
+		if svc.Origin != platform.OriginLaunchDir {
+			printField("Origin", svc.Origin.String())
+		}
+
+		if svc.AppBundle != "" {
+			printField("App Bundle", svc.AppBundle)
+		}
+
 		if svc.Program != "" {
 			printField("Program", svc.Program)
+		} else if svc.BundleProgram != "" {
+			printField("Bundle Program", svc.BundleProgram)
 		}
 
 		if len(svc.ProgramArgs) > 0 {
@@ -57,7 +83,7 @@ var infoCmd = &cobra.Command{
 		printField("Run At Load", fmt.Sprintf("%v", svc.RunAtLoad))
 
 		if svc.KeepAlive != nil {
-			printField("Keep Alive", fmt.Sprintf("%v", svc.KeepAlive))
+			printField("Keep Alive", svc.KeepAliveSummary())
 		} else {
 			printField("Keep Alive", "(none)")
 		}
@@ -68,6 +94,26 @@ var infoCmd = &cobra.Command{
 			printField("Start Interval", "(none)")
 		}
 
+		if len(svc.CalendarInterval) > 0 {
+			var parts []string
+			for _, ci := range svc.CalendarInterval {
+				parts = append(parts, ci.String())
+			}
+			printField("Calendar Interval", strings.Join(parts, "; "))
+
+			next := svc.NextRuns(3)
+			if len(next) == 0 {
+				printField("Next Run", "(never)")
+			}
+			for i, t := range next {
+				if i == 0 {
+					printField("Next Run", t.Format("Mon 2006-01-02 15:04 MST"))
+				} else {
+					fmt.Printf("%-22s %s\n", "", t.Format("Mon 2006-01-02 15:04 MST"))
+				}
+			}
+		}
+
 		if len(svc.WatchPaths) > 0 {
 			printField("Watch Paths", strings.Join(svc.WatchPaths, ", "))
 		} else {
@@ -111,6 +157,75 @@ var infoCmd = &cobra.Command{
 			printField("Blame", svc.BlameLine)
 		}
 
+		if svc.Runtime != nil {
+			printRuntime(svc.Runtime, printField)
+		}
+
 		return nil
 	},
 }
+
+// printRuntime prints the parsed "launchctl print" state for a service.
+func printRuntime(rt *launchctl.ServiceInfo, printField func(name, value string)) {
+	fmt.Println()
+	fmt.Println("RUNTIME (launchctl print)")
+
+	if rt.State != "" {
+		printField("Launchd State", rt.State)
+	}
+	if rt.Domain != "" {
+		printField("Launchd Domain", rt.Domain)
+	}
+	if rt.SpawnType != "" {
+		printField("Spawn Type", rt.SpawnType)
+	}
+	if len(rt.Properties) > 0 {
+		printField("Properties", strings.Join(rt.Properties, ", "))
+	}
+	printField("Runs", fmt.Sprintf("%d", rt.Runs))
+	printField("Forks / Execs", fmt.Sprintf("%d / %d", rt.Forks, rt.Execs))
+	if rt.LastExitCode != "" {
+		printField("Last Exit", rt.LastExitCode)
+	}
+	if rt.LastExitReason != "" {
+		printField("Last Exit Reason", rt.LastExitReason)
+	}
+	if rt.LastTerminatingSignal != "" {
+		printField("Last Signal", rt.LastTerminatingSignal)
+	}
+
+	printStringMap("Environment", rt.Environment)
+	printStringMap("Default Environment", rt.DefaultEnvironment)
+	printStringMap("Inherited Environment", rt.InheritedEnvironment)
+
+	for _, ep := range rt.Endpoints {
+		printStringMap("Endpoint "+ep.Name, ep.Attributes)
+	}
+	for _, sock := range rt.Sockets {
+		printStringMap("Socket "+sock.Name, sock.Attributes)
+	}
+	for _, tr := range rt.EventTriggers {
+		name := "Event Trigger " + tr.Name
+		if tr.Stream != "" {
+			name += " (" + tr.Stream + ")"
+		}
+		printStringMap(name, tr.Descriptor)
+	}
+}
+
+// printStringMap prints a titled block of key/value pairs in sorted order.
+func printStringMap(title string, m map[string]string) {
+	if len(m) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("%s:\n", title)
+	for _, k := range keys {
+		fmt.Printf("  %s = %s\n", k, m[k])
+	}
+}
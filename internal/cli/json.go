@@ -1,6 +1,14 @@
 package cli
 
-import "github.com/lu-zhengda/lanchr/internal/agent"
+import (
+	"time"
+
+	"github.com/lu-zhengda/lanchr/internal/agent"
+	"github.com/lu-zhengda/lanchr/internal/launchctl"
+	"github.com/lu-zhengda/lanchr/internal/manifest"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+	"github.com/lu-zhengda/lanchr/internal/systemd"
+)
 
 // ---------------------------------------------------------------------------
 // Service JSON types (list, search, info)
@@ -16,6 +24,8 @@ type jsonService struct {
 	LastExitStatus int    `json:"last_exit_status"`
 	PlistPath      string `json:"plist_path,omitempty"`
 	Program        string `json:"program,omitempty"`
+	Origin         string `json:"origin"`
+	AppBundle      string `json:"app_bundle,omitempty"`
 }
 
 // toJSONServices converts a slice of agent.Service to JSON-serializable form.
@@ -31,6 +41,8 @@ func toJSONServices(services []agent.Service) []jsonService {
 			LastExitStatus: svc.LastExitStatus,
 			PlistPath:      svc.PlistPath,
 			Program:        svc.BinaryPath(),
+			Origin:         svc.Origin.String(),
+			AppBundle:      svc.AppBundle,
 		})
 	}
 	return out
@@ -45,11 +57,19 @@ type jsonServiceDetail struct {
 	PID               int               `json:"pid"`
 	LastExitStatus    int               `json:"last_exit_status"`
 	PlistPath         string            `json:"plist_path,omitempty"`
+	PlistTarget       string            `json:"plist_target,omitempty"`
+	PlistDangling     bool              `json:"plist_dangling,omitempty"`
+	ParseError        string            `json:"parse_error,omitempty"`
+	Origin            string            `json:"origin"`
+	AppBundle         string            `json:"app_bundle,omitempty"`
 	Program           string            `json:"program,omitempty"`
 	ProgramArgs       []string          `json:"program_args,omitempty"`
 	RunAtLoad         bool              `json:"run_at_load"`
-	KeepAlive         interface{}       `json:"keep_alive"`
+	KeepAlive         interface{}       `json:"keep_alive"` // bool or *jsonKeepAlive
+	KeepAliveSummary  string            `json:"keep_alive_summary,omitempty"`
 	StartInterval     int               `json:"start_interval,omitempty"`
+	CalendarIntervals []jsonCalendar    `json:"calendar_intervals,omitempty"`
+	NextRuns          []string          `json:"next_runs,omitempty"`
 	WatchPaths        []string          `json:"watch_paths,omitempty"`
 	QueueDirectories  []string          `json:"queue_directories,omitempty"`
 	WorkingDirectory  string            `json:"working_directory,omitempty"`
@@ -59,6 +79,168 @@ type jsonServiceDetail struct {
 	ExitTimeout       int               `json:"exit_timeout,omitempty"`
 	Disabled          bool              `json:"disabled"`
 	BlameLine         string            `json:"blame,omitempty"`
+	Runtime           *jsonRuntime      `json:"runtime,omitempty"`
+}
+
+// jsonKeepAlive is the dictionary form of KeepAlive.
+type jsonKeepAlive struct {
+	SuccessfulExit     *bool           `json:"successful_exit,omitempty"`
+	Crashed            *bool           `json:"crashed,omitempty"`
+	NetworkState       *bool           `json:"network_state,omitempty"`
+	PathState          map[string]bool `json:"path_state,omitempty"`
+	OtherJobEnabled    map[string]bool `json:"other_job_enabled,omitempty"`
+	OtherJobActive     map[string]bool `json:"other_job_active,omitempty"`
+	AfterInitialDemand *bool           `json:"after_initial_demand,omitempty"`
+}
+
+// toJSONKeepAlive passes booleans through and converts conditions to
+// jsonKeepAlive.
+func toJSONKeepAlive(v interface{}) interface{} {
+	kc, ok := v.(agent.KeepAliveConditions)
+	if !ok {
+		return v
+	}
+	return &jsonKeepAlive{
+		SuccessfulExit:     kc.SuccessfulExit,
+		Crashed:            kc.Crashed,
+		NetworkState:       kc.NetworkState,
+		PathState:          kc.PathState,
+		OtherJobEnabled:    kc.OtherJobEnabled,
+		OtherJobActive:     kc.OtherJobActive,
+		AfterInitialDemand: kc.AfterInitialDemand,
+	}
+}
+
+// jsonCalendar is one StartCalendarInterval entry; omitted fields are
+// wildcards.
+type jsonCalendar struct {
+	Minute  *int `json:"minute,omitempty"`
+	Hour    *int `json:"hour,omitempty"`
+	Day     *int `json:"day,omitempty"`
+	Weekday *int `json:"weekday,omitempty"`
+	Month   *int `json:"month,omitempty"`
+}
+
+// toJSONCalendar converts calendar intervals to their JSON form.
+func toJSONCalendar(intervals []agent.CalendarInterval) []jsonCalendar {
+	var out []jsonCalendar
+	for _, ci := range intervals {
+		out = append(out, jsonCalendar{
+			Minute:  ci.Minute,
+			Hour:    ci.Hour,
+			Day:     ci.Day,
+			Weekday: ci.Weekday,
+			Month:   ci.Month,
+		})
+	}
+	return out
+}
+
+// toJSONTimes formats times as RFC 3339.
+func toJSONTimes(times []time.Time) []string {
+	var out []string
+	for _, t := range times {
+		out = append(out, t.Format(time.RFC3339))
+	}
+	return out
+}
+
+// jsonRuntime is the live launchd state from "launchctl print".
+type jsonRuntime struct {
+	State                 string             `json:"state,omitempty"`
+	PID                   int                `json:"pid"`
+	Path                  string             `json:"path,omitempty"`
+	BundleID              string             `json:"bundle_id,omitempty"`
+	Program               string             `json:"program,omitempty"`
+	Arguments             []string           `json:"arguments,omitempty"`
+	Type                  string             `json:"type,omitempty"`
+	Domain                string             `json:"domain,omitempty"`
+	SpawnType             string             `json:"spawn_type,omitempty"`
+	Properties            []string           `json:"properties,omitempty"`
+	Runs                  int                `json:"runs"`
+	Forks                 int                `json:"forks"`
+	Execs                 int                `json:"execs"`
+	LastExitCode          string             `json:"last_exit_code,omitempty"`
+	LastExitReason        string             `json:"last_exit_reason,omitempty"`
+	LastTerminatingSignal string             `json:"last_terminating_signal,omitempty"`
+	ExitTimeout           int                `json:"exit_timeout,omitempty"`
+	WorkingDirectory      string             `json:"working_directory,omitempty"`
+	StdoutPath            string             `json:"stdout_path,omitempty"`
+	StderrPath            string             `json:"stderr_path,omitempty"`
+	Environment           map[string]string  `json:"environment,omitempty"`
+	DefaultEnvironment    map[string]string  `json:"default_environment,omitempty"`
+	InheritedEnvironment  map[string]string  `json:"inherited_environment,omitempty"`
+	Endpoints             []jsonEndpoint     `json:"endpoints,omitempty"`
+	Sockets               []jsonEndpoint     `json:"sockets,omitempty"`
+	EventTriggers         []jsonEventTrigger `json:"event_triggers,omitempty"`
+}
+
+type jsonEndpoint struct {
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+type jsonEventTrigger struct {
+	Name       string            `json:"name"`
+	Stream     string            `json:"stream,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Descriptor map[string]string `json:"descriptor,omitempty"`
+}
+
+// toJSONRuntime converts parsed launchctl print output to its JSON form.
+func toJSONRuntime(info *launchctl.ServiceInfo) *jsonRuntime {
+	if info == nil {
+		return nil
+	}
+
+	rt := &jsonRuntime{
+		State:                 info.State,
+		PID:                   info.PID,
+		Path:                  info.Path,
+		BundleID:              info.BundleID,
+		Program:               info.Program,
+		Arguments:             info.Arguments,
+		Type:                  info.Type,
+		Domain:                info.Domain,
+		SpawnType:             info.SpawnType,
+		Properties:            info.Properties,
+		Runs:                  info.Runs,
+		Forks:                 info.Forks,
+		Execs:                 info.Execs,
+		LastExitCode:          info.LastExitCode,
+		LastExitReason:        info.LastExitReason,
+		LastTerminatingSignal: info.LastTerminatingSignal,
+		ExitTimeout:           info.ExitTimeout,
+		WorkingDirectory:      info.WorkingDirectory,
+		StdoutPath:            info.StdoutPath,
+		StderrPath:            info.StderrPath,
+		Environment:           info.Environment,
+		DefaultEnvironment:    info.DefaultEnvironment,
+		InheritedEnvironment:  info.InheritedEnvironment,
+	}
+	for _, ep := range info.Endpoints {
+		rt.Endpoints = append(rt.Endpoints, jsonEndpoint{Name: ep.Name, Attributes: ep.Attributes})
+	}
+	for _, sock := range info.Sockets {
+		rt.Sockets = append(rt.Sockets, jsonEndpoint{Name: sock.Name, Attributes: sock.Attributes})
+	}
+	for _, tr := range info.EventTriggers {
+		rt.EventTriggers = append(rt.EventTriggers, jsonEventTrigger{
+			Name:       tr.Name,
+			Stream:     tr.Stream,
+			Attributes: tr.Attributes,
+			Descriptor: tr.Descriptor,
+		})
+	}
+	return rt
+}
+
+// errorString returns err's message, or "" for nil.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
 }
 
 // toJSONServiceDetail converts an agent.Service to its full JSON representation.
@@ -71,11 +253,19 @@ func toJSONServiceDetail(svc *agent.Service) jsonServiceDetail {
 		PID:               svc.PID,
 		LastExitStatus:    svc.LastExitStatus,
 		PlistPath:         svc.PlistPath,
+		PlistTarget:       svc.PlistTarget,
+		PlistDangling:     svc.PlistDangling,
+		ParseError:        errorString(svc.ParseError),
+		Origin:            svc.Origin.String(),
+		AppBundle:         svc.AppBundle,
 		Program:           svc.BinaryPath(),
 		ProgramArgs:       svc.ProgramArgs,
 		RunAtLoad:         svc.RunAtLoad,
-		KeepAlive:         svc.KeepAlive,
+		KeepAlive:         toJSONKeepAlive(svc.KeepAlive),
+		KeepAliveSummary:  svc.KeepAliveSummary(),
 		StartInterval:     svc.StartInterval,
+		CalendarIntervals: toJSONCalendar(svc.CalendarInterval),
+		NextRuns:          toJSONTimes(svc.NextRuns(5)),
 		WatchPaths:        svc.WatchPaths,
 		QueueDirectories:  svc.QueueDirectories,
 		WorkingDirectory:  svc.WorkingDirectory,
@@ -85,6 +275,7 @@ func toJSONServiceDetail(svc *agent.Service) jsonServiceDetail {
 		ExitTimeout:       svc.ExitTimeout,
 		Disabled:          svc.Disabled,
 		BlameLine:         svc.BlameLine,
+		Runtime:           toJSONRuntime(svc.Runtime),
 	}
 }
 
@@ -159,15 +350,216 @@ type jsonCreate struct {
 }
 
 // ---------------------------------------------------------------------------
-// Import JSON type
+// Import JSON types
 // ---------------------------------------------------------------------------
 
+// jsonImport reports an import. SignedBy names the trusted key that
+// signed the bundle, when --verify checked it.
+// jsonImport reports an import. Label, PlistPath and Loaded repeat the
+// service of a single-service bundle, as import reported before bundles
+// held several.
 type jsonImport struct {
-	OK        bool   `json:"ok"`
-	Action    string `json:"action"`
-	Label     string `json:"label"`
-	PlistPath string `json:"plist_path"`
-	Loaded    bool   `json:"loaded"`
+	OK        bool                `json:"ok"`
+	Action    string              `json:"action"`
+	Label     string              `json:"label,omitempty"`
+	PlistPath string              `json:"plist_path,omitempty"`
+	Loaded    *bool               `json:"loaded,omitempty"`
+	SignedBy  string              `json:"signed_by,omitempty"`
+	Services  []jsonImportService `json:"services"`
+	Assets    []jsonImportAsset   `json:"assets,omitempty"`
+	Warnings  []string            `json:"warnings,omitempty"`
+}
+
+// jsonImportService reports an imported service. OriginalLabel is set when
+// --rename or --label-prefix changed its label, and Backup when --replace
+// replaced a plist.
+type jsonImportService struct {
+	Label         string `json:"label"`
+	OriginalLabel string `json:"original_label,omitempty"`
+	PlistPath     string `json:"plist_path"`
+	Backup        string `json:"backup,omitempty"`
+	Loaded        bool   `json:"loaded"`
+}
+
+// jsonImportAsset reports an embedded asset. Status is "written",
+// "unchanged" or "differs", for an existing file that was left as is.
+type jsonImportAsset struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+}
+
+// ---------------------------------------------------------------------------
+// Keygen JSON types
+// ---------------------------------------------------------------------------
+
+type jsonKeygen struct {
+	OK         bool   `json:"ok"`
+	Action     string `json:"action"`
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+	TrustedKey string `json:"trusted_key"`
+}
+
+// ---------------------------------------------------------------------------
+// Import-cron JSON types
+// ---------------------------------------------------------------------------
+
+type jsonImportCron struct {
+	OK       bool            `json:"ok"`
+	Action   string          `json:"action"`
+	DryRun   bool            `json:"dry_run"`
+	Agents   []jsonCronAgent `json:"agents"`
+	Warnings []string        `json:"warnings,omitempty"`
+}
+
+type jsonCronAgent struct {
+	Label           string `json:"label"`
+	PlistPath       string `json:"plist_path"`
+	Line            int    `json:"line"`
+	Schedule        string `json:"schedule"`
+	Command         string `json:"command"`
+	CalendarEntries int    `json:"calendar_entries"`
+	RunAtLoad       bool   `json:"run_at_load"`
+	Loaded          bool   `json:"loaded"`
+}
+
+func toJSONImportCron(jobs []cronJob, warnings []string) jsonImportCron {
+	out := jsonImportCron{
+		OK:       true,
+		Action:   "import-cron",
+		DryRun:   importCronDryRun,
+		Agents:   make([]jsonCronAgent, 0, len(jobs)),
+		Warnings: warnings,
+	}
+	for _, job := range jobs {
+		out.Agents = append(out.Agents, jsonCronAgent{
+			Label:           job.plist.Label,
+			PlistPath:       job.path,
+			Line:            job.entry.Line,
+			Schedule:        job.entry.Schedule,
+			Command:         job.entry.Command,
+			CalendarEntries: len(job.entry.Spec.Calendar),
+			RunAtLoad:       job.plist.RunAtLoad,
+			Loaded:          job.loaded,
+		})
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Import-procfile and import-supervisord JSON types
+// ---------------------------------------------------------------------------
+
+type jsonImportProcs struct {
+	OK       bool            `json:"ok"`
+	Action   string          `json:"action"`
+	DryRun   bool            `json:"dry_run"`
+	Agents   []jsonProcAgent `json:"agents"`
+	Warnings []string        `json:"warnings,omitempty"`
+}
+
+type jsonProcAgent struct {
+	Label     string      `json:"label"`
+	PlistPath string      `json:"plist_path"`
+	Process   string      `json:"process"`
+	Source    string      `json:"source"`
+	Command   string      `json:"command"`
+	KeepAlive interface{} `json:"keep_alive,omitempty"`
+	RunAtLoad bool        `json:"run_at_load"`
+	Loaded    bool        `json:"loaded"`
+}
+
+func toJSONImportProcs(action string, jobs []procJob, warnings []string) jsonImportProcs {
+	out := jsonImportProcs{
+		OK:       true,
+		Action:   action,
+		DryRun:   importProcDryRun,
+		Agents:   make([]jsonProcAgent, 0, len(jobs)),
+		Warnings: warnings,
+	}
+	for _, job := range jobs {
+		out.Agents = append(out.Agents, jsonProcAgent{
+			Label:     job.plist.Label,
+			PlistPath: job.path,
+			Process:   job.name,
+			Source:    job.source,
+			Command:   job.command,
+			KeepAlive: job.plist.KeepAlive,
+			RunAtLoad: job.plist.RunAtLoad,
+			Loaded:    job.loaded,
+		})
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Convert JSON types
+// ---------------------------------------------------------------------------
+
+type jsonConvert struct {
+	Label      string               `json:"label"`
+	OutputPath string               `json:"output_path,omitempty"`
+	Plist      *plist.Dict          `json:"plist"`
+	Warnings   []jsonConvertWarning `json:"warnings"`
+}
+
+type jsonConvertWarning struct {
+	Unit      string `json:"unit"`
+	Line      int    `json:"line,omitempty"`
+	Directive string `json:"directive"`
+	Message   string `json:"message"`
+}
+
+func toJSONConvert(label string, doc *plist.Document, outputPath string, warnings []systemd.Warning) jsonConvert {
+	return jsonConvert{
+		Label:      label,
+		OutputPath: outputPath,
+		Plist:      doc.Root,
+		Warnings:   toJSONConvertWarnings(warnings),
+	}
+}
+
+func toJSONConvertWarnings(warnings []systemd.Warning) []jsonConvertWarning {
+	out := make([]jsonConvertWarning, 0, len(warnings))
+	for _, w := range warnings {
+		out = append(out, jsonConvertWarning{
+			Unit:      w.Unit,
+			Line:      w.Line,
+			Directive: w.Directive,
+			Message:   w.Message,
+		})
+	}
+	return out
+}
+
+type jsonConvertUnits struct {
+	Label    string               `json:"label"`
+	Units    []jsonSystemdUnit    `json:"units"`
+	Warnings []jsonConvertWarning `json:"warnings"`
+}
+
+type jsonSystemdUnit struct {
+	Name    string `json:"name"`
+	Path    string `json:"path,omitempty"`
+	Content string `json:"content"`
+}
+
+// toJSONConvertUnits builds the output of convert --to systemd. paths, if
+// any, are where the units were written, in the same order.
+func toJSONConvertUnits(label string, units []*systemd.Unit, paths []string, warnings []systemd.Warning) jsonConvertUnits {
+	out := jsonConvertUnits{
+		Label:    label,
+		Units:    make([]jsonSystemdUnit, 0, len(units)),
+		Warnings: toJSONConvertWarnings(warnings),
+	}
+	for i, u := range units {
+		unit := jsonSystemdUnit{Name: u.Name, Content: string(u.Encode())}
+		if i < len(paths) {
+			unit.Path = paths[i]
+		}
+		out.Units = append(out.Units, unit)
+	}
+	return out
 }
 
 // ---------------------------------------------------------------------------
@@ -192,3 +584,193 @@ type jsonEdit struct {
 	ValidationOK    bool   `json:"validation_ok"`
 	Reloaded        bool   `json:"reloaded"`
 }
+
+// ---------------------------------------------------------------------------
+// Set/unset JSON type
+// ---------------------------------------------------------------------------
+
+type jsonSet struct {
+	OK        bool        `json:"ok"`
+	Action    string      `json:"action"`
+	Label     string      `json:"label"`
+	PlistPath string      `json:"plist_path"`
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value,omitempty"`
+	Previous  interface{} `json:"previous,omitempty"`
+	Changed   bool        `json:"changed"`
+	Reloaded  bool        `json:"reloaded"`
+}
+
+// ---------------------------------------------------------------------------
+// Lint JSON types
+// ---------------------------------------------------------------------------
+
+type jsonLint struct {
+	Files   []jsonLintFile  `json:"files"`
+	Summary jsonLintSummary `json:"summary"`
+}
+
+type jsonLintFile struct {
+	Path   string          `json:"path"`
+	Label  string          `json:"label,omitempty"`
+	Issues []jsonLintIssue `json:"issues"`
+}
+
+type jsonLintIssue struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Key      string `json:"key,omitempty"`
+	Message  string `json:"message"`
+}
+
+type jsonLintSummary struct {
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+}
+
+// toJSONLint converts lint results to a JSON-serializable structure.
+func toJSONLint(results []lintResult, errs, warnings int) jsonLint {
+	files := make([]jsonLintFile, 0, len(results))
+	for _, r := range results {
+		issues := make([]jsonLintIssue, 0, len(r.issues))
+		for _, issue := range r.issues {
+			issues = append(issues, jsonLintIssue{
+				Rule:     issue.Rule,
+				Severity: lintSeverity(issue.Severity),
+				Key:      issue.Key,
+				Message:  issue.Message,
+			})
+		}
+		files = append(files, jsonLintFile{Path: r.path, Label: r.label, Issues: issues})
+	}
+	return jsonLint{
+		Files:   files,
+		Summary: jsonLintSummary{Errors: errs, Warnings: warnings},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Template JSON types
+// ---------------------------------------------------------------------------
+
+type jsonTemplate struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Source      string              `json:"source"`
+	Parameters  []jsonTemplateParam `json:"parameters"`
+	Requires    []string            `json:"requires"`
+	Plist       *plist.Dict         `json:"plist,omitempty"`
+}
+
+type jsonTemplateParam struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Required    bool   `json:"required"`
+}
+
+type jsonTemplateNew struct {
+	OK     bool   `json:"ok"`
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+}
+
+// toJSONTemplate converts a template, with its plist if body is non-nil, to
+// a JSON-serializable structure. Built-in templates have the source
+// "built-in".
+func toJSONTemplate(t *plist.Template, body *plist.Dict) jsonTemplate {
+	source := t.Source
+	if source == "" {
+		source = "built-in"
+	}
+	params := make([]jsonTemplateParam, 0, len(t.Params))
+	for _, p := range t.Params {
+		params = append(params, jsonTemplateParam{
+			Name:        p.Name,
+			Description: p.Description,
+			Default:     p.Default,
+			Required:    p.Required,
+		})
+	}
+	requires := t.Requires
+	if requires == nil {
+		requires = []string{}
+	}
+	return jsonTemplate{
+		Name:        t.Name,
+		Description: t.Description,
+		Source:      source,
+		Parameters:  params,
+		Requires:    requires,
+		Plist:       body,
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Plan/apply JSON types
+// ---------------------------------------------------------------------------
+
+type jsonPlan struct {
+	Services []jsonPlanService `json:"services"`
+	Summary  jsonPlanSummary   `json:"summary"`
+}
+
+type jsonPlanService struct {
+	Label     string           `json:"label"`
+	Source    string           `json:"source"`
+	PlistPath string           `json:"plist_path"`
+	Action    string           `json:"action"`
+	Changes   []jsonPlanChange `json:"changes"`
+	Reloaded  *bool            `json:"reloaded,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+type jsonPlanChange struct {
+	Key string      `json:"key"`
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+type jsonPlanSummary struct {
+	Create    int `json:"create"`
+	Update    int `json:"update"`
+	Unchanged int `json:"unchanged"`
+}
+
+// toJSONPlan converts planned steps, with their apply results when results
+// is non-nil, to a JSON-serializable structure.
+func toJSONPlan(steps []manifest.Step, results []applyResult) jsonPlan {
+	out := jsonPlan{Services: make([]jsonPlanService, 0, len(steps))}
+	for i, step := range steps {
+		changes := make([]jsonPlanChange, 0, len(step.Changes))
+		for _, c := range step.Changes {
+			changes = append(changes, jsonPlanChange{Key: c.Key, Old: c.Old, New: c.New})
+		}
+		svc := jsonPlanService{
+			Label:     step.Manifest.Label,
+			Source:    step.Manifest.Source,
+			PlistPath: step.Path,
+			Action:    step.Action.String(),
+			Changes:   changes,
+		}
+		if results != nil {
+			reloaded := results[i].reloaded
+			svc.Reloaded = &reloaded
+			if results[i].err != nil {
+				svc.Error = results[i].err.Error()
+			}
+		}
+		out.Services = append(out.Services, svc)
+
+		switch step.Action {
+		case manifest.ActionCreate:
+			out.Summary.Create++
+		case manifest.ActionUpdate:
+			out.Summary.Update++
+		default:
+			out.Summary.Unchanged++
+		}
+	}
+	return out
+}
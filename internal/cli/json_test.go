@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/lu-zhengda/lanchr/internal/agent"
+	"github.com/lu-zhengda/lanchr/internal/launchctl"
 	"github.com/lu-zhengda/lanchr/internal/platform"
 )
 
@@ -302,11 +303,13 @@ func TestJSONCreate_RoundTrip(t *testing.T) {
 
 func TestJSONImport_RoundTrip(t *testing.T) {
 	input := jsonImport{
-		OK:        true,
-		Action:    "import",
-		Label:     "com.example.imported",
-		PlistPath: "/path/to/imported.plist",
-		Loaded:    false,
+		OK:     true,
+		Action: "import",
+		Services: []jsonImportService{
+			{Label: "com.example.imported", PlistPath: "/path/to/imported.plist", Loaded: false},
+			{Label: "com.me.other", OriginalLabel: "com.example.other", PlistPath: "/path/to/other.plist", Backup: "/path/to/other.plist.20240501-120000.bak", Loaded: true},
+		},
+		Assets: []jsonImportAsset{{Path: "/usr/local/bin/tool", Status: "written"}},
 	}
 
 	var buf bytes.Buffer
@@ -318,11 +321,17 @@ func TestJSONImport_RoundTrip(t *testing.T) {
 	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
 		t.Fatalf("failed to parse JSON: %v", err)
 	}
-	if got.Action != "import" || got.Label != "com.example.imported" {
+	if got.Action != "import" || len(got.Services) != 2 || got.Services[0].Label != "com.example.imported" {
 		t.Errorf("round-trip mismatch: %+v", got)
 	}
-	if got.Loaded {
-		t.Error("got loaded=true, want false")
+	if got.Services[0].Loaded || !got.Services[1].Loaded {
+		t.Errorf("got loaded %v, %v; want false, true", got.Services[0].Loaded, got.Services[1].Loaded)
+	}
+	if got.Services[1].OriginalLabel != "com.example.other" || got.Services[1].Backup == "" {
+		t.Errorf("got renamed service %+v", got.Services[1])
+	}
+	if len(got.Assets) != 1 || got.Assets[0].Status != "written" {
+		t.Errorf("got assets %+v", got.Assets)
 	}
 }
 
@@ -420,3 +429,120 @@ func TestJSONServiceDetail_OmitsEmpty(t *testing.T) {
 		}
 	}
 }
+
+func TestToJSONServiceDetail_Runtime(t *testing.T) {
+	svc := &agent.Service{
+		Label: "com.example.runtime",
+		Runtime: &launchctl.ServiceInfo{
+			State:       "running",
+			PID:         42,
+			Runs:        2,
+			SpawnType:   "daemon (3)",
+			Environment: map[string]string{"XPC_SERVICE_NAME": "com.example.runtime"},
+			Endpoints: []launchctl.Endpoint{
+				{Name: "com.example.runtime.xpc", Attributes: map[string]string{"active": "1"}},
+			},
+		},
+	}
+
+	got := toJSONServiceDetail(svc)
+	if got.Runtime == nil {
+		t.Fatal("expected runtime section, got nil")
+	}
+	if got.Runtime.SpawnType != "daemon (3)" {
+		t.Errorf("got spawn type %q, want %q", got.Runtime.SpawnType, "daemon (3)")
+	}
+	if len(got.Runtime.Endpoints) != 1 || got.Runtime.Endpoints[0].Name != "com.example.runtime.xpc" {
+		t.Errorf("got endpoints %+v", got.Runtime.Endpoints)
+	}
+
+	// Without launchctl print data the runtime section is omitted.
+	var buf bytes.Buffer
+	if err := fprintJSON(&buf, toJSONServiceDetail(&agent.Service{Label: "x"})); err != nil {
+		t.Fatalf("fprintJSON() error = %v", err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+	if _, ok := raw["runtime"]; ok {
+		t.Error("runtime should be omitted when launchctl print data is absent")
+	}
+}
+
+func TestToJSONServices_AppBundle(t *testing.T) {
+	services := []agent.Service{
+		{
+			Label:         "com.example.helper",
+			Domain:        platform.DomainGlobal,
+			Type:          platform.TypeDaemon,
+			Origin:        platform.OriginAppBundle,
+			AppBundle:     "/Applications/Example.app",
+			BundleProgram: "Contents/MacOS/ExampleHelper",
+			PlistPath:     "/Applications/Example.app/Contents/Library/LaunchDaemons/com.example.helper.plist",
+		},
+	}
+
+	got := toJSONServices(services)
+	if got[0].Origin != "app" {
+		t.Errorf("got origin %q, want %q", got[0].Origin, "app")
+	}
+	if got[0].AppBundle != "/Applications/Example.app" {
+		t.Errorf("got app_bundle %q, want %q", got[0].AppBundle, "/Applications/Example.app")
+	}
+	if want := "/Applications/Example.app/Contents/MacOS/ExampleHelper"; got[0].Program != want {
+		t.Errorf("got program %q, want %q", got[0].Program, want)
+	}
+}
+
+func TestToJSONServiceDetail_Symlink(t *testing.T) {
+	svc := &agent.Service{
+		Label:         "com.example.linked",
+		PlistPath:     "/Users/test/Library/LaunchAgents/com.example.linked.plist",
+		PlistTarget:   "/Users/test/dotfiles/com.example.linked.plist",
+		PlistDangling: true,
+	}
+
+	got := toJSONServiceDetail(svc)
+	if got.PlistTarget != svc.PlistTarget {
+		t.Errorf("got plist_target %q, want %q", got.PlistTarget, svc.PlistTarget)
+	}
+	if !got.PlistDangling {
+		t.Error("expected plist_dangling to be true")
+	}
+}
+
+func TestToJSONServiceDetail_KeepAliveConditions(t *testing.T) {
+	crashed := true
+	svc := &agent.Service{
+		Label: "com.example.keepalive",
+		KeepAlive: agent.KeepAliveConditions{
+			Crashed:   &crashed,
+			PathState: map[string]bool{"/tmp/flag": true},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := fprintJSON(&buf, toJSONServiceDetail(svc)); err != nil {
+		t.Fatalf("fprintJSON() error = %v", err)
+	}
+	var parsed struct {
+		KeepAlive struct {
+			Crashed   *bool           `json:"crashed"`
+			PathState map[string]bool `json:"path_state"`
+		} `json:"keep_alive"`
+		Summary string `json:"keep_alive_summary"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+	if parsed.KeepAlive.Crashed == nil || !*parsed.KeepAlive.Crashed {
+		t.Error("expected keep_alive.crashed to be true")
+	}
+	if !parsed.KeepAlive.PathState["/tmp/flag"] {
+		t.Error("expected keep_alive.path_state to contain /tmp/flag")
+	}
+	if parsed.Summary != "restart if crashed; run while /tmp/flag exists" {
+		t.Errorf("got summary %q", parsed.Summary)
+	}
+}
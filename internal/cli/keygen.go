@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/spf13/cobra"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+var keygenComment string
+
+var keygenCmd = &cobra.Command{
+	Use:   "keygen [file]",
+	Short: "Create a key pair for signing export bundles",
+	Long: `Create an ed25519 key pair for "export --sign". The private key is written to
+the file, ~/.config/lanchr/signing.key (or $XDG_CONFIG_HOME/lanchr/signing.key)
+by default, readable only by you; the public key is written next to it with a
+.pub extension. Existing keys are never overwritten.
+
+The public key file holds one line, "ed25519 <key> <comment>". Add it to the
+trusted keys file that "import --verify" is given on the Macs importing your
+bundles.`,
+	Example: `  lanchr keygen
+  lanchr keygen ~/keys/team.key --comment "ops team"
+  cat ~/.config/lanchr/signing.key.pub >> /Volumes/Shared/lanchr/trusted_keys`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := plist.DefaultSigningKeyPath()
+		if len(args) == 1 {
+			path = args[0]
+		}
+		comment := keygenComment
+		if !cmd.Flags().Changed("comment") {
+			comment = defaultKeyComment()
+		}
+
+		pub, err := plist.GenerateSigningKey(path, comment)
+		if err != nil {
+			return err
+		}
+		trusted := plist.FormatPublicKey(pub, comment)
+
+		if jsonFlag {
+			return printJSON(jsonKeygen{
+				OK:         true,
+				Action:     "keygen",
+				PrivateKey: path,
+				PublicKey:  path + ".pub",
+				TrustedKey: trusted,
+			})
+		}
+		fmt.Printf("Wrote the private key to %s\n", path)
+		fmt.Printf("Wrote the public key to %s.pub\n", path)
+		fmt.Printf("\nTo trust bundles signed with it, add this line to a trusted keys file:\n%s\n", trusted)
+		return nil
+	},
+}
+
+func init() {
+	keygenCmd.Flags().StringVar(&keygenComment, "comment", "", "Comment naming the key in trusted keys files (default: user@host)")
+
+	// Generating keys needs neither launchctl nor macOS.
+	localCommands["keygen"] = always
+}
+
+// defaultKeyComment returns user@host, or whichever of them is known.
+func defaultKeyComment() string {
+	var name string
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, _ := os.Hostname()
+	switch {
+	case name != "" && host != "":
+		return name + "@" + host
+	case name != "":
+		return name
+	}
+	return host
+}
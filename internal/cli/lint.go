@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/lu-zhengda/lanchr/internal/agent"
+	"github.com/lu-zhengda/lanchr/internal/platform"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint <file|label>...",
+	Short: "Check plists for common mistakes",
+	Long: `Check plist files, or the plists of services given by label, against a set
+of rules: value types, Program/ProgramArguments consistency, unsplit
+arguments, ~ and $VAR in paths, calendar ranges, UserName in agents, label
+format and StartInterval with KeepAlive. Exits non-zero if any error is found,
+so it can run as a pre-commit hook. Files are linted on any platform; plists
+in a LaunchDaemons directory are checked as daemons.`,
+	Example: `  lanchr lint ~/Library/LaunchAgents/com.example.agent.plist
+  lanchr lint com.example.agent
+  lanchr lint agents/*.plist --json`,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var results []lintResult
+		for _, arg := range args {
+			result, err := lintTarget(arg)
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+
+		var issues []agent.LintIssue
+		for _, r := range results {
+			issues = append(issues, r.issues...)
+		}
+		errs, warnings := agent.CountLintIssues(issues)
+
+		if jsonFlag {
+			if err := printJSON(toJSONLint(results, errs, warnings)); err != nil {
+				return err
+			}
+		} else {
+			for _, r := range results {
+				if len(r.issues) == 0 {
+					continue
+				}
+				fmt.Println(r.path)
+				for _, issue := range r.issues {
+					key := issue.Key
+					if key == "" {
+						key = "-"
+					}
+					fmt.Printf("  %-7s  %-18s  %-20s  %s\n", lintSeverity(issue.Severity), issue.Rule, key, issue.Message)
+				}
+				fmt.Println()
+			}
+			fmt.Printf("%d %s checked: %d %s, %d %s\n",
+				len(results), plural(len(results), "file", "files"),
+				errs, plural(errs, "error", "errors"),
+				warnings, plural(warnings, "warning", "warnings"))
+		}
+
+		if errs > 0 {
+			return fmt.Errorf("lint found %d %s", errs, plural(errs, "error", "errors"))
+		}
+		return nil
+	},
+}
+
+func init() {
+	// Plists given as files need no lookup by label.
+	localCommands["lint"] = allFiles
+}
+
+// lintResult is the outcome of linting one plist.
+type lintResult struct {
+	path   string
+	label  string
+	issues []agent.LintIssue
+}
+
+// lintTarget lints arg, which is a plist file or a service label.
+func lintTarget(arg string) (lintResult, error) {
+	if isFile(arg) {
+		return lintResult{path: arg, issues: agent.LintFile(arg, lintServiceType(arg))}, nil
+	}
+
+	scanner, _, _ := buildDeps()
+	svc, err := scanner.FindByLabel(arg)
+	if err != nil {
+		return lintResult{}, fmt.Errorf("%s is neither a plist file nor a known service: %w", arg, err)
+	}
+	if svc.PlistPath == "" {
+		return lintResult{}, fmt.Errorf("service %q has no plist on disk", arg)
+	}
+	return lintResult{path: svc.PlistPath, label: svc.Label, issues: agent.LintFile(svc.PlistPath, svc.Type)}, nil
+}
+
+// lintServiceType treats plists in the system daemon directories, or in any
+// directory named LaunchDaemons, as daemons.
+func lintServiceType(path string) platform.ServiceType {
+	if filepath.Base(filepath.Dir(path)) == "LaunchDaemons" {
+		return platform.TypeDaemon
+	}
+	return platform.TypeFromPath(path)
+}
+
+// lintSeverity names a lint severity.
+func lintSeverity(s agent.Severity) string {
+	if s == agent.SeverityCritical {
+		return "error"
+	}
+	return "warning"
+}
+
+// isFile reports whether path names an existing regular file.
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// allFiles reports whether every argument is an existing file.
+func allFiles(args []string) bool {
+	for _, arg := range args {
+		if !isFile(arg) {
+			return false
+		}
+	}
+	return true
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
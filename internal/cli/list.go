@@ -14,6 +14,7 @@ var (
 	listStatus  string
 	listType    string
 	listNoApple bool
+	listOrigin  string
 )
 
 var listCmd = &cobra.Command{
@@ -62,6 +63,14 @@ var listCmd = &cobra.Command{
 					if svc.Status != agent.StatusError {
 						continue
 					}
+				case "unknown":
+					if svc.Status != agent.StatusUnknown {
+						continue
+					}
+				case "invalid":
+					if svc.Status != agent.StatusInvalid {
+						continue
+					}
 				}
 			}
 
@@ -78,6 +87,10 @@ var listCmd = &cobra.Command{
 				}
 			}
 
+			if listOrigin != "" && svc.Origin.String() != listOrigin {
+				continue
+			}
+
 			if listNoApple && svc.IsApple() {
 				continue
 			}
@@ -95,8 +108,9 @@ var listCmd = &cobra.Command{
 
 func init() {
 	listCmd.Flags().StringVarP(&listDomain, "domain", "d", "", "Filter by domain: user, global, system")
-	listCmd.Flags().StringVarP(&listStatus, "status", "s", "", "Filter by status: running, stopped, error")
+	listCmd.Flags().StringVarP(&listStatus, "status", "s", "", "Filter by status: running, stopped, error, unknown, invalid")
 	listCmd.Flags().StringVarP(&listType, "type", "t", "", "Filter by type: agent, daemon")
+	listCmd.Flags().StringVarP(&listOrigin, "origin", "o", "", "Filter by origin: launchd, apple, app (SMAppService plists in app bundles)")
 	listCmd.Flags().BoolVar(&listNoApple, "no-apple", false, "Hide com.apple.* services")
 }
 
@@ -106,7 +120,9 @@ func outputTable(services []agent.Service) error {
 	for _, svc := range services {
 		indicator := svc.Status.Indicator()
 		pid := "-"
-		if svc.PID > 0 {
+		if svc.Status == agent.StatusUnknown {
+			pid = "n/a"
+		} else if svc.PID > 0 {
 			pid = fmt.Sprintf("%d", svc.PID)
 		}
 
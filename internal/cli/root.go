@@ -3,6 +3,8 @@ package cli
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
@@ -19,6 +21,28 @@ var (
 
 	// jsonFlag enables JSON output for all commands.
 	jsonFlag bool
+
+	// recordDir and replayDir capture or replay launchctl sessions (hidden).
+	recordDir string
+	replayDir string
+
+	// rootDir points at a mounted or extracted macOS tree to analyze offline.
+	rootDir string
+
+	// simulateFlag routes launchd changes to a simulator and file writes
+	// to a scratch tree, both of which simulateReset discards.
+	simulateFlag  bool
+	simulateReset bool
+
+	// executor is the launchctl backend shared by all commands. It is set up
+	// in PersistentPreRunE from the --record/--replay/--simulate flags.
+	executor launchctl.Executor
+
+	// liveRunner runs launchctl, unless --replay serves recorded output,
+	// and checkDarwin guards the commands that need it. Tests replace them
+	// to run commands on any platform.
+	liveRunner  launchctl.CmdRunner = &launchctl.RealCmdRunner{}
+	checkDarwin                     = platform.CheckDarwin
 )
 
 var rootCmd = &cobra.Command{
@@ -33,7 +57,38 @@ var rootCmd = &cobra.Command{
 		if shell, _ := cmd.Root().Flags().GetString("generate-completion"); shell != "" {
 			return nil
 		}
-		return platform.CheckDarwin()
+
+		if rootDir != "" {
+			return checkOfflineRoot(cmd)
+		}
+		// A simulation always sets up its launchd, even for writes alone,
+		// so that what they write is seen by the simulated one.
+		if local := localCommands[commandKey(cmd)]; local != nil && !simulateFlag && local(args) {
+			return nil
+		}
+
+		exec, err := newExecutor()
+		if err != nil {
+			return err
+		}
+		executor = exec
+
+		// Replayed sessions need no launchctl, so they run anywhere.
+		if replayDir == "" {
+			if err := checkDarwin(); err != nil {
+				return err
+			}
+		}
+
+		if simulateFlag {
+			sim, err := openSimulator(exec)
+			if err != nil {
+				return err
+			}
+			executor = sim
+			fmt.Fprintf(os.Stderr, "Simulation mode: launchd changes are kept in %s and files are written under %s; nothing is applied.\n", simulatorStatePath(), simulatorRootPath())
+		}
+		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if shell, _ := cmd.Flags().GetString("generate-completion"); shell != "" {
@@ -48,11 +103,7 @@ var rootCmd = &cobra.Command{
 				return fmt.Errorf("unsupported shell: %s (use bash, zsh, or fish)", shell)
 			}
 		}
-		exec := launchctl.NewDefaultExecutor()
-		parser := plist.NewParser()
-		scanner := agent.NewScanner(parser, exec)
-		manager := agent.NewManager(exec, scanner, parser)
-		doctor := agent.NewDoctor(scanner)
+		scanner, manager, doctor := buildDeps()
 
 		model := tui.New(scanner, manager, doctor, version)
 		p := tea.NewProgram(model, tea.WithAltScreen())
@@ -72,6 +123,13 @@ func init() {
 	rootCmd.Flags().String("generate-completion", "", "Generate shell completion (bash, zsh, fish)")
 	rootCmd.Flags().MarkHidden("generate-completion")
 	rootCmd.PersistentFlags().BoolVar(&jsonFlag, "json", false, "Output in JSON format")
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "Record every launchctl invocation to a fixture directory")
+	rootCmd.PersistentFlags().StringVar(&replayDir, "replay", "", "Serve launchctl output from a recorded fixture directory")
+	rootCmd.PersistentFlags().StringVar(&rootDir, "root", "", "Analyze the macOS tree mounted or extracted at this path instead of the running system (list, search, info, doctor, export, lint, convert)")
+	rootCmd.PersistentFlags().BoolVar(&simulateFlag, "simulate", false, "Apply launchd changes to a simulator seeded from the live state and write files to a scratch tree, both of which carry over between invocations")
+	rootCmd.PersistentFlags().BoolVar(&simulateReset, "simulate-reset", false, "With --simulate, discard the simulated changes and files and reseed from the live state")
+	rootCmd.PersistentFlags().MarkHidden("record")
+	rootCmd.PersistentFlags().MarkHidden("replay")
 
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(infoCmd)
@@ -81,19 +139,176 @@ func init() {
 	rootCmd.AddCommand(logsCmd)
 	rootCmd.AddCommand(doctorCmd)
 	rootCmd.AddCommand(createCmd)
+	rootCmd.AddCommand(templateCmd)
 	rootCmd.AddCommand(editCmd)
+	rootCmd.AddCommand(setCmd)
+	rootCmd.AddCommand(unsetCmd)
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(lintCmd)
+	rootCmd.AddCommand(schemaCmd)
 	rootCmd.AddCommand(restartCmd)
 	rootCmd.AddCommand(loadCmd)
 	rootCmd.AddCommand(unloadCmd)
 	rootCmd.AddCommand(exportCmd)
 	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(keygenCmd)
+	rootCmd.AddCommand(importCronCmd)
+	rootCmd.AddCommand(importProcfileCmd)
+	rootCmd.AddCommand(importSupervisordCmd)
+	rootCmd.AddCommand(convertCmd)
+}
+
+// commandKey returns the path of cmd below the root command, such as
+// "template list", by which the tables below name commands.
+func commandKey(cmd *cobra.Command) string {
+	return strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+}
+
+// localCommands are the commands that can run without launchctl, each
+// with whether the given arguments and flags let it. Those invocations
+// skip setting up the executor and run on any platform. Commands add
+// themselves in the init function of their file.
+var localCommands = map[string]func(args []string) bool{}
+
+func always([]string) bool { return true }
+
+// offlineCommands are the plist-only commands that work with --root.
+var offlineCommands = map[string]bool{
+	"list":    true,
+	"search":  true,
+	"info":    true,
+	"doctor":  true,
+	"export":  true,
+	"lint":    true,
+	"convert": true,
+}
+
+// checkOfflineRoot validates --root and the command it is used with, and
+// switches to the offline executor. No launchctl is needed, so it runs on
+// any platform.
+func checkOfflineRoot(cmd *cobra.Command) error {
+	if recordDir != "" || replayDir != "" || simulateFlag {
+		return fmt.Errorf("--root cannot be combined with --record, --replay, or --simulate")
+	}
+	if !offlineCommands[commandKey(cmd)] {
+		return fmt.Errorf("%q is not available with --root (supported: list, search, info, doctor, export, lint, convert)", cmd.CommandPath())
+	}
+	info, err := os.Stat(rootDir)
+	if err != nil {
+		return fmt.Errorf("failed to open root %s: %w", rootDir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("root %s is not a directory", rootDir)
+	}
+	executor = launchctl.NewOfflineExecutor()
+	return nil
+}
+
+// newExecutor returns the launchctl backend selected by the global flags.
+func newExecutor() (launchctl.Executor, error) {
+	switch {
+	case recordDir != "" && replayDir != "":
+		return nil, fmt.Errorf("--record and --replay cannot be used together")
+	case replayDir != "":
+		runner, err := launchctl.NewReplayRunner(replayDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load replay fixtures: %w", err)
+		}
+		// Query the domains and plists of the user the session was
+		// recorded as, which may be another uid on another machine.
+		if session := runner.Session(); session != nil {
+			platform.SetUser(session.UID, session.Home)
+		}
+		return launchctl.NewExecutorWithRunner(runner), nil
+	case recordDir != "":
+		home, err := platform.HomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		session := launchctl.Session{UID: platform.CurrentUID(), Home: home}
+		runner, err := launchctl.NewRecordingRunner(liveRunner, recordDir, session)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start recording: %w", err)
+		}
+		return launchctl.NewExecutorWithRunner(runner), nil
+	default:
+		return launchctl.NewExecutorWithRunner(liveRunner), nil
+	}
+}
+
+// simulatorStatePath returns where --simulate keeps the simulated launchd
+// between invocations: next to the user templates and signing key.
+func simulatorStatePath() string {
+	return filepath.Join(filepath.Dir(plist.DefaultTemplateDir()), "simulator.json")
+}
+
+// simulatorRootPath returns the scratch tree that --simulate writes plists
+// and other files to, at the paths they would have on the system.
+func simulatorRootPath() string {
+	return filepath.Join(filepath.Dir(plist.DefaultTemplateDir()), "simulator")
+}
+
+// simulatedPath returns where a file meant for path is written: path
+// itself, or with --simulate the same path under the scratch tree, whose
+// directories it creates. The simulated scanner lists scratch plists in
+// place of the real ones.
+func simulatedPath(path string) (string, error) {
+	root := simulatorRootPath()
+	if !simulateFlag || path == "" || strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return path, nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	scratch := filepath.Join(root, abs)
+	if err := os.MkdirAll(filepath.Dir(scratch), 0755); err != nil {
+		return "", fmt.Errorf("failed to create simulated directory for %s: %w", path, err)
+	}
+	return scratch, nil
+}
+
+// openSimulator returns the simulated launchd of --simulate. A new
+// simulation, or one reset with --simulate-reset, starts from the state of
+// live.
+func openSimulator(live launchctl.Executor) (*launchctl.Simulator, error) {
+	path := simulatorStatePath()
+	if simulateReset {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to reset the simulation: %w", err)
+		}
+		if err := os.RemoveAll(simulatorRootPath()); err != nil {
+			return nil, fmt.Errorf("failed to reset the simulation: %w", err)
+		}
+	}
+	sim, found, err := launchctl.OpenSimulator(path)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		sim.Seed(live, platform.LaunchdDomainTargets())
+		if err := sim.Save(); err != nil {
+			return nil, err
+		}
+	}
+	return sim, nil
 }
 
 // buildDeps creates the common dependencies for CLI commands.
 func buildDeps() (*agent.Scanner, *agent.Manager, *agent.Doctor) {
-	exec := launchctl.NewDefaultExecutor()
+	exec := executor
+	if exec == nil {
+		exec = launchctl.NewDefaultExecutor()
+	}
 	parser := plist.NewParser()
 	scanner := agent.NewScanner(parser, exec)
+	switch {
+	case rootDir != "":
+		scanner = agent.NewOfflineScanner(parser, rootDir)
+	case simulateFlag:
+		scanner = agent.NewSimulatedScanner(parser, exec, simulatorRootPath())
+	}
 	manager := agent.NewManager(exec, scanner, parser)
 	doctor := agent.NewDoctor(scanner)
 	return scanner, manager, doctor
@@ -0,0 +1,352 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/lu-zhengda/lanchr/internal/launchctl"
+	"github.com/lu-zhengda/lanchr/internal/platform"
+)
+
+// runLanchr runs lanchr with args and returns what it wrote to stdout.
+// Every flag starts from its default, as in a fresh process.
+func runLanchr(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	resetFlags(rootCmd)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- data
+	}()
+
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	err = rootCmd.Execute()
+
+	w.Close()
+	os.Stdout = stdout
+	return string(<-out), err
+}
+
+// resetFlags sets every flag of cmd and its subcommands back to its
+// default.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			sv.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// fakeLaunchctl answers launchctl invocations from a table of outputs,
+// failing the others as launchctl does for unknown services.
+type fakeLaunchctl map[string]string
+
+func (f fakeLaunchctl) Run(_ context.Context, name string, args ...string) ([]byte, error) {
+	if out, ok := f[strings.Join(args, " ")]; ok {
+		return []byte(out), nil
+	}
+	return nil, &launchctl.RunError{
+		ExitCode: 113,
+		Stderr:   []byte("Could not find service in domain for port\n"),
+		Err:      fmt.Errorf("exit status 113"),
+	}
+}
+
+// useLaunchctl makes the commands run launchctl through runner, skipping
+// the macOS check, for the rest of the test.
+func useLaunchctl(t *testing.T, runner launchctl.CmdRunner) {
+	t.Helper()
+	prevRunner, prevCheck := liveRunner, checkDarwin
+	liveRunner = runner
+	checkDarwin = func() error { return nil }
+	t.Cleanup(func() { liveRunner, checkDarwin = prevRunner, prevCheck })
+}
+
+// actAs makes lanchr act for uid and home for the rest of the test.
+func actAs(t *testing.T, uid int, home string) {
+	t.Helper()
+	liveHome, _ := os.UserHomeDir()
+	platform.SetUser(uid, home)
+	t.Cleanup(func() { platform.SetUser(os.Getuid(), liveHome) })
+}
+
+func writeAgentPlist(t *testing.T, dir, label string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, label+".plist")
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>` + label + `</string>
+	<key>Program</key>
+	<string>/usr/bin/true</string>
+</dict>
+</plist>
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRecordReplayAcrossUsers(t *testing.T) {
+	// Record a session of uid 501 ...
+	home := t.TempDir()
+	writeAgentPlist(t, filepath.Join(home, "Library", "LaunchAgents"), "com.example.recorded")
+	useLaunchctl(t, fakeLaunchctl{
+		"print gui/501": "gui/501 = {\n\tservices = {\n\t\t    4242      -    \tcom.example.recorded\n\t}\n}\n",
+	})
+	actAs(t, 501, home)
+
+	capture := filepath.Join(t.TempDir(), "capture")
+	if _, err := runLanchr(t, "--record", capture, "list"); err != nil {
+		t.Fatalf("lanchr --record list: %v", err)
+	}
+	first, _ := filepath.Glob(filepath.Join(capture, "0*.json"))
+	if len(first) == 0 {
+		t.Fatal("the recording has no fixtures")
+	}
+
+	// ... where a second recording adds to the capture.
+	if _, err := runLanchr(t, "--record", capture, "list"); err != nil {
+		t.Fatalf("second lanchr --record list: %v", err)
+	}
+	all, _ := filepath.Glob(filepath.Join(capture, "0*.json"))
+	if len(all) != 2*len(first) {
+		t.Errorf("got %d fixtures after two recordings, want %d", len(all), 2*len(first))
+	}
+
+	// ... and replay it as another user, on a machine without launchctl.
+	actAs(t, 502, t.TempDir())
+	checkDarwin = func() error { return errors.New("lanchr requires macOS") }
+
+	out, err := runLanchr(t, "--replay", capture, "--json", "list")
+	if err != nil {
+		t.Fatalf("lanchr --replay list: %v", err)
+	}
+	var services []jsonService
+	if err := json.Unmarshal([]byte(out), &services); err != nil {
+		t.Fatalf("failed to parse JSON %q: %v", out, err)
+	}
+	found := false
+	for _, svc := range services {
+		if svc.Label != "com.example.recorded" {
+			continue
+		}
+		found = true
+		if svc.PID != 4242 || svc.Status != "running" {
+			t.Errorf("got %s pid %d, want running pid 4242", svc.Status, svc.PID)
+		}
+	}
+	if !found {
+		t.Errorf("com.example.recorded is missing from the replayed list: %s", out)
+	}
+}
+
+func TestSimulateCarriesOver(t *testing.T) {
+	home := t.TempDir()
+	path := writeAgentPlist(t, filepath.Join(home, "Library", "LaunchAgents"), "com.example.rehearsed")
+	actAs(t, 501, home)
+	useLaunchctl(t, fakeLaunchctl{"print gui/501": "gui/501 = {\n\tservices = {\n\t}\n}\n"})
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	// Each invocation sees the simulated changes of the previous ones.
+	if _, err := runLanchr(t, "--simulate", "load", path); err != nil {
+		t.Fatalf("lanchr --simulate load: %v", err)
+	}
+	if _, err := runLanchr(t, "--simulate", "restart", "com.example.rehearsed"); err != nil {
+		t.Fatalf("lanchr --simulate restart: %v", err)
+	}
+	out, err := runLanchr(t, "--simulate", "--json", "info", "com.example.rehearsed")
+	if err != nil {
+		t.Fatalf("lanchr --simulate info: %v", err)
+	}
+	var detail jsonServiceDetail
+	if err := json.Unmarshal([]byte(out), &detail); err != nil {
+		t.Fatalf("failed to parse JSON %q: %v", out, err)
+	}
+	if detail.Status != "running" || detail.Runtime == nil || detail.Runtime.Runs != 1 {
+		t.Errorf("got %s with runtime %+v, want running once", detail.Status, detail.Runtime)
+	}
+
+	// The real launchd was never asked to change anything.
+	out, err = runLanchr(t, "--json", "info", "com.example.rehearsed")
+	if err != nil {
+		t.Fatalf("lanchr info: %v", err)
+	}
+	if err := json.Unmarshal([]byte(out), &detail); err != nil {
+		t.Fatalf("failed to parse JSON %q: %v", out, err)
+	}
+	if detail.Status == "running" {
+		t.Error("the live launchd reports the simulated service as running")
+	}
+
+	// --simulate-reset starts again from the live state.
+	if _, err := runLanchr(t, "--simulate", "--simulate-reset", "restart", "com.example.rehearsed"); err == nil {
+		t.Error("expected restart to fail after resetting the simulation, got nil")
+	}
+}
+
+func TestSimulateLeavesPlistsAlone(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	actAs(t, 501, home)
+	useLaunchctl(t, fakeLaunchctl{"print gui/501": "gui/501 = {\n\tservices = {\n\t}\n}\n"})
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	installed := filepath.Join(home, "Library", "LaunchAgents", "com.example.rehearsed.plist")
+
+	if _, err := runLanchr(t, "--simulate", "create", "--label", "com.example.rehearsed", "--program", "/usr/bin/true", "--load"); err != nil {
+		t.Fatalf("lanchr --simulate create --load: %v", err)
+	}
+	if _, err := runLanchr(t, "--simulate", "set", "com.example.rehearsed", "RunAtLoad", "true", "--reload"); err != nil {
+		t.Fatalf("lanchr --simulate set --reload: %v", err)
+	}
+	if _, err := os.Lstat(installed); !os.IsNotExist(err) {
+		t.Errorf("the simulation wrote %s: %v", installed, err)
+	}
+
+	// The simulation sees the plist it wrote, with the change.
+	out, err := runLanchr(t, "--simulate", "--json", "info", "com.example.rehearsed")
+	if err != nil {
+		t.Fatalf("lanchr --simulate info: %v", err)
+	}
+	var detail jsonServiceDetail
+	if err := json.Unmarshal([]byte(out), &detail); err != nil {
+		t.Fatalf("failed to parse JSON %q: %v", out, err)
+	}
+	if !detail.RunAtLoad || detail.Runtime == nil || detail.Runtime.Runs != 1 {
+		t.Errorf("got RunAtLoad %v with runtime %+v, want the simulated plist loaded", detail.RunAtLoad, detail.Runtime)
+	}
+
+	// The live system does not.
+	if _, err := runLanchr(t, "info", "com.example.rehearsed"); err == nil {
+		t.Error("expected the live info to miss the simulated service, got nil")
+	}
+}
+
+func TestRootFlag(t *testing.T) {
+	root := t.TempDir()
+	writeAgentPlist(t, filepath.Join(root, "Library", "LaunchAgents"), "com.example.offline")
+	prevCheck := checkDarwin
+	checkDarwin = func() error { return errors.New("lanchr requires macOS") }
+	t.Cleanup(func() { checkDarwin = prevCheck })
+
+	// The plist-only commands run anywhere.
+	out, err := runLanchr(t, "--root", root, "--json", "list")
+	if err != nil {
+		t.Fatalf("lanchr --root list: %v", err)
+	}
+	if !strings.Contains(out, `"com.example.offline"`) || !strings.Contains(out, `"unknown"`) {
+		t.Errorf("got %s, want com.example.offline with an unknown status", out)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"load", []string{"load", "x.plist"}, "not available with --root"},
+		{"unload", []string{"unload", "com.example.offline"}, "not available with --root"},
+		{"enable", []string{"enable", "com.example.offline"}, "not available with --root"},
+		{"restart", []string{"restart", "com.example.offline"}, "not available with --root"},
+		{"create", []string{"create", "-l", "com.example.new", "-p", "/usr/bin/true"}, "not available with --root"},
+		{"import", []string{"import", "bundle.json"}, "not available with --root"},
+		{"record", []string{"--record", t.TempDir(), "list"}, "cannot be combined"},
+		{"replay", []string{"--replay", t.TempDir(), "list"}, "cannot be combined"},
+		{"simulate", []string{"--simulate", "list"}, "cannot be combined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runLanchr(t, append([]string{"--root", root}, tt.args...)...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// The root must be a directory.
+	if _, err := runLanchr(t, "--root", filepath.Join(root, "missing"), "list"); err == nil {
+		t.Error("expected error for a missing root, got nil")
+	}
+	file := writeAgentPlist(t, root, "com.example.file")
+	if _, err := runLanchr(t, "--root", file, "list"); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("got error %v for a file root, want not a directory", err)
+	}
+}
+
+func TestLocalCommands(t *testing.T) {
+	prevCheck := checkDarwin
+	checkDarwin = func() error { return errors.New("lanchr requires macOS") }
+	t.Cleanup(func() { checkDarwin = prevCheck })
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	path := writeAgentPlist(t, dir, "com.example.local")
+	crontab := filepath.Join(dir, "crontab")
+	if err := os.WriteFile(crontab, []byte("*/5 * * * * /usr/local/bin/sync\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// These run anywhere ...
+	local := [][]string{
+		{"schema", "bundle"},
+		{"template", "list"},
+		{"keygen", filepath.Join(dir, "signing.key")},
+		{"lint", path},
+		{"convert", "--to", "systemd", path},
+		{"import-cron", "-o", filepath.Join(dir, "agents"), crontab},
+	}
+	for _, args := range local {
+		if _, err := runLanchr(t, args...); err != nil {
+			t.Errorf("lanchr %s: %v", strings.Join(args, " "), err)
+		}
+	}
+
+	// ... unless they need launchd after all.
+	needLaunchd := [][]string{
+		{"lint", "com.example.local"},
+		{"convert", "--to", "systemd", "com.example.local"},
+		{"import-cron", "--load", "-o", filepath.Join(dir, "agents"), crontab},
+		{"list"},
+	}
+	for _, args := range needLaunchd {
+		if _, err := runLanchr(t, args...); err == nil || !strings.Contains(err.Error(), "requires macOS") {
+			t.Errorf("lanchr %s: got error %v, want the macOS check", strings.Join(args, " "), err)
+		}
+	}
+
+	// Subcommands are told apart from top-level commands of the same name.
+	if _, err := runLanchr(t, "--root", dir, "template", "list"); err == nil || !strings.Contains(err.Error(), "not available with --root") {
+		t.Errorf("got error %v for template list with --root, want it rejected", err)
+	}
+}
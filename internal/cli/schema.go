@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/lu-zhengda/lanchr/internal/agent"
+	"github.com/lu-zhengda/lanchr/internal/jsonschema"
+	"github.com/lu-zhengda/lanchr/internal/platform"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// schemas maps the names accepted by "lanchr schema" to their generators.
+var schemas = map[string]func() *jsonschema.Schema{
+	"bundle":  plist.BundleSchema,
+	"plist":   plist.PlistSchema,
+	"service": serviceSchema,
+	"doctor":  doctorSchema,
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema <bundle|service|doctor|plist>",
+	Short: "Print the JSON Schema of a lanchr document",
+	Long: `Print the JSON Schema (draft 2020-12) of an export bundle, the --json output
+of info (service) or doctor, or a launchd plist converted to JSON. The schemas
+are generated from the types lanchr encodes, so they always match this version.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: schemaNames(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		gen, ok := schemas[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown schema %q (use %s)", args[0], strings.Join(schemaNames(), ", "))
+		}
+		return printJSON(gen())
+	},
+}
+
+func init() {
+	// Schemas are generated from the types, so launchctl is not needed.
+	localCommands["schema"] = always
+}
+
+// schemaNames returns the schema names in sorted order.
+func schemaNames() []string {
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// enumOf returns a string schema limited to the names of values.
+func enumOf[T fmt.Stringer](values ...T) *jsonschema.Schema {
+	s := jsonschema.Type("string")
+	for _, v := range values {
+		s.Enum = append(s.Enum, v.String())
+	}
+	return s
+}
+
+// serviceSchema describes the output of "lanchr info --json".
+func serviceSchema() *jsonschema.Schema {
+	keepAlive := (&jsonschema.Reflector{Tag: "json"}).Reflect(reflect.TypeOf(jsonKeepAlive{}))
+	r := &jsonschema.Reflector{
+		Tag: "json",
+		Overrides: map[string]*jsonschema.Schema{
+			"jsonServiceDetail.Domain": enumOf(platform.DomainUser, platform.DomainGlobal, platform.DomainSystem),
+			"jsonServiceDetail.Type":   enumOf(platform.TypeAgent, platform.TypeDaemon),
+			"jsonServiceDetail.Status": enumOf(agent.StatusStopped, agent.StatusRunning, agent.StatusError, agent.StatusDisabled, agent.StatusUnknown, agent.StatusInvalid),
+			"jsonServiceDetail.Origin": enumOf(platform.OriginLaunchDir, platform.OriginApple, platform.OriginAppBundle),
+			"jsonServiceDetail.KeepAlive": {OneOf: []*jsonschema.Schema{
+				jsonschema.Type("null"),
+				jsonschema.Type("boolean"),
+				keepAlive,
+			}},
+		},
+	}
+	s := r.Reflect(reflect.TypeOf(jsonServiceDetail{}))
+	s.Schema = jsonschema.Draft
+	s.ID = plist.SchemaBaseURL + "service.schema.json"
+	s.Title = "lanchr service"
+	s.Description = "A service as printed by \"lanchr info --json\"."
+	return s
+}
+
+// doctorSchema describes the output of "lanchr doctor --json".
+func doctorSchema() *jsonschema.Schema {
+	r := &jsonschema.Reflector{
+		Tag: "json",
+		Overrides: map[string]*jsonschema.Schema{
+			"jsonFinding.Severity": enumOf(agent.SeverityOK, agent.SeverityWarning, agent.SeverityCritical),
+		},
+	}
+	s := r.Reflect(reflect.TypeOf(jsonDoctor{}))
+	s.Schema = jsonschema.Draft
+	s.ID = plist.SchemaBaseURL + "doctor.schema.json"
+	s.Title = "lanchr doctor report"
+	s.Description = "The report printed by \"lanchr doctor --json\"."
+	return s
+}
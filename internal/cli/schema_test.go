@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lu-zhengda/lanchr/internal/agent"
+	"github.com/lu-zhengda/lanchr/internal/jsonschema"
+	"github.com/lu-zhengda/lanchr/internal/launchctl"
+	"github.com/lu-zhengda/lanchr/internal/platform"
+)
+
+var updateSchemas = flag.Bool("update", false, "rewrite the published schemas in schema/")
+
+// TestPublishedSchemas keeps schema/*.schema.json in sync with the types.
+// Run "go test ./internal/cli -run TestPublishedSchemas -update" after
+// changing a JSON type.
+func TestPublishedSchemas(t *testing.T) {
+	for _, name := range schemaNames() {
+		var buf bytes.Buffer
+		if err := fprintJSON(&buf, schemas[name]()); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		path := filepath.Join("..", "..", "schema", name+".schema.json")
+
+		if *updateSchemas {
+			if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+				t.Fatalf("failed to update %s: %v", path, err)
+			}
+			continue
+		}
+		published, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if !bytes.Equal(published, buf.Bytes()) {
+			t.Errorf("%s is out of date; run go test ./internal/cli -run TestPublishedSchemas -update", path)
+		}
+	}
+}
+
+// validateJSON checks that v, encoded as JSON, matches schema.
+func validateJSON(t *testing.T, schema *jsonschema.Schema, v interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := fprintJSON(&buf, v); err != nil {
+		t.Fatal(err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := jsonschema.Validate(schema, doc); err != nil {
+		t.Errorf("output does not match its schema: %v\n%s", err, buf.String())
+	}
+}
+
+func TestServiceSchema(t *testing.T) {
+	exit := false
+	services := []*agent.Service{
+		{Label: "com.example.minimal"},
+		{
+			Label:            "com.example.full",
+			Domain:           platform.DomainGlobal,
+			Type:             platform.TypeDaemon,
+			Status:           agent.StatusRunning,
+			PID:              42,
+			PlistPath:        "/Library/LaunchDaemons/com.example.full.plist",
+			Origin:           platform.OriginAppBundle,
+			AppBundle:        "/Applications/Example.app",
+			ProgramArgs:      []string{"/usr/local/bin/full", "--serve"},
+			KeepAlive:        agent.KeepAliveConditions{SuccessfulExit: &exit, PathState: map[string]bool{"/tmp/run": true}},
+			EnvironmentVars:  map[string]string{"PATH": "/usr/bin"},
+			CalendarInterval: []agent.CalendarInterval{{}},
+			Runtime: &launchctl.ServiceInfo{
+				State:     "running",
+				PID:       42,
+				Endpoints: []launchctl.Endpoint{{Name: "com.example.full.xpc", Attributes: map[string]string{"port": "1"}}},
+			},
+		},
+		{Label: "com.example.bool", KeepAlive: true},
+	}
+	for _, svc := range services {
+		validateJSON(t, serviceSchema(), toJSONServiceDetail(svc))
+	}
+}
+
+func TestDoctorSchema(t *testing.T) {
+	validateJSON(t, doctorSchema(), toJSONDoctor(nil))
+	validateJSON(t, doctorSchema(), toJSONDoctor([]agent.Finding{
+		{Severity: agent.SeverityCritical, Label: "com.example.a", Message: "binary not found"},
+		{Severity: agent.SeverityWarning, Label: "com.example.b", PlistPath: "/tmp/b.plist", Message: "stale log", Suggestion: "fix it"},
+	}))
+}
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/lu-zhengda/lanchr/internal/agent"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+var (
+	setType   string
+	setReload bool
+)
+
+var setCmd = &cobra.Command{
+	Use:   "set <label> <key> <value>",
+	Short: "Set a plist key",
+	Long: `Set a key in a service's plist. Nested keys are addressed with dots
+(EnvironmentVariables.PATH) and array elements by index (ProgramArguments.1);
+write "\." for a literal dot in a key. Values are checked against the types
+launchd expects. Arrays and dictionaries are given as JSON. Keys lanchr does
+not model take their type from --type, or from the value when --type is unset.`,
+	Example: `  lanchr set com.example.agent StartInterval 600
+  lanchr set com.example.agent EnvironmentVariables.PATH /opt/bin:/usr/bin
+  lanchr set com.example.agent ProgramArguments '["/usr/local/bin/sync", "--quiet"]'
+  lanchr set com.example.agent KeepAlive '{"SuccessfulExit": false}'`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		label, key, raw := args[0], args[1], args[2]
+
+		path, err := plist.ParseKeyPath(key)
+		if err != nil {
+			return err
+		}
+		value, err := plist.ParseValue(path, raw, setType)
+		if err != nil {
+			return err
+		}
+
+		return modifyPlist("set", label, path, value, setReload, func(doc *plist.Document) (bool, error) {
+			if old, ok := doc.Lookup(path); ok && plist.ValuesEqual(old, value) {
+				return false, nil
+			}
+			return true, doc.SetPath(path, value)
+		})
+	},
+}
+
+func init() {
+	setCmd.Flags().StringVar(&setType, "type", "", "Value type for keys lanchr does not model (string, integer, real, boolean, date, data, array, dictionary)")
+	setCmd.Flags().BoolVar(&setReload, "reload", false, "Bootout and bootstrap the service after a change")
+}
+
+// modifyPlist applies change to the plist of label, writes it back when
+// change reports a modification, and optionally reloads the service. value
+// is the new value reported for set; it is nil for unset.
+func modifyPlist(action, label string, path plist.KeyPath, value interface{}, reload bool, change func(*plist.Document) (bool, error)) error {
+	scanner, manager, _ := buildDeps()
+
+	svc, err := scanner.FindByLabel(label)
+	if err != nil {
+		return fmt.Errorf("failed to find service %q: %w", label, err)
+	}
+	if err := checkWritablePlist(svc, action); err != nil {
+		return err
+	}
+
+	doc, err := plist.ReadDocument(svc.PlistPath)
+	if err != nil {
+		return err
+	}
+	plistPath := svc.PlistPath
+	previous, _ := doc.Lookup(path)
+
+	changed, err := change(doc)
+	if err != nil {
+		return fmt.Errorf("failed to %s %s: %w", action, path, err)
+	}
+
+	if changed {
+		if _, err := doc.Plist(); err != nil {
+			return fmt.Errorf("failed to %s %s: %w", action, path, err)
+		}
+		// With --simulate, the change goes to a copy in the simulation's
+		// scratch tree.
+		if plistPath, err = simulatedPath(plistPath); err != nil {
+			return err
+		}
+		if err := plist.NewWriter().WriteDocument(doc, plistPath); err != nil {
+			return fmt.Errorf("failed to write plist: %w", err)
+		}
+	}
+
+	reloaded := false
+	if reload && changed {
+		if !jsonFlag {
+			fmt.Printf("Reloading %s...\n", label)
+		}
+		_ = manager.Unload(label)
+		if err := manager.Load(plistPath); err != nil {
+			return fmt.Errorf("failed to reload service: %w", err)
+		}
+		reloaded = true
+	}
+
+	if jsonFlag {
+		return printJSON(jsonSet{
+			OK:        true,
+			Action:    action,
+			Label:     label,
+			PlistPath: plistPath,
+			Key:       path.String(),
+			Value:     value,
+			Previous:  previous,
+			Changed:   changed,
+			Reloaded:  reloaded,
+		})
+	}
+
+	switch {
+	case action == "unset" && changed:
+		fmt.Printf("Removed %s from %s\n", path, plistPath)
+	case action == "unset":
+		fmt.Printf("%s is not set in %s\n", path, plistPath)
+	case changed:
+		fmt.Printf("Set %s in %s\n", path, plistPath)
+	default:
+		fmt.Printf("%s is already set to that value in %s\n", path, plistPath)
+	}
+	if reloaded {
+		fmt.Println("Service reloaded.")
+	}
+	return nil
+}
+
+// checkWritablePlist reports why the plist of svc cannot be modified.
+func checkWritablePlist(svc *agent.Service, action string) error {
+	switch {
+	case svc.PlistPath == "":
+		return fmt.Errorf("service %q has no plist on disk", svc.Label)
+	case svc.PlistDangling:
+		return fmt.Errorf("cannot %s %q: plist %s is a dangling symlink", action, svc.Label, svc.PlistPath)
+	case svc.IsSIPProtected():
+		return fmt.Errorf("cannot %s %q: service is SIP-protected", action, svc.Label)
+	}
+	return nil
+}
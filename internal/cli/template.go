@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+var (
+	templateShowRender bool
+	templateShowLabel  string
+	templateShowParams []string
+	templateNewFrom    string
+	templateNewFormat  string
+	templateNewForce   bool
+)
+
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "List, show and scaffold plist templates",
+	Long: `Manage the templates "lanchr create --template" starts from. Besides the
+built-in templates, every .yaml, .yml, .json or .plist file in
+~/.config/lanchr/templates (or $XDG_CONFIG_HOME/lanchr/templates) is a
+template named after the file. A template declares its parameters, their
+defaults and the binaries it needs, and its plist strings are text/template
+source: {{ .name }} for a parameter, and {{ .Label }}, {{ .Home }} and
+{{ .User }}. A file template replaces a built-in one of the same name.`,
+}
+
+var templateListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List built-in and user templates",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		templates, errs := plist.Templates(plist.DefaultTemplateDir())
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+
+		if jsonFlag {
+			out := make([]jsonTemplate, 0, len(templates))
+			for i := range templates {
+				out = append(out, toJSONTemplate(&templates[i], nil))
+			}
+			return printJSON(out)
+		}
+
+		fmt.Printf("%-18s  %-10s  %s\n", "NAME", "SOURCE", "DESCRIPTION")
+		for _, t := range templates {
+			source := "built-in"
+			if t.Source != "" {
+				source = "user"
+			}
+			fmt.Printf("%-18s  %-10s  %s\n", t.Name, source, t.Description)
+		}
+		return nil
+	},
+}
+
+var templateShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Print a template, or the plist it renders to",
+	Long: `Print a template definition. With --render, fill in the template with
+--label and --param values and print the resulting plist instead, exactly as
+"lanchr create --template" would start from it.`,
+	Example: `  lanchr template show interval
+  lanchr template show backup --render --label com.example.backup --param repo=/Volumes/Backup`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tmpl, err := plist.LookupTemplate(args[0], plist.DefaultTemplateDir())
+		if err != nil {
+			return err
+		}
+
+		if templateShowRender {
+			params, err := parseTemplateParams(templateShowParams)
+			if err != nil {
+				return err
+			}
+			doc, err := tmpl.Render(templateShowLabel, params)
+			if err != nil {
+				return err
+			}
+			if jsonFlag {
+				return printJSON(toJSONTemplate(tmpl, doc.Root))
+			}
+			data, err := doc.Encode()
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(data))
+			return nil
+		}
+
+		if jsonFlag {
+			return printJSON(toJSONTemplate(tmpl, tmpl.Body()))
+		}
+		if tmpl.Source != "" {
+			data, err := os.ReadFile(tmpl.Source)
+			if err != nil {
+				return fmt.Errorf("failed to read template %s: %w", tmpl.Source, err)
+			}
+			fmt.Printf("# %s\n", tmpl.Source)
+			fmt.Print(string(data))
+			return nil
+		}
+		data, err := plist.EncodeTemplate(tmpl, "yaml")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("# built-in template %q\n", tmpl.Name)
+		fmt.Print(string(data))
+		return nil
+	},
+}
+
+var templateNewCmd = &cobra.Command{
+	Use:   "new <name>",
+	Short: "Scaffold a user template",
+	Long: `Write a new template to ~/.config/lanchr/templates/<name>.<format>. It starts
+from an example with program and interval parameters or, with --from, from a
+copy of another template to adapt.`,
+	Example: `  lanchr template new backup
+  lanchr template new nightly --from calendar --format json`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if name == "" || strings.ContainsAny(name, "/\\") || strings.HasPrefix(name, ".") {
+			return fmt.Errorf("invalid template name %q", name)
+		}
+		format := strings.ToLower(templateNewFormat)
+		switch format {
+		case "yaml", "yml", "json", "plist":
+		default:
+			return fmt.Errorf("unknown template format %q (use yaml, json or plist)", templateNewFormat)
+		}
+
+		dir := plist.DefaultTemplateDir()
+		if !templateNewForce {
+			for _, e := range plist.TemplateExtensions {
+				if existing := filepath.Join(dir, name+e); isFile(existing) {
+					return fmt.Errorf("template %q already exists at %s (use --force to replace it)", name, existing)
+				}
+			}
+		}
+
+		tmpl := plist.StarterTemplate(name)
+		if templateNewFrom != "" {
+			from, err := plist.LookupTemplate(templateNewFrom, dir)
+			if err != nil {
+				return err
+			}
+			tmpl = from
+		}
+		data, err := plist.EncodeTemplate(tmpl, format)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, name+"."+format)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create template directory %s: %w", dir, err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write template %s: %w", path, err)
+		}
+
+		if jsonFlag {
+			return printJSON(jsonTemplateNew{OK: true, Action: "template-new", Name: name, Path: path})
+		}
+		fmt.Printf("Created %s\n", path)
+		return nil
+	},
+}
+
+func init() {
+	templateShowCmd.Flags().BoolVar(&templateShowRender, "render", false, "Print the rendered plist instead of the template")
+	templateShowCmd.Flags().StringVarP(&templateShowLabel, "label", "l", "", "Service label to render with")
+	templateShowCmd.Flags().StringArrayVar(&templateShowParams, "param", nil, "Template parameter (NAME=VALUE, repeatable)")
+	templateNewCmd.Flags().StringVar(&templateNewFrom, "from", "", "Start from a copy of this template")
+	templateNewCmd.Flags().StringVar(&templateNewFormat, "format", "yaml", "File format (yaml, json or plist)")
+	templateNewCmd.Flags().BoolVar(&templateNewForce, "force", false, "Replace an existing template")
+
+	templateCmd.AddCommand(templateListCmd)
+	templateCmd.AddCommand(templateShowCmd)
+	templateCmd.AddCommand(templateNewCmd)
+
+	// Templates only read and write files.
+	localCommands["template list"] = always
+	localCommands["template show"] = always
+	localCommands["template new"] = always
+}
+
+// parseTemplateParams parses repeated NAME=VALUE flags.
+func parseTemplateParams(flags []string) (map[string]string, error) {
+	params := make(map[string]string, len(flags))
+	for _, f := range flags {
+		name, value, ok := strings.Cut(f, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --param %q (use NAME=VALUE)", f)
+		}
+		params[name] = value
+	}
+	return params, nil
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+var unsetReload bool
+
+var unsetCmd = &cobra.Command{
+	Use:   "unset <label> <key>",
+	Short: "Remove a plist key",
+	Long:  "Remove a key from a service's plist. Keys are addressed as for 'lanchr set'. Removing a key that is not set is not an error.",
+	Example: `  lanchr unset com.example.agent KeepAlive
+  lanchr unset com.example.agent EnvironmentVariables.DEBUG`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		label := args[0]
+
+		path, err := plist.ParseKeyPath(args[1])
+		if err != nil {
+			return err
+		}
+		if len(path) == 1 && path[0] == "Label" {
+			return fmt.Errorf("cannot unset Label: it is required")
+		}
+
+		return modifyPlist("unset", label, path, nil, unsetReload, func(doc *plist.Document) (bool, error) {
+			return doc.UnsetPath(path)
+		})
+	},
+}
+
+func init() {
+	unsetCmd.Flags().BoolVar(&unsetReload, "reload", false, "Bootout and bootstrap the service after a change")
+}
@@ -0,0 +1,303 @@
+// Package cron parses cron expressions and crontabs and expands them into
+// launchd StartCalendarInterval entries.
+package cron
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Spec is a parsed cron expression.
+type Spec struct {
+	// Calendar holds the StartCalendarInterval entries the expression
+	// expands to. A key that is missing matches every value, so
+	// "* * * * *" is a single empty entry.
+	Calendar []map[string]int
+	// Reboot is set for @reboot, which runs once when the job is loaded
+	// and has no calendar entries.
+	Reboot bool
+}
+
+// field is one of the five fields of a cron expression.
+type field struct {
+	key      string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	monthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	dayNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+// fields lists the cron fields in expression order. Weekday 7 is Sunday,
+// as in cron.
+var fields = []field{
+	{"Minute", 0, 59, nil},
+	{"Hour", 0, 23, nil},
+	{"Day", 1, 31, nil},
+	{"Month", 1, 12, monthNames},
+	{"Weekday", 0, 7, dayNames},
+}
+
+// MaxEntries is the most StartCalendarInterval entries an expression may
+// expand to. launchd evaluates every entry, and a plist of thousands of
+// them is unreadable; a job that runs that often is better served by
+// StartInterval.
+const MaxEntries = 1000
+
+// expansionOrder is the order in which entries are generated, coarsest
+// field first, so that they read chronologically.
+var expansionOrder = []string{"Month", "Day", "Weekday", "Hour", "Minute"}
+
+// macros maps the @ shorthands to their expressions.
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a five-field cron expression ("minute hour day month
+// weekday") or an @ shorthand such as @daily or @reboot. Fields take
+// numbers, month and day names (JAN, MON), ranges (1-5), lists (1,15),
+// steps (*/15, 0-30/10) and combinations of these.
+//
+// launchd cannot express every expression: it runs an entry that sets both
+// Day and Weekday when either matches, which is how cron treats two
+// restricted day fields, but has no way to require both, as cron does when
+// one of them starts with "*". Such expressions are errors.
+func Parse(expr string) (*Spec, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@") {
+		name := strings.ToLower(expr)
+		if name == "@reboot" {
+			return &Spec{Reboot: true}, nil
+		}
+		full, ok := macros[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown shorthand %q", expr)
+		}
+		expr = full
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("expected 5 fields (minute hour day month weekday), got %d", len(parts))
+	}
+
+	// values holds the allowed values of each restricted field; a field
+	// that matches everything is left out.
+	values := make(map[string][]int)
+	for i, f := range fields {
+		vals, all, err := f.parse(parts[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", strings.ToLower(f.key), parts[i], err)
+		}
+		if !all {
+			values[f.key] = vals
+		}
+	}
+
+	// Like cron, decide from the text how the day fields combine, not from
+	// the values they cover: both must match if either starts with "*",
+	// and either one otherwise.
+	dayStar := strings.HasPrefix(parts[2], "*")
+	weekdayStar := strings.HasPrefix(parts[4], "*")
+	_, dayRestricted := values["Day"]
+	_, weekdayRestricted := values["Weekday"]
+	if !dayStar && !weekdayStar && (!dayRestricted || !weekdayRestricted) {
+		// Either field matches every day, such as 1-31, so the job runs
+		// every day.
+		delete(values, "Day")
+		delete(values, "Weekday")
+		dayRestricted, weekdayRestricted = false, false
+	}
+	if !dayRestricted || !weekdayRestricted {
+		if err := checkSize(values); err != nil {
+			return nil, err
+		}
+		return &Spec{Calendar: expand(values)}, nil
+	}
+	if dayStar || weekdayStar {
+		return nil, fmt.Errorf("launchd cannot require both a day of the month (%s) and a day of the week (%s)", parts[2], parts[4])
+	}
+
+	// Both day fields are restricted: cron runs when either matches, so
+	// the entries are those of each day field alone.
+	byDay := copyValues(values)
+	delete(byDay, "Weekday")
+	byWeekday := copyValues(values)
+	delete(byWeekday, "Day")
+	if err := checkSize(byDay, byWeekday); err != nil {
+		return nil, err
+	}
+	return &Spec{Calendar: append(expand(byDay), expand(byWeekday)...)}, nil
+}
+
+// checkSize returns an error if expanding all of sets would produce more
+// than MaxEntries entries.
+func checkSize(sets ...map[string][]int) error {
+	total := 0
+	for _, values := range sets {
+		n := 1
+		for _, vals := range values {
+			n *= len(vals)
+		}
+		total += n
+	}
+	if total > MaxEntries {
+		return fmt.Errorf("expression expands to %d calendar entries, more than the %d allowed; run the job at a fixed interval with StartInterval instead, or split it into coarser schedules", total, MaxEntries)
+	}
+	return nil
+}
+
+// parse parses one field. It returns the sorted values the field allows
+// and whether they cover the whole range.
+func (f field) parse(text string) ([]int, bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(text, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return nil, false, fmt.Errorf("step must be a positive number")
+			}
+			step = n
+		}
+
+		var lo, hi int
+		switch {
+		case rng == "*":
+			lo, hi = f.min, f.max
+			if f.key == "Weekday" {
+				hi = 6
+			}
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return nil, false, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return nil, false, err
+			}
+			if lo > hi {
+				return nil, false, fmt.Errorf("range %s starts after it ends", rng)
+			}
+		default:
+			var err error
+			if lo, err = f.value(rng); err != nil {
+				return nil, false, err
+			}
+			hi = lo
+			if hasStep {
+				hi = f.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			if f.key == "Weekday" {
+				set[v%7] = true
+			} else {
+				set[v] = true
+			}
+		}
+	}
+
+	vals := make([]int, 0, len(set))
+	for v := range set {
+		vals = append(vals, v)
+	}
+	sort.Ints(vals)
+
+	size := f.max - f.min + 1
+	if f.key == "Weekday" {
+		size = 7
+	}
+	return vals, len(vals) == size, nil
+}
+
+// value parses a single number or name.
+func (f field) value(text string) (int, error) {
+	if v, ok := f.names[strings.ToLower(text)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", text)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%d is out of range %d-%d", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// expand returns every combination of the restricted fields' values.
+func expand(values map[string][]int) []map[string]int {
+	entries := []map[string]int{{}}
+	for _, key := range expansionOrder {
+		vals, ok := values[key]
+		if !ok {
+			continue
+		}
+		next := make([]map[string]int, 0, len(entries)*len(vals))
+		for _, e := range entries {
+			for _, v := range vals {
+				entry := make(map[string]int, len(e)+1)
+				for k, ev := range e {
+					entry[k] = ev
+				}
+				entry[key] = v
+				next = append(next, entry)
+			}
+		}
+		entries = next
+	}
+	return entries
+}
+
+func copyValues(values map[string][]int) map[string][]int {
+	c := make(map[string][]int, len(values))
+	for k, v := range values {
+		c[k] = v
+	}
+	return c
+}
+
+// StartCalendarInterval returns the spec's calendar entries in the form
+// LaunchAgentPlist takes.
+func (s *Spec) StartCalendarInterval() interface{} {
+	return CalendarValue(s.Calendar)
+}
+
+// CalendarValue converts calendar entries to a StartCalendarInterval value:
+// nil for none, one dictionary, or an array of them.
+func CalendarValue(calendar []map[string]int) interface{} {
+	entries := make([]interface{}, 0, len(calendar))
+	for _, c := range calendar {
+		entry := make(map[string]interface{}, len(c))
+		for k, v := range c {
+			entry[k] = v
+		}
+		entries = append(entries, entry)
+	}
+	switch len(entries) {
+	case 0:
+		return nil
+	case 1:
+		return entries[0]
+	}
+	return entries
+}
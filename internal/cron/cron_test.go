@@ -0,0 +1,96 @@
+package cron
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []map[string]int
+	}{
+		{"* * * * *", []map[string]int{{}}},
+		{"30 9 * * *", []map[string]int{{"Hour": 9, "Minute": 30}}},
+		{"*/20 * * * *", []map[string]int{{"Minute": 0}, {"Minute": 20}, {"Minute": 40}}},
+		{"0 0-4/2 * * *", []map[string]int{{"Hour": 0, "Minute": 0}, {"Hour": 2, "Minute": 0}, {"Hour": 4, "Minute": 0}}},
+		{"0 22/1 * * *", []map[string]int{{"Hour": 22, "Minute": 0}, {"Hour": 23, "Minute": 0}}},
+		{"0 12 * JAN,jul *", []map[string]int{{"Month": 1, "Hour": 12, "Minute": 0}, {"Month": 7, "Hour": 12, "Minute": 0}}},
+		{"0 8 * * SAT-7", []map[string]int{{"Weekday": 0, "Hour": 8, "Minute": 0}, {"Weekday": 6, "Hour": 8, "Minute": 0}}},
+		{"0 0 * * 0-7", []map[string]int{{"Hour": 0, "Minute": 0}}},
+		{"0-59 0-23 1-31 1-12 0-6", []map[string]int{{}}},
+		{"@weekly", []map[string]int{{"Weekday": 0, "Hour": 0, "Minute": 0}}},
+		{"@YEARLY", []map[string]int{{"Month": 1, "Day": 1, "Hour": 0, "Minute": 0}}},
+		// Two restricted day fields match either, as in cron.
+		{"0 6 1 * mon", []map[string]int{{"Day": 1, "Hour": 6, "Minute": 0}, {"Weekday": 1, "Hour": 6, "Minute": 0}}},
+		// An explicit day range covering the month still matches either,
+		// so the job runs every day.
+		{"0 9 1-31 * mon", []map[string]int{{"Hour": 9, "Minute": 0}}},
+		{"0 9 15 * 0-6", []map[string]int{{"Hour": 9, "Minute": 0}}},
+	}
+	for _, tt := range tests {
+		spec, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(spec.Calendar, tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.expr, spec.Calendar, tt.want)
+		}
+	}
+
+	spec, err := Parse("*/15 9-17 * * MON-FRI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(spec.Calendar); n != 4*9*5 {
+		t.Errorf("Parse(*/15 9-17 * * MON-FRI) has %d entries, want 180", n)
+	}
+	if first := spec.Calendar[0]; !reflect.DeepEqual(first, map[string]int{"Weekday": 1, "Hour": 9, "Minute": 0}) {
+		t.Errorf("first entry = %v", first)
+	}
+
+	reboot, err := Parse("@reboot")
+	if err != nil || !reboot.Reboot || reboot.Calendar != nil {
+		t.Errorf("Parse(@reboot) = %+v, %v, want Reboot without calendar", reboot, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"* * * *", "expected 5 fields"},
+		{"@sometimes", "unknown shorthand"},
+		{"60 * * * *", "invalid minute"},
+		{"* 24 * * *", "out of range 0-23"},
+		{"* * 0 * *", "invalid day"},
+		{"* * * FOO *", `"FOO" is not a number`},
+		{"*/0 * * * *", "step must be a positive number"},
+		{"5-1 * * * *", "starts after it ends"},
+		{"0 0 */2 * 1", "cannot require both"},
+		{"0-58 0-22 * * *", "expands to 1357 calendar entries"},
+		// Each day field alone stays under the limit, but not both.
+		{"0-40 0-22 1 * 1", "expands to 1886 calendar entries"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCalendarValue(t *testing.T) {
+	if v := CalendarValue(nil); v != nil {
+		t.Errorf("CalendarValue(nil) = %v, want nil", v)
+	}
+	if v, ok := CalendarValue([]map[string]int{{"Hour": 9}}).(map[string]interface{}); !ok || v["Hour"] != 9 {
+		t.Errorf("one entry = %v, want a dictionary", v)
+	}
+	if v, ok := CalendarValue([]map[string]int{{"Hour": 9}, {"Hour": 17}}).([]interface{}); !ok || len(v) != 2 {
+		t.Errorf("two entries = %v, want an array of two", v)
+	}
+}
@@ -0,0 +1,162 @@
+package cron
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// Entry is one job of a crontab.
+type Entry struct {
+	// Line is the entry's line number in the crontab.
+	Line int
+	// Schedule is the cron expression as written.
+	Schedule string
+	Command  string
+	Spec     *Spec
+	// Env holds the variables set above the entry, as cron passes them to
+	// the command.
+	Env map[string]string
+}
+
+var envLineRe = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.*)$`)
+
+// ParseCrontab reads a user crontab, as "crontab -l" prints it. Variable
+// assignments apply to the entries below them. Every line that cannot be
+// parsed is reported, with its line number, in the returned error.
+//
+// A "%" in a command starts cron's standard input, which launchd has no
+// equivalent for, so an unescaped one is an error; "\%" is a literal
+// percent sign.
+func ParseCrontab(r io.Reader) ([]Entry, error) {
+	var (
+		entries []Entry
+		errs    []error
+		env     = make(map[string]string)
+	)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := envLineRe.FindStringSubmatch(line); m != nil {
+			env[m[1]] = unquote(m[2])
+			continue
+		}
+
+		entry, err := parseEntry(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", n, err))
+			continue
+		}
+		entry.Line = n
+		entry.Env = make(map[string]string, len(env))
+		for k, v := range env {
+			entry.Env[k] = v
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read crontab: %w", err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return entries, nil
+}
+
+// parseEntry splits a job line into its schedule and command.
+func parseEntry(line string) (Entry, error) {
+	n := len(fields)
+	if strings.HasPrefix(line, "@") {
+		n = 1
+	}
+	rest := line
+	var schedule []string
+	for i := 0; i < n; i++ {
+		rest = strings.TrimLeft(rest, " \t")
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			return Entry{}, fmt.Errorf("no command after the schedule")
+		}
+		schedule = append(schedule, rest[:end])
+		rest = rest[end:]
+	}
+
+	command := strings.TrimSpace(rest)
+	if unescapedPercent(command) >= 0 {
+		return Entry{}, fmt.Errorf("%% in a command (standard input) has no launchd equivalent; escape it as \\%%")
+	}
+	command = strings.ReplaceAll(command, `\%`, "%")
+
+	expr := strings.Join(schedule, " ")
+	spec, err := Parse(expr)
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid schedule %q: %w", expr, err)
+	}
+	return Entry{Schedule: expr, Command: command, Spec: spec}, nil
+}
+
+// unescapedPercent returns the index of the first "%" not preceded by a
+// backslash, or -1.
+func unescapedPercent(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '%':
+			return i
+		}
+	}
+	return -1
+}
+
+// unquote strips one pair of matching single or double quotes.
+func unquote(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// Plist converts the entry into a launchd job with the given label. The
+// command runs as cron runs it, through $SHELL (/bin/sh by default) with
+// -c, and the crontab's variables become its environment. SHELL and
+// MAILTO configure cron itself and are not passed on. launchd does not
+// mail output; when MAILTO is set to "", cron discards it, and so does the
+// job, by logging to /dev/null.
+func (e *Entry) Plist(label string) *plist.LaunchAgentPlist {
+	shell := e.Env["SHELL"]
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	pl := &plist.LaunchAgentPlist{
+		Label:                 label,
+		ProgramArguments:      []string{shell, "-c", e.Command},
+		StartCalendarInterval: e.Spec.StartCalendarInterval(),
+		RunAtLoad:             e.Spec.Reboot,
+	}
+
+	env := make(map[string]string)
+	for k, v := range e.Env {
+		if k != "SHELL" && k != "MAILTO" {
+			env[k] = v
+		}
+	}
+	if len(env) > 0 {
+		pl.EnvironmentVariables = env
+	}
+
+	if mailto, ok := e.Env["MAILTO"]; ok && mailto == "" {
+		pl.StandardOutPath = "/dev/null"
+		pl.StandardErrorPath = "/dev/null"
+	}
+	return pl
+}
@@ -0,0 +1,87 @@
+package cron
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const crontab = `# m h dom mon dow command
+SHELL=/bin/bash
+PATH = "/usr/local/bin:/usr/bin:/bin"
+MAILTO=me@example.com
+
+*/30 * * * *	/usr/local/bin/sync.sh  --quiet
+MAILTO=""
+@reboot /usr/local/bin/agent --init
+0 1 * * * date +\%F >> /tmp/dates
+`
+
+func TestParseCrontab(t *testing.T) {
+	entries, err := ParseCrontab(strings.NewReader(crontab))
+	if err != nil {
+		t.Fatalf("ParseCrontab() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+
+	sync := entries[0]
+	if sync.Line != 6 || sync.Schedule != "*/30 * * * *" || sync.Command != "/usr/local/bin/sync.sh  --quiet" {
+		t.Errorf("entries[0] = line %d, %q, %q", sync.Line, sync.Schedule, sync.Command)
+	}
+	if sync.Env["PATH"] != "/usr/local/bin:/usr/bin:/bin" || sync.Env["MAILTO"] != "me@example.com" {
+		t.Errorf("entries[0].Env = %v", sync.Env)
+	}
+	if !entries[1].Spec.Reboot || entries[1].Env["MAILTO"] != "" {
+		t.Errorf("entries[1] = %+v, want @reboot with MAILTO cleared", entries[1])
+	}
+	if entries[2].Command != "date +%F >> /tmp/dates" {
+		t.Errorf("entries[2].Command = %q, want the escaped %% unescaped", entries[2].Command)
+	}
+}
+
+func TestParseCrontabErrors(t *testing.T) {
+	_, err := ParseCrontab(strings.NewReader("* * * * *\n0 25 * * * /bin/true\n0 0 * * * echo 50%\n"))
+	if err == nil {
+		t.Fatal("ParseCrontab() error = nil")
+	}
+	for _, want := range []string{"line 1: no command", "line 2: invalid schedule", "line 3: % in a command"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestEntryPlist(t *testing.T) {
+	entries, err := ParseCrontab(strings.NewReader(crontab))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pl := entries[0].Plist("com.example.sync")
+	if want := []string{"/bin/bash", "-c", "/usr/local/bin/sync.sh  --quiet"}; !reflect.DeepEqual(pl.ProgramArguments, want) {
+		t.Errorf("ProgramArguments = %v, want %v", pl.ProgramArguments, want)
+	}
+	if want := map[string]string{"PATH": "/usr/local/bin:/usr/bin:/bin"}; !reflect.DeepEqual(pl.EnvironmentVariables, want) {
+		t.Errorf("EnvironmentVariables = %v, want %v without SHELL and MAILTO", pl.EnvironmentVariables, want)
+	}
+	if cal, ok := pl.StartCalendarInterval.([]interface{}); !ok || len(cal) != 2 {
+		t.Errorf("StartCalendarInterval = %v, want two entries", pl.StartCalendarInterval)
+	}
+	if pl.StandardOutPath != "" {
+		t.Errorf("StandardOutPath = %q, want it left to the caller", pl.StandardOutPath)
+	}
+
+	reboot := entries[1].Plist("com.example.agent")
+	if !reboot.RunAtLoad || reboot.StartCalendarInterval != nil {
+		t.Errorf("@reboot plist = RunAtLoad %v, calendar %v", reboot.RunAtLoad, reboot.StartCalendarInterval)
+	}
+	if reboot.StandardOutPath != "/dev/null" || reboot.StandardErrorPath != "/dev/null" {
+		t.Errorf("MAILTO=\"\" plist logs to %q and %q, want /dev/null", reboot.StandardOutPath, reboot.StandardErrorPath)
+	}
+
+	if sh := (&Entry{Command: "true", Spec: &Spec{}}).Plist("x").ProgramArguments[0]; sh != "/bin/sh" {
+		t.Errorf("default shell = %q, want /bin/sh", sh)
+	}
+}
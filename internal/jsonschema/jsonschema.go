@@ -0,0 +1,218 @@
+// Package jsonschema generates JSON Schemas (draft 2020-12) from Go types
+// and validates decoded JSON against them. It supports the subset of the
+// specification that lanchr's own documents need.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Draft is the JSON Schema dialect generated schemas declare.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema.
+type Schema struct {
+	Schema          string        `json:"$schema,omitempty"`
+	ID              string        `json:"$id,omitempty"`
+	Title           string        `json:"title,omitempty"`
+	Description     string        `json:"description,omitempty"`
+	Type            Types         `json:"type,omitempty"`
+	Format          string        `json:"format,omitempty"`
+	ContentEncoding string        `json:"contentEncoding,omitempty"`
+	Enum            []interface{} `json:"enum,omitempty"`
+	Minimum         *int          `json:"minimum,omitempty"`
+	Maximum         *int          `json:"maximum,omitempty"`
+	Properties      Properties    `json:"properties,omitempty"`
+	Required        []string      `json:"required,omitempty"`
+	// AdditionalProperties is nil, a bool, or a *Schema.
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+	Items                *Schema     `json:"items,omitempty"`
+	OneOf                []*Schema   `json:"oneOf,omitempty"`
+}
+
+// Types is the "type" keyword: a single type name, or several.
+type Types []string
+
+// MarshalJSON encodes a single type as a string and several as an array.
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// Property is one named entry of Properties.
+type Property struct {
+	Name   string
+	Schema *Schema
+}
+
+// Properties is the "properties" keyword, in declaration order.
+type Properties []Property
+
+// MarshalJSON encodes the properties as a JSON object in order.
+func (p Properties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, prop := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(prop.Name)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(prop.Schema)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Get returns the schema of the named property.
+func (p Properties) Get(name string) (*Schema, bool) {
+	for _, prop := range p {
+		if prop.Name == name {
+			return prop.Schema, true
+		}
+	}
+	return nil, false
+}
+
+// Type returns a schema that only checks the type.
+func Type(names ...string) *Schema {
+	return &Schema{Type: names}
+}
+
+// Range returns an integer schema bounded by min and max.
+func Range(min, max int) *Schema {
+	return &Schema{Type: Types{"integer"}, Minimum: &min, Maximum: &max}
+}
+
+// Reflector generates schemas from Go types.
+type Reflector struct {
+	// Tag is the struct tag that names properties, such as "json" or
+	// "plist". Tag options follow encoding/json: a field named "-" is
+	// skipped, and omitempty makes a property optional.
+	Tag string
+	// Overrides replaces the schema of struct fields, keyed by
+	// "TypeName.FieldName". Fields of interface type need one to be more
+	// specific than "any value".
+	Overrides map[string]*Schema
+	// AllowAdditional permits properties a struct does not declare.
+	AllowAdditional bool
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// Reflect returns the schema of values of type t as encoded by
+// encoding/json.
+func (r *Reflector) Reflect(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	case t == bytesType:
+		return &Schema{Type: Types{"string"}, ContentEncoding: "base64"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return r.Reflect(t.Elem())
+	case reflect.String:
+		return Type("string")
+	case reflect.Bool:
+		return Type("boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Type("integer")
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		min := 0
+		return &Schema{Type: Types{"integer"}, Minimum: &min}
+	case reflect.Float32, reflect.Float64:
+		return Type("number")
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: Types{"array"}, Items: r.Reflect(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: Types{"object"}, AdditionalProperties: r.Reflect(t.Elem())}
+	case reflect.Struct:
+		return r.reflectStruct(t)
+	}
+	// Interfaces accept any value.
+	return &Schema{}
+}
+
+func (r *Reflector) reflectStruct(t reflect.Type) *Schema {
+	s := &Schema{Type: Types{"object"}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, omitEmpty := r.fieldName(f)
+		if name == "" {
+			continue
+		}
+
+		prop, ok := r.Overrides[t.Name()+"."+f.Name]
+		if !ok {
+			prop = r.Reflect(f.Type)
+			// Without omitempty, nil slices, maps and pointers are encoded
+			// as null.
+			if !omitEmpty && len(prop.Type) == 1 {
+				switch f.Type.Kind() {
+				case reflect.Slice, reflect.Map, reflect.Ptr:
+					if f.Type != bytesType {
+						prop = withNull(prop)
+					}
+				}
+			}
+		}
+		s.Properties = append(s.Properties, Property{Name: name, Schema: prop})
+		if !omitEmpty {
+			s.Required = append(s.Required, name)
+		}
+	}
+	if !r.AllowAdditional {
+		s.AdditionalProperties = false
+	}
+	return s
+}
+
+// fieldName returns the property name of f and whether it is omitted when
+// empty. It returns "" for skipped fields.
+func (r *Reflector) fieldName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get(r.Tag)
+	if tag == "-" {
+		return "", false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	omitEmpty := false
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty
+}
+
+// withNull returns a copy of s that also accepts null.
+func withNull(s *Schema) *Schema {
+	c := *s
+	c.Type = append(Types{}, s.Type...)
+	c.Type = append(c.Type, "null")
+	return &c
+}
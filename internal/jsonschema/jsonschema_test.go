@@ -0,0 +1,101 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testInner struct {
+	Name string `json:"name"`
+}
+
+type testDoc struct {
+	ID       int               `json:"id"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Inner    *testInner        `json:"inner,omitempty"`
+	Created  time.Time         `json:"created"`
+	Blob     []byte            `json:"blob,omitempty"`
+	Skipped  string            `json:"-"`
+	Untagged bool
+	Any      interface{} `json:"any,omitempty"`
+}
+
+func decode(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestReflect(t *testing.T) {
+	r := &Reflector{Tag: "json"}
+	s := r.Reflect(reflect.TypeOf(testDoc{}))
+
+	var names []string
+	for _, p := range s.Properties {
+		names = append(names, p.Name)
+	}
+	if want := []string{"id", "tags", "labels", "inner", "created", "blob", "Untagged", "any"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("properties = %v, want %v", names, want)
+	}
+	if want := []string{"id", "tags", "created", "Untagged"}; !reflect.DeepEqual(s.Required, want) {
+		t.Errorf("required = %v, want %v", s.Required, want)
+	}
+	if tags, _ := s.Properties.Get("tags"); !reflect.DeepEqual(tags.Type, Types{"array", "null"}) {
+		t.Errorf("tags type = %v, want array or null", tags.Type)
+	}
+	if created, _ := s.Properties.Get("created"); created.Format != "date-time" {
+		t.Errorf("created format = %q, want date-time", created.Format)
+	}
+	if blob, _ := s.Properties.Get("blob"); blob.ContentEncoding != "base64" {
+		t.Errorf("blob contentEncoding = %q, want base64", blob.ContentEncoding)
+	}
+
+	out, err := json.Marshal(Type("string"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"type":"string"}` {
+		t.Errorf("Marshal(Type(string)) = %s", out)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	r := &Reflector{Tag: "json", Overrides: map[string]*Schema{
+		"testDoc.Any": {OneOf: []*Schema{Type("boolean"), Range(1, 5)}},
+	}}
+	s := r.Reflect(reflect.TypeOf(testDoc{}))
+
+	valid := testDoc{ID: 1, Labels: map[string]string{"a": "b"}, Inner: &testInner{Name: "x"}, Any: 3}
+	if err := Validate(s, decode(t, valid)); err != nil {
+		t.Errorf("Validate(valid) error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		wantErr string
+	}{
+		{"wrong type", map[string]interface{}{"id": "1", "tags": nil, "created": "", "Untagged": false}, "$.id: expected integer, got string"},
+		{"missing required", map[string]interface{}{"id": 1, "tags": nil, "created": ""}, `missing required property "Untagged"`},
+		{"unexpected property", map[string]interface{}{"id": 1, "tags": nil, "created": "", "Untagged": false, "extra": 1}, `unexpected property "extra"`},
+		{"nested", map[string]interface{}{"id": 1, "tags": []interface{}{"a", 2}, "created": "", "Untagged": false}, "$.tags[1]: expected string"},
+		{"one of", map[string]interface{}{"id": 1, "tags": nil, "created": "", "Untagged": false, "any": 9.0}, "$.any: matches none"},
+	}
+	for _, tt := range tests {
+		err := Validate(s, decode(t, tt.value))
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: Validate() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
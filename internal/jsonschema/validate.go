@@ -0,0 +1,133 @@
+package jsonschema
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Validate checks v, a value decoded by encoding/json into an interface{},
+// against s. It reports the first violation, located by a path such as
+// "$.plist.KeepAlive".
+func Validate(s *Schema, v interface{}) error {
+	return validate(s, v, "$")
+}
+
+func validate(s *Schema, v interface{}, path string) error {
+	if len(s.Type) > 0 && !matchesType(s.Type, v) {
+		return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(s.Type, " or "), valueType(v))
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		return fmt.Errorf("%s: %v is not one of %v", path, v, s.Enum)
+	}
+	if n, ok := v.(float64); ok {
+		if s.Minimum != nil && n < float64(*s.Minimum) {
+			return fmt.Errorf("%s: %v is less than %d", path, n, *s.Minimum)
+		}
+		if s.Maximum != nil && n > float64(*s.Maximum) {
+			return fmt.Errorf("%s: %v is greater than %d", path, n, *s.Maximum)
+		}
+	}
+
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := x[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			prop, ok := s.Properties.Get(k)
+			if !ok {
+				switch extra := s.AdditionalProperties.(type) {
+				case bool:
+					if !extra {
+						return fmt.Errorf("%s: unexpected property %q", path, k)
+					}
+					continue
+				case *Schema:
+					prop = extra
+				default:
+					continue
+				}
+			}
+			if err := validate(prop, x[k], path+"."+k); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, el := range x {
+				if err := validate(s.Items, el, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if len(s.OneOf) > 0 {
+		matched := 0
+		var errs []string
+		for _, alt := range s.OneOf {
+			if err := validate(alt, v, path); err != nil {
+				errs = append(errs, err.Error())
+			} else {
+				matched++
+			}
+		}
+		if matched != 1 {
+			if matched == 0 {
+				return fmt.Errorf("%s: matches none of the allowed forms (%s)", path, strings.Join(errs, "; "))
+			}
+			return fmt.Errorf("%s: matches %d of the allowed forms, want exactly one", path, matched)
+		}
+	}
+	return nil
+}
+
+// valueType returns the JSON Schema type name of a decoded JSON value.
+func valueType(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if x == math.Trunc(x) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func matchesType(types Types, v interface{}) bool {
+	got := valueType(v)
+	for _, t := range types {
+		if t == got || (t == "number" && got == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package launchctl
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DomainInfo holds parsed output from "launchctl print <domain-target>".
+type DomainInfo struct {
+	Target   string // "gui/501", "user/501", "system"
+	Type     string // "gui", "user", "system"
+	Handle   string
+	Services []ListEntry
+
+	// Raw is the full parsed tree, for keys not surfaced above.
+	Raw *PrintBlock
+}
+
+// Lookup returns the services table entry for label, if present.
+func (d *DomainInfo) Lookup(label string) (ListEntry, bool) {
+	if d == nil {
+		return ListEntry{}, false
+	}
+	for _, entry := range d.Services {
+		if entry.Label == label {
+			return entry, true
+		}
+	}
+	return ListEntry{}, false
+}
+
+// PrintDomain parses the output of "launchctl print <domain-target>".
+// Unlike "launchctl list", this reports the services loaded in the given
+// domain regardless of the caller's own session.
+func (e *DefaultExecutor) PrintDomain(domainTarget string) (*DomainInfo, error) {
+	out, err := e.run("print", domainTarget)
+	if err != nil {
+		return nil, err
+	}
+
+	info := parsePrintDomainOutput(out)
+	info.Target = domainTarget
+	return info, nil
+}
+
+// parsePrintDomainOutput extracts the services table from domain print output.
+// Each row of the "services = { ... }" block looks like:
+//
+//	     608      -    com.apple.Finder
+//	       0     78    com.example.failing
+//
+// A PID of 0 means the service is loaded but not running (stored as -1), and
+// a status of "-" means it has never exited.
+func parsePrintDomainOutput(data []byte) *DomainInfo {
+	root := parsePrintBlock(data)
+	info := &DomainInfo{
+		Type:   root.Get("type"),
+		Handle: root.Get("handle"),
+		Raw:    root,
+	}
+
+	for _, row := range root.Child("services").Values() {
+		fields := strings.Fields(row)
+		if len(fields) < 3 {
+			continue
+		}
+
+		entry := ListEntry{
+			PID:    -1,
+			Status: 0,
+			Label:  fields[len(fields)-1],
+		}
+
+		if pid, err := strconv.Atoi(fields[0]); err == nil && pid > 0 {
+			entry.PID = pid
+		}
+		if fields[1] != "-" {
+			if status, err := strconv.Atoi(fields[1]); err == nil {
+				entry.Status = status
+			}
+		}
+
+		info.Services = append(info.Services, entry)
+	}
+
+	return info
+}
@@ -0,0 +1,65 @@
+package launchctl
+
+import "testing"
+
+const samplePrintDomain = `system = {
+	type = system
+	handle = 0
+	active count = 512
+	service count = 380
+
+	services = {
+		       0      -    	com.apple.backupd
+		     387      -    	com.openssh.sshd
+		       0     78    	com.example.failing
+		     512     -9    	com.example.killed
+	}
+
+	unmanaged processes = {
+		com.apple.xpc.launchd.unmanaged.loginwindow.123 = {
+			active count = 1
+		}
+	}
+}
+`
+
+func TestParsePrintDomainOutput(t *testing.T) {
+	info := parsePrintDomainOutput([]byte(samplePrintDomain))
+
+	if info.Type != "system" {
+		t.Errorf("got type %q, want %q", info.Type, "system")
+	}
+	if len(info.Services) != 4 {
+		t.Fatalf("got %d services, want 4", len(info.Services))
+	}
+
+	tests := []struct {
+		label      string
+		wantPID    int
+		wantStatus int
+	}{
+		{"com.apple.backupd", -1, 0},
+		{"com.openssh.sshd", 387, 0},
+		{"com.example.failing", -1, 78},
+		{"com.example.killed", 512, -9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			entry, ok := info.Lookup(tt.label)
+			if !ok {
+				t.Fatalf("service %q not found", tt.label)
+			}
+			if entry.PID != tt.wantPID {
+				t.Errorf("got PID %d, want %d", entry.PID, tt.wantPID)
+			}
+			if entry.Status != tt.wantStatus {
+				t.Errorf("got status %d, want %d", entry.Status, tt.wantStatus)
+			}
+		})
+	}
+
+	if _, ok := info.Lookup("com.example.missing"); ok {
+		t.Error("expected lookup of unknown label to fail")
+	}
+}
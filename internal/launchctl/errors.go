@@ -0,0 +1,90 @@
+package launchctl
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Sentinel errors for well-known launchd failure codes. Use errors.Is to
+// test an error returned by an Executor against these.
+var (
+	ErrNotPrivileged   = errors.New("operation not permitted")
+	ErrSIPProtected    = errors.New("operation not permitted while System Integrity Protection is engaged")
+	ErrAlreadyLoaded   = errors.New("service already loaded")
+	ErrInProgress      = errors.New("operation already in progress")
+	ErrServiceNotFound = errors.New("service not found")
+	ErrDomainNotFound  = errors.New("domain not found")
+	ErrServiceDisabled = errors.New("service is disabled")
+	ErrUnsupported     = errors.New("domain does not support the specified action")
+)
+
+// knownCodes maps launchd error codes to sentinel errors. launchctl exits
+// with the same code it prints in messages like
+// "Bootstrap failed: 5: Input/output error".
+var knownCodes = map[int]error{
+	1:   ErrNotPrivileged,   // EPERM
+	3:   ErrServiceNotFound, // ESRCH: no such process
+	5:   ErrAlreadyLoaded,   // EIO: bootstrap of an already-loaded (or invalid) plist
+	17:  ErrAlreadyLoaded,   // EEXIST
+	37:  ErrInProgress,      // EALREADY
+	112: ErrDomainNotFound,  // could not find specified domain
+	113: ErrServiceNotFound, // could not find specified service
+	119: ErrServiceDisabled, // service is disabled
+	125: ErrUnsupported,     // domain does not support specified action
+	150: ErrSIPProtected,    // operation not permitted while SIP is engaged
+}
+
+// failureCodeRe extracts the code from "<Verb> failed: <code>: <message>".
+var failureCodeRe = regexp.MustCompile(`failed: (\d+): `)
+
+// Error is returned by DefaultExecutor when launchctl exits non-zero.
+type Error struct {
+	Args     []string // launchctl arguments, e.g. ["bootstrap", "gui/501", "/path"]
+	ExitCode int      // process exit status, or -1 if it did not run
+	Code     int      // launchd error code, parsed from stderr when available
+	Stderr   string   // trimmed stderr (or stdout when stderr was empty)
+	Err      error    // underlying error from the runner
+}
+
+// newError builds an Error from a failed invocation and its captured output.
+func newError(args []string, exitCode int, stderr string, err error) *Error {
+	e := &Error{
+		Args:     args,
+		ExitCode: exitCode,
+		Code:     exitCode,
+		Stderr:   strings.TrimSpace(stderr),
+		Err:      err,
+	}
+	if m := failureCodeRe.FindStringSubmatch(e.Stderr); m != nil {
+		if code, convErr := strconv.Atoi(m[1]); convErr == nil {
+			e.Code = code
+		}
+	}
+	return e
+}
+
+// Error formats the failure as "launchctl <args>: <stderr>" falling back to
+// the runner error when launchctl printed nothing.
+func (e *Error) Error() string {
+	cmd := "launchctl " + strings.Join(e.Args, " ")
+	if e.Stderr != "" {
+		return fmt.Sprintf("%s: %s", cmd, e.Stderr)
+	}
+	return fmt.Sprintf("%s: %v", cmd, e.Err)
+}
+
+// Kind returns the sentinel error for the launchd code, or nil if unknown.
+func (e *Error) Kind() error {
+	return knownCodes[e.Code]
+}
+
+// Unwrap exposes both the sentinel (for errors.Is) and the runner error.
+func (e *Error) Unwrap() []error {
+	if kind := e.Kind(); kind != nil {
+		return []error{kind, e.Err}
+	}
+	return []error{e.Err}
+}
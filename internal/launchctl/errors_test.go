@@ -0,0 +1,122 @@
+package launchctl
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeRunner returns a canned result for every invocation.
+type fakeRunner struct {
+	out []byte
+	err error
+}
+
+func (f *fakeRunner) Run(_ context.Context, _ string, _ ...string) ([]byte, error) {
+	return f.out, f.err
+}
+
+func TestExecutorTypedErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		exitCode int
+		stderr   string
+		want     error
+		wantText string
+	}{
+		{
+			name:     "already loaded",
+			exitCode: 5,
+			stderr:   "Bootstrap failed: 5: Input/output error\n",
+			want:     ErrAlreadyLoaded,
+			wantText: "Input/output error",
+		},
+		{
+			name:     "service not found",
+			exitCode: 113,
+			stderr:   "Could not find service \"com.example\" in domain for port\n",
+			want:     ErrServiceNotFound,
+			wantText: "Could not find service",
+		},
+		{
+			name:     "in progress",
+			exitCode: 37,
+			stderr:   "Bootout failed: 37: Operation already in progress\n",
+			want:     ErrInProgress,
+		},
+		{
+			name:     "code parsed from message",
+			exitCode: 1,
+			stderr:   "Boot-out failed: 150: Operation not permitted while System Integrity Protection is engaged\n",
+			want:     ErrSIPProtected,
+		},
+		{
+			name:     "not privileged",
+			exitCode: 1,
+			stderr:   "Not privileged to stop service.\n",
+			want:     ErrNotPrivileged,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{err: &RunError{
+				ExitCode: tt.exitCode,
+				Stderr:   []byte(tt.stderr),
+				Err:      errors.New("exit status"),
+			}}
+			exec := NewExecutorWithRunner(runner)
+
+			err := exec.Bootstrap("gui/501", "/tmp/com.example.plist")
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.want)
+			}
+
+			var lerr *Error
+			if !errors.As(err, &lerr) {
+				t.Fatalf("expected *Error, got %T", err)
+			}
+			if lerr.ExitCode != tt.exitCode {
+				t.Errorf("got exit code %d, want %d", lerr.ExitCode, tt.exitCode)
+			}
+			if len(lerr.Args) != 3 || lerr.Args[0] != "bootstrap" {
+				t.Errorf("got args %v", lerr.Args)
+			}
+			if tt.wantText != "" && !strings.Contains(err.Error(), tt.wantText) {
+				t.Errorf("error %q should contain stderr text %q", err.Error(), tt.wantText)
+			}
+		})
+	}
+}
+
+func TestExecutorUnknownCode(t *testing.T) {
+	runner := &fakeRunner{err: &RunError{ExitCode: 200, Err: errors.New("exit status 200")}}
+	err := NewExecutorWithRunner(runner).Enable("gui/501/com.example")
+
+	var lerr *Error
+	if !errors.As(err, &lerr) {
+		t.Fatalf("expected *Error, got %T", err)
+	}
+	if lerr.Kind() != nil {
+		t.Errorf("got kind %v for unknown code, want nil", lerr.Kind())
+	}
+	if !strings.Contains(err.Error(), "exit status 200") {
+		t.Errorf("error %q should fall back to the runner error", err.Error())
+	}
+}
+
+func TestIsPermissionDenied(t *testing.T) {
+	if IsPermissionDenied(nil) {
+		t.Error("nil error should not be permission denied")
+	}
+	if !IsPermissionDenied(newError([]string{"enable"}, 1, "", errors.New("exit status 1"))) {
+		t.Error("exit code 1 should be permission denied")
+	}
+	if IsPermissionDenied(newError([]string{"enable"}, 113, "", errors.New("exit status 113"))) {
+		t.Error("exit code 113 should not be permission denied")
+	}
+}
@@ -1,9 +1,9 @@
 package launchctl
 
 import (
+	"bytes"
 	"context"
-	"fmt"
-	"io"
+	"errors"
 	"os/exec"
 )
 
@@ -12,6 +12,9 @@ type Executor interface {
 	// List returns parsed output of "launchctl list".
 	List() ([]ListEntry, error)
 
+	// PrintDomain returns parsed output of "launchctl print <domain-target>".
+	PrintDomain(domainTarget string) (*DomainInfo, error)
+
 	// PrintService returns parsed output of "launchctl print <service-target>".
 	PrintService(serviceTarget string) (*ServiceInfo, error)
 
@@ -49,16 +52,37 @@ type ListEntry struct {
 
 // ServiceInfo holds parsed output from "launchctl print <service-target>".
 type ServiceInfo struct {
-	State        string // "running", "waiting", etc.
-	PID          int
-	Path         string
-	BundleID     string
-	Program      string
-	Type         string // "LaunchAgent", "LaunchDaemon"
-	Runs         int
-	LastExitCode string
-	ExitTimeout  int
-	Domain       string
+	State                 string // "running", "waiting", etc.
+	PID                   int
+	Path                  string
+	BundleID              string
+	Program               string
+	Arguments             []string
+	Type                  string // "LaunchAgent", "LaunchDaemon"
+	Runs                  int
+	Forks                 int
+	Execs                 int
+	LastExitCode          string
+	LastExitReason        string
+	LastTerminatingSignal string
+	ExitTimeout           int
+	Domain                string
+	WorkingDirectory      string
+	StdoutPath            string
+	StderrPath            string
+	SpawnType             string   // "daemon (3)", "interactive (4)", etc.
+	Properties            []string // "keepalive", "runatload", etc.
+
+	Environment          map[string]string
+	DefaultEnvironment   map[string]string
+	InheritedEnvironment map[string]string
+
+	Endpoints     []Endpoint
+	Sockets       []Socket
+	EventTriggers []EventTrigger
+
+	// Raw is the full parsed tree, for keys not surfaced above.
+	Raw *PrintBlock
 }
 
 // CmdRunner abstracts shell command execution for testability.
@@ -66,14 +90,40 @@ type CmdRunner interface {
 	Run(ctx context.Context, name string, args ...string) ([]byte, error)
 }
 
+// RunError is returned by a CmdRunner when the command ran but exited
+// non-zero. It carries what the executor needs to build a typed Error.
+type RunError struct {
+	ExitCode int
+	Stderr   []byte
+	Err      error
+}
+
+func (e *RunError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
 // RealCmdRunner executes real shell commands.
 type RealCmdRunner struct{}
 
-// Run executes a command and returns its combined stdout.
+// Run executes a command and returns its stdout. On a non-zero exit the
+// captured stderr is returned in a *RunError.
 func (r *RealCmdRunner) Run(_ context.Context, name string, args ...string) ([]byte, error) {
 	cmd := exec.Command(name, args...)
-	cmd.Stderr = io.Discard
-	return cmd.Output()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return out, &RunError{ExitCode: exitErr.ExitCode(), Stderr: stderr.Bytes(), Err: err}
+		}
+		return out, err
+	}
+	return out, nil
 }
 
 // DefaultExecutor shells out to /bin/launchctl.
@@ -95,7 +145,18 @@ func (e *DefaultExecutor) run(args ...string) ([]byte, error) {
 	ctx := context.Background()
 	out, err := e.runner.Run(ctx, "launchctl", args...)
 	if err != nil {
-		return out, fmt.Errorf("launchctl %v: %w", args, err)
+		exitCode := -1
+		var stderr []byte
+		var runErr *RunError
+		if errors.As(err, &runErr) {
+			exitCode = runErr.ExitCode
+			stderr = runErr.Stderr
+		}
+		// launchctl prints some failures to stdout instead of stderr.
+		if len(bytes.TrimSpace(stderr)) == 0 {
+			stderr = out
+		}
+		return out, newError(args, exitCode, string(stderr), err)
 	}
 	return out, nil
 }
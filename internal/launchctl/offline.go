@@ -0,0 +1,83 @@
+package launchctl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnavailable is returned by OfflineExecutor: there is no launchd to ask.
+var ErrUnavailable = errors.New("launchctl is not available in offline mode")
+
+// OfflineExecutor must satisfy Executor so it can stand in for DefaultExecutor.
+var _ Executor = OfflineExecutor{}
+
+// OfflineExecutor is used when analyzing a macOS tree that is not the
+// running system. Every call fails with ErrUnavailable so callers can mark
+// runtime state as unknown instead of treating it as a failure.
+type OfflineExecutor struct{}
+
+// NewOfflineExecutor creates an executor that never runs launchctl.
+func NewOfflineExecutor() OfflineExecutor {
+	return OfflineExecutor{}
+}
+
+// unavailable builds the error returned for "launchctl <args>".
+func unavailable(args ...string) error {
+	return fmt.Errorf("launchctl %s: %w", strings.Join(args, " "), ErrUnavailable)
+}
+
+// List always fails with ErrUnavailable.
+func (OfflineExecutor) List() ([]ListEntry, error) {
+	return nil, unavailable("list")
+}
+
+// PrintDomain always fails with ErrUnavailable.
+func (OfflineExecutor) PrintDomain(domainTarget string) (*DomainInfo, error) {
+	return nil, unavailable("print", domainTarget)
+}
+
+// PrintService always fails with ErrUnavailable.
+func (OfflineExecutor) PrintService(serviceTarget string) (*ServiceInfo, error) {
+	return nil, unavailable("print", serviceTarget)
+}
+
+// PrintDisabled always fails with ErrUnavailable.
+func (OfflineExecutor) PrintDisabled(domainTarget string) (map[string]bool, error) {
+	return nil, unavailable("print-disabled", domainTarget)
+}
+
+// Blame always fails with ErrUnavailable.
+func (OfflineExecutor) Blame(serviceTarget string) (string, error) {
+	return "", unavailable("blame", serviceTarget)
+}
+
+// Enable always fails with ErrUnavailable.
+func (OfflineExecutor) Enable(serviceTarget string) error {
+	return unavailable("enable", serviceTarget)
+}
+
+// Disable always fails with ErrUnavailable.
+func (OfflineExecutor) Disable(serviceTarget string) error {
+	return unavailable("disable", serviceTarget)
+}
+
+// Bootstrap always fails with ErrUnavailable.
+func (OfflineExecutor) Bootstrap(domainTarget string, plistPath string) error {
+	return unavailable("bootstrap", domainTarget, plistPath)
+}
+
+// Bootout always fails with ErrUnavailable.
+func (OfflineExecutor) Bootout(serviceTarget string) error {
+	return unavailable("bootout", serviceTarget)
+}
+
+// Kickstart always fails with ErrUnavailable.
+func (OfflineExecutor) Kickstart(serviceTarget string, kill bool) error {
+	return unavailable("kickstart", serviceTarget)
+}
+
+// Kill always fails with ErrUnavailable.
+func (OfflineExecutor) Kill(signal string, serviceTarget string) error {
+	return unavailable("kill", signal, serviceTarget)
+}
@@ -0,0 +1,20 @@
+package launchctl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOfflineExecutor(t *testing.T) {
+	exec := NewOfflineExecutor()
+
+	if _, err := exec.PrintDomain("system"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("PrintDomain() error = %v, want ErrUnavailable", err)
+	}
+	if err := exec.Bootstrap("system", "/x.plist"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Bootstrap() error = %v, want ErrUnavailable", err)
+	}
+	if err := exec.Kill("TERM", "system/com.example"); err == nil || err.Error() != "launchctl kill TERM system/com.example: "+ErrUnavailable.Error() {
+		t.Errorf("Kill() error = %v", err)
+	}
+}
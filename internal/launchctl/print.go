@@ -7,6 +7,120 @@ import (
 	"strings"
 )
 
+// PrintBlock is a "{ ... }" section of launchctl print output. Entries are
+// kept in the order launchctl emitted them.
+type PrintBlock struct {
+	Entries []PrintEntry
+}
+
+// PrintEntry is a single line inside a PrintBlock. It is either a scalar
+// ("key = value", "key => value" or a bare value inside a list block) or a
+// nested block ("key = { ... }").
+type PrintEntry struct {
+	Key   string
+	Value string
+	Block *PrintBlock
+}
+
+// Get returns the scalar value for key, or "" if it is absent.
+func (b *PrintBlock) Get(key string) string {
+	if b == nil {
+		return ""
+	}
+	for _, e := range b.Entries {
+		if e.Key == key && e.Block == nil {
+			return e.Value
+		}
+	}
+	return ""
+}
+
+// Has reports whether key is present as a scalar or a nested block.
+func (b *PrintBlock) Has(key string) bool {
+	if b == nil {
+		return false
+	}
+	for _, e := range b.Entries {
+		if e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Child returns the nested block for key, or nil if it is absent.
+func (b *PrintBlock) Child(key string) *PrintBlock {
+	if b == nil {
+		return nil
+	}
+	for _, e := range b.Entries {
+		if e.Key == key && e.Block != nil {
+			return e.Block
+		}
+	}
+	return nil
+}
+
+// Map returns the scalar entries of the block as a map. Nested blocks are
+// flattened using dotted keys ("descriptor.Notification").
+func (b *PrintBlock) Map() map[string]string {
+	result := make(map[string]string)
+	b.flatten("", result)
+	return result
+}
+
+func (b *PrintBlock) flatten(prefix string, out map[string]string) {
+	if b == nil {
+		return
+	}
+	for _, e := range b.Entries {
+		key := e.Key
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if e.Block != nil {
+			e.Block.flatten(key, out)
+			continue
+		}
+		out[key] = e.Value
+	}
+}
+
+// Values returns the bare (keyless) values of a list block, such as the
+// "arguments = { ... }" section.
+func (b *PrintBlock) Values() []string {
+	if b == nil {
+		return nil
+	}
+	var values []string
+	for _, e := range b.Entries {
+		if e.Key == "" && e.Block == nil {
+			values = append(values, e.Value)
+		}
+	}
+	return values
+}
+
+// Endpoint is a Mach service endpoint from the "endpoints" block.
+type Endpoint struct {
+	Name       string
+	Attributes map[string]string
+}
+
+// Socket is a listening socket from the "sockets" block.
+type Socket struct {
+	Name       string
+	Attributes map[string]string
+}
+
+// EventTrigger is a LaunchEvents trigger from the "event triggers" block.
+type EventTrigger struct {
+	Name       string
+	Stream     string
+	Attributes map[string]string
+	Descriptor map[string]string
+}
+
 // PrintService parses the output of "launchctl print <service-target>".
 // The output is a structured dump of service properties.
 // We parse defensively, treating missing fields as optional.
@@ -19,57 +133,217 @@ func (e *DefaultExecutor) PrintService(serviceTarget string) (*ServiceInfo, erro
 	return parsePrintServiceOutput(out), nil
 }
 
-func parsePrintServiceOutput(data []byte) *ServiceInfo {
-	info := &ServiceInfo{
-		PID: -1,
-	}
+// parsePrintBlock parses launchctl print output into a tree of blocks.
+// The outer "<target> = {" wrapper, if present, is unwrapped so that the
+// returned block holds the service properties directly.
+func parsePrintBlock(data []byte) *PrintBlock {
+	root := &PrintBlock{}
+	stack := []*PrintBlock{root}
 
 	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
 		}
 
-		// Parse "key = value" patterns.
-		if idx := strings.Index(line, " = "); idx != -1 {
-			key := strings.TrimSpace(line[:idx])
-			value := strings.TrimSpace(line[idx+3:])
-
-			switch key {
-			case "state":
-				info.State = value
-			case "pid":
-				if pid, err := strconv.Atoi(value); err == nil {
-					info.PID = pid
-				}
-			case "path":
-				info.Path = value
-			case "bundle identifier":
-				info.BundleID = value
-			case "program":
-				info.Program = value
-			case "type":
-				info.Type = value
-			case "runs":
-				if runs, err := strconv.Atoi(value); err == nil {
-					info.Runs = runs
-				}
-			case "last exit code":
-				info.LastExitCode = value
-			case "exit timeout":
-				// Value may be something like "5" or "5 seconds".
-				valParts := strings.Fields(value)
-				if len(valParts) > 0 {
-					if timeout, err := strconv.Atoi(valParts[0]); err == nil {
-						info.ExitTimeout = timeout
-					}
+		current := stack[len(stack)-1]
+
+		if line == "}" {
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		key, value := splitPrintLine(line)
+
+		if value == "{" {
+			child := &PrintBlock{}
+			current.Entries = append(current.Entries, PrintEntry{Key: key, Block: child})
+			stack = append(stack, child)
+			continue
+		}
+
+		current.Entries = append(current.Entries, PrintEntry{Key: key, Value: value})
+	}
+
+	// Unwrap "gui/501/com.example = { ... }".
+	if len(root.Entries) == 1 && root.Entries[0].Block != nil {
+		return root.Entries[0].Block
+	}
+	return root
+}
+
+// splitPrintLine splits a line on the first " => " or " = " separator and
+// strips surrounding quotes from the key and the value. Lines with no
+// separator are returned as bare values with an empty key.
+func splitPrintLine(line string) (string, string) {
+	if line == "{" {
+		return "", "{"
+	}
+
+	sepIdx, sepLen := -1, 0
+	if idx := strings.Index(line, " => "); idx != -1 {
+		sepIdx, sepLen = idx, 4
+	}
+	if idx := strings.Index(line, " = "); idx != -1 && (sepIdx == -1 || idx < sepIdx) {
+		sepIdx, sepLen = idx, 3
+	}
+	if sepIdx == -1 {
+		return "", unquote(line)
+	}
+
+	key := unquote(strings.TrimSpace(line[:sepIdx]))
+	value := unquote(strings.TrimSpace(line[sepIdx+sepLen:]))
+	return key, value
+}
+
+// unquote strips one pair of double quotes surrounding s, if any.
+func unquote(s string) string {
+	if len(s) >= 2 && strings.HasPrefix(s, "\"") && strings.HasSuffix(s, "\"") {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func parsePrintServiceOutput(data []byte) *ServiceInfo {
+	info := &ServiceInfo{
+		PID: -1,
+	}
+
+	root := parsePrintBlock(data)
+	info.Raw = root
+
+	for _, entry := range root.Entries {
+		if entry.Block != nil {
+			continue
+		}
+		key, value := entry.Key, entry.Value
+
+		switch key {
+		case "state":
+			info.State = value
+		case "pid":
+			if pid, err := strconv.Atoi(value); err == nil {
+				info.PID = pid
+			}
+		case "path":
+			info.Path = value
+		case "bundle identifier":
+			info.BundleID = value
+		case "program":
+			info.Program = value
+		case "type":
+			info.Type = value
+		case "runs":
+			if runs, err := strconv.Atoi(value); err == nil {
+				info.Runs = runs
+			}
+		case "last exit code":
+			info.LastExitCode = value
+		case "last exit reason":
+			info.LastExitReason = value
+		case "last terminating signal":
+			info.LastTerminatingSignal = value
+		case "exit timeout":
+			// Value may be something like "5" or "5 seconds".
+			valParts := strings.Fields(value)
+			if len(valParts) > 0 {
+				if timeout, err := strconv.Atoi(valParts[0]); err == nil {
+					info.ExitTimeout = timeout
 				}
-			case "domain":
-				info.Domain = value
 			}
+		case "domain":
+			info.Domain = value
+		case "working directory":
+			info.WorkingDirectory = value
+		case "stdout path":
+			info.StdoutPath = value
+		case "stderr path":
+			info.StderrPath = value
+		case "spawn type":
+			info.SpawnType = value
+		case "forks":
+			if forks, err := strconv.Atoi(value); err == nil {
+				info.Forks = forks
+			}
+		case "execs":
+			if execs, err := strconv.Atoi(value); err == nil {
+				info.Execs = execs
+			}
+		case "properties":
+			info.Properties = splitProperties(value)
 		}
 	}
 
+	info.Arguments = root.Child("arguments").Values()
+	info.Environment = scalarMap(root.Child("environment"))
+	info.DefaultEnvironment = scalarMap(root.Child("default environment"))
+	info.InheritedEnvironment = scalarMap(root.Child("inherited environment"))
+
+	for _, e := range namedBlocks(root.Child("endpoints")) {
+		info.Endpoints = append(info.Endpoints, Endpoint{Name: e.Key, Attributes: e.Block.Map()})
+	}
+	for _, e := range namedBlocks(root.Child("sockets")) {
+		info.Sockets = append(info.Sockets, Socket{Name: e.Key, Attributes: e.Block.Map()})
+	}
+	for _, e := range namedBlocks(root.Child("event triggers")) {
+		trigger := EventTrigger{
+			Name:       e.Key,
+			Stream:     e.Block.Get("stream"),
+			Attributes: scalarMap(e.Block),
+			Descriptor: e.Block.Child("descriptor").Map(),
+		}
+		delete(trigger.Attributes, "stream")
+		info.EventTriggers = append(info.EventTriggers, trigger)
+	}
+
 	return info
 }
+
+// scalarMap returns only the direct scalar entries of a block, or nil if the
+// block is absent or empty.
+func scalarMap(b *PrintBlock) map[string]string {
+	if b == nil {
+		return nil
+	}
+	result := make(map[string]string)
+	for _, e := range b.Entries {
+		if e.Block == nil && e.Key != "" {
+			result[e.Key] = e.Value
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// namedBlocks returns the nested blocks of b, in the order launchctl
+// printed them.
+func namedBlocks(b *PrintBlock) []PrintEntry {
+	if b == nil {
+		return nil
+	}
+	var entries []PrintEntry
+	for _, e := range b.Entries {
+		if e.Block != nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// splitProperties splits "keepalive | runatload | inferred program" into its parts.
+func splitProperties(value string) []string {
+	var props []string
+	for _, p := range strings.Split(value, "|") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			props = append(props, p)
+		}
+	}
+	return props
+}
@@ -0,0 +1,160 @@
+package launchctl
+
+import "testing"
+
+const samplePrintService = `gui/501/com.example.agent = {
+	active count = 1
+	path = /Users/test/Library/LaunchAgents/com.example.agent.plist
+	type = LaunchAgent
+	state = running
+
+	program = /usr/local/bin/agent
+	arguments = {
+		/usr/local/bin/agent
+		--verbose
+	}
+
+	working directory = /tmp
+	stdout path = /tmp/agent.out
+	stderr path = /tmp/agent.err
+
+	inherited environment = {
+		SSH_AUTH_SOCK => /private/tmp/com.apple.launchd.abc/Listeners
+	}
+
+	default environment = {
+		PATH => /usr/bin:/bin:/usr/sbin:/sbin
+	}
+
+	environment = {
+		XPC_SERVICE_NAME => com.example.agent
+	}
+
+	domain = gui/501 [100005]
+	runs = 3
+	pid = 4321
+	forks = 2
+	execs = 1
+	last exit code = 1
+	last exit reason = OS_REASON_CODESIGNING
+	last terminating signal = Killed: 9
+	spawn type = daemon (3)
+	exit timeout = 5
+
+	endpoints = {
+		"com.example.agent.xpc" = {
+			port = 0x1a03
+			active = 0
+			managed = 1
+		}
+		"com.example.agent.helper" = {
+			port = 0x1b03
+			active = 0
+			managed = 0
+		}
+	}
+
+	event triggers = {
+		com.example.trigger => {
+			keepalive = 0
+			stream = com.apple.notifyd.matching
+			descriptor = {
+				"Notification" => "com.example.changed"
+			}
+		}
+	}
+
+	properties = keepalive | runatload | inferred program
+}
+`
+
+func TestParsePrintServiceOutput(t *testing.T) {
+	info := parsePrintServiceOutput([]byte(samplePrintService))
+
+	if info.State != "running" {
+		t.Errorf("got state %q, want %q", info.State, "running")
+	}
+	if info.PID != 4321 {
+		t.Errorf("got PID %d, want 4321", info.PID)
+	}
+	if info.Path != "/Users/test/Library/LaunchAgents/com.example.agent.plist" {
+		t.Errorf("unexpected path %q", info.Path)
+	}
+	if info.Runs != 3 || info.Forks != 2 || info.Execs != 1 {
+		t.Errorf("got runs/forks/execs %d/%d/%d, want 3/2/1", info.Runs, info.Forks, info.Execs)
+	}
+	if info.LastExitCode != "1" {
+		t.Errorf("got last exit code %q, want %q", info.LastExitCode, "1")
+	}
+	if info.LastExitReason != "OS_REASON_CODESIGNING" {
+		t.Errorf("got last exit reason %q", info.LastExitReason)
+	}
+	if info.LastTerminatingSignal != "Killed: 9" {
+		t.Errorf("got last terminating signal %q", info.LastTerminatingSignal)
+	}
+	if info.SpawnType != "daemon (3)" {
+		t.Errorf("got spawn type %q", info.SpawnType)
+	}
+	if info.ExitTimeout != 5 {
+		t.Errorf("got exit timeout %d, want 5", info.ExitTimeout)
+	}
+	if info.Domain != "gui/501 [100005]" {
+		t.Errorf("got domain %q", info.Domain)
+	}
+
+	if len(info.Arguments) != 2 || info.Arguments[1] != "--verbose" {
+		t.Errorf("got arguments %v", info.Arguments)
+	}
+	if info.Environment["XPC_SERVICE_NAME"] != "com.example.agent" {
+		t.Errorf("got environment %v", info.Environment)
+	}
+	if info.DefaultEnvironment["PATH"] != "/usr/bin:/bin:/usr/sbin:/sbin" {
+		t.Errorf("got default environment %v", info.DefaultEnvironment)
+	}
+	if info.InheritedEnvironment["SSH_AUTH_SOCK"] == "" {
+		t.Errorf("got inherited environment %v", info.InheritedEnvironment)
+	}
+
+	// Blocks keep the order launchctl printed them in.
+	if len(info.Endpoints) != 2 || info.Endpoints[0].Name != "com.example.agent.xpc" || info.Endpoints[1].Name != "com.example.agent.helper" {
+		t.Fatalf("got endpoints %+v", info.Endpoints)
+	}
+	if info.Endpoints[0].Attributes["managed"] != "1" {
+		t.Errorf("got endpoint attributes %v", info.Endpoints[0].Attributes)
+	}
+
+	if len(info.EventTriggers) != 1 {
+		t.Fatalf("got %d event triggers, want 1", len(info.EventTriggers))
+	}
+	tr := info.EventTriggers[0]
+	if tr.Name != "com.example.trigger" || tr.Stream != "com.apple.notifyd.matching" {
+		t.Errorf("got trigger %+v", tr)
+	}
+	if tr.Descriptor["Notification"] != "com.example.changed" {
+		t.Errorf("got descriptor %v", tr.Descriptor)
+	}
+
+	want := []string{"keepalive", "runatload", "inferred program"}
+	if len(info.Properties) != len(want) {
+		t.Fatalf("got properties %v, want %v", info.Properties, want)
+	}
+	for i := range want {
+		if info.Properties[i] != want[i] {
+			t.Errorf("properties[%d] = %q, want %q", i, info.Properties[i], want[i])
+		}
+	}
+
+	if info.Raw.Get("active count") != "1" {
+		t.Errorf("raw tree missing %q", "active count")
+	}
+}
+
+func TestParsePrintServiceOutput_Empty(t *testing.T) {
+	info := parsePrintServiceOutput(nil)
+	if info.PID != -1 {
+		t.Errorf("got PID %d, want -1", info.PID)
+	}
+	if info.Environment != nil || info.Endpoints != nil {
+		t.Errorf("expected no nested data, got %+v", info)
+	}
+}
@@ -0,0 +1,259 @@
+package launchctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fixture is a single recorded command invocation.
+type Fixture struct {
+	Name       string   `json:"name"`
+	Args       []string `json:"args"`
+	Stdout     string   `json:"stdout"`
+	Stderr     string   `json:"stderr,omitempty"`
+	ExitCode   int      `json:"exit_code"` // -1 if the command could not be run
+	DurationMS int64    `json:"duration_ms"`
+}
+
+// Session describes the user a capture was recorded as, so that replaying
+// it queries the same launchd domains and plist directories.
+type Session struct {
+	UID  int    `json:"uid"`
+	Home string `json:"home"`
+}
+
+// sessionFile holds the Session of a fixture directory, next to the
+// NNNN.json fixtures.
+const sessionFile = "session.json"
+
+// fixtureKey identifies invocations with the same command line.
+func fixtureKey(name string, args []string) string {
+	return name + "\x00" + strings.Join(args, "\x00")
+}
+
+// RecordingRunner wraps another CmdRunner and writes every invocation to a
+// fixture directory as NNNN.json, in call order.
+type RecordingRunner struct {
+	runner CmdRunner
+	dir    string
+
+	mu    sync.Mutex
+	count int
+}
+
+// NewRecordingRunner creates a runner that records invocations of runner
+// into dir as session, creating the directory if needed. Recording into a
+// directory that holds a capture adds to it, numbering on from its last
+// fixture; the capture must be of the same session.
+func NewRecordingRunner(runner CmdRunner, dir string, session Session) (*RecordingRunner, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory %s: %w", dir, err)
+	}
+	paths, err := fixturePaths(dir)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := readSession(dir)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case prev != nil && *prev != session:
+		return nil, fmt.Errorf("%s holds a capture of uid %d (home %s), not uid %d (home %s); record into another directory", dir, prev.UID, prev.Home, session.UID, session.Home)
+	case prev == nil && len(paths) > 0:
+		return nil, fmt.Errorf("%s holds fixtures of an unknown session; record into another directory", dir)
+	case prev == nil:
+		data, err := json.MarshalIndent(session, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode session: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, sessionFile), append(data, '\n'), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write session: %w", err)
+		}
+	}
+
+	r := &RecordingRunner{runner: runner, dir: dir}
+	for _, path := range paths {
+		if n := fixtureNumber(path); n > r.count {
+			r.count = n
+		}
+	}
+	return r, nil
+}
+
+// fixturePaths returns the fixtures in dir, in recording order.
+func fixturePaths(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixtures in %s: %w", dir, err)
+	}
+	fixtures := paths[:0]
+	for _, path := range paths {
+		if filepath.Base(path) != sessionFile {
+			fixtures = append(fixtures, path)
+		}
+	}
+	// Sort by number, not name, so that 10000.json follows 9999.json.
+	sort.Strings(fixtures)
+	sort.SliceStable(fixtures, func(i, j int) bool {
+		return fixtureNumber(fixtures[i]) < fixtureNumber(fixtures[j])
+	})
+	return fixtures, nil
+}
+
+// fixtureNumber returns the call number of the fixture at path, or 0 if
+// its name has none.
+func fixtureNumber(path string) int {
+	var n int
+	if _, err := fmt.Sscanf(filepath.Base(path), "%d.json", &n); err != nil {
+		return 0
+	}
+	return n
+}
+
+// readSession reads the session of the capture in dir, or returns nil if
+// it has none, as captures made before sessions were recorded.
+func readSession(dir string) (*Session, error) {
+	data, err := os.ReadFile(filepath.Join(dir, sessionFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode session %s: %w", filepath.Join(dir, sessionFile), err)
+	}
+	return &session, nil
+}
+
+// Run executes the command through the wrapped runner and records the result.
+func (r *RecordingRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	start := time.Now()
+	out, err := r.runner.Run(ctx, name, args...)
+
+	fx := Fixture{
+		Name:       name,
+		Args:       args,
+		Stdout:     string(out),
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		var runErr *RunError
+		if errors.As(err, &runErr) {
+			fx.ExitCode = runErr.ExitCode
+			fx.Stderr = string(runErr.Stderr)
+		} else {
+			fx.ExitCode = -1
+			fx.Stderr = err.Error()
+		}
+	}
+
+	if werr := r.write(&fx); werr != nil && err == nil {
+		return out, werr
+	}
+	return out, err
+}
+
+func (r *RecordingRunner) write(fx *Fixture) error {
+	r.mu.Lock()
+	r.count++
+	path := filepath.Join(r.dir, fmt.Sprintf("%04d.json", r.count))
+	r.mu.Unlock()
+
+	data, err := json.MarshalIndent(fx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write fixture %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReplayRunner serves recorded fixtures back instead of running commands.
+// Invocations are matched by command name and arguments. When the same
+// command line was recorded several times, the recordings are served in
+// order and the last one is repeated once exhausted.
+type ReplayRunner struct {
+	session *Session
+
+	mu       sync.Mutex
+	fixtures map[string][]Fixture
+}
+
+// NewReplayRunner loads every *.json fixture from dir.
+func NewReplayRunner(dir string) (*ReplayRunner, error) {
+	paths, err := fixturePaths(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no fixtures found in %s", dir)
+	}
+	session, err := readSession(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ReplayRunner{session: session, fixtures: make(map[string][]Fixture)}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture %s: %w", path, err)
+		}
+		var fx Fixture
+		if err := json.Unmarshal(data, &fx); err != nil {
+			return nil, fmt.Errorf("failed to decode fixture %s: %w", path, err)
+		}
+		key := fixtureKey(fx.Name, fx.Args)
+		r.fixtures[key] = append(r.fixtures[key], fx)
+	}
+	return r, nil
+}
+
+// Session returns the session the fixtures were recorded as, or nil if
+// the capture predates sessions.
+func (r *ReplayRunner) Session() *Session {
+	return r.session
+}
+
+// Run returns the recorded output for the invocation.
+func (r *ReplayRunner) Run(_ context.Context, name string, args ...string) ([]byte, error) {
+	key := fixtureKey(name, args)
+
+	r.mu.Lock()
+	queue := r.fixtures[key]
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("no recorded fixture for %s %s", name, strings.Join(args, " "))
+	}
+	fx := queue[0]
+	if len(queue) > 1 {
+		r.fixtures[key] = queue[1:]
+	}
+	r.mu.Unlock()
+
+	out := []byte(fx.Stdout)
+	switch {
+	case fx.ExitCode == 0:
+		return out, nil
+	case fx.ExitCode < 0:
+		return out, errors.New(fx.Stderr)
+	default:
+		return out, &RunError{
+			ExitCode: fx.ExitCode,
+			Stderr:   []byte(fx.Stderr),
+			Err:      fmt.Errorf("exit status %d", fx.ExitCode),
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package launchctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// scriptedRunner answers each command line from a fixed table.
+type scriptedRunner struct {
+	responses map[string]Fixture
+}
+
+func (s *scriptedRunner) Run(_ context.Context, name string, args ...string) ([]byte, error) {
+	fx, ok := s.responses[fixtureKey(name, args)]
+	if !ok {
+		return nil, errors.New("unexpected command")
+	}
+	if fx.ExitCode != 0 {
+		return []byte(fx.Stdout), &RunError{ExitCode: fx.ExitCode, Stderr: []byte(fx.Stderr), Err: errors.New("exit status")}
+	}
+	return []byte(fx.Stdout), nil
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "capture")
+
+	live := &scriptedRunner{responses: map[string]Fixture{
+		fixtureKey("launchctl", []string{"print", "system"}): {Stdout: samplePrintDomain},
+		fixtureKey("launchctl", []string{"bootstrap", "system", "/x.plist"}): {
+			ExitCode: 5,
+			Stderr:   "Bootstrap failed: 5: Input/output error\n",
+		},
+	}}
+
+	session := Session{UID: 501, Home: "/Users/me"}
+	recorder, err := NewRecordingRunner(live, dir, session)
+	if err != nil {
+		t.Fatalf("NewRecordingRunner() error = %v", err)
+	}
+	recExec := NewExecutorWithRunner(recorder)
+
+	recorded, err := recExec.PrintDomain("system")
+	if err != nil {
+		t.Fatalf("PrintDomain() error = %v", err)
+	}
+	if err := recExec.Bootstrap("system", "/x.plist"); !errors.Is(err, ErrAlreadyLoaded) {
+		t.Fatalf("Bootstrap() error = %v, want ErrAlreadyLoaded", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "0*.json"))
+	if len(files) != 2 {
+		t.Fatalf("got %d fixture files, want 2", len(files))
+	}
+
+	replay, err := NewReplayRunner(dir)
+	if err != nil {
+		t.Fatalf("NewReplayRunner() error = %v", err)
+	}
+	if got := replay.Session(); got == nil || *got != session {
+		t.Errorf("got replayed session %+v, want %+v", got, session)
+	}
+	repExec := NewExecutorWithRunner(replay)
+
+	replayed, err := repExec.PrintDomain("system")
+	if err != nil {
+		t.Fatalf("replayed PrintDomain() error = %v", err)
+	}
+	if len(replayed.Services) != len(recorded.Services) {
+		t.Errorf("got %d replayed services, want %d", len(replayed.Services), len(recorded.Services))
+	}
+
+	err = repExec.Bootstrap("system", "/x.plist")
+	if !errors.Is(err, ErrAlreadyLoaded) {
+		t.Errorf("replayed Bootstrap() error = %v, want ErrAlreadyLoaded", err)
+	}
+	var lerr *Error
+	if errors.As(err, &lerr) && lerr.ExitCode != 5 {
+		t.Errorf("got replayed exit code %d, want 5", lerr.ExitCode)
+	}
+
+	// Repeated calls keep serving the last recording.
+	if _, err := repExec.PrintDomain("system"); err != nil {
+		t.Errorf("repeated PrintDomain() error = %v", err)
+	}
+
+	// Unrecorded invocations fail.
+	if _, err := repExec.PrintDomain("gui/501"); err == nil {
+		t.Error("expected error for unrecorded invocation, got nil")
+	}
+}
+
+func TestRecordingRunnerContinuesCapture(t *testing.T) {
+	dir := t.TempDir()
+	live := &scriptedRunner{responses: map[string]Fixture{
+		fixtureKey("launchctl", []string{"print", "system"}): {Stdout: samplePrintDomain},
+	}}
+	session := Session{UID: 501, Home: "/Users/me"}
+
+	// Two invocations recording into the same directory.
+	for i := 0; i < 2; i++ {
+		recorder, err := NewRecordingRunner(live, dir, session)
+		if err != nil {
+			t.Fatalf("NewRecordingRunner() #%d error = %v", i+1, err)
+		}
+		if _, err := recorder.Run(context.Background(), "launchctl", "print", "system"); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+	for _, name := range []string{"0001.json", "0002.json", sessionFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s in the capture: %v", name, err)
+		}
+	}
+	if _, err := NewReplayRunner(dir); err != nil {
+		t.Errorf("NewReplayRunner() error = %v", err)
+	}
+
+	// Another user's session must go to its own directory.
+	if _, err := NewRecordingRunner(live, dir, Session{UID: 502, Home: "/Users/you"}); err == nil {
+		t.Error("expected error recording another session into the capture, got nil")
+	}
+
+	// So must a capture of unknown origin.
+	legacy := t.TempDir()
+	if err := os.WriteFile(filepath.Join(legacy, "0001.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRecordingRunner(live, legacy, session); err == nil {
+		t.Error("expected error recording into fixtures without a session, got nil")
+	}
+}
+
+func TestReplayRunnerOrdersFixturesNumerically(t *testing.T) {
+	dir := t.TempDir()
+	for n, stdout := range map[int]string{9999: "first", 10000: "second"} {
+		fx := Fixture{Name: "launchctl", Args: []string{"print", "system"}, Stdout: stdout}
+		data, err := json.Marshal(fx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%04d.json", n)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replay, err := NewReplayRunner(dir)
+	if err != nil {
+		t.Fatalf("NewReplayRunner() error = %v", err)
+	}
+	for _, want := range []string{"first", "second"} {
+		out, err := replay.Run(context.Background(), "launchctl", "print", "system")
+		if err != nil || string(out) != want {
+			t.Errorf("Run() = %q, %v, want %q", out, err, want)
+		}
+	}
+
+	// Recording on into the capture numbers after the highest fixture.
+	if err := os.WriteFile(filepath.Join(dir, sessionFile), []byte(`{"uid": 501, "home": "/Users/me"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	live := &scriptedRunner{responses: map[string]Fixture{
+		fixtureKey("launchctl", []string{"print", "system"}): {Stdout: "third"},
+	}}
+	recorder, err := NewRecordingRunner(live, dir, Session{UID: 501, Home: "/Users/me"})
+	if err != nil {
+		t.Fatalf("NewRecordingRunner() error = %v", err)
+	}
+	if _, err := recorder.Run(context.Background(), "launchctl", "print", "system"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "10001.json")); err != nil {
+		t.Errorf("expected the next fixture as 10001.json: %v", err)
+	}
+}
+
+func TestNewReplayRunnerEmptyDir(t *testing.T) {
+	if _, err := NewReplayRunner(t.TempDir()); err == nil {
+		t.Fatal("expected error for empty fixture directory, got nil")
+	}
+}
+
+func TestNewReplayRunnerInvalidFixture(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "0001.json"), []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReplayRunner(dir); err == nil {
+		t.Fatal("expected error for invalid fixture, got nil")
+	}
+}
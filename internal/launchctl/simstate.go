@@ -0,0 +1,130 @@
+package launchctl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// simState is the saved form of a Simulator.
+type simState struct {
+	Domains  map[string][]simServiceState `json:"domains"`
+	Disabled map[string]map[string]bool   `json:"disabled"`
+	NextPID  int                          `json:"next_pid"`
+}
+
+// simServiceState is the saved form of a simService.
+type simServiceState struct {
+	Label     string `json:"label"`
+	Path      string `json:"path,omitempty"`
+	Program   string `json:"program,omitempty"`
+	KeepAlive bool   `json:"keep_alive,omitempty"`
+	PID       int    `json:"pid"`
+	Status    int    `json:"status"`
+	Runs      int    `json:"runs,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// OpenSimulator returns the simulated launchd saved at path, or an empty
+// one if path does not exist, and reports whether it was found. Every
+// change to it is saved back to path, so that a simulation carries over
+// from one lanchr invocation to the next.
+func OpenSimulator(path string) (*Simulator, bool, error) {
+	s := NewSimulator()
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read simulator state: %w", err)
+	}
+	var state simState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, false, fmt.Errorf("failed to decode simulator state %s: %w", path, err)
+	}
+
+	for target, services := range state.Domains {
+		domain := s.domain(target)
+		for _, svc := range services {
+			domain[svc.Label] = &simService{
+				label:     svc.Label,
+				path:      svc.Path,
+				program:   svc.Program,
+				keepAlive: svc.KeepAlive,
+				pid:       svc.PID,
+				status:    svc.Status,
+				runs:      svc.Runs,
+				reason:    svc.Reason,
+			}
+		}
+	}
+	for target, overrides := range state.Disabled {
+		for label, disabled := range overrides {
+			s.overrides(target)[label] = disabled
+		}
+	}
+	if state.NextPID > s.nextPID {
+		s.nextPID = state.NextPID
+	}
+	return s, true, nil
+}
+
+// Save writes the simulator to the path it was opened from. A simulator
+// made with NewSimulator lives in memory only, and Save does nothing.
+func (s *Simulator) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+// save is Save for callers that hold s.mu.
+func (s *Simulator) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	state := simState{
+		Domains:  make(map[string][]simServiceState),
+		Disabled: s.disabled,
+		NextPID:  s.nextPID,
+	}
+	for target, domain := range s.domains {
+		services := make([]simServiceState, 0, len(domain))
+		for _, svc := range domain {
+			services = append(services, simServiceState{
+				Label:     svc.label,
+				Path:      svc.path,
+				Program:   svc.program,
+				KeepAlive: svc.keepAlive,
+				PID:       svc.pid,
+				Status:    svc.status,
+				Runs:      svc.runs,
+				Reason:    svc.reason,
+			})
+		}
+		sort.Slice(services, func(i, j int) bool { return services[i].Label < services[j].Label })
+		state.Domains[target] = services
+	}
+
+	data, err := json.MarshalIndent(&state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode simulator state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to save simulator state: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to save simulator state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save simulator state: %w", err)
+	}
+	return nil
+}
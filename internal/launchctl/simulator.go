@@ -0,0 +1,410 @@
+package launchctl
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// domainTargetRe matches the launchd domain targets the simulator accepts.
+var domainTargetRe = regexp.MustCompile(`^(system|(gui|user|login)/\d+)$`)
+
+// Simulator must satisfy Executor so it can stand in for DefaultExecutor.
+var _ Executor = (*Simulator)(nil)
+
+// simService is a loaded service inside a simulated domain.
+type simService struct {
+	label     string
+	path      string
+	program   string
+	keepAlive bool
+	pid       int // -1 if not running
+	status    int // last exit status
+	runs      int
+	reason    string // last launch reason, reported by Blame
+}
+
+// Simulator is an in-memory model of launchd that implements Executor.
+// It tracks domains, loaded services, the enable/disable override database,
+// PIDs and exit statuses, and returns the same errors launchctl would for
+// invalid transitions. Nothing it does touches the real launchd. A
+// simulator opened with OpenSimulator saves every change to its state file.
+type Simulator struct {
+	mu       sync.Mutex
+	path     string // state file, see OpenSimulator
+	parser   *plist.Parser
+	domains  map[string]map[string]*simService
+	disabled map[string]map[string]bool
+	nextPID  int
+}
+
+// NewSimulator creates an empty simulated launchd.
+func NewSimulator() *Simulator {
+	return &Simulator{
+		parser:   plist.NewParser(),
+		domains:  make(map[string]map[string]*simService),
+		disabled: make(map[string]map[string]bool),
+		nextPID:  1000,
+	}
+}
+
+// Seed copies the loaded services, with their plist, program and KeepAlive
+// state, and the disabled overrides of the given domains from another
+// executor. Only read-only queries are issued, so it is safe to seed from
+// the real launchd. Domains that fail to print are skipped.
+func (s *Simulator) Seed(from Executor, domainTargets []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, target := range domainTargets {
+		if !domainTargetRe.MatchString(target) {
+			continue
+		}
+		if info, err := from.PrintDomain(target); err == nil {
+			domain := s.domain(target)
+			for _, entry := range info.Services {
+				svc := &simService{
+					label:  entry.Label,
+					pid:    entry.PID,
+					status: entry.Status,
+				}
+				if info, err := from.PrintService(target + "/" + entry.Label); err == nil {
+					svc.path = info.Path
+					svc.program = info.Program
+					svc.runs = info.Runs
+					svc.keepAlive = slices.Contains(info.Properties, "keepalive")
+				}
+				domain[entry.Label] = svc
+				if entry.PID >= s.nextPID {
+					s.nextPID = entry.PID + 1
+				}
+			}
+		}
+		if disabled, err := from.PrintDisabled(target); err == nil {
+			overrides := s.overrides(target)
+			for label, d := range disabled {
+				overrides[label] = d
+			}
+		}
+	}
+}
+
+// simError builds the Error launchctl would return for code.
+func simError(code int, message string, args ...string) error {
+	return newError(args, code, message, fmt.Errorf("exit status %d", code))
+}
+
+// domain returns the service table for target, creating it if needed.
+// Callers must hold s.mu.
+func (s *Simulator) domain(target string) map[string]*simService {
+	d, ok := s.domains[target]
+	if !ok {
+		d = make(map[string]*simService)
+		s.domains[target] = d
+	}
+	return d
+}
+
+// overrides returns the disabled override table for target.
+// Callers must hold s.mu.
+func (s *Simulator) overrides(target string) map[string]bool {
+	o, ok := s.disabled[target]
+	if !ok {
+		o = make(map[string]bool)
+		s.disabled[target] = o
+	}
+	return o
+}
+
+// splitServiceTarget splits "gui/501/com.example" into its domain target
+// and label.
+func splitServiceTarget(serviceTarget string) (string, string, bool) {
+	idx := strings.LastIndex(serviceTarget, "/")
+	if idx <= 0 || idx == len(serviceTarget)-1 {
+		return "", "", false
+	}
+	domain, label := serviceTarget[:idx], serviceTarget[idx+1:]
+	if !domainTargetRe.MatchString(domain) {
+		return "", "", false
+	}
+	return domain, label, true
+}
+
+// lookup finds a loaded service. Callers must hold s.mu.
+func (s *Simulator) lookup(serviceTarget string) (*simService, string, bool) {
+	domain, label, ok := splitServiceTarget(serviceTarget)
+	if !ok {
+		return nil, "", false
+	}
+	svc, ok := s.domains[domain][label]
+	return svc, domain, ok
+}
+
+// start launches a service with a fresh PID. Callers must hold s.mu.
+func (s *Simulator) start(svc *simService, reason string) {
+	svc.pid = s.nextPID
+	s.nextPID++
+	svc.runs++
+	svc.reason = reason
+}
+
+// List returns the services loaded in all non-system domains, which is
+// what "launchctl list" shows for a logged-in user.
+func (s *Simulator) List() ([]ListEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []ListEntry
+	for target, domain := range s.domains {
+		if target == "system" {
+			continue
+		}
+		for _, svc := range domain {
+			entries = append(entries, ListEntry{PID: svc.pid, Status: svc.status, Label: svc.label})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Label < entries[j].Label })
+	return entries, nil
+}
+
+// PrintDomain returns the services table of a simulated domain.
+func (s *Simulator) PrintDomain(domainTarget string) (*DomainInfo, error) {
+	if !domainTargetRe.MatchString(domainTarget) {
+		return nil, simError(112, "Could not find specified domain", "print", domainTarget)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info := &DomainInfo{
+		Target: domainTarget,
+		Type:   strings.SplitN(domainTarget, "/", 2)[0],
+		Raw:    &PrintBlock{},
+	}
+	for _, svc := range s.domains[domainTarget] {
+		info.Services = append(info.Services, ListEntry{PID: svc.pid, Status: svc.status, Label: svc.label})
+	}
+	sort.Slice(info.Services, func(i, j int) bool { return info.Services[i].Label < info.Services[j].Label })
+	return info, nil
+}
+
+// PrintService returns the state of a loaded service.
+func (s *Simulator) PrintService(serviceTarget string) (*ServiceInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	svc, domain, ok := s.lookup(serviceTarget)
+	if !ok {
+		return nil, simError(113, fmt.Sprintf("Could not find service %q in domain for port", serviceTarget), "print", serviceTarget)
+	}
+
+	state := "waiting"
+	if svc.pid > 0 {
+		state = "running"
+	} else if svc.runs > 0 {
+		state = "not running"
+	}
+
+	lastExit := "(never exited)"
+	if svc.runs > 0 && svc.pid <= 0 {
+		lastExit = fmt.Sprintf("%d", svc.status)
+	}
+
+	var properties []string
+	if svc.keepAlive {
+		properties = append(properties, "keepalive")
+	}
+
+	return &ServiceInfo{
+		State:        state,
+		PID:          svc.pid,
+		Path:         svc.path,
+		Program:      svc.program,
+		Runs:         svc.runs,
+		LastExitCode: lastExit,
+		Domain:       domain,
+		Properties:   properties,
+		Raw:          &PrintBlock{},
+	}, nil
+}
+
+// PrintDisabled returns the disabled override database for a domain.
+func (s *Simulator) PrintDisabled(domainTarget string) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[string]bool)
+	for label, disabled := range s.disabled[domainTarget] {
+		result[label] = disabled
+	}
+	return result, nil
+}
+
+// Blame returns the reason a loaded service was last launched.
+func (s *Simulator) Blame(serviceTarget string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	svc, _, ok := s.lookup(serviceTarget)
+	if !ok {
+		return "", simError(113, fmt.Sprintf("Could not find service %q in domain for port", serviceTarget), "blame", serviceTarget)
+	}
+	if svc.reason == "" {
+		return "(not running)", nil
+	}
+	return svc.reason, nil
+}
+
+// Enable clears the disabled override for a service. Like launchd, it does
+// not require the service to be loaded.
+func (s *Simulator) Enable(serviceTarget string) error {
+	return s.setDisabled("enable", serviceTarget, false)
+}
+
+// Disable sets the disabled override for a service. The service stays
+// loaded (and running) until it is booted out.
+func (s *Simulator) Disable(serviceTarget string) error {
+	return s.setDisabled("disable", serviceTarget, true)
+}
+
+func (s *Simulator) setDisabled(verb, serviceTarget string, disabled bool) error {
+	domain, label, ok := splitServiceTarget(serviceTarget)
+	if !ok {
+		return simError(112, "Could not find specified domain", verb, serviceTarget)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.overrides(domain)[label] = disabled
+	return s.save()
+}
+
+// Bootstrap parses a plist and loads it into a domain. RunAtLoad and
+// KeepAlive services are started immediately.
+func (s *Simulator) Bootstrap(domainTarget string, plistPath string) error {
+	if !domainTargetRe.MatchString(domainTarget) {
+		return simError(112, "Bootstrap failed: 112: Could not find specified domain", "bootstrap", domainTarget, plistPath)
+	}
+
+	pl, err := s.parser.Parse(plistPath)
+	if err != nil {
+		return simError(5, "Bootstrap failed: 5: Input/output error", "bootstrap", domainTarget, plistPath)
+	}
+	if pl.Label == "" {
+		return simError(5, "Bootstrap failed: 5: Input/output error", "bootstrap", domainTarget, plistPath)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.disabled[domainTarget][pl.Label] {
+		return simError(119, "Bootstrap failed: 119: Service is disabled", "bootstrap", domainTarget, plistPath)
+	}
+
+	domain := s.domain(domainTarget)
+	if _, loaded := domain[pl.Label]; loaded {
+		return simError(5, "Bootstrap failed: 5: Input/output error", "bootstrap", domainTarget, plistPath)
+	}
+
+	keepAlive, _ := pl.KeepAlive.(bool)
+	svc := &simService{
+		label:     pl.Label,
+		path:      plistPath,
+		program:   pl.ProgramPath(),
+		keepAlive: keepAlive,
+		pid:       -1,
+	}
+	if pl.RunAtLoad || keepAlive {
+		s.start(svc, "speculative")
+	}
+	domain[pl.Label] = svc
+	return s.save()
+}
+
+// Bootout unloads a service from its domain, stopping it if running.
+func (s *Simulator) Bootout(serviceTarget string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	svc, domain, ok := s.lookup(serviceTarget)
+	if !ok {
+		return simError(3, "Boot-out failed: 3: No such process", "bootout", serviceTarget)
+	}
+	delete(s.domains[domain], svc.label)
+	return s.save()
+}
+
+// Kickstart starts a loaded service. With kill, a running instance is
+// killed and restarted; without it, a running service is left alone.
+func (s *Simulator) Kickstart(serviceTarget string, kill bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	svc, domain, ok := s.lookup(serviceTarget)
+	if !ok {
+		return simError(113, fmt.Sprintf("Could not find service %q in domain for port", serviceTarget), "kickstart", serviceTarget)
+	}
+
+	if s.disabled[domain][svc.label] {
+		return simError(119, "Service is disabled", "kickstart", serviceTarget)
+	}
+
+	if svc.pid > 0 {
+		if !kill {
+			return nil
+		}
+		svc.status = -9
+	}
+	s.start(svc, "kickstart")
+	return s.save()
+}
+
+// Kill sends a signal to a running service. KeepAlive services are
+// restarted by launchd, as they would be for real.
+func (s *Simulator) Kill(signal string, serviceTarget string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	svc, _, ok := s.lookup(serviceTarget)
+	if !ok {
+		return simError(113, fmt.Sprintf("Could not find service %q in domain for port", serviceTarget), "kill", signal, serviceTarget)
+	}
+	if svc.pid <= 0 {
+		return simError(3, "No such process", "kill", signal, serviceTarget)
+	}
+
+	num, err := signalNumber(signal)
+	if err != nil {
+		return simError(22, err.Error(), "kill", signal, serviceTarget)
+	}
+
+	svc.pid = -1
+	svc.status = -num
+	if svc.keepAlive {
+		s.start(svc, "keepalive")
+	}
+	return s.save()
+}
+
+// signalNumber resolves "TERM", "SIGTERM" or "15" to a signal number.
+func signalNumber(signal string) (int, error) {
+	names := map[string]int{
+		"HUP": 1, "INT": 2, "QUIT": 3, "KILL": 9, "USR1": 30, "USR2": 31, "TERM": 15,
+	}
+	name := strings.TrimPrefix(strings.ToUpper(signal), "SIG")
+	if n, ok := names[name]; ok {
+		return n, nil
+	}
+	var n int
+	if _, err := fmt.Sscanf(signal, "%d", &n); err == nil && n > 0 {
+		return n, nil
+	}
+	return 0, errors.New("Invalid argument")
+}
@@ -0,0 +1,218 @@
+package launchctl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// writeTestPlist writes a minimal plist for label into dir and returns its path.
+func writeTestPlist(t *testing.T, dir, label string, runAtLoad bool, keepAlive interface{}) string {
+	t.Helper()
+	path := filepath.Join(dir, label+".plist")
+	pl := &plist.LaunchAgentPlist{
+		Label:     label,
+		Program:   "/usr/bin/true",
+		RunAtLoad: runAtLoad,
+		KeepAlive: keepAlive,
+	}
+	if err := plist.NewWriter().WriteWithoutValidation(pl, path); err != nil {
+		t.Fatalf("failed to write plist: %v", err)
+	}
+	return path
+}
+
+func TestSimulatorLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestPlist(t, dir, "com.example.sim", true, nil)
+	sim := NewSimulator()
+	target := "gui/501/com.example.sim"
+
+	// Operations on an unloaded service fail the way launchctl does.
+	if err := sim.Bootout(target); !errors.Is(err, ErrServiceNotFound) {
+		t.Errorf("Bootout() on unloaded service = %v, want ErrServiceNotFound", err)
+	}
+	if err := sim.Kickstart(target, true); !errors.Is(err, ErrServiceNotFound) {
+		t.Errorf("Kickstart() on unloaded service = %v, want ErrServiceNotFound", err)
+	}
+
+	// Bootstrap a RunAtLoad service: it starts immediately.
+	if err := sim.Bootstrap("gui/501", path); err != nil {
+		t.Fatalf("Bootstrap() error = %v", err)
+	}
+	info, err := sim.PrintService(target)
+	if err != nil {
+		t.Fatalf("PrintService() error = %v", err)
+	}
+	if info.State != "running" || info.PID <= 0 {
+		t.Errorf("got state %q pid %d after bootstrap, want running", info.State, info.PID)
+	}
+	firstPID := info.PID
+
+	// Bootstrapping twice is an I/O error.
+	if err := sim.Bootstrap("gui/501", path); !errors.Is(err, ErrAlreadyLoaded) {
+		t.Errorf("second Bootstrap() = %v, want ErrAlreadyLoaded", err)
+	}
+
+	// Kickstart -k restarts with a new PID.
+	if err := sim.Kickstart(target, true); err != nil {
+		t.Fatalf("Kickstart() error = %v", err)
+	}
+	info, _ = sim.PrintService(target)
+	if info.PID == firstPID || info.Runs != 2 {
+		t.Errorf("got pid %d runs %d after kickstart -k, want new pid and 2 runs", info.PID, info.Runs)
+	}
+
+	// Kill stops it and records the signal as the exit status.
+	if err := sim.Kill("SIGTERM", target); err != nil {
+		t.Fatalf("Kill() error = %v", err)
+	}
+	domain, _ := sim.PrintDomain("gui/501")
+	entry, ok := domain.Lookup("com.example.sim")
+	if !ok || entry.PID != -1 || entry.Status != -15 {
+		t.Errorf("got entry %+v after kill, want stopped with status -15", entry)
+	}
+	if err := sim.Kill("SIGTERM", target); !errors.Is(err, ErrServiceNotFound) {
+		t.Errorf("Kill() on stopped service = %v, want ErrServiceNotFound (no such process)", err)
+	}
+
+	// Disable persists in the override database; bootstrap is refused.
+	if err := sim.Disable(target); err != nil {
+		t.Fatalf("Disable() error = %v", err)
+	}
+	disabled, _ := sim.PrintDisabled("gui/501")
+	if !disabled["com.example.sim"] {
+		t.Error("expected service to be disabled")
+	}
+	if err := sim.Bootout(target); err != nil {
+		t.Fatalf("Bootout() error = %v", err)
+	}
+	if err := sim.Bootstrap("gui/501", path); !errors.Is(err, ErrServiceDisabled) {
+		t.Errorf("Bootstrap() of disabled service = %v, want ErrServiceDisabled", err)
+	}
+	if err := sim.Enable(target); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	if err := sim.Bootstrap("gui/501", path); err != nil {
+		t.Errorf("Bootstrap() after enable = %v", err)
+	}
+}
+
+func TestSimulatorKeepAliveRestart(t *testing.T) {
+	path := writeTestPlist(t, t.TempDir(), "com.example.keep", false, true)
+	sim := NewSimulator()
+	target := "system/com.example.keep"
+
+	if err := sim.Bootstrap("system", path); err != nil {
+		t.Fatalf("Bootstrap() error = %v", err)
+	}
+	before, _ := sim.PrintService(target)
+	if err := sim.Kill("KILL", target); err != nil {
+		t.Fatalf("Kill() error = %v", err)
+	}
+	after, _ := sim.PrintService(target)
+	if after.PID <= 0 || after.PID == before.PID {
+		t.Errorf("KeepAlive service should be relaunched, got pid %d (before %d)", after.PID, before.PID)
+	}
+}
+
+func TestSimulatorInvalidDomain(t *testing.T) {
+	sim := NewSimulator()
+	if err := sim.Bootstrap("bogus", "/x.plist"); !errors.Is(err, ErrDomainNotFound) {
+		t.Errorf("Bootstrap() into invalid domain = %v, want ErrDomainNotFound", err)
+	}
+	if _, err := sim.PrintDomain("bogus"); !errors.Is(err, ErrDomainNotFound) {
+		t.Errorf("PrintDomain() of invalid domain = %v, want ErrDomainNotFound", err)
+	}
+}
+
+func TestSimulatorSeed(t *testing.T) {
+	source := NewSimulator()
+	path := writeTestPlist(t, t.TempDir(), "com.example.seeded", true, true)
+	if err := source.Bootstrap("gui/501", path); err != nil {
+		t.Fatalf("Bootstrap() error = %v", err)
+	}
+	_ = source.Disable("gui/501/com.example.other")
+
+	sim := NewSimulator()
+	sim.Seed(source, []string{"gui/501", "system"})
+
+	domain, _ := sim.PrintDomain("gui/501")
+	if _, ok := domain.Lookup("com.example.seeded"); !ok {
+		t.Error("seeded service missing")
+	}
+	info, err := sim.PrintService("gui/501/com.example.seeded")
+	if err != nil {
+		t.Fatalf("PrintService() error = %v", err)
+	}
+	if info.Path != path || info.Program != "/usr/bin/true" {
+		t.Errorf("seeded service = %+v, want its plist and program", info)
+	}
+
+	// KeepAlive carries over: a killed service is restarted.
+	before := info.PID
+	if err := sim.Kill("SIGTERM", "gui/501/com.example.seeded"); err != nil {
+		t.Fatalf("Kill() error = %v", err)
+	}
+	after, _ := sim.PrintService("gui/501/com.example.seeded")
+	if after.PID <= 0 || after.PID == before {
+		t.Errorf("PID after Kill() = %d, want a new PID (KeepAlive)", after.PID)
+	}
+	disabled, _ := sim.PrintDisabled("gui/501")
+	if !disabled["com.example.other"] {
+		t.Error("seeded disabled override missing")
+	}
+}
+
+func TestSimulatorPersists(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "lanchr", "simulator.json")
+	path := writeTestPlist(t, t.TempDir(), "com.example.saved", true, nil)
+
+	sim, found, err := OpenSimulator(state)
+	if err != nil || found {
+		t.Fatalf("OpenSimulator() of a new state = %v, found %v", err, found)
+	}
+	if err := sim.Bootstrap("gui/501", path); err != nil {
+		t.Fatalf("Bootstrap() error = %v", err)
+	}
+	if err := sim.Disable("gui/501/com.example.other"); err != nil {
+		t.Fatalf("Disable() error = %v", err)
+	}
+	before, _ := sim.PrintService("gui/501/com.example.saved")
+
+	// The next invocation sees the changes of the previous one.
+	sim, found, err = OpenSimulator(state)
+	if err != nil || !found {
+		t.Fatalf("OpenSimulator() of the saved state = %v, found %v", err, found)
+	}
+	after, err := sim.PrintService("gui/501/com.example.saved")
+	if err != nil {
+		t.Fatalf("PrintService() error = %v", err)
+	}
+	if after.PID != before.PID || after.Path != path || after.Runs != 1 {
+		t.Errorf("got %+v after reopening, want %+v", after, before)
+	}
+	disabled, _ := sim.PrintDisabled("gui/501")
+	if !disabled["com.example.other"] {
+		t.Error("disabled override was not saved")
+	}
+
+	// New PIDs do not reuse the saved ones.
+	if err := sim.Kickstart("gui/501/com.example.saved", true); err != nil {
+		t.Fatalf("Kickstart() error = %v", err)
+	}
+	restarted, _ := sim.PrintService("gui/501/com.example.saved")
+	if restarted.PID <= before.PID {
+		t.Errorf("got pid %d after restart, want above %d", restarted.PID, before.PID)
+	}
+
+	if err := os.WriteFile(state, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := OpenSimulator(state); err == nil {
+		t.Error("expected error opening a corrupt state, got nil")
+	}
+}
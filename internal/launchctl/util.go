@@ -1,6 +1,9 @@
 package launchctl
 
-import "strings"
+import (
+	"errors"
+	"strings"
+)
 
 // trimOutput removes trailing whitespace and newlines from command output.
 func trimOutput(data []byte) string {
@@ -12,6 +15,9 @@ func IsPermissionDenied(err error) bool {
 	if err == nil {
 		return false
 	}
+	if errors.Is(err, ErrNotPrivileged) {
+		return true
+	}
 	msg := err.Error()
 	return strings.Contains(msg, "permission denied") ||
 		strings.Contains(msg, "Operation not permitted") ||
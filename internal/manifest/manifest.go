@@ -0,0 +1,323 @@
+// Package manifest compiles declarative YAML service manifests into launchd
+// plists, and plans and applies the changes they make to the plists on
+// disk.
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+	"github.com/lu-zhengda/lanchr/internal/envfile"
+	"github.com/lu-zhengda/lanchr/internal/platform"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// Manifest is one service in a manifest file. A file holds one manifest per
+// YAML document, separated by "---":
+//
+//	label: com.example.sync
+//	program: ~/bin/sync
+//	args: [--quiet]
+//	schedule: weekdays at 9:00
+//	env_file: sync.env
+//	log_dir: ~/Library/Logs/sync
+type Manifest struct {
+	Label string `yaml:"label"`
+	// Type is "agent" (the default) or "daemon".
+	Type string `yaml:"type"`
+	// Domain is "user" (the default, ~/Library/LaunchAgents) or "global"
+	// (/Library). Daemons are always global.
+	Domain  string   `yaml:"domain"`
+	Program string   `yaml:"program"`
+	Args    []string `yaml:"args"`
+	// Schedule holds one or more schedules as ParseSchedule accepts them.
+	Schedule  StringList        `yaml:"schedule"`
+	RunAtLoad bool              `yaml:"run_at_load"`
+	KeepAlive KeepAlive         `yaml:"keep_alive"`
+	Env       map[string]string `yaml:"env"`
+	// EnvFile names KEY=VALUE files, relative to the manifest. Env wins
+	// over them.
+	EnvFile StringList `yaml:"env_file"`
+	// LogDir receives <label>.out.log and <label>.err.log unless Stdout or
+	// Stderr is set.
+	LogDir           string     `yaml:"log_dir"`
+	Stdout           string     `yaml:"stdout"`
+	Stderr           string     `yaml:"stderr"`
+	WorkingDir       string     `yaml:"working_dir"`
+	Watch            StringList `yaml:"watch"`
+	User             string     `yaml:"user"`
+	Group            string     `yaml:"group"`
+	Nice             int        `yaml:"nice"`
+	ThrottleInterval int        `yaml:"throttle_interval"`
+	ProcessType      string     `yaml:"process_type"`
+	Disabled         bool       `yaml:"disabled"`
+
+	// Source is the file the manifest was read from.
+	Source string `yaml:"-"`
+}
+
+// StringList is a YAML string or list of strings.
+type StringList []string
+
+// UnmarshalYAML accepts a scalar as a one-element list.
+func (l *StringList) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*l = StringList{n.Value}
+		return nil
+	}
+	var list []string
+	if err := n.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// KeepAlive is a manifest's restart policy: true or "always", false,
+// "on-failure" (restart after a non-zero exit) or "on-crash".
+type KeepAlive struct {
+	Value interface{}
+}
+
+// UnmarshalYAML accepts a boolean or one of the named policies.
+func (k *KeepAlive) UnmarshalYAML(n *yaml.Node) error {
+	var b bool
+	if n.ShortTag() == "!!bool" && n.Decode(&b) == nil {
+		k.Value = b
+		return nil
+	}
+	switch n.Value {
+	case "always":
+		k.Value = true
+	case "on-failure":
+		k.Value = map[string]interface{}{"SuccessfulExit": false}
+	case "on-crash":
+		k.Value = map[string]interface{}{"Crashed": true}
+	default:
+		return fmt.Errorf("line %d: keep_alive must be true, false, always, on-failure or on-crash", n.Line)
+	}
+	return nil
+}
+
+// Load reads the manifests in paths. A directory contributes every .yaml
+// and .yml file in it, in name order. Labels must be unique.
+func Load(paths []string) ([]*Manifest, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifests: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest directory %s: %w", p, err)
+		}
+		var names []string
+		for _, e := range entries {
+			ext := strings.ToLower(filepath.Ext(e.Name()))
+			if !e.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				names = append(names, e.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			files = append(files, filepath.Join(p, name))
+		}
+	}
+
+	var (
+		all  []*Manifest
+		seen = make(map[string]string)
+	)
+	for _, f := range files {
+		manifests, err := LoadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range manifests {
+			if other, ok := seen[m.Label]; ok {
+				return nil, fmt.Errorf("service %q is declared in both %s and %s", m.Label, other, f)
+			}
+			seen[m.Label] = f
+			all = append(all, m)
+		}
+	}
+	return all, nil
+}
+
+// LoadFile reads every manifest in a YAML file. Unknown keys are errors, so
+// that typos do not go unnoticed.
+func LoadFile(path string) ([]*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var manifests []*Manifest
+	for n := 1; ; n++ {
+		var m Manifest
+		err := dec.Decode(&m)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode manifest %s: %w", path, err)
+		}
+		// Empty documents, such as after a trailing "---", declare nothing.
+		if reflect.ValueOf(m).IsZero() {
+			continue
+		}
+		if m.Label == "" {
+			return nil, fmt.Errorf("invalid manifest %s: document %d has no label", path, n)
+		}
+		m.Source = path
+		manifests = append(manifests, &m)
+	}
+	return manifests, nil
+}
+
+// ServiceType returns the manifest's service type.
+func (m *Manifest) ServiceType() (platform.ServiceType, error) {
+	switch m.Type {
+	case "", "agent":
+		return platform.TypeAgent, nil
+	case "daemon":
+		return platform.TypeDaemon, nil
+	}
+	return 0, fmt.Errorf("%s: type must be agent or daemon, not %q", m.Label, m.Type)
+}
+
+// PlistPath returns where the manifest's plist is installed.
+func (m *Manifest) PlistPath() (string, error) {
+	t, err := m.ServiceType()
+	if err != nil {
+		return "", err
+	}
+	name := m.Label + ".plist"
+	switch {
+	case t == platform.TypeDaemon && (m.Domain == "" || m.Domain == "global"):
+		return filepath.Join("/Library/LaunchDaemons", name), nil
+	case t == platform.TypeDaemon:
+		return "", fmt.Errorf("%s: daemons are always in the global domain", m.Label)
+	case m.Domain == "" || m.Domain == "user":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		return filepath.Join(home, "Library", "LaunchAgents", name), nil
+	case m.Domain == "global":
+		return filepath.Join("/Library/LaunchAgents", name), nil
+	}
+	return "", fmt.Errorf("%s: domain must be user or global, not %q", m.Label, m.Domain)
+}
+
+// Compile turns the manifest into the plist it describes. Paths starting
+// with ~ are expanded, since launchd does not expand them.
+func (m *Manifest) Compile() (*plist.LaunchAgentPlist, error) {
+	if _, err := m.PlistPath(); err != nil {
+		return nil, err
+	}
+	if m.Program == "" {
+		return nil, fmt.Errorf("%s: program is required", m.Label)
+	}
+	program := expandHome(m.Program)
+	if !filepath.IsAbs(program) {
+		return nil, fmt.Errorf("%s: program must be an absolute path, not %q", m.Label, m.Program)
+	}
+
+	pl := &plist.LaunchAgentPlist{
+		Label:            m.Label,
+		Disabled:         m.Disabled,
+		ProgramArguments: append([]string{program}, m.Args...),
+		RunAtLoad:        m.RunAtLoad,
+		KeepAlive:        m.KeepAlive.Value,
+		WorkingDirectory: expandHome(m.WorkingDir),
+		UserName:         m.User,
+		GroupName:        m.Group,
+		Nice:             m.Nice,
+		ThrottleInterval: m.ThrottleInterval,
+		ProcessType:      m.ProcessType,
+	}
+	for _, w := range m.Watch {
+		pl.WatchPaths = append(pl.WatchPaths, expandHome(w))
+	}
+
+	if len(m.Schedule) > 0 {
+		s, err := ParseSchedule(m.Schedule...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Label, err)
+		}
+		pl.StartInterval = s.Interval
+		pl.StartCalendarInterval = s.StartCalendarInterval()
+		pl.RunAtLoad = pl.RunAtLoad || s.AtLoad
+	}
+
+	env, err := m.environment()
+	if err != nil {
+		return nil, err
+	}
+	if len(env) > 0 {
+		pl.EnvironmentVariables = env
+	}
+
+	pl.StandardOutPath = expandHome(m.Stdout)
+	pl.StandardErrorPath = expandHome(m.Stderr)
+	if m.LogDir != "" {
+		dir := expandHome(m.LogDir)
+		if pl.StandardOutPath == "" {
+			pl.StandardOutPath = filepath.Join(dir, m.Label+".out.log")
+		}
+		if pl.StandardErrorPath == "" {
+			pl.StandardErrorPath = filepath.Join(dir, m.Label+".err.log")
+		}
+	}
+	return pl, nil
+}
+
+// environment merges the env files and env map.
+func (m *Manifest) environment() (map[string]string, error) {
+	env := make(map[string]string)
+	for _, f := range m.EnvFile {
+		path := expandHome(f)
+		if !filepath.IsAbs(path) && m.Source != "" {
+			path = filepath.Join(filepath.Dir(m.Source), path)
+		}
+		vars, err := envfile.Read(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Label, err)
+		}
+		for k, v := range vars {
+			env[k] = v
+		}
+	}
+	for k, v := range m.Env {
+		env[k] = v
+	}
+	return env, nil
+}
+
+// expandHome replaces a leading ~ with the home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
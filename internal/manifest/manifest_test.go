@@ -0,0 +1,212 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const syncManifest = `label: com.example.sync
+program: ~/bin/sync
+args: [--quiet]
+schedule: [daily at 2am, at login]
+keep_alive: on-failure
+env: {REGION: us}
+env_file: sync.env
+log_dir: ~/Library/Logs/sync
+stderr: /tmp/sync.err
+---
+label: com.example.tick
+type: agent
+domain: global
+program: /bin/date
+schedule: every 15m
+`
+
+func TestCompile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := t.TempDir()
+	writeFile(t, dir, "sync.env", "# comment\nexport TOKEN=\"a b\\tc\"\nREGION=eu\nNAME='x'\n\n")
+	manifests, err := LoadFile(writeFile(t, dir, "services.yaml", syncManifest))
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if len(manifests) != 2 {
+		t.Fatalf("got %d manifests, want 2", len(manifests))
+	}
+
+	pl, err := manifests[0].Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if want := filepath.Join(home, "bin", "sync"); pl.ProgramArguments[0] != want || pl.ProgramArguments[1] != "--quiet" {
+		t.Errorf("ProgramArguments = %v, want [%s --quiet]", pl.ProgramArguments, want)
+	}
+	if !pl.RunAtLoad {
+		t.Error("RunAtLoad = false, want true from \"at login\"")
+	}
+	if cal, ok := pl.StartCalendarInterval.(map[string]interface{}); !ok || cal["Hour"] != 2 {
+		t.Errorf("StartCalendarInterval = %v, want Hour 2", pl.StartCalendarInterval)
+	}
+	if ka, ok := pl.KeepAlive.(map[string]interface{}); !ok || ka["SuccessfulExit"] != false {
+		t.Errorf("KeepAlive = %v, want SuccessfulExit false", pl.KeepAlive)
+	}
+	env := pl.EnvironmentVariables
+	if env["REGION"] != "us" || env["TOKEN"] != "a b\tc" || env["NAME"] != "x" {
+		t.Errorf("EnvironmentVariables = %v", env)
+	}
+	if want := filepath.Join(home, "Library", "Logs", "sync", "com.example.sync.out.log"); pl.StandardOutPath != want {
+		t.Errorf("StandardOutPath = %q, want %q", pl.StandardOutPath, want)
+	}
+	if pl.StandardErrorPath != "/tmp/sync.err" {
+		t.Errorf("StandardErrorPath = %q, want the explicit stderr", pl.StandardErrorPath)
+	}
+
+	tick, err := manifests[1].Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tick.StartInterval != 900 {
+		t.Errorf("StartInterval = %d, want 900", tick.StartInterval)
+	}
+	if path, _ := manifests[1].PlistPath(); path != "/Library/LaunchAgents/com.example.tick.plist" {
+		t.Errorf("PlistPath() = %q", path)
+	}
+	if path, _ := manifests[0].PlistPath(); path != filepath.Join(home, "Library", "LaunchAgents", "com.example.sync.plist") {
+		t.Errorf("PlistPath() = %q", path)
+	}
+}
+
+func TestManifestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"unknown key", "label: a\nprogram: /bin/true\nschedul: daily\n", "field schedul not found"},
+		{"no label", "program: /bin/true\n", "has no label"},
+		{"bad keep_alive", "label: a\nprogram: /bin/true\nkeep_alive: sometimes\n", "keep_alive must be"},
+		{"no program", "label: a\n", "program is required"},
+		{"relative program", "label: a\nprogram: sync\n", "absolute path"},
+		{"bad type", "label: a\nprogram: /bin/true\ntype: job\n", "agent or daemon"},
+		{"user daemon", "label: a\nprogram: /bin/true\ntype: daemon\ndomain: user\n", "always in the global domain"},
+		{"bad schedule", "label: a\nprogram: /bin/true\nschedule: sometimes\n", "invalid schedule"},
+		{"missing env file", "label: a\nprogram: /bin/true\nenv_file: nope.env\n", "env file"},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		manifests, err := LoadFile(writeFile(t, dir, "m.yaml", tt.content))
+		if err == nil {
+			_, err = manifests[0].Compile()
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "b.yaml", "label: b\nprogram: /bin/true\n")
+	writeFile(t, dir, "a.yml", "label: a\nprogram: /bin/true\n")
+	writeFile(t, dir, "notes.md", "not a manifest")
+
+	manifests, err := Load([]string{dir})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(manifests) != 2 || manifests[0].Label != "a" || manifests[1].Label != "b" {
+		t.Errorf("Load() labels = %v, want [a b]", manifests)
+	}
+
+	dup := writeFile(t, t.TempDir(), "dup.yaml", "label: a\nprogram: /bin/true\n")
+	if _, err := Load([]string{dir, dup}); err == nil || !strings.Contains(err.Error(), "declared in both") {
+		t.Errorf("Load() duplicate error = %v", err)
+	}
+}
+
+func TestLoadFileEmptyDocuments(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "services.yaml", "---\nlabel: a\nprogram: /bin/true\n---\n# b is gone\n---\nlabel: c\nprogram: /bin/true\n---\n")
+	manifests, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if len(manifests) != 2 || manifests[0].Label != "a" || manifests[1].Label != "c" {
+		t.Errorf("LoadFile() = %v, want a and c", manifests)
+	}
+
+	path = writeFile(t, dir, "nolabel.yaml", "label: a\nprogram: /bin/true\n---\n---\nprogram: /bin/true\n")
+	if _, err := LoadFile(path); err == nil || !strings.Contains(err.Error(), "document 3 has no label") {
+		t.Errorf("LoadFile() error = %v, want document 3 has no label", err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := t.TempDir()
+	path := writeFile(t, dir, "tick.yaml", "label: com.example.tick\nprogram: /bin/date\nschedule: every 15m\n")
+	manifests, err := Load([]string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps, err := Plan(manifests)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if steps[0].Action != ActionCreate || len(steps[0].Changes) != 3 {
+		t.Fatalf("first plan = %v with %d changes, want create with 3", steps[0].Action, len(steps[0].Changes))
+	}
+
+	// Install a plist that differs: another interval and an extra key.
+	installed := plist.DocumentFromPlist(steps[0].Plist)
+	installed.Root.Set("StartInterval", int64(600))
+	installed.Root.Set("Nice", int64(5))
+	if err := os.MkdirAll(filepath.Dir(steps[0].Path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := installed.WriteFile(steps[0].Path); err != nil {
+		t.Fatal(err)
+	}
+
+	steps, err = Plan(manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := steps[0].Changes
+	if steps[0].Action != ActionUpdate || len(changes) != 2 {
+		t.Fatalf("second plan = %v with changes %+v, want update with 2", steps[0].Action, changes)
+	}
+	if changes[0].Key != "StartInterval" || changes[0].Old != int64(600) || changes[0].New != int64(900) {
+		t.Errorf("changes[0] = %+v, want StartInterval 600 -> 900", changes[0])
+	}
+	if changes[1].Key != "Nice" || changes[1].New != nil {
+		t.Errorf("changes[1] = %+v, want Nice removed", changes[1])
+	}
+
+	if err := steps[0].Doc.WriteFile(steps[0].Path); err != nil {
+		t.Fatal(err)
+	}
+	steps, err = Plan(manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps[0].Action != ActionNone || len(steps[0].Changes) != 0 {
+		t.Errorf("third plan = %v with %+v, want unchanged", steps[0].Action, steps[0].Changes)
+	}
+}
@@ -0,0 +1,118 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// Action is what applying a manifest does to its plist.
+type Action int
+
+const (
+	ActionNone   Action = iota // the plist already matches
+	ActionCreate               // there is no plist yet
+	ActionUpdate               // the plist differs
+)
+
+// String returns "unchanged", "create" or "update".
+func (a Action) String() string {
+	switch a {
+	case ActionNone:
+		return "unchanged"
+	case ActionCreate:
+		return "create"
+	case ActionUpdate:
+		return "update"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is one top-level plist key that differs. Old is nil for an added
+// key and New is nil for a removed one.
+type Change struct {
+	Key string
+	Old interface{}
+	New interface{}
+}
+
+// Step is the planned change for one manifest.
+type Step struct {
+	Manifest *Manifest
+	Path     string
+	Action   Action
+	Changes  []Change
+	// Doc is the plist to write, in the format of the existing file.
+	Doc *plist.Document
+	// Plist is the compiled manifest.
+	Plist *plist.LaunchAgentPlist
+}
+
+// Plan compiles the manifests and compares each with the plist installed
+// at its path. The manifest is the source of truth: keys it does not set
+// are removed from an existing plist.
+func Plan(manifests []*Manifest) ([]Step, error) {
+	steps := make([]Step, 0, len(manifests))
+	for _, m := range manifests {
+		step, err := planOne(m)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Source, err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func planOne(m *Manifest) (Step, error) {
+	pl, err := m.Compile()
+	if err != nil {
+		return Step{}, err
+	}
+	path, err := m.PlistPath()
+	if err != nil {
+		return Step{}, err
+	}
+	doc := plist.DocumentFromPlist(pl)
+	step := Step{Manifest: m, Path: path, Doc: doc, Plist: pl}
+
+	old := plist.NewDocument()
+	if _, err := os.Lstat(path); err == nil {
+		if old, err = plist.ReadDocument(path); err != nil {
+			return Step{}, err
+		}
+		doc.Format = old.Format
+	} else if !os.IsNotExist(err) {
+		return Step{}, fmt.Errorf("failed to stat %s: %w", path, err)
+	} else {
+		step.Action = ActionCreate
+	}
+
+	step.Changes = Diff(old.Root, doc.Root)
+	if step.Action != ActionCreate && len(step.Changes) > 0 {
+		step.Action = ActionUpdate
+	}
+	return step, nil
+}
+
+// Diff returns the top-level keys that differ between two plists: changed
+// and added keys in the order of new, then removed keys in the order of
+// old.
+func Diff(old, new *plist.Dict) []Change {
+	var changes []Change
+	for _, k := range new.Keys() {
+		nv, _ := new.Get(k)
+		ov, ok := old.Get(k)
+		if !ok || !plist.ValuesEqual(ov, nv) {
+			changes = append(changes, Change{Key: k, Old: ov, New: nv})
+		}
+	}
+	for _, k := range old.Keys() {
+		if _, ok := new.Get(k); !ok {
+			ov, _ := old.Get(k)
+			changes = append(changes, Change{Key: k, Old: ov})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,238 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lu-zhengda/lanchr/internal/cron"
+)
+
+// Schedule is when a service runs, as launchd keys: a StartInterval, a set
+// of StartCalendarInterval entries, RunAtLoad, or a combination.
+type Schedule struct {
+	Interval int
+	Calendar []map[string]int
+	AtLoad   bool
+}
+
+var weekdays = map[string]int{
+	"sun": 0, "sunday": 0, "sundays": 0,
+	"mon": 1, "monday": 1, "mondays": 1,
+	"tue": 2, "tues": 2, "tuesday": 2, "tuesdays": 2,
+	"wed": 3, "wednesday": 3, "wednesdays": 3,
+	"thu": 4, "thur": 4, "thurs": 4, "thursday": 4, "thursdays": 4,
+	"fri": 5, "friday": 5, "fridays": 5,
+	"sat": 6, "saturday": 6, "saturdays": 6,
+}
+
+var (
+	everyRe   = regexp.MustCompile(`^every\s+(\d+)\s*([a-z]+)$`)
+	timeRe    = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+	minuteRe  = regexp.MustCompile(`^:(\d{2})$`)
+	listSepRe = regexp.MustCompile(`\s*(?:,|\band\b)\s*`)
+	// cronRe matches the first field of a cron expression.
+	cronRe = regexp.MustCompile(`^[0-9*][0-9*/,-]*$`)
+)
+
+// intervalUnits maps the unit words "every N <unit>" accepts to seconds.
+var intervalUnits = map[string]int{
+	"s": 1, "sec": 1, "secs": 1, "second": 1, "seconds": 1,
+	"m": 60, "min": 60, "mins": 60, "minute": 60, "minutes": 60,
+	"h": 3600, "hr": 3600, "hrs": 3600, "hour": 3600, "hours": 3600,
+	"d": 86400, "day": 86400, "days": 86400,
+}
+
+// ParseSchedule parses the human schedules a manifest accepts and merges
+// them into one Schedule:
+//
+//	every 30m, every 2 hours       StartInterval
+//	hourly, hourly at :15          every hour, on the minute given
+//	daily, daily at 9:30           every day (at midnight by default)
+//	at 9am and 5:30pm              every day at each time
+//	weekdays at 9:00               Monday to Friday
+//	weekends at 10:00              Saturday and Sunday
+//	mon, wed and fri at 7:00       the days listed
+//	monthly, monthly on 15 at 8:00 a day of the month (the 1st by default)
+//	at login, at load              RunAtLoad
+//	*/15 9-17 * * 1-5, @daily      a cron expression (see cron.Parse)
+//
+// A service cannot have both an interval and calendar entries.
+func ParseSchedule(specs ...string) (*Schedule, error) {
+	s := &Schedule{}
+	for _, spec := range specs {
+		if err := s.parse(spec); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+	}
+	if s.Interval > 0 && len(s.Calendar) > 0 {
+		return nil, fmt.Errorf("invalid schedule: an interval (every ...) cannot be combined with calendar times")
+	}
+	return s, nil
+}
+
+func (s *Schedule) parse(spec string) error {
+	spec = strings.Join(strings.Fields(strings.ToLower(spec)), " ")
+	if spec == "" {
+		return fmt.Errorf("empty schedule")
+	}
+
+	switch spec {
+	case "at login", "at load", "at startup", "at boot":
+		s.AtLoad = true
+		return nil
+	}
+
+	if f := strings.Fields(spec); (len(f) == 5 && cronRe.MatchString(f[0])) || strings.HasPrefix(spec, "@") {
+		c, err := cron.Parse(spec)
+		if err != nil {
+			return err
+		}
+		s.AtLoad = s.AtLoad || c.Reboot
+		s.Calendar = append(s.Calendar, c.Calendar...)
+		return nil
+	}
+
+	if m := everyRe.FindStringSubmatch(spec); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		unit, ok := intervalUnits[m[2]]
+		if !ok {
+			return fmt.Errorf("unknown unit %q (use seconds, minutes, hours or days)", m[2])
+		}
+		if n <= 0 {
+			return fmt.Errorf("interval must be positive")
+		}
+		if s.Interval > 0 {
+			return fmt.Errorf("only one interval is allowed")
+		}
+		s.Interval = n * unit
+		return nil
+	}
+
+	// Split "<days> at <times>".
+	when, times := spec, ""
+	if i := strings.Index(spec, " at "); i >= 0 {
+		when, times = spec[:i], spec[i+len(" at "):]
+	} else if strings.HasPrefix(spec, "at ") {
+		when, times = "", spec[len("at "):]
+	}
+
+	if when == "hourly" {
+		minute := 0
+		if times != "" {
+			m := minuteRe.FindStringSubmatch(times)
+			if m == nil {
+				return fmt.Errorf("hourly takes a minute such as \"hourly at :15\"")
+			}
+			minute, _ = strconv.Atoi(m[1])
+			if minute > 59 {
+				return fmt.Errorf("minute %d is out of range", minute)
+			}
+		}
+		s.Calendar = append(s.Calendar, map[string]int{"Minute": minute})
+		return nil
+	}
+
+	clock := []map[string]int{{"Hour": 0, "Minute": 0}}
+	if times != "" {
+		parsed, err := parseTimes(times)
+		if err != nil {
+			return err
+		}
+		clock = parsed
+	}
+
+	var days []map[string]int
+	switch {
+	case when == "" || when == "daily" || when == "every day":
+		days = []map[string]int{{}}
+	case when == "weekdays":
+		for d := 1; d <= 5; d++ {
+			days = append(days, map[string]int{"Weekday": d})
+		}
+	case when == "weekends":
+		days = []map[string]int{{"Weekday": 6}, {"Weekday": 0}}
+	case strings.HasPrefix(when, "monthly"):
+		day := 1
+		if rest := strings.TrimPrefix(when, "monthly"); rest != "" {
+			rest = strings.TrimPrefix(strings.TrimSpace(rest), "on ")
+			rest = strings.TrimRight(rest, "stndrdth")
+			n, err := strconv.Atoi(strings.TrimSpace(rest))
+			if err != nil || n < 1 || n > 31 {
+				return fmt.Errorf("monthly takes a day of the month such as \"monthly on 15\"")
+			}
+			day = n
+		}
+		days = []map[string]int{{"Day": day}}
+	default:
+		when = strings.TrimPrefix(strings.TrimPrefix(when, "every "), "on ")
+		for _, name := range listSepRe.Split(when, -1) {
+			d, ok := weekdays[name]
+			if !ok {
+				return fmt.Errorf("unknown day %q", name)
+			}
+			days = append(days, map[string]int{"Weekday": d})
+		}
+	}
+
+	for _, day := range days {
+		for _, t := range clock {
+			entry := make(map[string]int, len(day)+len(t))
+			for k, v := range day {
+				entry[k] = v
+			}
+			for k, v := range t {
+				entry[k] = v
+			}
+			s.Calendar = append(s.Calendar, entry)
+		}
+	}
+	return nil
+}
+
+// parseTimes parses a list of times of day: "9:30", "17:00", "9am",
+// "noon", "midnight".
+func parseTimes(list string) ([]map[string]int, error) {
+	var out []map[string]int
+	for _, t := range listSepRe.Split(list, -1) {
+		switch t {
+		case "noon":
+			out = append(out, map[string]int{"Hour": 12, "Minute": 0})
+			continue
+		case "midnight":
+			out = append(out, map[string]int{"Hour": 0, "Minute": 0})
+			continue
+		}
+		m := timeRe.FindStringSubmatch(t)
+		if m == nil {
+			return nil, fmt.Errorf("invalid time %q (use 9:30, 17:00 or 9am)", t)
+		}
+		hour, _ := strconv.Atoi(m[1])
+		minute := 0
+		if m[2] != "" {
+			minute, _ = strconv.Atoi(m[2])
+		}
+		switch m[3] {
+		case "am", "pm":
+			if hour < 1 || hour > 12 {
+				return nil, fmt.Errorf("invalid time %q", t)
+			}
+			hour %= 12
+			if m[3] == "pm" {
+				hour += 12
+			}
+		}
+		if hour > 23 || minute > 59 {
+			return nil, fmt.Errorf("invalid time %q", t)
+		}
+		out = append(out, map[string]int{"Hour": hour, "Minute": minute})
+	}
+	return out, nil
+}
+
+// StartCalendarInterval returns the calendar entries in the form
+// LaunchAgentPlist takes: nil, one dictionary, or an array of them.
+func (s *Schedule) StartCalendarInterval() interface{} {
+	return cron.CalendarValue(s.Calendar)
+}
@@ -0,0 +1,97 @@
+package manifest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		specs    []string
+		interval int
+		calendar []map[string]int
+		atLoad   bool
+	}{
+		{specs: []string{"every 30m"}, interval: 1800},
+		{specs: []string{"every 2 hours"}, interval: 7200},
+		{specs: []string{"Every 1 Day"}, interval: 86400},
+		{specs: []string{"hourly"}, calendar: []map[string]int{{"Minute": 0}}},
+		{specs: []string{"hourly at :15"}, calendar: []map[string]int{{"Minute": 15}}},
+		{specs: []string{"daily"}, calendar: []map[string]int{{"Hour": 0, "Minute": 0}}},
+		{specs: []string{"daily at 9:30"}, calendar: []map[string]int{{"Hour": 9, "Minute": 30}}},
+		{specs: []string{"every day at noon"}, calendar: []map[string]int{{"Hour": 12, "Minute": 0}}},
+		{specs: []string{"at 9am and 5:30pm"}, calendar: []map[string]int{{"Hour": 9, "Minute": 0}, {"Hour": 17, "Minute": 30}}},
+		{specs: []string{"at 12am, 12pm"}, calendar: []map[string]int{{"Hour": 0, "Minute": 0}, {"Hour": 12, "Minute": 0}}},
+		{specs: []string{"weekends at 10:00"}, calendar: []map[string]int{{"Weekday": 6, "Hour": 10, "Minute": 0}, {"Weekday": 0, "Hour": 10, "Minute": 0}}},
+		{specs: []string{"mon, wed and fri at 7:00"}, calendar: []map[string]int{
+			{"Weekday": 1, "Hour": 7, "Minute": 0},
+			{"Weekday": 3, "Hour": 7, "Minute": 0},
+			{"Weekday": 5, "Hour": 7, "Minute": 0},
+		}},
+		{specs: []string{"every sunday at 23:59"}, calendar: []map[string]int{{"Weekday": 0, "Hour": 23, "Minute": 59}}},
+		{specs: []string{"monthly"}, calendar: []map[string]int{{"Day": 1, "Hour": 0, "Minute": 0}}},
+		{specs: []string{"monthly on 15th at 8:00"}, calendar: []map[string]int{{"Day": 15, "Hour": 8, "Minute": 0}}},
+		{specs: []string{"at login"}, atLoad: true},
+		{specs: []string{"at login", "every 5m"}, interval: 300, atLoad: true},
+		{specs: []string{"30 6 * * SUN"}, calendar: []map[string]int{{"Weekday": 0, "Hour": 6, "Minute": 30}}},
+		{specs: []string{"@reboot", "@daily"}, calendar: []map[string]int{{"Hour": 0, "Minute": 0}}, atLoad: true},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.specs...)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q) error = %v", tt.specs, err)
+			continue
+		}
+		if s.Interval != tt.interval || s.AtLoad != tt.atLoad || !reflect.DeepEqual(s.Calendar, tt.calendar) {
+			t.Errorf("ParseSchedule(%q) = %+v, want interval %d, calendar %v, at load %v", tt.specs, s, tt.interval, tt.calendar, tt.atLoad)
+		}
+	}
+
+	weekdays, err := ParseSchedule("weekdays at 9:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(weekdays.Calendar) != 5 || weekdays.Calendar[0]["Weekday"] != 1 || weekdays.Calendar[4]["Weekday"] != 5 {
+		t.Errorf("weekdays calendar = %v, want Monday to Friday", weekdays.Calendar)
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	tests := []struct {
+		specs   []string
+		wantErr string
+	}{
+		{[]string{""}, "empty schedule"},
+		{[]string{"every 5 fortnights"}, "unknown unit"},
+		{[]string{"every 0m"}, "must be positive"},
+		{[]string{"every 5m", "every 10m"}, "only one interval"},
+		{[]string{"every 5m", "daily"}, "cannot be combined"},
+		{[]string{"daily at 25:00"}, "invalid time"},
+		{[]string{"daily at 13pm"}, "invalid time"},
+		{[]string{"hourly at 15"}, "hourly takes a minute"},
+		{[]string{"monthly on 32"}, "day of the month"},
+		{[]string{"funday at 9:00"}, `unknown day "funday"`},
+		{[]string{"0 25 * * *"}, "invalid hour"},
+	}
+	for _, tt := range tests {
+		_, err := ParseSchedule(tt.specs...)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ParseSchedule(%q) error = %v, want %q", tt.specs, err, tt.wantErr)
+		}
+	}
+}
+
+func TestStartCalendarInterval(t *testing.T) {
+	if v := (&Schedule{}).StartCalendarInterval(); v != nil {
+		t.Errorf("empty schedule = %v, want nil", v)
+	}
+	one := &Schedule{Calendar: []map[string]int{{"Hour": 9}}}
+	if _, ok := one.StartCalendarInterval().(map[string]interface{}); !ok {
+		t.Errorf("one entry = %T, want a dictionary", one.StartCalendarInterval())
+	}
+	two := &Schedule{Calendar: []map[string]int{{"Hour": 9}, {"Hour": 17}}}
+	if v, ok := two.StartCalendarInterval().([]interface{}); !ok || len(v) != 2 {
+		t.Errorf("two entries = %v, want an array of two", two.StartCalendarInterval())
+	}
+}
@@ -0,0 +1,279 @@
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Domain represents the launchd domain a service belongs to.
+type Domain int
+
+const (
+	DomainUser   Domain = iota // ~/Library/LaunchAgents
+	DomainGlobal               // /Library/LaunchAgents, /Library/LaunchDaemons
+	DomainSystem               // /System/Library/LaunchAgents, /System/Library/LaunchDaemons
+)
+
+// String returns a human-readable name for the domain.
+func (d Domain) String() string {
+	switch d {
+	case DomainUser:
+		return "user"
+	case DomainGlobal:
+		return "global"
+	case DomainSystem:
+		return "system"
+	default:
+		return "unknown"
+	}
+}
+
+// ServiceType distinguishes launch agents from launch daemons.
+type ServiceType int
+
+const (
+	TypeAgent  ServiceType = iota
+	TypeDaemon
+)
+
+// String returns "agent" or "daemon".
+func (t ServiceType) String() string {
+	switch t {
+	case TypeAgent:
+		return "agent"
+	case TypeDaemon:
+		return "daemon"
+	default:
+		return "unknown"
+	}
+}
+
+// Origin describes where on disk a plist was discovered.
+type Origin int
+
+const (
+	OriginLaunchDir Origin = iota // the classic LaunchAgents/LaunchDaemons directories
+	OriginApple                   // /Library/Apple/System/Library/LaunchDaemons
+	OriginAppBundle               // Contents/Library inside an app bundle (SMAppService)
+)
+
+// String returns a short name for the origin.
+func (o Origin) String() string {
+	switch o {
+	case OriginLaunchDir:
+		return "launchd"
+	case OriginApple:
+		return "apple"
+	case OriginAppBundle:
+		return "app"
+	default:
+		return "unknown"
+	}
+}
+
+// PlistDir describes a directory that contains plist files.
+type PlistDir struct {
+	Path      string
+	Domain    Domain
+	Type      ServiceType
+	Origin    Origin
+	AppBundle string // owning .app bundle for OriginAppBundle
+}
+
+// identity is a user lanchr acts for: the uid whose launchd domains are
+// queried and the home whose LaunchAgents are scanned.
+type identity struct {
+	uid  int
+	home string
+}
+
+// user, when set, replaces the running user.
+var user *identity
+
+// SetUser makes lanchr act for the user with uid and home instead of the
+// running user, for replaying a session recorded on another machine.
+func SetUser(uid int, home string) {
+	user = &identity{uid, home}
+}
+
+// CurrentUID returns the effective user ID.
+func CurrentUID() int {
+	if user != nil {
+		return user.uid
+	}
+	return os.Getuid()
+}
+
+// HomeDir returns the user's home directory.
+func HomeDir() (string, error) {
+	if user != nil {
+		return user.home, nil
+	}
+	return os.UserHomeDir()
+}
+
+// GUIDomainTarget returns the GUI domain target for the current user.
+func GUIDomainTarget() string {
+	return fmt.Sprintf("gui/%d", CurrentUID())
+}
+
+// UserDomainTarget returns the user domain target for the current user.
+func UserDomainTarget() string {
+	return fmt.Sprintf("user/%d", CurrentUID())
+}
+
+// ServiceTarget builds the launchctl service target string for a given domain and label.
+func ServiceTarget(domain Domain, label string) string {
+	switch domain {
+	case DomainUser, DomainGlobal:
+		return fmt.Sprintf("gui/%d/%s", CurrentUID(), label)
+	case DomainSystem:
+		return fmt.Sprintf("system/%s", label)
+	default:
+		return fmt.Sprintf("gui/%d/%s", CurrentUID(), label)
+	}
+}
+
+// DomainTarget returns the domain-level target (without a service label).
+func DomainTarget(domain Domain) string {
+	switch domain {
+	case DomainUser, DomainGlobal:
+		return GUIDomainTarget()
+	case DomainSystem:
+		return "system"
+	default:
+		return GUIDomainTarget()
+	}
+}
+
+// LaunchdDomainTarget returns the launchd domain a plist of the given domain
+// and type is bootstrapped into. Daemons always load into "system"; agents,
+// including those under /System/Library, load into the user's GUI domain.
+func LaunchdDomainTarget(_ Domain, t ServiceType) string {
+	if t == TypeDaemon {
+		return "system"
+	}
+	return GUIDomainTarget()
+}
+
+// LaunchdDomainTargets returns the domain targets to query for loaded
+// services, in lookup order.
+func LaunchdDomainTargets() []string {
+	return []string{GUIDomainTarget(), UserDomainTarget(), "system"}
+}
+
+// PlistDirectories returns all known plist directories on macOS.
+func PlistDirectories() []PlistDir {
+	home, _ := HomeDir()
+	dirs := []PlistDir{
+		{Path: filepath.Join(home, "Library", "LaunchAgents"), Domain: DomainUser, Type: TypeAgent},
+		{Path: "/Library/LaunchAgents", Domain: DomainGlobal, Type: TypeAgent},
+		{Path: "/Library/LaunchDaemons", Domain: DomainGlobal, Type: TypeDaemon},
+		{Path: "/System/Library/LaunchAgents", Domain: DomainSystem, Type: TypeAgent},
+		{Path: "/System/Library/LaunchDaemons", Domain: DomainSystem, Type: TypeDaemon},
+	}
+	return append(dirs, embeddedPlistDirectories("/")...)
+}
+
+// PlistDirectoriesUnder returns the plist directories of a macOS tree
+// mounted or extracted at root, for offline analysis. Every home directory
+// under root/Users contributes its own LaunchAgents directory.
+func PlistDirectoriesUnder(root string) []PlistDir {
+	var dirs []PlistDir
+	homes, _ := filepath.Glob(filepath.Join(root, "Users", "*"))
+	for _, home := range homes {
+		dirs = append(dirs, PlistDir{Path: filepath.Join(home, "Library", "LaunchAgents"), Domain: DomainUser, Type: TypeAgent})
+	}
+	dirs = append(dirs,
+		PlistDir{Path: filepath.Join(root, "Library", "LaunchAgents"), Domain: DomainGlobal, Type: TypeAgent},
+		PlistDir{Path: filepath.Join(root, "Library", "LaunchDaemons"), Domain: DomainGlobal, Type: TypeDaemon},
+		PlistDir{Path: filepath.Join(root, "System", "Library", "LaunchAgents"), Domain: DomainSystem, Type: TypeAgent},
+		PlistDir{Path: filepath.Join(root, "System", "Library", "LaunchDaemons"), Domain: DomainSystem, Type: TypeDaemon},
+	)
+	return append(dirs, embeddedPlistDirectories(root)...)
+}
+
+// embeddedPlistDirectories returns the plist directories outside the classic
+// locations: Apple's /Library/Apple daemons, and the Contents/Library
+// directories of app bundles in /Applications that register their helpers
+// with SMAppService.
+func embeddedPlistDirectories(root string) []PlistDir {
+	dirs := []PlistDir{
+		{Path: filepath.Join(root, "Library", "Apple", "System", "Library", "LaunchDaemons"), Domain: DomainSystem, Type: TypeDaemon, Origin: OriginApple},
+	}
+	for _, pattern := range []string{"*.app", "*/*.app"} {
+		apps, _ := filepath.Glob(filepath.Join(root, "Applications", pattern))
+		for _, app := range apps {
+			dirs = append(dirs,
+				PlistDir{Path: filepath.Join(app, "Contents", "Library", "LaunchAgents"), Domain: DomainGlobal, Type: TypeAgent, Origin: OriginAppBundle, AppBundle: app},
+				PlistDir{Path: filepath.Join(app, "Contents", "Library", "LaunchDaemons"), Domain: DomainGlobal, Type: TypeDaemon, Origin: OriginAppBundle, AppBundle: app},
+			)
+		}
+	}
+	return dirs
+}
+
+// IsSIPProtected returns true if the path is under /System/Library or
+// /Library/Apple.
+func IsSIPProtected(path string) bool {
+	return (len(path) > 15 && path[:15] == "/System/Library") ||
+		strings.HasPrefix(path, "/Library/Apple/")
+}
+
+// canonicalPath makes path absolute and resolves symlinks in its directory
+// but not in the final component: a symlinked plist is classified by the
+// directory the link lives in, not by where it points.
+func canonicalPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return abs
+	}
+	return filepath.Join(dir, filepath.Base(abs))
+}
+
+// DomainFromPath determines the domain from a plist file path.
+func DomainFromPath(path string) Domain {
+	path = canonicalPath(path)
+	home, _ := HomeDir()
+	if resolved, err := filepath.EvalSymlinks(home); err == nil {
+		home = resolved
+	}
+	userAgents := filepath.Join(home, "Library", "LaunchAgents")
+
+	switch {
+	case strings.HasPrefix(path, userAgents+"/"):
+		return DomainUser
+	case strings.HasPrefix(path, "/Library/LaunchAgents/"):
+		return DomainGlobal
+	case strings.HasPrefix(path, "/Library/LaunchDaemons/"):
+		return DomainGlobal
+	case strings.HasPrefix(path, "/System/Library/LaunchAgents/"):
+		return DomainSystem
+	case strings.HasPrefix(path, "/System/Library/LaunchDaemons/"):
+		return DomainSystem
+	case strings.HasPrefix(path, "/Library/Apple/System/Library/LaunchDaemons/"):
+		return DomainSystem
+	case strings.Contains(path, ".app/Contents/Library/"):
+		return DomainGlobal
+	default:
+		return DomainUser
+	}
+}
+
+// TypeFromPath determines whether a plist is an agent or daemon from its path.
+func TypeFromPath(path string) ServiceType {
+	path = canonicalPath(path)
+	if strings.HasPrefix(path, "/Library/LaunchDaemons/") ||
+		strings.HasPrefix(path, "/System/Library/LaunchDaemons/") ||
+		strings.HasPrefix(path, "/Library/Apple/System/Library/LaunchDaemons/") ||
+		strings.Contains(path, ".app/Contents/Library/LaunchDaemons/") {
+		return TypeDaemon
+	}
+	return TypeAgent
+}
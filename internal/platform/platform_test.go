@@ -0,0 +1,45 @@
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDomainAndTypeFromPath(t *testing.T) {
+	home := t.TempDir()
+	liveHome, _ := os.UserHomeDir()
+	SetUser(501, home)
+	t.Cleanup(func() { SetUser(os.Getuid(), liveHome) })
+	agents := filepath.Join(home, "Library", "LaunchAgents")
+	if err := os.MkdirAll(agents, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		domain Domain
+		typ    ServiceType
+	}{
+		{filepath.Join(agents, "x.plist"), DomainUser, TypeAgent},
+		{"/Library/LaunchAgents/x.plist", DomainGlobal, TypeAgent},
+		{"/Library/LaunchDaemons/x.plist", DomainGlobal, TypeDaemon},
+		{"/System/Library/LaunchAgents/x.plist", DomainSystem, TypeAgent},
+		{"/System/Library/LaunchDaemons/x.plist", DomainSystem, TypeDaemon},
+		{"/Library/Apple/System/Library/LaunchDaemons/x.plist", DomainSystem, TypeDaemon},
+		{"/Applications/X.app/Contents/Library/LaunchAgents/x.plist", DomainGlobal, TypeAgent},
+		{"/Applications/X.app/Contents/Library/LaunchDaemons/x.plist", DomainGlobal, TypeDaemon},
+		// Only whole directory names match.
+		{agents + "Old/x.plist", DomainUser, TypeAgent},
+		{"/Library/LaunchDaemonsOld/x.plist", DomainUser, TypeAgent},
+		{"/tmp/x.plist", DomainUser, TypeAgent},
+	}
+	for _, tt := range tests {
+		if got := DomainFromPath(tt.path); got != tt.domain {
+			t.Errorf("DomainFromPath(%s) = %s, want %s", tt.path, got, tt.domain)
+		}
+		if got := TypeFromPath(tt.path); got != tt.typ {
+			t.Errorf("TypeFromPath(%s) = %s, want %s", tt.path, got, tt.typ)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package plist
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// backupTimeFormat is the timestamp in backup file names.
+const backupTimeFormat = "20060102-150405"
+
+// BackupPath returns the name a backup of path taken at t gets:
+// "com.example.agent.plist.20240501-120000.bak". launchd only loads files
+// ending in .plist, so backups next to the original are ignored.
+func BackupPath(path string, t time.Time) string {
+	return fmt.Sprintf("%s.%s.bak", path, t.Format(backupTimeFormat))
+}
+
+// unusedBackupPath returns BackupPath(path, t), numbered if a backup was
+// already taken in the same second.
+func unusedBackupPath(path string, t time.Time) string {
+	backup := BackupPath(path, t)
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(backup); os.IsNotExist(err) {
+			return backup
+		}
+		backup = fmt.Sprintf("%s.%s-%d.bak", path, t.Format(backupTimeFormat), n)
+	}
+}
+
+// writeFileAtomic replaces path with data so that readers see either the
+// old or the new file, never a partial one. The data is written to a
+// temporary file in the same directory, synced and renamed over path. An
+// existing file keeps its mode and owner, and is kept as a timestamped
+// backup (see BackupPath), whose name is returned. A symlinked path is
+// written through to its target.
+func writeFileAtomic(path string, data []byte) (string, error) {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	dir := filepath.Dir(path)
+
+	mode := os.FileMode(0644)
+	uid, gid := -1, -1
+	existing, err := os.Lstat(path)
+	switch {
+	case err == nil:
+		mode = existing.Mode().Perm()
+		uid, gid = fileOwner(existing)
+	case !os.IsNotExist(err):
+		return "", fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file in %s: %w", dir, err)
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", tmpPath, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", fmt.Errorf("failed to sync %s: %w", tmpPath, err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return "", fmt.Errorf("failed to set mode on %s: %w", tmpPath, err)
+	}
+	if uid >= 0 && (uid != os.Geteuid() || gid != os.Getegid()) {
+		if err := tmp.Chown(uid, gid); err != nil {
+			return "", fmt.Errorf("failed to keep owner %d:%d of %s: %w", uid, gid, path, err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to close %s: %w", tmpPath, err)
+	}
+
+	backup := ""
+	if existing != nil {
+		backup = unusedBackupPath(path, time.Now())
+		if err := backupFile(path, backup); err != nil {
+			return "", err
+		}
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	committed = true
+
+	// Persist the rename itself; not every file system supports this.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return backup, nil
+}
+
+// backupFile preserves src at dst, as a hard link when possible and as a
+// copy with the same mode otherwise.
+func backupFile(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to back up %s: %w", src, err)
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to back up %s: %w", src, err)
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create backup %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return fmt.Errorf("failed to write backup %s: %w", dst, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write backup %s: %w", dst, err)
+	}
+	return nil
+}
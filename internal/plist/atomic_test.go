@@ -0,0 +1,115 @@
+package plist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// backups returns the backup files of path.
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(path + ".*.bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestWriteFileAtomic_NewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "com.test.new.plist")
+
+	backup, err := writeFileAtomic(path, []byte("new"))
+	if err != nil {
+		t.Fatalf("writeFileAtomic() error = %v", err)
+	}
+	if backup != "" {
+		t.Errorf("backup = %q, want none for a new file", backup)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("mode = %v, want 0644", info.Mode().Perm())
+	}
+}
+
+func TestWriteFileAtomic_KeepsModeAndBacksUp(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "com.test.existing.plist")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := writeFileAtomic(path, []byte("new"))
+	if err != nil {
+		t.Fatalf("writeFileAtomic() error = %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "new" {
+		t.Errorf("content = %q, want new", got)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	if got, _ := os.ReadFile(backup); string(got) != "old" {
+		t.Errorf("backup %s = %q, want old", backup, got)
+	}
+
+	// A second write in the same second gets its own backup.
+	if _, err := writeFileAtomic(path, []byte("newer")); err != nil {
+		t.Fatalf("second writeFileAtomic() error = %v", err)
+	}
+	if n := len(backups(t, path)); n != 2 {
+		t.Errorf("got %d backups, want 2", n)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("temporary file %s left behind", e.Name())
+		}
+	}
+}
+
+func TestWriteFileAtomic_WritesThroughSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "real.plist")
+	link := filepath.Join(dir, "com.test.link.plist")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writeFileAtomic(link, []byte("new")); err != nil {
+		t.Fatalf("writeFileAtomic() error = %v", err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("link was replaced by a regular file")
+	}
+	if got, _ := os.ReadFile(target); string(got) != "new" {
+		t.Errorf("target content = %q, want new", got)
+	}
+}
+
+func TestDocumentWriteFile_EncodeErrorKeepsOriginal(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "com.test.keep.plist")
+	if err := os.WriteFile(path, []byte(documentXML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := &Document{Format: Format(99), Root: NewDict()}
+	if err := doc.WriteFile(path); err == nil {
+		t.Fatal("expected an encode error, got nil")
+	}
+	if got, _ := os.ReadFile(path); string(got) != documentXML {
+		t.Error("original plist was modified by a failed write")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d files, want only the original", len(entries))
+	}
+}
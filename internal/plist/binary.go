@@ -0,0 +1,446 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+// bplistMagic starts every binary plist.
+const bplistMagic = "bplist00"
+
+// bplistEpoch is the reference date of binary plist dates.
+var bplistEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// errBinaryTruncated is returned for offsets past the end of the data.
+var errBinaryTruncated = errors.New("binary plist is truncated")
+
+// bplistReader decodes the object table of a binary plist.
+type bplistReader struct {
+	data       []byte
+	offsets    []uint64
+	refSize    int
+	inProgress map[uint64]bool
+}
+
+// decodeBinary parses a bplist00 file, keeping dictionary key order.
+func decodeBinary(data []byte) (interface{}, error) {
+	if len(data) < len(bplistMagic)+32 || string(data[:len(bplistMagic)]) != bplistMagic {
+		return nil, errors.New("not a binary plist")
+	}
+	trailer := data[len(data)-32:]
+	offsetSize := int(trailer[6])
+	refSize := int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:16])
+	topObject := binary.BigEndian.Uint64(trailer[16:24])
+	tableOffset := binary.BigEndian.Uint64(trailer[24:32])
+
+	if offsetSize < 1 || offsetSize > 8 || refSize < 1 || refSize > 8 {
+		return nil, errors.New("invalid binary plist trailer")
+	}
+	if numObjects == 0 || topObject >= numObjects ||
+		tableOffset >= uint64(len(data)) ||
+		numObjects > (uint64(len(data))-tableOffset)/uint64(offsetSize) {
+		return nil, errors.New("invalid binary plist trailer")
+	}
+
+	r := &bplistReader{
+		data:       data,
+		offsets:    make([]uint64, numObjects),
+		refSize:    refSize,
+		inProgress: make(map[uint64]bool),
+	}
+	for i := range r.offsets {
+		start := tableOffset + uint64(i*offsetSize)
+		r.offsets[i] = readUint(data[start : start+uint64(offsetSize)])
+	}
+	return r.object(topObject)
+}
+
+// readUint reads a big-endian unsigned integer of up to 8 bytes.
+func readUint(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
+
+// bytesAt returns n bytes at off, or an error if they run past the data.
+func (r *bplistReader) bytesAt(off, n uint64) ([]byte, error) {
+	if off > uint64(len(r.data)) || n > uint64(len(r.data))-off {
+		return nil, errBinaryTruncated
+	}
+	return r.data[off : off+n], nil
+}
+
+// length reads the count that follows a marker byte at off. Counts of 15
+// or more are stored as an integer object after the marker. It returns the
+// count and the offset of the payload.
+func (r *bplistReader) length(marker byte, off uint64) (uint64, uint64, error) {
+	if marker&0x0F != 0x0F {
+		return uint64(marker & 0x0F), off + 1, nil
+	}
+	hdr, err := r.bytesAt(off+1, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if hdr[0]&0xF0 != 0x10 {
+		return 0, 0, fmt.Errorf("invalid length marker 0x%02x", hdr[0])
+	}
+	size := uint64(1) << (hdr[0] & 0x0F)
+	b, err := r.bytesAt(off+2, size)
+	if err != nil {
+		return 0, 0, err
+	}
+	return readUint(b), off + 2 + size, nil
+}
+
+// refs reads n object references at off.
+func (r *bplistReader) refs(off, n uint64) ([]uint64, error) {
+	b, err := r.bytesAt(off, n*uint64(r.refSize))
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]uint64, n)
+	for i := range refs {
+		refs[i] = readUint(b[i*r.refSize : (i+1)*r.refSize])
+	}
+	return refs, nil
+}
+
+// object decodes the object at index i.
+func (r *bplistReader) object(i uint64) (interface{}, error) {
+	if i >= uint64(len(r.offsets)) {
+		return nil, fmt.Errorf("object reference %d out of range", i)
+	}
+	if r.inProgress[i] {
+		return nil, errors.New("binary plist contains a reference cycle")
+	}
+	r.inProgress[i] = true
+	defer delete(r.inProgress, i)
+
+	off := r.offsets[i]
+	hdr, err := r.bytesAt(off, 1)
+	if err != nil {
+		return nil, err
+	}
+	marker := hdr[0]
+
+	switch marker & 0xF0 {
+	case 0x00:
+		switch marker {
+		case 0x08:
+			return false, nil
+		case 0x09:
+			return true, nil
+		}
+		return nil, fmt.Errorf("unsupported binary plist marker 0x%02x", marker)
+	case 0x10:
+		size := uint64(1) << (marker & 0x0F)
+		b, err := r.bytesAt(off+1, size)
+		if err != nil {
+			return nil, err
+		}
+		switch size {
+		case 1, 2, 4, 8:
+			// Only 8-byte integers are signed, which int64 gets for free.
+			return int64(readUint(b)), nil
+		case 16:
+			// 128-bit integers are written for values above math.MaxInt64.
+			n := readUint(b[8:])
+			if n > math.MaxInt64 {
+				return n, nil
+			}
+			return int64(n), nil
+		}
+		return nil, fmt.Errorf("unsupported integer size %d", size)
+	case 0x20:
+		size := uint64(1) << (marker & 0x0F)
+		b, err := r.bytesAt(off+1, size)
+		if err != nil {
+			return nil, err
+		}
+		switch size {
+		case 4:
+			return float64(math.Float32frombits(uint32(readUint(b)))), nil
+		case 8:
+			return math.Float64frombits(readUint(b)), nil
+		}
+		return nil, fmt.Errorf("unsupported real size %d", size)
+	case 0x30:
+		b, err := r.bytesAt(off+1, 8)
+		if err != nil {
+			return nil, err
+		}
+		secs := math.Float64frombits(readUint(b))
+		return bplistEpoch.Add(time.Duration(secs * float64(time.Second))), nil
+	case 0x40, 0x50, 0x60:
+		n, start, err := r.length(marker, off)
+		if err != nil {
+			return nil, err
+		}
+		if marker&0xF0 == 0x60 {
+			b, err := r.bytesAt(start, n*2)
+			if err != nil {
+				return nil, err
+			}
+			units := make([]uint16, n)
+			for j := range units {
+				units[j] = binary.BigEndian.Uint16(b[j*2:])
+			}
+			return string(utf16.Decode(units)), nil
+		}
+		b, err := r.bytesAt(start, n)
+		if err != nil {
+			return nil, err
+		}
+		if marker&0xF0 == 0x40 {
+			return append([]byte(nil), b...), nil
+		}
+		return string(b), nil
+	case 0x80:
+		b, err := r.bytesAt(off+1, uint64(marker&0x0F)+1)
+		if err != nil {
+			return nil, err
+		}
+		return UID(readUint(b)), nil
+	case 0xA0, 0xC0:
+		n, start, err := r.length(marker, off)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := r.refs(start, n)
+		if err != nil {
+			return nil, err
+		}
+		arr := make([]interface{}, 0, n)
+		for _, ref := range refs {
+			v, err := r.object(ref)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, nil
+	case 0xD0:
+		n, start, err := r.length(marker, off)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := r.refs(start, 2*n)
+		if err != nil {
+			return nil, err
+		}
+		d := NewDict()
+		for j := uint64(0); j < n; j++ {
+			k, err := r.object(refs[j])
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("dictionary key is %s, not a string", typeName(k))
+			}
+			v, err := r.object(refs[n+j])
+			if err != nil {
+				return nil, err
+			}
+			d.Set(key, v)
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("unsupported binary plist marker 0x%02x", marker)
+}
+
+// bplistWriter flattens a value tree into a binary plist object table.
+type bplistWriter struct {
+	objects [][]byte // encoded objects, without references resolved
+	refs    [][]int  // child object indices for arrays and dictionaries
+	strings map[string]int
+}
+
+// encodeBinary serializes root as a bplist00 file. Strings are uniqued,
+// like CoreFoundation does, which keeps key-heavy launchd plists small.
+func encodeBinary(root *Dict) ([]byte, error) {
+	w := &bplistWriter{strings: make(map[string]int)}
+	if _, err := w.flatten(root); err != nil {
+		return nil, err
+	}
+
+	refSize := minBytes(uint64(len(w.objects)))
+	var buf bytes.Buffer
+	buf.WriteString(bplistMagic)
+	offsets := make([]uint64, len(w.objects))
+	for i, obj := range w.objects {
+		offsets[i] = uint64(buf.Len())
+		buf.Write(obj)
+		for _, ref := range w.refs[i] {
+			writeUint(&buf, uint64(ref), refSize)
+		}
+	}
+
+	tableOffset := uint64(buf.Len())
+	offsetSize := minBytes(tableOffset)
+	for _, off := range offsets {
+		writeUint(&buf, off, offsetSize)
+	}
+
+	trailer := make([]byte, 32)
+	trailer[6] = byte(offsetSize)
+	trailer[7] = byte(refSize)
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(w.objects)))
+	binary.BigEndian.PutUint64(trailer[16:], 0)
+	binary.BigEndian.PutUint64(trailer[24:], tableOffset)
+	buf.Write(trailer)
+	return buf.Bytes(), nil
+}
+
+// minBytes returns the smallest of 1, 2, 4 or 8 bytes that can hold n.
+func minBytes(n uint64) int {
+	switch {
+	case n <= math.MaxUint8:
+		return 1
+	case n <= math.MaxUint16:
+		return 2
+	case n <= math.MaxUint32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func writeUint(buf *bytes.Buffer, n uint64, size int) {
+	for i := size - 1; i >= 0; i-- {
+		buf.WriteByte(byte(n >> (8 * i)))
+	}
+}
+
+// header encodes a marker with a count, spilling counts of 15 or more into
+// a following integer object.
+func header(marker byte, n int) []byte {
+	if n < 15 {
+		return []byte{marker | byte(n)}
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(marker | 0x0F)
+	size := minBytes(uint64(n))
+	buf.WriteByte(0x10 | byte(math.Log2(float64(size))))
+	writeUint(&buf, uint64(n), size)
+	return buf.Bytes()
+}
+
+// add appends an encoded object and returns its index.
+func (w *bplistWriter) add(obj []byte, refs []int) int {
+	w.objects = append(w.objects, obj)
+	w.refs = append(w.refs, refs)
+	return len(w.objects) - 1
+}
+
+// flatten appends v (and its children, after it) to the object table.
+func (w *bplistWriter) flatten(v interface{}) (int, error) {
+	switch x := v.(type) {
+	case *Dict:
+		idx := w.add(header(0xD0, x.Len()), nil)
+		refs := make([]int, 2*x.Len())
+		for i, k := range x.keys {
+			ki, err := w.flatten(k)
+			if err != nil {
+				return 0, err
+			}
+			refs[i] = ki
+		}
+		for i, k := range x.keys {
+			vi, err := w.flatten(x.values[k])
+			if err != nil {
+				return 0, err
+			}
+			refs[x.Len()+i] = vi
+		}
+		w.refs[idx] = refs
+		return idx, nil
+	case []interface{}:
+		idx := w.add(header(0xA0, len(x)), nil)
+		refs := make([]int, len(x))
+		for i, el := range x {
+			ei, err := w.flatten(el)
+			if err != nil {
+				return 0, err
+			}
+			refs[i] = ei
+		}
+		w.refs[idx] = refs
+		return idx, nil
+	case string:
+		if idx, ok := w.strings[x]; ok {
+			return idx, nil
+		}
+		var obj []byte
+		if isASCII(x) {
+			obj = append(header(0x50, len(x)), x...)
+		} else {
+			units := utf16.Encode([]rune(x))
+			obj = header(0x60, len(units))
+			for _, u := range units {
+				obj = binary.BigEndian.AppendUint16(obj, u)
+			}
+		}
+		idx := w.add(obj, nil)
+		w.strings[x] = idx
+		return idx, nil
+	case int64:
+		return w.add(encodeInt(x), nil), nil
+	case uint64:
+		if x <= math.MaxInt64 {
+			return w.add(encodeInt(int64(x)), nil), nil
+		}
+		obj := []byte{0x14}
+		obj = binary.BigEndian.AppendUint64(obj, 0)
+		obj = binary.BigEndian.AppendUint64(obj, x)
+		return w.add(obj, nil), nil
+	case float64:
+		return w.add(binary.BigEndian.AppendUint64([]byte{0x23}, math.Float64bits(x)), nil), nil
+	case bool:
+		if x {
+			return w.add([]byte{0x09}, nil), nil
+		}
+		return w.add([]byte{0x08}, nil), nil
+	case time.Time:
+		secs := x.Sub(bplistEpoch).Seconds()
+		return w.add(binary.BigEndian.AppendUint64([]byte{0x33}, math.Float64bits(secs)), nil), nil
+	case []byte:
+		return w.add(append(header(0x40, len(x)), x...), nil), nil
+	case UID:
+		size := minBytes(uint64(x))
+		var buf bytes.Buffer
+		buf.WriteByte(0x80 | byte(size-1))
+		writeUint(&buf, uint64(x), size)
+		return w.add(buf.Bytes(), nil), nil
+	}
+	return 0, fmt.Errorf("cannot encode %T in a binary plist", v)
+}
+
+// encodeInt encodes an integer object. Negative values always take 8 bytes.
+func encodeInt(n int64) []byte {
+	if n < 0 {
+		return binary.BigEndian.AppendUint64([]byte{0x13}, uint64(n))
+	}
+	size := minBytes(uint64(n))
+	var buf bytes.Buffer
+	buf.WriteByte(0x10 | byte(math.Log2(float64(size))))
+	writeUint(&buf, uint64(n), size)
+	return buf.Bytes()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -1,37 +1,305 @@
 package plist
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// ExportBundle is a portable JSON representation of a launch agent/daemon.
+// BundleVersion is the version of the export bundles this package writes.
+// Version 1 bundles held a single service in the envelope itself; they are
+// still read.
+const BundleVersion = 2
+
+// ExportBundle is a portable JSON representation of one or more launch
+// agents/daemons, with the files their plists reference if requested.
 type ExportBundle struct {
-	Version   int              `json:"version"`
+	Version    int             `json:"version"`
 	ExportedAt string          `json:"exported_at"`
+	Services   []BundleService `json:"services"`
+	Assets     []BundleAsset   `json:"assets,omitempty"`
+	// Signature is set by "lanchr export --sign".
+	Signature *BundleSignature `json:"signature,omitempty"`
+}
+
+// BundleService is one launch agent/daemon in an export bundle.
+type BundleService struct {
 	Label     string           `json:"label"`
 	Domain    string           `json:"domain"`
 	Type      string           `json:"type"`
 	PlistPath string           `json:"plist_path"`
 	Plist     LaunchAgentPlist `json:"plist"`
+	// PlistData is the original plist file. When present, import writes it
+	// verbatim so that keys Plist does not model and the encoding survive,
+	// and Plist, if set, must describe the same plist.
+	PlistData []byte `json:"plist_data,omitempty"`
 }
 
-// NewExportBundle creates an ExportBundle from a parsed plist and its metadata.
-func NewExportBundle(pl *LaunchAgentPlist, plistPath, domain, serviceType string) *ExportBundle {
+// HomePlaceholder stands for the exporting user's home directory in the
+// paths of an export bundle. Import expands it to the importing user's
+// home, so that a bundle moves between users.
+const HomePlaceholder = "{{HOME}}"
+
+// Document returns the service's plist as a document: the original file
+// if the bundle has it, or one built from Plist.
+func (s *BundleService) Document() (*Document, error) {
+	if len(s.PlistData) == 0 {
+		return DocumentFromPlist(&s.Plist), nil
+	}
+	doc, err := ParseDocument(s.PlistData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode plist of %s in export bundle: %w", s.Label, err)
+	}
+	return doc, nil
+}
+
+// CheckLabel returns an error if label cannot name a plist file, because
+// it contains a slash, ".." or a NUL byte and "<label>.plist" would not
+// stay in the directory it is written to.
+func CheckLabel(label string) error {
+	if strings.ContainsAny(label, "/\x00") || strings.Contains(label, "..") {
+		return fmt.Errorf("label %q cannot name a plist file: it contains a slash, \"..\" or a NUL byte", label)
+	}
+	return nil
+}
+
+// checkPlist reports an error if the service has both PlistData and a
+// Plist that describes a different plist, since import would silently
+// write the former.
+func (s *BundleService) checkPlist() error {
+	if len(s.PlistData) == 0 || reflect.ValueOf(s.Plist).IsZero() {
+		return nil
+	}
+	doc, err := s.Document()
+	if err != nil {
+		return err
+	}
+	pl, err := doc.Plist()
+	if err != nil {
+		return fmt.Errorf("failed to decode plist of %s in export bundle: %w", s.Label, err)
+	}
+	same, err := sameJSON(&s.Plist, pl)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return fmt.Errorf("the plist of %s does not match its plist_data", s.Label)
+	}
+	return nil
+}
+
+// sameJSON reports whether a and b have the same JSON encoding, ignoring
+// the order of keys and how numbers were typed.
+func sameJSON(a, b interface{}) (bool, error) {
+	var decoded [2]interface{}
+	for i, v := range []interface{}{a, b} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return false, err
+		}
+		if err := json.Unmarshal(data, &decoded[i]); err != nil {
+			return false, err
+		}
+	}
+	return reflect.DeepEqual(decoded[0], decoded[1]), nil
+}
+
+// SetDocument replaces the service's plist, and its label, with doc.
+func (s *BundleService) SetDocument(doc *Document) error {
+	pl, err := doc.Plist()
+	if err != nil {
+		return err
+	}
+	data, err := doc.Encode()
+	if err != nil {
+		return err
+	}
+	s.Label = pl.Label
+	s.Plist = *pl
+	s.PlistData = data
+	return nil
+}
+
+// BundleAsset is a script or binary referenced by a plist, embedded in an
+// export bundle.
+type BundleAsset struct {
+	// Path is where the file was on the exporting system: an absolute,
+	// clean path, which may start with HomePlaceholder.
+	Path string `json:"path"`
+	// Mode is the file's permission bits in octal, such as "0755".
+	Mode   string `json:"mode"`
+	SHA256 string `json:"sha256"`
+	Data   []byte `json:"data"`
+}
+
+// NewBundle creates an empty ExportBundle.
+func NewBundle() *ExportBundle {
 	return &ExportBundle{
-		Version:    1,
+		Version:    BundleVersion,
 		ExportedAt: time.Now().UTC().Format(time.RFC3339),
-		Label:      pl.Label,
-		Domain:     domain,
-		Type:       serviceType,
-		PlistPath:  plistPath,
-		Plist:      *pl,
 	}
 }
 
+// NewExportBundle creates an ExportBundle holding a single service.
+func NewExportBundle(pl *LaunchAgentPlist, plistPath, domain, serviceType string) *ExportBundle {
+	b := NewBundle()
+	b.AddService(pl, plistPath, domain, serviceType)
+	return b
+}
+
+// AddService adds a service to the bundle and returns it, so that its
+// PlistData can be set.
+func (b *ExportBundle) AddService(pl *LaunchAgentPlist, plistPath, domain, serviceType string) *BundleService {
+	b.Services = append(b.Services, BundleService{
+		Label:     pl.Label,
+		Domain:    domain,
+		Type:      serviceType,
+		PlistPath: plistPath,
+		Plist:     *pl,
+	})
+	return &b.Services[len(b.Services)-1]
+}
+
+// AddAsset embeds the file at hostPath, recorded under path, which differs
+// when exporting from a mounted tree. A path already in the bundle is
+// skipped.
+func (b *ExportBundle) AddAsset(path, hostPath string) error {
+	for _, a := range b.Assets {
+		if a.Path == path {
+			return nil
+		}
+	}
+	info, err := os.Stat(hostPath)
+	if err != nil {
+		return fmt.Errorf("failed to read asset: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("asset %s is not a regular file", path)
+	}
+	data, err := os.ReadFile(hostPath)
+	if err != nil {
+		return fmt.Errorf("failed to read asset: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	b.Assets = append(b.Assets, BundleAsset{
+		Path:   path,
+		Mode:   fmt.Sprintf("%04o", info.Mode().Perm()),
+		SHA256: hex.EncodeToString(sum[:]),
+		Data:   data,
+	})
+	return nil
+}
+
+// FileMode returns the asset's permission bits.
+func (a *BundleAsset) FileMode() (os.FileMode, error) {
+	n, err := strconv.ParseUint(a.Mode, 8, 32)
+	if err != nil || n > 0777 {
+		return 0, fmt.Errorf("asset %s has an invalid mode %q", a.Path, a.Mode)
+	}
+	return os.FileMode(n), nil
+}
+
+// Verify checks the asset's data against its checksum.
+func (a *BundleAsset) Verify() error {
+	sum := sha256.Sum256(a.Data)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), a.SHA256) {
+		return fmt.Errorf("asset %s does not match its SHA-256 checksum", a.Path)
+	}
+	return nil
+}
+
+// Matches reports whether the file at path has the asset's content.
+func (a *BundleAsset) Matches(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && bytes.Equal(data, a.Data)
+}
+
+// systemDirs hold programs that come with macOS, so they are never
+// embedded as assets.
+var systemDirs = []string{"/bin/", "/sbin/", "/usr/bin/", "/usr/sbin/", "/usr/libexec/", "/System/"}
+
+// AssetPaths returns the files a plist references that are worth
+// embedding in a bundle: its program and any absolute, clean path among
+// its arguments, such as a script given to an interpreter, except those
+// in the system directories.
+func AssetPaths(pl *LaunchAgentPlist) []string {
+	candidates := []string{pl.ProgramPath()}
+	if len(pl.ProgramArguments) > 1 {
+		candidates = append(candidates, pl.ProgramArguments[1:]...)
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for _, p := range candidates {
+		if !strings.HasPrefix(p, "/") || filepath.Clean(p) != p || seen[p] || isSystemPath(p) {
+			continue
+		}
+		seen[p] = true
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// ReplacePath replaces old with new wherever old appears in s as a whole
+// path or the start of one: "/Users/me" is replaced in "/Users/me/bin" and
+// "PATH=/Users/me/bin:/usr/bin", but not in "/Users/mel" or "/x/Users/me".
+func ReplacePath(s, old, new string) string {
+	if old == "" {
+		return s
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, old)
+		if i < 0 {
+			break
+		}
+		end := i + len(old)
+		whole := (i == 0 || !isPathByte(s[i-1])) &&
+			(end == len(s) || s[end] == '/' || !isPathByte(s[end]))
+		b.WriteString(s[:i])
+		if whole {
+			b.WriteString(new)
+		} else {
+			b.WriteString(old)
+		}
+		s = s[end:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// isPathByte reports whether c can be part of a path component or join
+// two of them.
+func isPathByte(c byte) bool {
+	return c == '/' || c == '.' || c == '_' || c == '-' ||
+		c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// validAssetPath reports whether p is an absolute, clean path, in the
+// home placeholder or not, so that an asset cannot be written anywhere
+// but where it says.
+func validAssetPath(p string) bool {
+	p = strings.TrimPrefix(p, HomePlaceholder)
+	return filepath.IsAbs(p) && filepath.Clean(p) == p
+}
+
+func isSystemPath(p string) bool {
+	for _, dir := range systemDirs {
+		if strings.HasPrefix(p, dir) {
+			return true
+		}
+	}
+	return false
+}
+
 // WriteBundle serializes an ExportBundle to the given writer as JSON.
 func WriteBundle(w io.Writer, bundle *ExportBundle) error {
 	enc := json.NewEncoder(w)
@@ -53,19 +321,66 @@ func WriteBundleToFile(path string, bundle *ExportBundle) error {
 	return WriteBundle(f, bundle)
 }
 
-// ReadBundle reads an ExportBundle from the given reader.
+// ReadBundle reads an ExportBundle from the given reader. A version 1
+// bundle is returned as a bundle of its one service. Embedded assets are
+// checked against their checksums.
 func ReadBundle(r io.Reader) (*ExportBundle, error) {
-	var bundle ExportBundle
+	// A version 1 bundle has the fields of a BundleService in the
+	// envelope.
+	var raw struct {
+		ExportBundle
+		BundleService
+	}
 	dec := json.NewDecoder(r)
-	if err := dec.Decode(&bundle); err != nil {
+	if err := dec.Decode(&raw); err != nil {
 		return nil, fmt.Errorf("failed to decode export bundle: %w", err)
 	}
 
-	if bundle.Version == 0 {
+	bundle := raw.ExportBundle
+	switch bundle.Version {
+	case 0:
 		return nil, fmt.Errorf("invalid export bundle: missing version")
+	case 1:
+		if raw.Label == "" {
+			return nil, fmt.Errorf("invalid export bundle: missing label")
+		}
+		bundle.Services = []BundleService{raw.BundleService}
+		bundle.Assets = nil
+		bundle.Signature = nil
+	case 2:
+		if len(bundle.Services) == 0 {
+			return nil, fmt.Errorf("invalid export bundle: no services")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported export bundle version %d; upgrade lanchr", bundle.Version)
+	}
+
+	labels := make(map[string]bool)
+	for i, svc := range bundle.Services {
+		if svc.Label == "" {
+			return nil, fmt.Errorf("invalid export bundle: service %d is missing its label", i+1)
+		}
+		if err := CheckLabel(svc.Label); err != nil {
+			return nil, fmt.Errorf("invalid export bundle: %w", err)
+		}
+		if labels[svc.Label] {
+			return nil, fmt.Errorf("invalid export bundle: %s appears more than once", svc.Label)
+		}
+		labels[svc.Label] = true
+		if err := bundle.Services[i].checkPlist(); err != nil {
+			return nil, fmt.Errorf("invalid export bundle: %w", err)
+		}
 	}
-	if bundle.Label == "" {
-		return nil, fmt.Errorf("invalid export bundle: missing label")
+	for i := range bundle.Assets {
+		if !validAssetPath(bundle.Assets[i].Path) {
+			return nil, fmt.Errorf("invalid export bundle: asset path %q is not absolute and clean", bundle.Assets[i].Path)
+		}
+		if err := bundle.Assets[i].Verify(); err != nil {
+			return nil, fmt.Errorf("invalid export bundle: %w", err)
+		}
+		if _, err := bundle.Assets[i].FileMode(); err != nil {
+			return nil, fmt.Errorf("invalid export bundle: %w", err)
+		}
 	}
 
 	return &bundle, nil
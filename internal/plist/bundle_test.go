@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -21,17 +22,21 @@ func TestExportBundleRoundTrip(t *testing.T) {
 
 	bundle := NewExportBundle(pl, "/Users/test/Library/LaunchAgents/com.test.roundtrip.plist", "user", "agent")
 
-	if bundle.Version != 1 {
-		t.Errorf("expected version 1, got %d", bundle.Version)
+	if bundle.Version != BundleVersion {
+		t.Errorf("expected version %d, got %d", BundleVersion, bundle.Version)
 	}
-	if bundle.Label != "com.test.roundtrip" {
-		t.Errorf("expected label %q, got %q", "com.test.roundtrip", bundle.Label)
+	if len(bundle.Services) != 1 {
+		t.Fatalf("expected 1 service, got %d", len(bundle.Services))
 	}
-	if bundle.Domain != "user" {
-		t.Errorf("expected domain %q, got %q", "user", bundle.Domain)
+	svc := bundle.Services[0]
+	if svc.Label != "com.test.roundtrip" {
+		t.Errorf("expected label %q, got %q", "com.test.roundtrip", svc.Label)
 	}
-	if bundle.Type != "agent" {
-		t.Errorf("expected type %q, got %q", "agent", bundle.Type)
+	if svc.Domain != "user" {
+		t.Errorf("expected domain %q, got %q", "user", svc.Domain)
+	}
+	if svc.Type != "agent" {
+		t.Errorf("expected type %q, got %q", "agent", svc.Type)
 	}
 	if bundle.ExportedAt == "" {
 		t.Error("expected non-empty ExportedAt")
@@ -44,22 +49,23 @@ func TestExportBundleRoundTrip(t *testing.T) {
 	}
 
 	// Read back.
-	restored, err := ReadBundle(&buf)
+	bundleRead, err := ReadBundle(&buf)
 	if err != nil {
 		t.Fatalf("failed to read bundle: %v", err)
 	}
-
-	if restored.Version != bundle.Version {
-		t.Errorf("version mismatch: %d != %d", restored.Version, bundle.Version)
+	if bundleRead.Version != bundle.Version {
+		t.Errorf("version mismatch: %d != %d", bundleRead.Version, bundle.Version)
 	}
-	if restored.Label != bundle.Label {
-		t.Errorf("label mismatch: %q != %q", restored.Label, bundle.Label)
+	restored := bundleRead.Services[0]
+
+	if restored.Label != svc.Label {
+		t.Errorf("label mismatch: %q != %q", restored.Label, svc.Label)
 	}
-	if restored.Domain != bundle.Domain {
-		t.Errorf("domain mismatch: %q != %q", restored.Domain, bundle.Domain)
+	if restored.Domain != svc.Domain {
+		t.Errorf("domain mismatch: %q != %q", restored.Domain, svc.Domain)
 	}
-	if restored.Type != bundle.Type {
-		t.Errorf("type mismatch: %q != %q", restored.Type, bundle.Type)
+	if restored.Type != svc.Type {
+		t.Errorf("type mismatch: %q != %q", restored.Type, svc.Type)
 	}
 	if restored.Plist.Label != pl.Label {
 		t.Errorf("plist label mismatch: %q != %q", restored.Plist.Label, pl.Label)
@@ -96,10 +102,11 @@ func TestWriteBundleToFileAndReadBack(t *testing.T) {
 	}
 
 	// Read back.
-	restored, err := ReadBundleFromFile(path)
+	bundleRead, err := ReadBundleFromFile(path)
 	if err != nil {
 		t.Fatalf("failed to read bundle from file: %v", err)
 	}
+	restored := bundleRead.Services[0]
 
 	if restored.Label != "com.test.file" {
 		t.Errorf("expected label %q, got %q", "com.test.file", restored.Label)
@@ -128,6 +135,46 @@ func TestReadBundleInvalid(t *testing.T) {
 			input:   `{"version": 1}`,
 			wantErr: "missing label",
 		},
+		{
+			name:    "no services",
+			input:   `{"version": 2, "services": []}`,
+			wantErr: "no services",
+		},
+		{
+			name:    "duplicate label",
+			input:   `{"version": 2, "services": [{"label": "a"}, {"label": "a"}]}`,
+			wantErr: "a appears more than once",
+		},
+		{
+			name:    "label with a slash",
+			input:   `{"version": 2, "services": [{"label": "../../Library/LaunchDaemons/evil"}]}`,
+			wantErr: "cannot name a plist file",
+		},
+		{
+			name:    "label with a NUL byte",
+			input:   `{"version": 2, "services": [{"label": "a\u0000b"}]}`,
+			wantErr: "cannot name a plist file",
+		},
+		{
+			name:    "bad checksum",
+			input:   `{"version": 2, "services": [{"label": "a"}], "assets": [{"path": "/x", "mode": "0755", "sha256": "00", "data": "AAEC"}]}`,
+			wantErr: "does not match its SHA-256 checksum",
+		},
+		{
+			name:    "relative asset path",
+			input:   `{"version": 2, "services": [{"label": "a"}], "assets": [{"path": "x", "mode": "0755", "sha256": "", "data": ""}]}`,
+			wantErr: `asset path "x" is not absolute and clean`,
+		},
+		{
+			name:    "unclean asset path",
+			input:   `{"version": 2, "services": [{"label": "a"}], "assets": [{"path": "{{HOME}}/../x", "mode": "0755", "sha256": "", "data": ""}]}`,
+			wantErr: "is not absolute and clean",
+		},
+		{
+			name:    "future version",
+			input:   `{"version": 3}`,
+			wantErr: "unsupported export bundle version 3",
+		},
 		{
 			name:    "invalid json",
 			input:   "not json at all",
@@ -154,3 +201,179 @@ func TestReadBundleFromFileNotFound(t *testing.T) {
 		t.Fatal("expected error for nonexistent file, got nil")
 	}
 }
+
+func TestReadBundleVersion1(t *testing.T) {
+	v1 := `{
+  "version": 1,
+  "exported_at": "2024-05-01T12:00:00Z",
+  "label": "com.test.v1",
+  "domain": "user",
+  "type": "agent",
+  "plist_path": "/Users/test/Library/LaunchAgents/com.test.v1.plist",
+  "plist": {"Label": "com.test.v1", "Program": "/usr/bin/true", "KeepAlive": null, "StartCalendarInterval": null, "Umask": null, "LimitLoadToSessionType": null, "AssociatedBundleIdentifiers": null}
+}`
+	bundle, err := ReadBundle(strings.NewReader(v1))
+	if err != nil {
+		t.Fatalf("ReadBundle() error = %v", err)
+	}
+	if len(bundle.Services) != 1 {
+		t.Fatalf("expected 1 service, got %d", len(bundle.Services))
+	}
+	svc := bundle.Services[0]
+	if svc.Label != "com.test.v1" || svc.Domain != "user" || svc.Plist.Program != "/usr/bin/true" {
+		t.Errorf("service = %+v", svc)
+	}
+}
+
+func TestExportBundleAssets(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "backup.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho backup\n"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := NewBundle()
+	bundle.AddService(&LaunchAgentPlist{Label: "com.test.a", ProgramArguments: []string{"/bin/sh", script}}, "", "user", "agent")
+	bundle.AddService(&LaunchAgentPlist{Label: "com.test.b", Program: script}, "", "user", "agent")
+	for _, svc := range bundle.Services {
+		for _, p := range AssetPaths(&svc.Plist) {
+			if err := bundle.AddAsset(p, p); err != nil {
+				t.Fatalf("AddAsset(%s) error = %v", p, err)
+			}
+		}
+	}
+	if len(bundle.Assets) != 1 {
+		t.Fatalf("expected the script to be embedded once, got %d assets", len(bundle.Assets))
+	}
+	asset := bundle.Assets[0]
+	if asset.Mode != "0750" || len(asset.SHA256) != 64 {
+		t.Errorf("asset = %+v", asset)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, bundle); err != nil {
+		t.Fatalf("WriteBundle() error = %v", err)
+	}
+	restored, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatalf("ReadBundle() error = %v", err)
+	}
+	if len(restored.Services) != 2 || len(restored.Assets) != 1 {
+		t.Fatalf("restored %d services and %d assets, want 2 and 1", len(restored.Services), len(restored.Assets))
+	}
+	if !restored.Assets[0].Matches(script) {
+		t.Error("restored asset does not match the script")
+	}
+	if mode, err := restored.Assets[0].FileMode(); err != nil || mode != 0750 {
+		t.Errorf("FileMode() = %v, %v", mode, err)
+	}
+}
+
+func TestAssetPaths(t *testing.T) {
+	pl := &LaunchAgentPlist{
+		Program:          "/opt/homebrew/bin/node",
+		ProgramArguments: []string{"node", "/Users/me/app/server.js", "--port", "80", "/opt/homebrew/bin/node", "/usr/bin/true"},
+	}
+	want := []string{"/opt/homebrew/bin/node", "/Users/me/app/server.js"}
+	if got := AssetPaths(pl); !reflect.DeepEqual(got, want) {
+		t.Errorf("AssetPaths() = %v, want %v", got, want)
+	}
+}
+
+func TestReplacePath(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"/Users/me", "{{HOME}}"},
+		{"/Users/me/bin/tool", "{{HOME}}/bin/tool"},
+		{"PATH=/Users/me/bin:/Users/me/.local/bin:/usr/bin", "PATH={{HOME}}/bin:{{HOME}}/.local/bin:/usr/bin"},
+		{"cd /Users/me && make", "cd {{HOME}} && make"},
+		{"/Users/mel/bin", "/Users/mel/bin"},
+		{"/Volumes/x/Users/me", "/Volumes/x/Users/me"},
+		{"/usr/bin/true", "/usr/bin/true"},
+	}
+	for _, tt := range tests {
+		if got := ReplacePath(tt.s, "/Users/me", HomePlaceholder); got != tt.want {
+			t.Errorf("ReplacePath(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+	if got := ReplacePath("{{HOME}}/bin", HomePlaceholder, "/Users/you"); got != "/Users/you/bin" {
+		t.Errorf("expanding the placeholder gave %q", got)
+	}
+}
+
+func TestBundleServiceSetDocument(t *testing.T) {
+	pl := &LaunchAgentPlist{
+		Label:            "com.example.agent",
+		ProgramArguments: []string{"/Users/me/bin/agent", "--config", "/Users/me/.agent.toml"},
+		WorkingDirectory: "/Users/me",
+	}
+	bundle := NewExportBundle(pl, "/Users/me/Library/LaunchAgents/com.example.agent.plist", "user", "agent")
+	svc := &bundle.Services[0]
+	svc.PlistData = encodeXML(DocumentFromPlist(pl).Root)
+
+	doc, err := svc.Document()
+	if err != nil {
+		t.Fatalf("Document() error = %v", err)
+	}
+	doc.MapStrings(func(s string) string { return ReplacePath(s, "/Users/me", HomePlaceholder) })
+	doc.Root.Set("Label", "com.me.agent")
+	if err := svc.SetDocument(doc); err != nil {
+		t.Fatalf("SetDocument() error = %v", err)
+	}
+
+	if svc.Label != "com.me.agent" || svc.Plist.Label != "com.me.agent" {
+		t.Errorf("labels = %q, %q, want com.me.agent", svc.Label, svc.Plist.Label)
+	}
+	wantArgs := []string{"{{HOME}}/bin/agent", "--config", "{{HOME}}/.agent.toml"}
+	if !reflect.DeepEqual(svc.Plist.ProgramArguments, wantArgs) || svc.Plist.WorkingDirectory != "{{HOME}}" {
+		t.Errorf("Plist = %+v", svc.Plist)
+	}
+	// The original file is replaced as well.
+	again, err := svc.Document()
+	if err != nil {
+		t.Fatalf("Document() error = %v", err)
+	}
+	if wd, _ := again.Root.Get("WorkingDirectory"); wd != "{{HOME}}" {
+		t.Errorf("PlistData WorkingDirectory = %v", wd)
+	}
+}
+
+func TestReadBundlePlistData(t *testing.T) {
+	pl := &LaunchAgentPlist{
+		Label:     "com.example.agent",
+		Program:   "/usr/local/bin/agent",
+		KeepAlive: map[string]interface{}{"SuccessfulExit": false},
+		Umask:     18,
+	}
+	data, err := DocumentFromPlist(pl).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(typed LaunchAgentPlist) error {
+		bundle := NewExportBundle(pl, "/Library/LaunchAgents/com.example.agent.plist", "global", "agent")
+		bundle.Services[0].Plist = typed
+		bundle.Services[0].PlistData = data
+		var buf bytes.Buffer
+		if err := WriteBundle(&buf, bundle); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ReadBundle(&buf)
+		return err
+	}
+
+	if err := read(*pl); err != nil {
+		t.Errorf("ReadBundle() with a matching plist error = %v", err)
+	}
+	// plist_data alone is enough.
+	if err := read(LaunchAgentPlist{}); err != nil {
+		t.Errorf("ReadBundle() without a plist error = %v", err)
+	}
+	// A plist that says something else than the file import would write is
+	// rejected.
+	other := *pl
+	other.Program = "/usr/local/bin/other"
+	if err := read(other); err == nil || !strings.Contains(err.Error(), "does not match its plist_data") {
+		t.Errorf("ReadBundle() with a different plist error = %v, want a mismatch", err)
+	}
+}
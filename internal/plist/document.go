@@ -0,0 +1,596 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	goplist "howett.net/plist"
+)
+
+// Format is the on-disk encoding of a plist document.
+type Format int
+
+const (
+	FormatXML      Format = goplist.XMLFormat
+	FormatBinary   Format = goplist.BinaryFormat
+	FormatOpenStep Format = goplist.OpenStepFormat
+	FormatGNUStep  Format = goplist.GNUStepFormat
+)
+
+// String returns the name plutil uses for the format.
+func (f Format) String() string {
+	switch f {
+	case FormatXML:
+		return "xml1"
+	case FormatBinary:
+		return "binary1"
+	case FormatOpenStep:
+		return "openstep"
+	case FormatGNUStep:
+		return "gnustep"
+	default:
+		return "unknown"
+	}
+}
+
+// UID is a binary plist UID, as used by NSKeyedArchiver.
+type UID uint64
+
+// Dict is an ordered plist dictionary. Values are one of string, int64,
+// uint64 (for integers above math.MaxInt64), float64, bool, time.Time,
+// []byte, UID, []interface{} or *Dict.
+type Dict struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// NewDict creates an empty dictionary.
+func NewDict() *Dict {
+	return &Dict{values: make(map[string]interface{})}
+}
+
+// Len returns the number of keys.
+func (d *Dict) Len() int {
+	return len(d.keys)
+}
+
+// Keys returns the keys in document order.
+func (d *Dict) Keys() []string {
+	return append([]string(nil), d.keys...)
+}
+
+// Get returns the value for key.
+func (d *Dict) Get(key string) (interface{}, bool) {
+	v, ok := d.values[key]
+	return v, ok
+}
+
+// Set stores value under key, keeping the key's position if it exists and
+// appending it otherwise.
+func (d *Dict) Set(key string, value interface{}) {
+	if _, ok := d.values[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.values[key] = value
+}
+
+// Delete removes key, reporting whether it was present.
+func (d *Dict) Delete(key string) bool {
+	if _, ok := d.values[key]; !ok {
+		return false
+	}
+	delete(d.values, key)
+	for i, k := range d.keys {
+		if k == key {
+			d.keys = append(d.keys[:i], d.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// MarshalJSON encodes the dictionary as a JSON object in key order.
+func (d *Dict) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range d.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(d.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Document is a plist file as written: every key in order, every value with
+// its original type, and the encoding it was read in. It sits under
+// LaunchAgentPlist so that rewriting a plist keeps what lanchr does not model.
+type Document struct {
+	Format Format
+	Root   *Dict
+}
+
+// NewDocument creates an empty XML document.
+func NewDocument() *Document {
+	return &Document{Format: FormatXML, Root: NewDict()}
+}
+
+// ParseDocument decodes plist data in any supported format. The root must
+// be a dictionary. OpenStep and GNUStep dictionaries have no reliable key
+// order, so their keys come back sorted.
+func ParseDocument(data []byte) (*Document, error) {
+	var (
+		root   interface{}
+		format Format
+		err    error
+	)
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(data, []byte(bplistMagic)):
+		format = FormatBinary
+		root, err = decodeBinary(data)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		format = FormatXML
+		root, err = decodeXML(trimmed)
+	default:
+		var v interface{}
+		var f int
+		f, err = goplist.Unmarshal(data, &v)
+		format = Format(f)
+		root = fromGeneric(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := root.(*Dict)
+	if !ok {
+		return nil, fmt.Errorf("plist root is %s, not a dictionary", typeName(root))
+	}
+	return &Document{Format: format, Root: dict}, nil
+}
+
+// ReadDocument reads and decodes a plist file.
+func ReadDocument(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plist %s: %w", path, err)
+	}
+	doc, err := ParseDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode plist %s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// Encode serializes the document in its format.
+func (d *Document) Encode() ([]byte, error) {
+	switch d.Format {
+	case FormatXML:
+		return encodeXML(d.Root), nil
+	case FormatBinary:
+		return encodeBinary(d.Root)
+	case FormatOpenStep, FormatGNUStep:
+		data, err := goplist.MarshalIndent(ToGeneric(d.Root), int(d.Format), "\t")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode plist: %w", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported plist format %d", d.Format)
+	}
+}
+
+// WriteFile encodes the document and atomically replaces path with it. An
+// existing file keeps its owner and mode and is kept as a timestamped
+// backup next to it; a new file gets mode 0644.
+func (d *Document) WriteFile(path string) error {
+	data, err := d.Encode()
+	if err != nil {
+		return err
+	}
+	if _, err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write plist file %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteFileWithBackup is WriteFile, also returning the path of the backup
+// of the file it replaced, or "" if there was none.
+func (d *Document) WriteFileWithBackup(path string) (string, error) {
+	data, err := d.Encode()
+	if err != nil {
+		return "", err
+	}
+	backup, err := writeFileAtomic(path, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to write plist file %s: %w", path, err)
+	}
+	return backup, nil
+}
+
+// MapStrings replaces every string value in the document, at any depth,
+// with f of it. Dictionary keys are left alone.
+func (d *Document) MapStrings(f func(string) string) {
+	mapStrings(d.Root, f)
+}
+
+func mapStrings(v interface{}, f func(string) string) interface{} {
+	switch x := v.(type) {
+	case string:
+		return f(x)
+	case *Dict:
+		for _, k := range x.keys {
+			x.values[k] = mapStrings(x.values[k], f)
+		}
+	case []interface{}:
+		for i, el := range x {
+			x[i] = mapStrings(el, f)
+		}
+	}
+	return v
+}
+
+// Plist decodes the document into the typed LaunchAgentPlist view.
+func (d *Document) Plist() (*LaunchAgentPlist, error) {
+	var pl LaunchAgentPlist
+	if _, err := goplist.Unmarshal(encodeXML(d.Root), &pl); err != nil {
+		return nil, fmt.Errorf("failed to decode plist: %w", err)
+	}
+	return &pl, nil
+}
+
+// Apply writes the fields of pl into the document. Keys LaunchAgentPlist
+// does not model are left alone, existing keys keep their position, and
+// values that did not change keep their original type and nested key order.
+// Explicit false, zero and empty values that pl omits are kept as well.
+func (d *Document) Apply(pl *LaunchAgentPlist) {
+	typed := structToDict(reflect.ValueOf(pl).Elem())
+	for _, key := range plistKeys() {
+		nv, ok := typed.Get(key)
+		ov, exists := d.Root.Get(key)
+		switch {
+		case ok && exists:
+			d.Root.Set(key, mergeValue(ov, nv))
+		case ok:
+			d.Root.Set(key, nv)
+		case exists && !isZeroValue(ov):
+			d.Root.Delete(key)
+		}
+	}
+}
+
+// Unmodeled returns the top-level keys of the document that
+// LaunchAgentPlist does not model, in document order.
+func (d *Document) Unmodeled() []string {
+	modeled := make(map[string]bool)
+	for _, key := range plistKeys() {
+		modeled[key] = true
+	}
+	var keys []string
+	for _, key := range d.Root.Keys() {
+		if !modeled[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// DocumentFromPlist builds a new XML document from pl, with keys in
+// LaunchAgentPlist field order.
+func DocumentFromPlist(pl *LaunchAgentPlist) *Document {
+	return &Document{Format: FormatXML, Root: structToDict(reflect.ValueOf(pl).Elem())}
+}
+
+// mergeValue returns nv, reusing ov (or parts of it) where they are equal so
+// that number types and nested key order survive a rewrite.
+func mergeValue(ov, nv interface{}) interface{} {
+	if ValuesEqual(ov, nv) {
+		return ov
+	}
+	od, ok1 := ov.(*Dict)
+	nd, ok2 := nv.(*Dict)
+	if !ok1 || !ok2 {
+		return nv
+	}
+	merged := NewDict()
+	for _, k := range od.keys {
+		if v, ok := nd.values[k]; ok {
+			merged.Set(k, mergeValue(od.values[k], v))
+		}
+	}
+	for _, k := range nd.keys {
+		if _, ok := merged.values[k]; !ok {
+			merged.Set(k, nd.values[k])
+		}
+	}
+	return merged
+}
+
+// ValuesEqual compares two plist values, treating integers of different Go
+// types as equal when their values are.
+func ValuesEqual(a, b interface{}) bool {
+	if aNeg, aMag, ok := intValue(a); ok {
+		bNeg, bMag, ok := intValue(b)
+		return ok && aNeg == bNeg && aMag == bMag
+	}
+	switch av := a.(type) {
+	case *Dict:
+		bv, ok := b.(*Dict)
+		if !ok || av.Len() != bv.Len() {
+			return false
+		}
+		for _, k := range av.keys {
+			if v, ok := bv.values[k]; !ok || !ValuesEqual(av.values[k], v) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !ValuesEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case []byte:
+		bv, ok := b.([]byte)
+		return ok && bytes.Equal(av, bv)
+	case time.Time:
+		bv, ok := b.(time.Time)
+		return ok && av.Equal(bv)
+	default:
+		return a == b
+	}
+}
+
+// intValue splits an integer value into sign and magnitude so that int64
+// and uint64 values can be compared.
+func intValue(v interface{}) (neg bool, mag uint64, ok bool) {
+	switch n := v.(type) {
+	case int64:
+		if n < 0 {
+			return true, uint64(-n), true
+		}
+		return false, uint64(n), true
+	case uint64:
+		return false, n, true
+	}
+	return false, 0, false
+}
+
+// isZeroValue reports whether v is false, zero, or empty.
+func isZeroValue(v interface{}) bool {
+	switch x := v.(type) {
+	case bool:
+		return !x
+	case int64:
+		return x == 0
+	case uint64:
+		return x == 0
+	case float64:
+		return x == 0
+	case string:
+		return x == ""
+	case []byte:
+		return len(x) == 0
+	case []interface{}:
+		return len(x) == 0
+	case *Dict:
+		return x.Len() == 0
+	}
+	return false
+}
+
+// plistKeys returns the plist keys LaunchAgentPlist models.
+func plistKeys() []string {
+	t := reflect.TypeOf(LaunchAgentPlist{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name, _ := plistTag(t.Field(i)); name != "" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// plistTag parses a `plist:"Name,omitempty"` struct tag.
+func plistTag(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("plist")
+	if tag == "-" {
+		return "", false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, opts == "omitempty"
+}
+
+// structToDict converts a tagged struct into a Dict in field order,
+// following the omitempty rules of the plist encoder.
+func structToDict(v reflect.Value) *Dict {
+	d := NewDict()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, omitEmpty := plistTag(t.Field(i))
+		if name == "" {
+			continue
+		}
+		fv := v.Field(i)
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+		if val, ok := toValue(fv); ok {
+			d.Set(name, val)
+		}
+	}
+	return d
+}
+
+// toValue converts a Go value into a Document value. It returns false for
+// nil pointers, interfaces and maps, which have no plist representation.
+func toValue(v reflect.Value) (interface{}, bool) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil, false
+		}
+		if d, ok := v.Interface().(*Dict); ok {
+			return d, true
+		}
+		return toValue(v.Elem())
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Type() == reflect.TypeOf(UID(0)) {
+			return UID(v.Uint()), true
+		}
+		if u := v.Uint(); u <= 1<<63-1 {
+			return int64(u), true
+		}
+		return v.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return append([]byte(nil), v.Bytes()...), true
+		}
+		arr := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if el, ok := toValue(v.Index(i)); ok {
+				arr = append(arr, el)
+			}
+		}
+		return arr, true
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, false
+		}
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		d := NewDict()
+		for _, k := range keys {
+			if el, ok := toValue(v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))); ok {
+				d.Set(k, el)
+			}
+		}
+		return d, true
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			return t, true
+		}
+		return structToDict(v), true
+	}
+	return nil, false
+}
+
+// fromGeneric converts values decoded by howett.net/plist into Document
+// values. Map keys are sorted since their order was not preserved.
+func fromGeneric(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		d := NewDict()
+		for _, k := range keys {
+			d.Set(k, fromGeneric(x[k]))
+		}
+		return d
+	case []interface{}:
+		arr := make([]interface{}, len(x))
+		for i, el := range x {
+			arr[i] = fromGeneric(el)
+		}
+		return arr
+	case goplist.UID:
+		return UID(x)
+	case uint64:
+		if x <= 1<<63-1 {
+			return int64(x)
+		}
+		return x
+	case float32:
+		return float64(x)
+	}
+	return v
+}
+
+// ToGeneric converts Document values into the maps and slices
+// howett.net/plist encodes and decodes, as found in LaunchAgentPlist
+// interface{} fields.
+func ToGeneric(v interface{}) interface{} {
+	switch x := v.(type) {
+	case *Dict:
+		m := make(map[string]interface{}, x.Len())
+		for _, k := range x.keys {
+			m[k] = ToGeneric(x.values[k])
+		}
+		return m
+	case []interface{}:
+		arr := make([]interface{}, len(x))
+		for i, el := range x {
+			arr[i] = ToGeneric(el)
+		}
+		return arr
+	case UID:
+		return goplist.UID(x)
+	}
+	return v
+}
+
+// typeName returns the plist type name of a Document value.
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case *Dict:
+		return "dictionary"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case int64, uint64:
+		return "integer"
+	case float64:
+		return "real"
+	case bool:
+		return "boolean"
+	case time.Time:
+		return "date"
+	case []byte:
+		return "data"
+	case UID:
+		return "uid"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
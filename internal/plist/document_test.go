@@ -0,0 +1,226 @@
+package plist
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	goplist "howett.net/plist"
+)
+
+const documentXML = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>com.test.document</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>/usr/bin/true</string>
+		<string>--flag &amp; value</string>
+	</array>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+		<key>FutureCondition</key>
+		<integer>3</integer>
+	</dict>
+	<key>SessionCreate</key>
+	<true/>
+	<key>ProcessType</key>
+	<string>Interactive</string>
+	<key>RunAtLoad</key>
+	<false/>
+	<key>com.vendor.Extra</key>
+	<dict>
+		<key>Ratio</key>
+		<real>1.5</real>
+		<key>Blob</key>
+		<data>
+		AAEC
+		</data>
+		<key>Since</key>
+		<date>2024-05-01T12:00:00Z</date>
+		<key>Big</key>
+		<integer>18446744073709551615</integer>
+		<key>Negative</key>
+		<integer>-7</integer>
+	</dict>
+</dict>
+</plist>
+`
+
+func TestParseDocument_XMLRoundTrip(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentXML))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	if doc.Format != FormatXML {
+		t.Errorf("Format = %v, want xml1", doc.Format)
+	}
+
+	wantKeys := []string{"Label", "ProgramArguments", "KeepAlive", "SessionCreate", "ProcessType", "RunAtLoad", "com.vendor.Extra"}
+	if got := doc.Root.Keys(); !reflect.DeepEqual(got, wantKeys) {
+		t.Errorf("Keys() = %v, want %v", got, wantKeys)
+	}
+
+	out, err := doc.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if string(out) != documentXML {
+		t.Errorf("XML round trip changed the document:\n%s", out)
+	}
+}
+
+func TestParseDocument_BinaryRoundTrip(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentXML))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	doc.Root.Set("Unicode", "café ☕")
+	doc.Format = FormatBinary
+
+	data, err := doc.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("bplist00")) {
+		t.Fatalf("encoded data is not a binary plist: %q", data[:8])
+	}
+
+	// The encoding must be readable by an independent decoder.
+	var generic map[string]interface{}
+	if _, err := goplist.Unmarshal(data, &generic); err != nil {
+		t.Fatalf("howett.net/plist failed to decode output: %v", err)
+	}
+	if generic["Unicode"] != "café ☕" {
+		t.Errorf("Unicode = %v, want %q", generic["Unicode"], "café ☕")
+	}
+	extra := generic["com.vendor.Extra"].(map[string]interface{})
+	if extra["Big"] != uint64(18446744073709551615) {
+		t.Errorf("Big = %v (%T), want max uint64", extra["Big"], extra["Big"])
+	}
+
+	back, err := ParseDocument(data)
+	if err != nil {
+		t.Fatalf("ParseDocument(binary) error = %v", err)
+	}
+	if back.Format != FormatBinary {
+		t.Errorf("Format = %v, want binary1", back.Format)
+	}
+	if !reflect.DeepEqual(back.Root.Keys(), doc.Root.Keys()) {
+		t.Errorf("Keys() = %v, want %v", back.Root.Keys(), doc.Root.Keys())
+	}
+	if !ValuesEqual(back.Root, doc.Root) {
+		t.Error("binary round trip changed values")
+	}
+	v, _ := back.Root.Get("com.vendor.Extra")
+	since, _ := v.(*Dict).Get("Since")
+	if want := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC); !since.(time.Time).Equal(want) {
+		t.Errorf("Since = %v, want %v", since, want)
+	}
+}
+
+func TestParseDocument_RejectsNonDictionary(t *testing.T) {
+	if _, err := ParseDocument([]byte(`<plist version="1.0"><array/></plist>`)); err == nil {
+		t.Error("expected error for array root, got nil")
+	}
+	if _, err := ParseDocument([]byte("bplist00garbage")); err == nil {
+		t.Error("expected error for truncated binary plist, got nil")
+	}
+}
+
+func TestDocumentApply(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentXML))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	pl, err := doc.Plist()
+	if err != nil {
+		t.Fatalf("Plist() error = %v", err)
+	}
+
+	pl.ProgramArguments = []string{"/usr/bin/false"}
+	pl.StartInterval = 60
+	doc.Apply(pl)
+
+	wantKeys := []string{"Label", "ProgramArguments", "KeepAlive", "SessionCreate", "ProcessType", "RunAtLoad", "com.vendor.Extra", "StartInterval"}
+	if got := doc.Root.Keys(); !reflect.DeepEqual(got, wantKeys) {
+		t.Errorf("Keys() = %v, want %v", got, wantKeys)
+	}
+
+	// RunAtLoad is omitted by the typed struct but was explicitly false.
+	if v, ok := doc.Root.Get("RunAtLoad"); !ok || v != false {
+		t.Errorf("RunAtLoad = %v, %v; want explicit false", v, ok)
+	}
+	// Unknown nested KeepAlive keys survive.
+	ka, _ := doc.Root.Get("KeepAlive")
+	if v, ok := ka.(*Dict).Get("FutureCondition"); !ok || v != int64(3) {
+		t.Errorf("KeepAlive.FutureCondition = %v, %v; want 3", v, ok)
+	}
+	if v, _ := doc.Root.Get("ProgramArguments"); !ValuesEqual(v, []interface{}{"/usr/bin/false"}) {
+		t.Errorf("ProgramArguments = %v", v)
+	}
+
+	// Clearing a typed field removes its key.
+	pl.StartInterval = 0
+	doc.Apply(pl)
+	if _, ok := doc.Root.Get("StartInterval"); ok {
+		t.Error("StartInterval should have been removed")
+	}
+}
+
+func TestDocumentUnmodeled(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentXML))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	want := []string{"SessionCreate", "com.vendor.Extra"}
+	if got := doc.Unmodeled(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmodeled() = %v, want %v", got, want)
+	}
+}
+
+func TestWriterUpdate_PreservesExistingDocument(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "com.test.document.plist")
+
+	doc, err := ParseDocument([]byte(documentXML))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	doc.Format = FormatBinary
+	if err := doc.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	pl, err := NewParser().Parse(path)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	pl.ProgramArguments = []string{"/usr/bin/false"}
+	if err := NewWriter().Update(pl, path); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("bplist00")) {
+		t.Error("rewrite converted a binary plist to another format")
+	}
+	back, err := ParseDocument(data)
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	for _, key := range []string{"SessionCreate", "ProcessType", "com.vendor.Extra"} {
+		if _, ok := back.Root.Get(key); !ok {
+			t.Errorf("rewrite dropped %s", key)
+		}
+	}
+}
@@ -0,0 +1,485 @@
+package plist
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyPath addresses a value inside a plist document, such as
+// "EnvironmentVariables.PATH" or "ProgramArguments.1". Array elements are
+// addressed by index, and a literal dot in a key is written as "\.".
+type KeyPath []string
+
+// ParseKeyPath splits a dotted key path into its segments.
+func ParseKeyPath(s string) (KeyPath, error) {
+	var (
+		path KeyPath
+		seg  strings.Builder
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '.' || s[i+1] == '\\'):
+			seg.WriteByte(s[i+1])
+			i++
+		case c == '.':
+			path = append(path, seg.String())
+			seg.Reset()
+		default:
+			seg.WriteByte(c)
+		}
+	}
+	path = append(path, seg.String())
+	for _, p := range path {
+		if p == "" {
+			return nil, fmt.Errorf("invalid key path %q: empty key", s)
+		}
+	}
+	return path, nil
+}
+
+// String returns the dotted form of the path.
+func (p KeyPath) String() string {
+	segs := make([]string, len(p))
+	for i, seg := range p {
+		seg = strings.ReplaceAll(seg, `\`, `\\`)
+		segs[i] = strings.ReplaceAll(seg, ".", `\.`)
+	}
+	return strings.Join(segs, ".")
+}
+
+// child returns p extended by seg without aliasing p's backing array.
+func (p KeyPath) child(seg string) KeyPath {
+	return append(p[:len(p):len(p)], seg)
+}
+
+// dynamicKeyTypes lists the types launchd accepts for the keys that
+// LaunchAgentPlist leaves as interface{}.
+var dynamicKeyTypes = map[string][]string{
+	"KeepAlive":                   {"boolean", "dictionary"},
+	"StartCalendarInterval":       {"dictionary", "array"},
+	"Umask":                       {"integer", "string"},
+	"LimitLoadToSessionType":      {"string", "array"},
+	"AssociatedBundleIdentifiers": {"string", "array"},
+}
+
+// schemaType returns the Go type LaunchAgentPlist declares for the value at
+// path, or nil when the path is not modeled or may hold any type.
+func schemaType(path KeyPath) reflect.Type {
+	t := reflect.TypeOf(LaunchAgentPlist{})
+	for _, seg := range path {
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := fieldByPlistName(t, seg)
+			if !ok {
+				return nil
+			}
+			t = f.Type
+		case reflect.Map:
+			t = t.Elem()
+		case reflect.Slice:
+			if _, err := strconv.Atoi(seg); err != nil {
+				return nil
+			}
+			t = t.Elem()
+		default:
+			return nil
+		}
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Interface {
+			return nil
+		}
+	}
+	return t
+}
+
+// fieldByPlistName finds the struct field tagged with the plist key name.
+func fieldByPlistName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if n, _ := plistTag(t.Field(i)); n == name {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// expectedTypes returns the plist type names allowed at path, or nil if any
+// type is allowed.
+func expectedTypes(path KeyPath) []string {
+	if len(path) == 1 {
+		if types, ok := dynamicKeyTypes[path[0]]; ok {
+			return types
+		}
+	}
+	t := schemaType(path)
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return []string{"string"}
+	case reflect.Bool:
+		return []string{"boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return []string{"integer"}
+	case reflect.Float32, reflect.Float64:
+		return []string{"real"}
+	case reflect.Slice, reflect.Array:
+		return []string{"array"}
+	case reflect.Map, reflect.Struct:
+		return []string{"dictionary"}
+	}
+	return nil
+}
+
+// CheckValue reports whether v, and everything nested in it, has the types
+// LaunchAgentPlist expects at path. Keys the schema does not model accept
+// any type.
+func CheckValue(path KeyPath, v interface{}) error {
+	if allowed := expectedTypes(path); allowed != nil && !containsString(allowed, typeName(v)) {
+		return fmt.Errorf("%s must be %s, not %s", path, strings.Join(allowed, " or "), typeName(v))
+	}
+	switch x := v.(type) {
+	case *Dict:
+		for _, k := range x.keys {
+			if err := CheckValue(path.child(k), x.values[k]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, el := range x {
+			if err := CheckValue(path.child(strconv.Itoa(i)), el); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ParseValue converts command-line text into a document value for path and
+// checks it against the schema. typ forces a plist type ("string",
+// "integer", "real", "boolean", "date", "data", "array" or "dictionary");
+// when empty, the schema decides, and for keys it leaves open the type is
+// inferred from the text. Arrays and dictionaries are written as JSON.
+func ParseValue(path KeyPath, raw, typ string) (interface{}, error) {
+	typ = normalizeTypeName(typ)
+	if typ == "" {
+		if allowed := expectedTypes(path); len(allowed) == 1 {
+			typ = allowed[0]
+		} else {
+			typ = inferTypeName(raw)
+		}
+	}
+
+	var (
+		v   interface{}
+		err error
+	)
+	switch typ {
+	case "string":
+		v = raw
+	case "integer":
+		v, err = strconv.ParseInt(raw, 10, 64)
+	case "real":
+		v, err = strconv.ParseFloat(raw, 64)
+	case "boolean":
+		v, err = parseBool(raw)
+	case "date":
+		v, err = time.Parse(time.RFC3339, raw)
+	case "data":
+		v, err = base64.StdEncoding.DecodeString(raw)
+	case "array", "dictionary":
+		v, err = parseJSONValue(raw)
+		if err == nil && typeName(v) != typ {
+			err = fmt.Errorf("JSON value is not %s", map[string]string{"array": "an array", "dictionary": "an object"}[typ])
+		}
+	default:
+		return nil, fmt.Errorf("unknown plist type %q (use string, integer, real, boolean, date, data, array or dictionary)", typ)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value %q for %s: %w", typ, raw, path, err)
+	}
+	if err := CheckValue(path, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// normalizeTypeName maps short type names to their plist names.
+func normalizeTypeName(typ string) string {
+	switch strings.ToLower(typ) {
+	case "str":
+		return "string"
+	case "int":
+		return "integer"
+	case "float":
+		return "real"
+	case "bool":
+		return "boolean"
+	case "dict":
+		return "dictionary"
+	}
+	return strings.ToLower(typ)
+}
+
+// inferTypeName guesses the plist type of untyped command-line text.
+func inferTypeName(raw string) string {
+	if _, err := parseBool(raw); err == nil && !isDigits(raw) {
+		return "boolean"
+	}
+	if _, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return "integer"
+	}
+	if _, err := strconv.ParseFloat(raw, 64); err == nil && strings.ContainsAny(raw, ".eE") {
+		return "real"
+	}
+	switch trimmed := strings.TrimSpace(raw); {
+	case strings.HasPrefix(trimmed, "{"):
+		return "dictionary"
+	case strings.HasPrefix(trimmed, "["):
+		return "array"
+	}
+	return "string"
+}
+
+// parseBool accepts the spellings plutil and PlistBuddy accept.
+func parseBool(raw string) (bool, error) {
+	switch strings.ToLower(raw) {
+	case "true", "yes", "1":
+		return true, nil
+	case "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("not a boolean (use true or false)")
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// parseJSONValue decodes JSON into document values, keeping object key
+// order.
+func parseJSONValue(raw string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			arr := []interface{}{}
+			for dec.More() {
+				el, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, el)
+			}
+			_, err := dec.Token()
+			return arr, err
+		}
+		d := NewDict()
+		for dec.More() {
+			kt, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			el, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			d.Set(kt.(string), el)
+		}
+		_, err := dec.Token()
+		return d, err
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n, nil
+		}
+		return t.Float64()
+	case string, bool:
+		return t, nil
+	}
+	return nil, fmt.Errorf("null has no plist representation")
+}
+
+// Lookup returns the value at path.
+func (d *Document) Lookup(path KeyPath) (interface{}, bool) {
+	var cur interface{} = d.Root
+	for _, seg := range path {
+		switch c := cur.(type) {
+		case *Dict:
+			v, ok := c.Get(seg)
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			cur = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// SetPath stores v at path, creating missing dictionaries and arrays along
+// the way. An array index may name an existing element or the position just
+// past the end, which appends.
+func (d *Document) SetPath(path KeyPath, v interface{}) error {
+	if len(path) == 0 {
+		return fmt.Errorf("empty key path")
+	}
+	_, err := setIn(d.Root, path, 0, v)
+	return err
+}
+
+// setIn stores v at path[depth:] inside parent and returns the updated
+// parent, which differs from parent when an array grew.
+func setIn(parent interface{}, path KeyPath, depth int, v interface{}) (interface{}, error) {
+	seg := path[depth]
+	last := depth == len(path)-1
+	switch c := parent.(type) {
+	case *Dict:
+		if last {
+			c.Set(seg, v)
+			return c, nil
+		}
+		child, ok := c.Get(seg)
+		if !ok {
+			child = newContainer(path, depth+1)
+		}
+		nc, err := setIn(child, path, depth+1, v)
+		if err != nil {
+			return nil, err
+		}
+		c.Set(seg, nc)
+		return c, nil
+	case []interface{}:
+		i, err := strconv.Atoi(seg)
+		if err != nil || i < 0 || i > len(c) {
+			return nil, fmt.Errorf("%s: no element %s in an array of %d", path[:depth], seg, len(c))
+		}
+		if i == len(c) {
+			c = append(c, newContainer(path, depth+1))
+		}
+		if last {
+			c[i] = v
+			return c, nil
+		}
+		nc, err := setIn(c[i], path, depth+1, v)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = nc
+		return c, nil
+	default:
+		return nil, fmt.Errorf("%s is a %s, not a dictionary or array", path[:depth], typeName(parent))
+	}
+}
+
+// newContainer returns an empty value to hold path[depth:]: an array when
+// the schema says so or the next segment is an index, a dictionary
+// otherwise.
+func newContainer(path KeyPath, depth int) interface{} {
+	if depth >= len(path) {
+		return nil
+	}
+	if t := schemaType(path[:depth]); t != nil {
+		if t.Kind() == reflect.Slice {
+			return []interface{}{}
+		}
+		return NewDict()
+	}
+	if _, err := strconv.Atoi(path[depth]); err == nil {
+		return []interface{}{}
+	}
+	return NewDict()
+}
+
+// UnsetPath removes the value at path, reporting whether it was present.
+func (d *Document) UnsetPath(path KeyPath) (bool, error) {
+	if len(path) == 0 {
+		return false, fmt.Errorf("empty key path")
+	}
+	_, removed, err := unsetIn(d.Root, path, 0)
+	return removed, err
+}
+
+// unsetIn removes path[depth:] from parent and returns the updated parent,
+// which differs from parent when an array shrank.
+func unsetIn(parent interface{}, path KeyPath, depth int) (interface{}, bool, error) {
+	seg := path[depth]
+	last := depth == len(path)-1
+	switch c := parent.(type) {
+	case *Dict:
+		if last {
+			return c, c.Delete(seg), nil
+		}
+		child, ok := c.Get(seg)
+		if !ok {
+			return c, false, nil
+		}
+		nc, removed, err := unsetIn(child, path, depth+1)
+		if err != nil || !removed {
+			return c, removed, err
+		}
+		c.Set(seg, nc)
+		return c, true, nil
+	case []interface{}:
+		i, err := strconv.Atoi(seg)
+		if err != nil || i < 0 || i >= len(c) {
+			return c, false, nil
+		}
+		if last {
+			return append(c[:i], c[i+1:]...), true, nil
+		}
+		nc, removed, err := unsetIn(c[i], path, depth+1)
+		if err != nil || !removed {
+			return c, removed, err
+		}
+		c[i] = nc
+		return c, true, nil
+	default:
+		return nil, false, fmt.Errorf("%s is a %s, not a dictionary or array", path[:depth], typeName(parent))
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package plist
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseKeyPath(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    KeyPath
+		wantErr bool
+	}{
+		{in: "StartInterval", want: KeyPath{"StartInterval"}},
+		{in: "EnvironmentVariables.PATH", want: KeyPath{"EnvironmentVariables", "PATH"}},
+		{in: `MachServices.com\.example\.xpc`, want: KeyPath{"MachServices", "com.example.xpc"}},
+		{in: "ProgramArguments.1", want: KeyPath{"ProgramArguments", "1"}},
+		{in: "KeepAlive.", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseKeyPath(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseKeyPath(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseKeyPath(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+		if !tt.wantErr && got.String() != tt.in {
+			t.Errorf("ParseKeyPath(%q).String() = %q", tt.in, got.String())
+		}
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		path    string
+		raw     string
+		typ     string
+		want    interface{}
+		wantErr string
+	}{
+		{path: "StartInterval", raw: "600", want: int64(600)},
+		{path: "StartInterval", raw: "ten", wantErr: "invalid integer"},
+		{path: "RunAtLoad", raw: "yes", want: true},
+		{path: "Label", raw: "123", want: "123"},
+		{path: "EnvironmentVariables.PATH", raw: "/opt/bin:/usr/bin", want: "/opt/bin:/usr/bin"},
+		{path: "KeepAlive", raw: "true", want: true},
+		{path: "KeepAlive", raw: "3", wantErr: "KeepAlive must be boolean or dictionary, not integer"},
+		{path: "ProgramArguments", raw: `["/bin/sh", 1]`, wantErr: "ProgramArguments.1 must be string"},
+		{path: "EnvironmentVariables", raw: `[]`, wantErr: "JSON value is not an object"},
+		{path: "HardResourceLimits.NumberOfFiles", raw: "1024", want: int64(1024)},
+		{path: "StartInterval", raw: "600", typ: "string", wantErr: "StartInterval must be integer, not string"},
+		{path: "com.vendor.Ratio", raw: "1.5", want: 1.5},
+		{path: "com.vendor.Name", raw: "42", typ: "string", want: "42"},
+		{path: "com.vendor.Name", raw: "x", typ: "uuid", wantErr: "unknown plist type"},
+	}
+	for _, tt := range tests {
+		path, err := ParseKeyPath(tt.path)
+		if err != nil {
+			t.Fatalf("ParseKeyPath(%q) error = %v", tt.path, err)
+		}
+		got, err := ParseValue(path, tt.raw, tt.typ)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseValue(%s, %q) error = %v, want %q", tt.path, tt.raw, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseValue(%s, %q) error = %v", tt.path, tt.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseValue(%s, %q) = %#v, want %#v", tt.path, tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestParseValue_JSONKeepsKeyOrder(t *testing.T) {
+	v, err := ParseValue(KeyPath{"KeepAlive"}, `{"SuccessfulExit": false, "Crashed": true}`, "")
+	if err != nil {
+		t.Fatalf("ParseValue() error = %v", err)
+	}
+	d, ok := v.(*Dict)
+	if !ok {
+		t.Fatalf("ParseValue() = %T, want *Dict", v)
+	}
+	if got, want := d.Keys(), []string{"SuccessfulExit", "Crashed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+	out, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	if string(out) != `{"SuccessfulExit":false,"Crashed":true}` {
+		t.Errorf("MarshalJSON() = %s", out)
+	}
+}
+
+func TestDocumentSetAndUnsetPath(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentXML))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+
+	if err := doc.SetPath(KeyPath{"EnvironmentVariables", "PATH"}, "/opt/bin"); err != nil {
+		t.Fatalf("SetPath(EnvironmentVariables.PATH) error = %v", err)
+	}
+	if v, _ := doc.Lookup(KeyPath{"EnvironmentVariables", "PATH"}); v != "/opt/bin" {
+		t.Errorf("EnvironmentVariables.PATH = %v, want /opt/bin", v)
+	}
+
+	if err := doc.SetPath(KeyPath{"ProgramArguments", "2"}, "--verbose"); err != nil {
+		t.Fatalf("SetPath(ProgramArguments.2) error = %v", err)
+	}
+	if err := doc.SetPath(KeyPath{"ProgramArguments", "5"}, "x"); err == nil {
+		t.Error("SetPath(ProgramArguments.5) succeeded past the end of the array")
+	}
+	if err := doc.SetPath(KeyPath{"WatchPaths", "0"}, "/tmp/in"); err != nil {
+		t.Fatalf("SetPath(WatchPaths.0) error = %v", err)
+	}
+	if v, _ := doc.Lookup(KeyPath{"WatchPaths"}); !reflect.DeepEqual(v, []interface{}{"/tmp/in"}) {
+		t.Errorf("WatchPaths = %#v, want a one-element array", v)
+	}
+	if err := doc.SetPath(KeyPath{"ProcessType", "Nested"}, "x"); err == nil {
+		t.Error("SetPath(ProcessType.Nested) succeeded through a string")
+	}
+
+	removed, err := doc.UnsetPath(KeyPath{"KeepAlive", "FutureCondition"})
+	if err != nil || !removed {
+		t.Errorf("UnsetPath(KeepAlive.FutureCondition) = %v, %v; want true, nil", removed, err)
+	}
+	removed, err = doc.UnsetPath(KeyPath{"ProgramArguments", "1"})
+	if err != nil || !removed {
+		t.Errorf("UnsetPath(ProgramArguments.1) = %v, %v; want true, nil", removed, err)
+	}
+	if v, _ := doc.Lookup(KeyPath{"ProgramArguments"}); !reflect.DeepEqual(v, []interface{}{"/usr/bin/true", "--verbose"}) {
+		t.Errorf("ProgramArguments = %#v", v)
+	}
+	removed, err = doc.UnsetPath(KeyPath{"StartInterval"})
+	if err != nil || removed {
+		t.Errorf("UnsetPath(StartInterval) = %v, %v; want false, nil", removed, err)
+	}
+
+	pl, err := doc.Plist()
+	if err != nil {
+		t.Fatalf("Plist() error = %v", err)
+	}
+	if pl.EnvironmentVariables["PATH"] != "/opt/bin" {
+		t.Errorf("typed EnvironmentVariables = %v", pl.EnvironmentVariables)
+	}
+	wantKeys := []string{"Label", "ProgramArguments", "KeepAlive", "SessionCreate", "ProcessType", "RunAtLoad", "com.vendor.Extra", "EnvironmentVariables", "WatchPaths"}
+	if got := doc.Root.Keys(); !reflect.DeepEqual(got, wantKeys) {
+		t.Errorf("Keys() = %v, want %v", got, wantKeys)
+	}
+}
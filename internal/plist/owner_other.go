@@ -0,0 +1,11 @@
+//go:build !unix
+
+package plist
+
+import "os"
+
+// fileOwner reports no owner on platforms without Unix ownership, so that
+// replaced files are not chowned.
+func fileOwner(os.FileInfo) (int, int) {
+	return -1, -1
+}
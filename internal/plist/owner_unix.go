@@ -0,0 +1,16 @@
+//go:build unix
+
+package plist
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the uid and gid that own the file described by info.
+func fileOwner(info os.FileInfo) (int, int) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(st.Uid), int(st.Gid)
+	}
+	return -1, -1
+}
@@ -1,9 +1,14 @@
 package plist
 
 import (
+	"bytes"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	goplist "howett.net/plist"
@@ -29,12 +34,77 @@ func (p *Parser) Parse(path string) (*LaunchAgentPlist, error) {
 	var result LaunchAgentPlist
 	decoder := goplist.NewDecoder(f)
 	if err := decoder.Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode plist %s: %w", path, err)
+		perr := &ParseError{Path: path, Err: err}
+		if data, rerr := os.ReadFile(path); rerr == nil {
+			perr.Line, perr.Column = errorPosition(data, err)
+		}
+		return nil, perr
 	}
 
 	return &result, nil
 }
 
+// ParseError reports a plist file that could not be decoded, with the
+// position of the problem where the decoder gives one.
+type ParseError struct {
+	Path string
+	// Line and Column are 1-based, or 0 when unknown.
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to decode plist %s: %v", e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Position returns "line 5, column 12", "line 5", or "" when the position
+// is unknown.
+func (e *ParseError) Position() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("line %d, column %d", e.Line, e.Column)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d", e.Line)
+	}
+	return ""
+}
+
+// textErrorRe matches the position in OpenStep and GNUstep decoding
+// errors, whose line and character are 0-based.
+var textErrorRe = regexp.MustCompile(`at line (\d+) character (\d+)$`)
+
+// errorPosition locates the error decoding data. howett.net/plist reports
+// only the line of XML syntax errors, so the XML is tokenized again to
+// find the column. Errors in the structure of a well-formed file, such as
+// a value of the wrong type, have no position.
+func errorPosition(data []byte, err error) (line, column int) {
+	if m := textErrorRe.FindStringSubmatch(err.Error()); m != nil {
+		line, _ = strconv.Atoi(m[1])
+		column, _ = strconv.Atoi(m[2])
+		return line + 1, column + 1
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n"), []byte("<")) {
+		return 0, 0
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	// Plists declare their DTD and encoding; only the syntax matters here.
+	dec.Strict = true
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	for {
+		if _, err := dec.Token(); err != nil {
+			if err == io.EOF {
+				return 0, 0
+			}
+			return dec.InputPos()
+		}
+	}
+}
+
 // ParseAll reads all *.plist files from a directory.
 // Errors for individual files are collected rather than failing on the first error.
 func (p *Parser) ParseAll(dir string) ([]*LaunchAgentPlist, []error) {
@@ -0,0 +1,37 @@
+package plist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseErrorPosition(t *testing.T) {
+	tests := []struct {
+		name, content, position string
+	}{
+		{"xml", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<plist version=\"1.0\">\n<dict>\n  <key>Label</key>\n  <string>x</strin>\n</dict>\n</plist>\n", "line 5, column 20"},
+		{"openstep", "{\n  Label = \"x\";\n  Program = \"/bin/true\"\n  RunAtLoad = 1;\n}\n", "line 4, column 4"},
+		{"type", "<?xml version=\"1.0\"?>\n<plist version=\"1.0\"><dict><key>Label</key><integer>5</integer></dict></plist>\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "com.example.bad.plist")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := NewParser().Parse(path)
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("Parse() error = %v, want a *ParseError", err)
+			}
+			if perr.Path != path {
+				t.Errorf("Path = %q, want %q", perr.Path, path)
+			}
+			if got := perr.Position(); got != tt.position {
+				t.Errorf("Position() = %q, want %q", got, tt.position)
+			}
+		})
+	}
+}
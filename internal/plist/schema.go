@@ -0,0 +1,108 @@
+package plist
+
+import (
+	"reflect"
+
+	"github.com/lu-zhengda/lanchr/internal/jsonschema"
+)
+
+// SchemaBaseURL is where the published schemas live. Each schema's $id is
+// this URL followed by "<name>.schema.json".
+const SchemaBaseURL = "https://raw.githubusercontent.com/lu-zhengda/lanchr/main/schema/"
+
+// calendarEntrySchema describes one StartCalendarInterval dictionary.
+func calendarEntrySchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: jsonschema.Types{"object"},
+		Properties: jsonschema.Properties{
+			{Name: "Minute", Schema: jsonschema.Range(0, 59)},
+			{Name: "Hour", Schema: jsonschema.Range(0, 23)},
+			{Name: "Day", Schema: jsonschema.Range(1, 31)},
+			{Name: "Weekday", Schema: jsonschema.Range(0, 7)},
+			{Name: "Month", Schema: jsonschema.Range(1, 12)},
+		},
+		AdditionalProperties: false,
+	}
+}
+
+// keepAliveSchema describes the boolean or conditions dictionary form of
+// KeepAlive.
+func keepAliveSchema() *jsonschema.Schema {
+	flags := &jsonschema.Schema{Type: jsonschema.Types{"object"}, AdditionalProperties: jsonschema.Type("boolean")}
+	return &jsonschema.Schema{OneOf: []*jsonschema.Schema{
+		jsonschema.Type("boolean"),
+		{
+			Type: jsonschema.Types{"object"},
+			Properties: jsonschema.Properties{
+				{Name: "SuccessfulExit", Schema: jsonschema.Type("boolean")},
+				{Name: "Crashed", Schema: jsonschema.Type("boolean")},
+				{Name: "NetworkState", Schema: jsonschema.Type("boolean")},
+				{Name: "PathState", Schema: flags},
+				{Name: "OtherJobEnabled", Schema: flags},
+				{Name: "OtherJobActive", Schema: flags},
+				{Name: "AfterInitialDemand", Schema: jsonschema.Type("boolean")},
+			},
+		},
+	}}
+}
+
+// stringOrStrings accepts a string or an array of strings.
+func stringOrStrings() *jsonschema.Schema {
+	return &jsonschema.Schema{OneOf: []*jsonschema.Schema{
+		jsonschema.Type("string"),
+		{Type: jsonschema.Types{"array"}, Items: jsonschema.Type("string")},
+	}}
+}
+
+// plistOverrides gives the LaunchAgentPlist fields of interface type the
+// forms launchd accepts.
+func plistOverrides(nullable bool) map[string]*jsonschema.Schema {
+	overrides := map[string]*jsonschema.Schema{
+		"LaunchAgentPlist.KeepAlive": keepAliveSchema(),
+		"LaunchAgentPlist.StartCalendarInterval": {OneOf: []*jsonschema.Schema{
+			calendarEntrySchema(),
+			{Type: jsonschema.Types{"array"}, Items: calendarEntrySchema()},
+		}},
+		"LaunchAgentPlist.Umask":                       {OneOf: []*jsonschema.Schema{jsonschema.Type("integer"), jsonschema.Type("string")}},
+		"LaunchAgentPlist.LimitLoadToSessionType":      stringOrStrings(),
+		"LaunchAgentPlist.AssociatedBundleIdentifiers": stringOrStrings(),
+	}
+	// In an export bundle, unset interface fields are encoded as null.
+	if nullable {
+		for k, s := range overrides {
+			overrides[k] = &jsonschema.Schema{OneOf: append([]*jsonschema.Schema{jsonschema.Type("null")}, s.OneOf...)}
+		}
+	}
+	return overrides
+}
+
+// PlistSchema returns the JSON Schema of a launchd plist converted to JSON
+// (for example with "plutil -convert json"). Keys lanchr does not model
+// are allowed.
+func PlistSchema() *jsonschema.Schema {
+	r := &jsonschema.Reflector{Tag: "plist", Overrides: plistOverrides(false), AllowAdditional: true}
+	s := r.Reflect(reflect.TypeOf(LaunchAgentPlist{}))
+	s.Schema = jsonschema.Draft
+	s.ID = SchemaBaseURL + "plist.schema.json"
+	s.Title = "launchd plist"
+	s.Description = "A launchd.plist(5) job definition in JSON form."
+	return s
+}
+
+// BundleSchema returns the JSON Schema of an ExportBundle as written by
+// "lanchr export".
+func BundleSchema() *jsonschema.Schema {
+	overrides := plistOverrides(true)
+	overrides["BundleService.PlistData"] = &jsonschema.Schema{
+		Type:            jsonschema.Types{"string"},
+		ContentEncoding: "base64",
+		Description:     "The original plist file, which import writes verbatim. When it is present, plist must either be empty or describe the same plist; a bundle where they differ is rejected.",
+	}
+	r := &jsonschema.Reflector{Tag: "json", Overrides: overrides}
+	s := r.Reflect(reflect.TypeOf(ExportBundle{}))
+	s.Schema = jsonschema.Draft
+	s.ID = SchemaBaseURL + "bundle.schema.json"
+	s.Title = "lanchr export bundle"
+	s.Description = "Launch agents and daemons exported by \"lanchr export\", with the files they reference if requested. Paths in the exporting user's home start with {{HOME}}, which import expands to the importing user's home. A bundle signed with \"export --sign\" carries an ed25519 signature over its compact JSON encoding without the signature. Version 1 bundles, which held one service in the envelope, are still imported."
+	return s
+}
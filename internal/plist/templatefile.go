@@ -0,0 +1,689 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// TemplateExtensions are the file extensions LoadTemplates reads, in the
+// order a template name is looked up.
+var TemplateExtensions = []string{".yaml", ".yml", ".json", ".plist"}
+
+// TemplateParam is a value a template asks for when it is rendered. Its
+// name is used as {{ .name }} in the template.
+type TemplateParam struct {
+	Name        string
+	Description string
+	Default     string
+	Required    bool
+}
+
+// builtinTemplateData are the values every template can use besides its
+// parameters.
+var builtinTemplateData = []string{"Label", "Home", "User"}
+
+// paramNameRe matches names usable as {{ .name }}.
+var paramNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// DefaultTemplateDir returns the directory user templates are loaded from:
+// $XDG_CONFIG_HOME/lanchr/templates, or ~/.config/lanchr/templates.
+func DefaultTemplateDir() string {
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		home, _ := os.UserHomeDir()
+		config = filepath.Join(home, ".config")
+	}
+	return filepath.Join(config, "lanchr", "templates")
+}
+
+// LoadTemplates reads every template file in dir, sorted by name. A missing
+// directory holds no templates. Files that fail to load are reported and
+// skipped.
+func LoadTemplates(dir string) ([]Template, []error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, []error{fmt.Errorf("failed to read template directory %s: %w", dir, err)}
+	}
+
+	var (
+		templates []Template
+		errs      []error
+		seen      = make(map[string]string)
+	)
+	for _, e := range entries {
+		if e.IsDir() || !isTemplateFile(e.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		t, err := LoadTemplateFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if other, ok := seen[t.Name]; ok {
+			errs = append(errs, fmt.Errorf("template %q is defined twice: %s and %s", t.Name, other, path))
+			continue
+		}
+		seen[t.Name] = path
+		templates = append(templates, *t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, errs
+}
+
+// Templates returns the built-in templates followed by the templates in
+// dir. A file template with the name of a built-in one replaces it.
+func Templates(dir string) ([]Template, []error) {
+	custom, errs := LoadTemplates(dir)
+	byName := make(map[string]Template, len(custom))
+	for _, t := range custom {
+		byName[t.Name] = t
+	}
+
+	var all []Template
+	for _, t := range BuiltinTemplates() {
+		if c, ok := byName[t.Name]; ok {
+			t = c
+			delete(byName, t.Name)
+		}
+		all = append(all, t)
+	}
+	for _, t := range custom {
+		if _, ok := byName[t.Name]; ok {
+			all = append(all, t)
+		}
+	}
+	return all, errs
+}
+
+// LookupTemplate returns the template called name, preferring a file in dir
+// over a built-in template.
+func LookupTemplate(name, dir string) (*Template, error) {
+	for _, ext := range TemplateExtensions {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return LoadTemplateFile(path)
+		}
+	}
+	if t, err := GetTemplate(name); err == nil {
+		return t, nil
+	}
+
+	all, _ := Templates(dir)
+	names := make([]string, 0, len(all))
+	for _, t := range all {
+		names = append(names, t.Name)
+	}
+	return nil, fmt.Errorf("unknown template %q; available: %s", name, strings.Join(names, ", "))
+}
+
+// isTemplateFile reports whether name has a template file extension.
+func isTemplateFile(name string) bool {
+	return containsString(TemplateExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// LoadTemplateFile reads a template from a YAML, JSON or plist file, chosen
+// by extension. The template is named after the file. Every format holds
+// the same dictionary:
+//
+//	description: Nightly restic backup
+//	parameters:
+//	  - name: repo
+//	    description: Repository to back up to
+//	    required: true
+//	requires: [restic]
+//	plist:
+//	  ProgramArguments: [restic, -r, "{{ .repo }}", backup, "{{ .Home }}"]
+//	  StartCalendarInterval: {Hour: 2, Minute: 0}
+func LoadTemplateFile(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %w", path, err)
+	}
+	root, err := decodeTemplateData(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode template %s: %w", path, err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	t, err := TemplateFromDict(name, root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", path, err)
+	}
+	t.Source = path
+	return t, nil
+}
+
+// decodeTemplateData decodes a template file into document values.
+func decodeTemplateData(path string, data []byte) (*Dict, error) {
+	var (
+		v   interface{}
+		err error
+	)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		v, err = decodeYAML(data)
+	case ".json":
+		v, err = parseJSONValue(string(data))
+	default:
+		var doc *Document
+		doc, err = ParseDocument(data)
+		if doc != nil {
+			v = doc.Root
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	root, ok := v.(*Dict)
+	if !ok {
+		return nil, fmt.Errorf("root is %s, not a dictionary", typeName(v))
+	}
+	return root, nil
+}
+
+// TemplateFromDict reads a template from the dictionary a template file
+// holds (see LoadTemplateFile).
+func TemplateFromDict(name string, root *Dict) (*Template, error) {
+	t := &Template{Name: name}
+	for _, k := range root.Keys() {
+		v, _ := root.Get(k)
+		switch k {
+		case "description":
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("description must be a string, not %s", typeName(v))
+			}
+			t.Description = s
+		case "parameters":
+			params, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("parameters must be an array, not %s", typeName(v))
+			}
+			for i, p := range params {
+				param, err := templateParam(p, t.Params)
+				if err != nil {
+					return nil, fmt.Errorf("parameters.%d: %w", i, err)
+				}
+				t.Params = append(t.Params, param)
+			}
+		case "requires":
+			bins, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("requires must be an array, not %s", typeName(v))
+			}
+			for i, b := range bins {
+				s, ok := b.(string)
+				if !ok || s == "" {
+					return nil, fmt.Errorf("requires.%d must be a binary name or path", i)
+				}
+				t.Requires = append(t.Requires, s)
+			}
+		case "plist":
+			body, ok := v.(*Dict)
+			if !ok {
+				return nil, fmt.Errorf("plist must be a dictionary, not %s", typeName(v))
+			}
+			t.body = body
+		default:
+			return nil, fmt.Errorf("unknown key %q (use description, parameters, requires and plist)", k)
+		}
+	}
+	if t.body == nil {
+		return nil, fmt.Errorf("plist dictionary is missing")
+	}
+	return t, nil
+}
+
+// templateParam reads one entry of a template's parameters array.
+func templateParam(v interface{}, declared []TemplateParam) (TemplateParam, error) {
+	var p TemplateParam
+	d, ok := v.(*Dict)
+	if !ok {
+		return p, fmt.Errorf("must be a dictionary, not %s", typeName(v))
+	}
+	for _, k := range d.Keys() {
+		val, _ := d.Get(k)
+		var err error
+		switch k {
+		case "name":
+			p.Name, err = scalarString(val)
+		case "description":
+			p.Description, err = scalarString(val)
+		case "default":
+			p.Default, err = scalarString(val)
+		case "required":
+			var ok bool
+			if p.Required, ok = val.(bool); !ok {
+				err = fmt.Errorf("must be a boolean, not %s", typeName(val))
+			}
+		default:
+			return p, fmt.Errorf("unknown key %q (use name, description, default and required)", k)
+		}
+		if err != nil {
+			return p, fmt.Errorf("%s %w", k, err)
+		}
+	}
+	switch {
+	case !paramNameRe.MatchString(p.Name):
+		return p, fmt.Errorf("name %q must be letters, digits and underscores", p.Name)
+	case containsString(builtinTemplateData, p.Name):
+		return p, fmt.Errorf("name %q is reserved", p.Name)
+	}
+	for _, other := range declared {
+		if other.Name == p.Name {
+			return p, fmt.Errorf("parameter %q is declared twice", p.Name)
+		}
+	}
+	return p, nil
+}
+
+// scalarString formats a string, number or boolean as template text.
+func scalarString(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case int64, uint64, bool:
+		return fmt.Sprint(x), nil
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64), nil
+	}
+	return "", fmt.Errorf("must be a string, number or boolean, not %s", typeName(v))
+}
+
+// StarterTemplate returns the example a new user template starts from: a
+// program run every interval seconds, logging to ~/Library/Logs.
+func StarterTemplate(name string) *Template {
+	body := NewDict()
+	body.Set("ProgramArguments", []interface{}{"{{ .program }}"})
+	body.Set("StartInterval", "{{ .interval }}")
+	body.Set("RunAtLoad", true)
+	body.Set("StandardOutPath", "{{ .Home }}/Library/Logs/{{ .Label }}.log")
+	body.Set("StandardErrorPath", "{{ .Home }}/Library/Logs/{{ .Label }}.log")
+	return &Template{
+		Name:        name,
+		Description: fmt.Sprintf("Describe what %s agents do", name),
+		Params: []TemplateParam{
+			{Name: "program", Description: "Executable to run", Required: true},
+			{Name: "interval", Description: "Seconds between runs", Default: "3600"},
+		},
+		body: body,
+	}
+}
+
+// Custom reports whether the template was loaded from a file.
+func (t *Template) Custom() bool {
+	return t.body != nil
+}
+
+// Body returns the template's plist dictionary, before rendering.
+func (t *Template) Body() *Dict {
+	if t.body != nil {
+		return t.body
+	}
+	body := DocumentFromPlist(&t.Plist).Root
+	if label, _ := body.Get("Label"); label == "" {
+		body.Delete("Label")
+	}
+	return body
+}
+
+// Dict returns the template in the form LoadTemplateFile reads.
+func (t *Template) Dict() *Dict {
+	d := NewDict()
+	if t.Description != "" {
+		d.Set("description", t.Description)
+	}
+	if len(t.Params) > 0 {
+		params := make([]interface{}, 0, len(t.Params))
+		for _, p := range t.Params {
+			pd := NewDict()
+			pd.Set("name", p.Name)
+			if p.Description != "" {
+				pd.Set("description", p.Description)
+			}
+			if p.Default != "" {
+				pd.Set("default", p.Default)
+			}
+			if p.Required {
+				pd.Set("required", true)
+			}
+			params = append(params, pd)
+		}
+		d.Set("parameters", params)
+	}
+	if len(t.Requires) > 0 {
+		bins := make([]interface{}, 0, len(t.Requires))
+		for _, b := range t.Requires {
+			bins = append(bins, b)
+		}
+		d.Set("requires", bins)
+	}
+	d.Set("plist", t.Body())
+	return d
+}
+
+// EncodeTemplate serializes t as a template file in format ("yaml", "json"
+// or "plist").
+func EncodeTemplate(t *Template, format string) ([]byte, error) {
+	d := t.Dict()
+	switch format {
+	case "yaml", "yml":
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(yamlNode(d)); err != nil {
+			return nil, fmt.Errorf("failed to encode template: %w", err)
+		}
+		if err := enc.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode template: %w", err)
+		}
+		return buf.Bytes(), nil
+	case "json":
+		data, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode template: %w", err)
+		}
+		return append(data, '\n'), nil
+	case "plist":
+		return encodeXML(d), nil
+	}
+	return nil, fmt.Errorf("unknown template format %q (use yaml, json or plist)", format)
+}
+
+// CheckRequirements reports the first binary the template requires that is
+// not installed.
+func (t *Template) CheckRequirements() error {
+	for _, bin := range t.Requires {
+		var err error
+		if strings.Contains(bin, "/") {
+			_, err = os.Stat(bin)
+		} else {
+			_, err = exec.LookPath(bin)
+		}
+		if err != nil {
+			return fmt.Errorf("template %q requires %s, which is not installed", t.Name, bin)
+		}
+	}
+	return nil
+}
+
+// Render fills in the template and returns the plist it describes. Each
+// parameter takes its value from values, then its default; a required one
+// with neither is an error, as is a value for an undeclared parameter.
+// Besides its parameters a template can use {{ .Label }}, {{ .Home }} and
+// {{ .User }}. A non-empty label also replaces the Label key.
+//
+// Rendered strings are converted to the type the key takes, so that
+// StartInterval: "{{ .interval }}" becomes an integer. Keys lanchr does not
+// model are converted only when the string is a single {{ }} action.
+func (t *Template) Render(label string, values map[string]string) (*Document, error) {
+	if t.body == nil {
+		for name := range values {
+			return nil, fmt.Errorf("template %q has no parameter %q", t.Name, name)
+		}
+		pl := t.Plist
+		if label != "" {
+			pl.Label = label
+		}
+		return DocumentFromPlist(&pl), nil
+	}
+
+	data, err := t.templateData(label, values)
+	if err != nil {
+		return nil, err
+	}
+	v, err := renderValue(t.body, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render template %q: %w", t.Name, err)
+	}
+	root := v.(*Dict)
+	if label != "" {
+		if _, ok := root.Get("Label"); !ok {
+			labeled := NewDict()
+			labeled.Set("Label", label)
+			for _, k := range root.Keys() {
+				val, _ := root.Get(k)
+				labeled.Set(k, val)
+			}
+			root = labeled
+		}
+		root.Set("Label", label)
+	}
+	if err := CheckValue(nil, root); err != nil {
+		return nil, fmt.Errorf("template %q: %w", t.Name, err)
+	}
+	return &Document{Format: FormatXML, Root: root}, nil
+}
+
+// templateData returns the values a template is executed with.
+func (t *Template) templateData(label string, values map[string]string) (map[string]string, error) {
+	var declared []string
+	for _, p := range t.Params {
+		declared = append(declared, p.Name)
+	}
+	for name := range values {
+		if !containsString(declared, name) {
+			if len(declared) == 0 {
+				return nil, fmt.Errorf("template %q has no parameter %q", t.Name, name)
+			}
+			return nil, fmt.Errorf("template %q has no parameter %q (parameters: %s)", t.Name, name, strings.Join(declared, ", "))
+		}
+	}
+
+	home, _ := os.UserHomeDir()
+	username := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	data := map[string]string{"Label": label, "Home": home, "User": username}
+
+	var missing []string
+	for _, p := range t.Params {
+		v, ok := values[p.Name]
+		if !ok {
+			v = p.Default
+			if v == "" && p.Required {
+				missing = append(missing, p.Name)
+			}
+		}
+		data[p.Name] = v
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("template %q requires parameters: %s", t.Name, strings.Join(missing, ", "))
+	}
+	return data, nil
+}
+
+// renderValue executes every string in v, keys included, as a template.
+func renderValue(v interface{}, data map[string]string, path KeyPath) (interface{}, error) {
+	switch x := v.(type) {
+	case *Dict:
+		out := NewDict()
+		for _, k := range x.Keys() {
+			key, err := renderString(k, data, path)
+			if err != nil {
+				return nil, err
+			}
+			val, _ := x.Get(k)
+			rv, err := renderValue(val, data, path.child(key))
+			if err != nil {
+				return nil, err
+			}
+			out.Set(key, rv)
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, 0, len(x))
+		for i, el := range x {
+			rv, err := renderValue(el, data, path.child(strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, rv)
+		}
+		return out, nil
+	case string:
+		if !strings.Contains(x, "{{") {
+			return x, nil
+		}
+		s, err := renderString(x, data, path)
+		if err != nil {
+			return nil, err
+		}
+		allowed := expectedTypes(path)
+		if (allowed != nil && !containsString(allowed, "string")) || (allowed == nil && isSingleAction(x)) {
+			return ParseValue(path, s, "")
+		}
+		return s, nil
+	}
+	return v, nil
+}
+
+// renderString executes s as a text/template with data. Referring to a
+// value that does not exist is an error.
+func renderString(s string, data map[string]string, path KeyPath) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	name := path.String()
+	if name == "" {
+		name = "plist"
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// isSingleAction reports whether s is exactly one {{ }} action.
+func isSingleAction(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "{{") && strings.HasSuffix(s, "}}") && strings.Count(s, "{{") == 1
+}
+
+// decodeYAML decodes a YAML document into document values, keeping mapping
+// key order.
+func decodeYAML(data []byte) (interface{}, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 {
+		return nil, fmt.Errorf("empty YAML document")
+	}
+	return yamlValue(&doc)
+}
+
+func yamlValue(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		return yamlValue(n.Content[0])
+	case yaml.AliasNode:
+		return yamlValue(n.Alias)
+	case yaml.MappingNode:
+		d := NewDict()
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			v, err := yamlValue(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			d.Set(n.Content[i].Value, v)
+		}
+		return d, nil
+	case yaml.SequenceNode:
+		arr := make([]interface{}, 0, len(n.Content))
+		for _, el := range n.Content {
+			v, err := yamlValue(el)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, nil
+	}
+
+	var (
+		v   interface{}
+		err error
+	)
+	switch n.ShortTag() {
+	case "!!str":
+		return n.Value, nil
+	case "!!int":
+		var i int64
+		err = n.Decode(&i)
+		v = i
+	case "!!float":
+		var f float64
+		err = n.Decode(&f)
+		v = f
+	case "!!bool":
+		var b bool
+		err = n.Decode(&b)
+		v = b
+	case "!!timestamp":
+		var t time.Time
+		err = n.Decode(&t)
+		v = t
+	case "!!binary":
+		v, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(n.Value), ""))
+	case "!!null":
+		return nil, fmt.Errorf("line %d: null has no plist representation", n.Line)
+	default:
+		return nil, fmt.Errorf("line %d: unsupported YAML tag %s", n.Line, n.ShortTag())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", n.Line, err)
+	}
+	return v, nil
+}
+
+// yamlNode converts document values into a YAML tree, keeping key order.
+func yamlNode(v interface{}) *yaml.Node {
+	switch x := v.(type) {
+	case *Dict:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		for _, k := range x.Keys() {
+			val, _ := x.Get(k)
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k}, yamlNode(val))
+		}
+		return n
+	case []interface{}:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, el := range x {
+			n.Content = append(n.Content, yamlNode(el))
+		}
+		return n
+	case []byte:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!binary", Value: base64.StdEncoding.EncodeToString(x)}
+	case UID:
+		v = uint64(x)
+	}
+	n := &yaml.Node{}
+	if err := n.Encode(v); err != nil {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(v)}
+	}
+	return n
+}
@@ -0,0 +1,228 @@
+package plist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const yamlTemplate = `description: Nightly backup
+parameters:
+  - name: repo
+    description: Repository to back up to
+    required: true
+  - name: hour
+    default: 2
+requires: [sh]
+plist:
+  ProgramArguments: [restic, -r, "{{ .repo }}", backup, "{{ .Home }}"]
+  StartCalendarInterval:
+    Hour: "{{ .hour }}"
+    Minute: 30
+  ThrottleInterval: "{{ .hour }}0"
+  KeepAlive: "{{ if .repo }}false{{ end }}"
+  X-Team: "{{ .User }}"
+`
+
+const jsonTemplate = `{
+  "description": "Nightly backup",
+  "parameters": [
+    {"name": "repo", "description": "Repository to back up to", "required": true},
+    {"name": "hour", "default": "2"}
+  ],
+  "requires": ["sh"],
+  "plist": {
+    "ProgramArguments": ["restic", "-r", "{{ .repo }}", "backup", "{{ .Home }}"],
+    "StartCalendarInterval": {"Hour": "{{ .hour }}", "Minute": 30},
+    "ThrottleInterval": "{{ .hour }}0",
+    "KeepAlive": "{{ if .repo }}false{{ end }}",
+    "X-Team": "{{ .User }}"
+  }
+}`
+
+func writeTemplate(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadTemplateFileFormats(t *testing.T) {
+	dir := t.TempDir()
+	yamlT, err := LoadTemplateFile(writeTemplate(t, dir, "backup.yaml", yamlTemplate))
+	if err != nil {
+		t.Fatalf("LoadTemplateFile(yaml) error = %v", err)
+	}
+	jsonT, err := LoadTemplateFile(writeTemplate(t, dir, "backup.json", jsonTemplate))
+	if err != nil {
+		t.Fatalf("LoadTemplateFile(json) error = %v", err)
+	}
+	data, err := EncodeTemplate(yamlT, "plist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plistT, err := LoadTemplateFile(writeTemplate(t, dir, "backup.plist", string(data)))
+	if err != nil {
+		t.Fatalf("LoadTemplateFile(plist) error = %v", err)
+	}
+
+	for _, tmpl := range []*Template{yamlT, jsonT, plistT} {
+		if tmpl.Name != "backup" || tmpl.Description != "Nightly backup" || !tmpl.Custom() {
+			t.Errorf("%s: name = %q, description = %q", tmpl.Source, tmpl.Name, tmpl.Description)
+		}
+		if len(tmpl.Params) != 2 || !tmpl.Params[0].Required || tmpl.Params[1].Default != "2" {
+			t.Errorf("%s: params = %+v", tmpl.Source, tmpl.Params)
+		}
+		if !ValuesEqual(tmpl.Body(), yamlT.Body()) {
+			t.Errorf("%s: body differs from the YAML template", tmpl.Source)
+		}
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	tmpl, err := LoadTemplateFile(writeTemplate(t, t.TempDir(), "backup.yaml", yamlTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := tmpl.Render("com.example.backup", map[string]string{"repo": "/Volumes/Backup"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	if keys := doc.Root.Keys(); keys[0] != "Label" {
+		t.Errorf("keys = %v, want Label first", keys)
+	}
+	pl, err := doc.Plist()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pl.Label != "com.example.backup" || pl.ProgramArguments[2] != "/Volumes/Backup" {
+		t.Errorf("Label = %q, ProgramArguments = %v", pl.Label, pl.ProgramArguments)
+	}
+	// Rendered strings take the type of their key.
+	if v, _ := doc.Lookup(KeyPath{"StartCalendarInterval", "Hour"}); v != int64(2) {
+		t.Errorf("Hour = %#v, want int64(2)", v)
+	}
+	if pl.ThrottleInterval != 20 {
+		t.Errorf("ThrottleInterval = %d, want 20", pl.ThrottleInterval)
+	}
+	if v, _ := doc.Lookup(KeyPath{"KeepAlive"}); v != false {
+		t.Errorf("KeepAlive = %#v, want false", v)
+	}
+	// Unmodeled keys keep their strings.
+	if v, _ := doc.Lookup(KeyPath{"X-Team"}); v == "" || v == nil {
+		t.Errorf("X-Team = %#v, want the user name", v)
+	}
+}
+
+func TestTemplateRenderErrors(t *testing.T) {
+	tmpl, err := LoadTemplateFile(writeTemplate(t, t.TempDir(), "backup.yaml", yamlTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		values  map[string]string
+		wantErr string
+	}{
+		{"missing required", nil, "requires parameters: repo"},
+		{"unknown parameter", map[string]string{"repo": "r", "bogus": "1"}, `no parameter "bogus"`},
+		{"wrong type", map[string]string{"repo": "r", "hour": "two"}, "invalid integer value"},
+	}
+	for _, tt := range tests {
+		_, err := tmpl.Render("com.example.backup", tt.values)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: Render() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadTemplateFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"no plist", "description: x\n", "plist dictionary is missing"},
+		{"unknown key", "plist: {}\nextra: 1\n", `unknown key "extra"`},
+		{"reserved parameter", "parameters: [{name: Label}]\nplist: {}\n", "reserved"},
+		{"bad parameter name", "parameters: [{name: my-param}]\nplist: {}\n", "letters, digits and underscores"},
+		{"duplicate parameter", "parameters: [{name: a}, {name: a}]\nplist: {}\n", "declared twice"},
+		{"null value", "plist: {Label: ~}\n", "null"},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		_, err := LoadTemplateFile(writeTemplate(t, dir, "t.yaml", tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: LoadTemplateFile() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTemplatesAndLookup(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "backup.yaml", yamlTemplate)
+	writeTemplate(t, dir, "simple.json", `{"description": "House simple", "plist": {"RunAtLoad": true}}`)
+	writeTemplate(t, dir, "broken.yaml", "plist: [")
+	writeTemplate(t, dir, "notes.txt", "ignored")
+
+	all, errs := Templates(dir)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "broken.yaml") {
+		t.Errorf("errs = %v, want one error for broken.yaml", errs)
+	}
+	if len(all) != len(BuiltinTemplates())+1 {
+		t.Fatalf("got %d templates, want the built-ins plus backup", len(all))
+	}
+	if all[0].Name != "simple" || all[0].Description != "House simple" {
+		t.Errorf("first template = %q (%q), want the user simple template in place of the built-in", all[0].Name, all[0].Description)
+	}
+	if last := all[len(all)-1]; last.Name != "backup" {
+		t.Errorf("last template = %q, want backup", last.Name)
+	}
+
+	if tmpl, err := LookupTemplate("backup", dir); err != nil || !tmpl.Custom() {
+		t.Errorf("LookupTemplate(backup) = %v, %v", tmpl, err)
+	}
+	if tmpl, err := LookupTemplate("interval", dir); err != nil || tmpl.Custom() {
+		t.Errorf("LookupTemplate(interval) = %v, %v", tmpl, err)
+	}
+	_, err := LookupTemplate("nope", dir)
+	if err == nil || !strings.Contains(err.Error(), "backup") {
+		t.Errorf("LookupTemplate(nope) error = %v, want a list including backup", err)
+	}
+}
+
+func TestEncodeTemplateRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	starter := StarterTemplate("starter")
+	for _, format := range []string{"yaml", "json", "plist"} {
+		data, err := EncodeTemplate(starter, format)
+		if err != nil {
+			t.Fatalf("EncodeTemplate(%s) error = %v", format, err)
+		}
+		tmpl, err := LoadTemplateFile(writeTemplate(t, dir, "starter."+format, string(data)))
+		if err != nil {
+			t.Fatalf("%s: LoadTemplateFile() error = %v", format, err)
+		}
+		if !ValuesEqual(tmpl.Dict(), starter.Dict()) {
+			t.Errorf("%s: round trip changed the template", format)
+		}
+		if _, err := tmpl.Render("com.example.starter", map[string]string{"program": "/bin/echo"}); err != nil {
+			t.Errorf("%s: Render() error = %v", format, err)
+		}
+	}
+}
+
+func TestTemplateCheckRequirements(t *testing.T) {
+	tmpl := &Template{Name: "t", Requires: []string{"sh"}}
+	if err := tmpl.CheckRequirements(); err != nil {
+		t.Errorf("CheckRequirements() error = %v", err)
+	}
+	tmpl.Requires = append(tmpl.Requires, "/nonexistent/lanchr-test-binary")
+	if err := tmpl.CheckRequirements(); err == nil || !strings.Contains(err.Error(), "not installed") {
+		t.Errorf("CheckRequirements() error = %v, want not installed", err)
+	}
+}
@@ -6,6 +6,7 @@ type LaunchAgentPlist struct {
 	Disabled                  bool                   `plist:"Disabled,omitempty"`
 	Program                   string                 `plist:"Program,omitempty"`
 	ProgramArguments          []string               `plist:"ProgramArguments,omitempty"`
+	BundleProgram             string                 `plist:"BundleProgram,omitempty"`
 	EnableGlobbing            bool                   `plist:"EnableGlobbing,omitempty"`
 	EnvironmentVariables      map[string]string      `plist:"EnvironmentVariables,omitempty"`
 	WorkingDirectory          string                 `plist:"WorkingDirectory,omitempty"`
@@ -3,11 +3,9 @@ package plist
 import (
 	"fmt"
 	"os"
-
-	goplist "howett.net/plist"
 )
 
-// Writer generates plist XML files.
+// Writer generates plist files.
 type Writer struct{}
 
 // NewWriter creates a new plist writer.
@@ -15,48 +13,47 @@ func NewWriter() *Writer {
 	return &Writer{}
 }
 
-// Write serializes a LaunchAgentPlist to XML format at the given path.
+// Write serializes a LaunchAgentPlist as a new XML plist at the given path,
+// replacing any plist already there. Use Update to change an existing one.
 func (w *Writer) Write(pl *LaunchAgentPlist, path string) error {
 	if errs := w.Validate(pl); len(errs) > 0 {
 		return fmt.Errorf("failed to validate plist: %s", errs[0].Message)
 	}
-
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to create plist file %s: %w", path, err)
-	}
-	defer f.Close()
-
-	encoder := goplist.NewEncoderForFormat(f, goplist.XMLFormat)
-	encoder.Indent("\t")
-	if err := encoder.Encode(pl); err != nil {
-		return fmt.Errorf("failed to encode plist: %w", err)
-	}
-
-	return nil
+	return DocumentFromPlist(pl).WriteFile(path)
 }
 
-// WriteWithoutValidation serializes a LaunchAgentPlist to XML format at the
-// given path, skipping binary-exists validation. This is useful for importing
+// WriteWithoutValidation serializes a LaunchAgentPlist at the given path like
+// Write, skipping binary-exists validation. This is useful for importing
 // plists where the referenced binary may not be installed yet.
 func (w *Writer) WriteWithoutValidation(pl *LaunchAgentPlist, path string) error {
 	if pl.Label == "" {
 		return fmt.Errorf("failed to validate plist: Label is required")
 	}
+	return DocumentFromPlist(pl).WriteFile(path)
+}
 
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+// Update merges a LaunchAgentPlist into the plist at the given path: keys
+// LaunchAgentPlist does not model, key order and the file's format (XML or
+// binary) are kept. Without a readable plist there, it writes a new one.
+func (w *Writer) Update(pl *LaunchAgentPlist, path string) error {
+	if errs := w.Validate(pl); len(errs) > 0 {
+		return fmt.Errorf("failed to validate plist: %s", errs[0].Message)
+	}
+	doc, err := ReadDocument(path)
 	if err != nil {
-		return fmt.Errorf("failed to create plist file %s: %w", path, err)
+		doc = DocumentFromPlist(pl)
+	} else {
+		doc.Apply(pl)
 	}
-	defer f.Close()
+	return doc.WriteFile(path)
+}
 
-	encoder := goplist.NewEncoderForFormat(f, goplist.XMLFormat)
-	encoder.Indent("\t")
-	if err := encoder.Encode(pl); err != nil {
-		return fmt.Errorf("failed to encode plist: %w", err)
+// WriteDocument writes a document as-is, in its own format.
+func (w *Writer) WriteDocument(doc *Document, path string) error {
+	if label, _ := doc.Root.Get("Label"); label == nil || label == "" {
+		return fmt.Errorf("failed to validate plist: Label is required")
 	}
-
-	return nil
+	return doc.WriteFile(path)
 }
 
 // Validate checks a plist for common issues.
@@ -70,7 +67,7 @@ func (w *Writer) Validate(pl *LaunchAgentPlist) []ValidationError {
 		})
 	}
 
-	if pl.Program == "" && len(pl.ProgramArguments) == 0 {
+	if pl.Program == "" && len(pl.ProgramArguments) == 0 && pl.BundleProgram == "" {
 		errs = append(errs, ValidationError{
 			Field:   "Program",
 			Message: "either Program or ProgramArguments is required",
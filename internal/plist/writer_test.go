@@ -47,6 +47,31 @@ func TestWriteWithoutValidation(t *testing.T) {
 	}
 }
 
+func TestWriteReplacesExistingPlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "com.test.writer.plist")
+	old := DocumentFromPlist(&LaunchAgentPlist{Label: "com.test.writer", Program: "/bin/sh"})
+	old.Root.Set("com.vendor.Stale", "left over")
+	if err := old.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	// Re-creating the plist drops keys the new one does not set.
+	pl := &LaunchAgentPlist{Label: "com.test.writer", Program: "/bin/sh", RunAtLoad: true}
+	if err := NewWriter().Write(pl, path); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	doc, err := ReadDocument(path)
+	if err != nil {
+		t.Fatalf("ReadDocument() error = %v", err)
+	}
+	if _, ok := doc.Root.Get("com.vendor.Stale"); ok {
+		t.Error("Write kept a key of the plist it replaced")
+	}
+	if v, _ := doc.Root.Get("RunAtLoad"); v != true {
+		t.Errorf("RunAtLoad = %v, want true", v)
+	}
+}
+
 func TestValidate(t *testing.T) {
 	w := NewWriter()
 
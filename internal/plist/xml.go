@@ -0,0 +1,266 @@
+package plist
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xmlHeader is the preamble Apple's tools write for XML plists.
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+`
+
+// xmlDateLayout is the ISO 8601 form plists use for <date>.
+const xmlDateLayout = "2006-01-02T15:04:05Z"
+
+// decodeXML parses an XML plist, keeping dictionary key order.
+func decodeXML(data []byte) (interface{}, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	start, err := nextStart(dec)
+	if err == io.EOF {
+		return nil, errors.New("plist contains no value")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if start.Name.Local == "plist" {
+		inner, err := nextStart(dec)
+		if err != nil {
+			return nil, fmt.Errorf("empty <plist> element: %w", err)
+		}
+		return decodeXMLValue(dec, inner)
+	}
+	return decodeXMLValue(dec, start)
+}
+
+// nextStart returns the next start element, skipping everything else. It
+// returns errEndElement if an end element comes first.
+func nextStart(dec *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return t, nil
+		case xml.EndElement:
+			return xml.StartElement{}, errEndElement
+		}
+	}
+}
+
+// errEndElement signals the end of a dict or array in nextStart.
+var errEndElement = errors.New("unexpected end element")
+
+// elementText reads the character data of the current element up to its end.
+func elementText(dec *xml.Decoder) (string, error) {
+	var sb strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			sb.Write(t)
+		case xml.EndElement:
+			return sb.String(), nil
+		case xml.StartElement:
+			return "", fmt.Errorf("unexpected <%s> inside text element", t.Name.Local)
+		}
+	}
+}
+
+// decodeXMLValue decodes the element that start opens.
+func decodeXMLValue(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	line, _ := dec.InputPos()
+	switch start.Name.Local {
+	case "dict":
+		d := NewDict()
+		for {
+			keyStart, err := nextStart(dec)
+			if err == errEndElement {
+				return d, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if keyStart.Name.Local != "key" {
+				return nil, fmt.Errorf("line %d: expected <key> in <dict>, got <%s>", line, keyStart.Name.Local)
+			}
+			key, err := elementText(dec)
+			if err != nil {
+				return nil, err
+			}
+			valStart, err := nextStart(dec)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: missing value for key %q", line, key)
+			}
+			val, err := decodeXMLValue(dec, valStart)
+			if err != nil {
+				return nil, err
+			}
+			d.Set(key, val)
+		}
+	case "array":
+		arr := []interface{}{}
+		for {
+			elStart, err := nextStart(dec)
+			if err == errEndElement {
+				return arr, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			val, err := decodeXMLValue(dec, elStart)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+	case "true", "false":
+		if err := dec.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+	}
+
+	text, err := elementText(dec)
+	if err != nil {
+		return nil, err
+	}
+	switch start.Name.Local {
+	case "string":
+		return text, nil
+	case "integer":
+		s := strings.TrimSpace(text)
+		if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return n, nil
+		}
+		if n, err := strconv.ParseUint(s, 0, 64); err == nil {
+			return n, nil
+		}
+		return nil, fmt.Errorf("line %d: invalid integer %q", line, s)
+	case "real":
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid real %q", line, text)
+		}
+		return f, nil
+	case "date":
+		t, err := time.Parse(xmlDateLayout, strings.TrimSpace(text))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q", line, text)
+		}
+		return t, nil
+	case "data":
+		clean := strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+				return -1
+			}
+			return r
+		}, text)
+		b, err := base64.StdEncoding.DecodeString(clean)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid data: %w", line, err)
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("line %d: unknown plist element <%s>", line, start.Name.Local)
+	}
+}
+
+// xmlEscaper escapes character data. Unlike xml.EscapeText it leaves
+// newlines and quotes alone, as Apple's tools do.
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// encodeXML writes root in the layout Apple's tools use: tab indentation,
+// with the root dictionary at column zero.
+func encodeXML(root *Dict) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xmlHeader)
+	writeXMLValue(&buf, root, 0)
+	buf.WriteString("</plist>\n")
+	return buf.Bytes()
+}
+
+func writeXMLValue(buf *bytes.Buffer, v interface{}, depth int) {
+	indent := strings.Repeat("\t", depth)
+	buf.WriteString(indent)
+	switch x := v.(type) {
+	case *Dict:
+		if x.Len() == 0 {
+			buf.WriteString("<dict/>\n")
+			return
+		}
+		buf.WriteString("<dict>\n")
+		for _, k := range x.keys {
+			fmt.Fprintf(buf, "%s\t<key>%s</key>\n", indent, xmlEscaper.Replace(k))
+			writeXMLValue(buf, x.values[k], depth+1)
+		}
+		buf.WriteString(indent + "</dict>\n")
+	case []interface{}:
+		if len(x) == 0 {
+			buf.WriteString("<array/>\n")
+			return
+		}
+		buf.WriteString("<array>\n")
+		for _, el := range x {
+			writeXMLValue(buf, el, depth+1)
+		}
+		buf.WriteString(indent + "</array>\n")
+	case string:
+		fmt.Fprintf(buf, "<string>%s</string>\n", xmlEscaper.Replace(x))
+	case int64:
+		fmt.Fprintf(buf, "<integer>%d</integer>\n", x)
+	case uint64:
+		fmt.Fprintf(buf, "<integer>%d</integer>\n", x)
+	case float64:
+		fmt.Fprintf(buf, "<real>%s</real>\n", formatReal(x))
+	case bool:
+		if x {
+			buf.WriteString("<true/>\n")
+		} else {
+			buf.WriteString("<false/>\n")
+		}
+	case time.Time:
+		fmt.Fprintf(buf, "<date>%s</date>\n", x.UTC().Format(xmlDateLayout))
+	case []byte:
+		buf.WriteString("<data>\n")
+		enc := base64.StdEncoding.EncodeToString(x)
+		for len(enc) > 0 {
+			n := min(len(enc), 68)
+			fmt.Fprintf(buf, "%s%s\n", indent, enc[:n])
+			enc = enc[n:]
+		}
+		buf.WriteString(indent + "</data>\n")
+	case UID:
+		// XML has no UID type; CoreFoundation writes a CF$UID dictionary.
+		fmt.Fprintf(buf, "<dict>\n%s\t<key>CF$UID</key>\n%s\t<integer>%d</integer>\n%s</dict>\n", indent, indent, uint64(x), indent)
+	default:
+		fmt.Fprintf(buf, "<string>%s</string>\n", xmlEscaper.Replace(fmt.Sprint(x)))
+	}
+}
+
+// formatReal formats a float the way plutil does, including infinities.
+func formatReal(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+infinity"
+	case math.IsInf(f, -1):
+		return "-infinity"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
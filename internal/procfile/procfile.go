@@ -0,0 +1,95 @@
+// Package procfile reads Procfiles, which list the processes of a project
+// as "name: command" lines, as used by foreman and Heroku.
+package procfile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// Process is one line of a Procfile.
+type Process struct {
+	Name    string
+	Command string
+	// Line is the process's line number in the Procfile.
+	Line int
+}
+
+var processRe = regexp.MustCompile(`^([A-Za-z0-9_-]+)\s*:\s*(.*)$`)
+
+// Read reads the Procfile at path.
+func Read(path string) ([]Process, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Procfile: %w", err)
+	}
+	defer f.Close()
+	procs, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Procfile %s:\n%w", path, err)
+	}
+	return procs, nil
+}
+
+// Parse reads a Procfile. Blank lines and lines starting with # are
+// skipped. Every line that cannot be parsed, and every repeated name, is
+// reported with its line number in the returned error.
+func Parse(r io.Reader) ([]Process, error) {
+	var (
+		procs []Process
+		errs  []error
+		seen  = make(map[string]int)
+	)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := processRe.FindStringSubmatch(line)
+		switch {
+		case m == nil:
+			errs = append(errs, fmt.Errorf("line %d: expected \"name: command\", got %q", n, line))
+		case strings.TrimSpace(m[2]) == "":
+			errs = append(errs, fmt.Errorf("line %d: process %s has no command", n, m[1]))
+		case seen[m[1]] > 0:
+			errs = append(errs, fmt.Errorf("line %d: process %s is already defined on line %d", n, m[1], seen[m[1]]))
+		default:
+			seen[m[1]] = n
+			procs = append(procs, Process{Name: m[1], Command: strings.TrimSpace(m[2]), Line: n})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Procfile: %w", err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return procs, nil
+}
+
+// Plist converts the process into a launchd job with the given label that
+// starts at load. The command runs as foreman runs it, through /bin/sh -c
+// in dir, with env as its environment.
+func (p *Process) Plist(label, dir string, env map[string]string) *plist.LaunchAgentPlist {
+	pl := &plist.LaunchAgentPlist{
+		Label:            label,
+		ProgramArguments: []string{"/bin/sh", "-c", p.Command},
+		WorkingDirectory: dir,
+		RunAtLoad:        true,
+	}
+	if len(env) > 0 {
+		pl.EnvironmentVariables = make(map[string]string, len(env))
+		for k, v := range env {
+			pl.EnvironmentVariables[k] = v
+		}
+	}
+	return pl
+}
@@ -0,0 +1,53 @@
+package procfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	procs, err := Parse(strings.NewReader(`# dev processes
+web: bundle exec rails s -p $PORT
+
+worker:python worker.py  
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := []Process{
+		{Name: "web", Command: "bundle exec rails s -p $PORT", Line: 2},
+		{Name: "worker", Command: "python worker.py", Line: 4},
+	}
+	if !reflect.DeepEqual(procs, want) {
+		t.Errorf("Parse() = %+v, want %+v", procs, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse(strings.NewReader("web: a\nno colon here\nweb: b\nempty:\n"))
+	if err == nil {
+		t.Fatal("Parse() error = nil")
+	}
+	for _, want := range []string{"line 2: expected", "line 3: process web is already defined on line 1", "line 4: process empty has no command"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestProcessPlist(t *testing.T) {
+	p := Process{Name: "web", Command: "rails s -p $PORT"}
+	env := map[string]string{"PORT": "5000"}
+	pl := p.Plist("com.example.web", "/src/shop", env)
+	if !reflect.DeepEqual(pl.ProgramArguments, []string{"/bin/sh", "-c", "rails s -p $PORT"}) {
+		t.Errorf("ProgramArguments = %q", pl.ProgramArguments)
+	}
+	if pl.WorkingDirectory != "/src/shop" || !pl.RunAtLoad || pl.EnvironmentVariables["PORT"] != "5000" {
+		t.Errorf("plist = %+v", pl)
+	}
+	env["PORT"] = "6000"
+	if pl.EnvironmentVariables["PORT"] != "5000" {
+		t.Error("Plist() shares the environment map with its caller")
+	}
+}
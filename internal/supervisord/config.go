@@ -0,0 +1,538 @@
+// Package supervisord reads supervisord configuration files and converts
+// their programs into launchd jobs.
+package supervisord
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Warning reports an option or section that was dropped or only
+// approximated.
+type Warning struct {
+	File    string
+	Line    int
+	Message string
+}
+
+// String formats the warning as "file:line: message".
+func (w Warning) String() string {
+	return fmt.Sprintf("%s:%d: %s", w.File, w.Line, w.Message)
+}
+
+// Config is a parsed supervisord configuration, with its includes.
+type Config struct {
+	// Programs holds one entry per process, in file order; a program with
+	// numprocs contributes several.
+	Programs []Program
+	Warnings []Warning
+}
+
+// Program is one process of a [program:x] section, with its options
+// expanded.
+type Program struct {
+	// Name is the process name: the program name, or with numprocs, the
+	// expanded process_name.
+	Name string
+	// Program is the name of the [program:x] section.
+	Program string
+	File    string
+	Line    int
+
+	Command        string
+	Directory      string
+	Environment    map[string]string
+	AutoStart      bool
+	AutoRestart    string
+	StopWaitSecs   int
+	Umask          *int
+	StdoutLogfile  string
+	StderrLogfile  string
+	RedirectStderr bool
+}
+
+// option is one "key = value" line.
+type option struct {
+	value string
+	line  int
+}
+
+// section is one [name] block of a configuration file.
+type section struct {
+	name    string
+	file    string
+	line    int
+	options map[string]option
+}
+
+// serverSections configure supervisord itself and have nothing to convert.
+var serverSections = map[string]bool{
+	"supervisord":      true,
+	"unix_http_server": true,
+	"inet_http_server": true,
+	"supervisorctl":    true,
+	"include":          true,
+	"rpcinterface":     true,
+	"ctlplugin":        true,
+}
+
+// otherSections define processes or groups that are not converted.
+var otherSections = map[string]bool{
+	"group":         true,
+	"eventlistener": true,
+	"fcgi-program":  true,
+}
+
+// ignoredOptions need no warning: the numprocs options are expanded into
+// processes, and launchd already signals the whole process group.
+var ignoredOptions = map[string]bool{
+	"stopasgroup":    true,
+	"killasgroup":    true,
+	"process_name":   true,
+	"numprocs":       true,
+	"numprocs_start": true,
+}
+
+// Read reads the supervisord configuration at path and the files its
+// [include] section names. Every problem found is reported in the
+// returned error.
+func Read(path string) (*Config, error) {
+	sections, err := parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	var includes []section
+	for _, s := range sections {
+		if s.name == "include" {
+			includes = append(includes, s)
+		}
+	}
+	for _, inc := range includes {
+		files, ok := inc.options["files"]
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: [include] has no files option", inc.file, inc.line)
+		}
+		for _, pattern := range strings.Fields(files.value) {
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(inc.file), pattern)
+			}
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid include pattern %q: %w", inc.file, files.line, pattern, err)
+			}
+			sort.Strings(matches)
+			for _, m := range matches {
+				more, err := parseFile(m)
+				if err != nil {
+					return nil, err
+				}
+				for _, s := range more {
+					if s.name == "include" {
+						cfg.warn(s.file, s.line, "[include] is only read from the main configuration file")
+						continue
+					}
+					sections = append(sections, s)
+				}
+			}
+		}
+	}
+
+	seen := make(map[string]section)
+	var (
+		baseEnv map[string]string
+		errs    []error
+	)
+	for _, s := range sections {
+		if prev, ok := seen[s.name]; ok && s.name != "include" {
+			errs = append(errs, fmt.Errorf("%s:%d: [%s] is already defined at %s:%d", s.file, s.line, s.name, prev.file, prev.line))
+			continue
+		}
+		seen[s.name] = s
+		if s.name == "supervisord" {
+			if env, ok := s.options["environment"]; ok {
+				v, err := expand(env.value, map[string]string{"here": filepath.Dir(s.file)})
+				if err == nil {
+					baseEnv, err = parseEnvironment(v)
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s:%d: environment: %w", s.file, env.line, err))
+				}
+			}
+		}
+	}
+
+	for _, s := range sections {
+		kind, name, _ := strings.Cut(s.name, ":")
+		switch {
+		case kind == "program":
+			progs, err := cfg.programs(s, name)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			cfg.Programs = append(cfg.Programs, progs...)
+		case otherSections[kind]:
+			cfg.warn(s.file, s.line, fmt.Sprintf("[%s] is not converted; only [program:x] sections are", s.name))
+		case !serverSections[kind]:
+			cfg.warn(s.file, s.line, fmt.Sprintf("unknown section [%s] is ignored", s.name))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	// Children inherit supervisord's environment, which a program's own
+	// environment overrides.
+	for i := range cfg.Programs {
+		env := make(map[string]string, len(baseEnv)+len(cfg.Programs[i].Environment))
+		for k, v := range baseEnv {
+			env[k] = v
+		}
+		for k, v := range cfg.Programs[i].Environment {
+			env[k] = v
+		}
+		cfg.Programs[i].Environment = env
+	}
+	return cfg, nil
+}
+
+func (c *Config) warn(file string, line int, message string) {
+	c.Warnings = append(c.Warnings, Warning{File: file, Line: line, Message: message})
+}
+
+// programs expands a [program:x] section into its processes. Options
+// launchd cannot express are reported once per section.
+func (c *Config) programs(s section, name string) ([]Program, error) {
+	get := func(key, def string) option {
+		if o, ok := s.options[key]; ok {
+			return o
+		}
+		return option{value: def, line: s.line}
+	}
+	errorf := func(o option, key, format string, args ...interface{}) error {
+		return fmt.Errorf("%s:%d: [%s] %s: %s", s.file, o.line, s.name, key, fmt.Sprintf(format, args...))
+	}
+	if _, ok := s.options["command"]; !ok {
+		return nil, fmt.Errorf("%s:%d: [%s] has no command", s.file, s.line, s.name)
+	}
+
+	vars := map[string]string{
+		"here":         filepath.Dir(s.file),
+		"program_name": name,
+		"group_name":   name,
+	}
+	if host, err := os.Hostname(); err == nil {
+		vars["host_node_name"] = host
+	}
+	intOption := func(key, def string) (int, error) {
+		o := get(key, def)
+		v, err := expand(o.value, vars)
+		if err != nil {
+			return 0, errorf(o, key, "%v", err)
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, errorf(o, key, "%q is not a non-negative integer", v)
+		}
+		return n, nil
+	}
+	numprocs, err := intOption("numprocs", "1")
+	if err != nil {
+		return nil, err
+	}
+	start, err := intOption("numprocs_start", "0")
+	if err != nil {
+		return nil, err
+	}
+	processName := get("process_name", "%(program_name)s")
+	if numprocs > 1 && !strings.Contains(processName.value, "%(process_num)") {
+		return nil, errorf(processName, "process_name", "must include %%(process_num) when numprocs is %d", numprocs)
+	}
+
+	c.checkOptions(s)
+
+	var progs []Program
+	for i := 0; i < numprocs; i++ {
+		vars["process_num"] = strconv.Itoa(start + i)
+		value := func(key, def string) (string, error) {
+			o := get(key, def)
+			v, err := expand(o.value, vars)
+			if err != nil {
+				return "", errorf(o, key, "%v", err)
+			}
+			return v, nil
+		}
+		p := Program{Program: name, File: s.file, Line: s.line}
+		var err error
+		if p.Name, err = value("process_name", "%(program_name)s"); err != nil {
+			return nil, err
+		}
+		// Later options may refer to the process name.
+		vars["process_name"] = p.Name
+		if p.Command, err = value("command", ""); err != nil {
+			return nil, err
+		}
+		if p.Directory, err = value("directory", ""); err != nil {
+			return nil, err
+		}
+		env, err := value("environment", "")
+		if err != nil {
+			return nil, err
+		}
+		if p.Environment, err = parseEnvironment(env); err != nil {
+			return nil, errorf(get("environment", ""), "environment", "%v", err)
+		}
+		if p.AutoStart, err = c.boolOption(s, "autostart", true); err != nil {
+			return nil, err
+		}
+		if p.RedirectStderr, err = c.boolOption(s, "redirect_stderr", false); err != nil {
+			return nil, err
+		}
+		if p.AutoRestart, err = value("autorestart", "unexpected"); err != nil {
+			return nil, err
+		}
+		p.AutoRestart = strings.ToLower(p.AutoRestart)
+		if _, err := KeepAlive(p.AutoRestart); err != nil {
+			return nil, errorf(get("autorestart", ""), "autorestart", "%v", err)
+		}
+		if p.StopWaitSecs, err = intOption("stopwaitsecs", "0"); err != nil {
+			return nil, err
+		}
+		if o, ok := s.options["umask"]; ok {
+			n, err := strconv.ParseInt(o.value, 8, 32)
+			if err != nil {
+				return nil, errorf(o, "umask", "%q is not an octal number", o.value)
+			}
+			umask := int(n)
+			p.Umask = &umask
+		}
+		if p.StdoutLogfile, err = value("stdout_logfile", ""); err != nil {
+			return nil, err
+		}
+		if p.StderrLogfile, err = value("stderr_logfile", ""); err != nil {
+			return nil, err
+		}
+		progs = append(progs, p)
+	}
+	return progs, nil
+}
+
+// checkOptions reports the options of a program section that launchd
+// cannot express, or only approximately.
+func (c *Config) checkOptions(s section) {
+	keys := make([]string, 0, len(s.options))
+	for k := range s.options {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return s.options[keys[i]].line < s.options[keys[j]].line })
+
+	for _, key := range keys {
+		o := s.options[key]
+		prefix := fmt.Sprintf("[%s] %s=%s: ", s.name, key, o.value)
+		switch key {
+		case "command", "directory", "environment", "autostart", "autorestart",
+			"stopwaitsecs", "umask", "stdout_logfile", "stderr_logfile", "redirect_stderr":
+		case "exitcodes":
+			if strings.TrimSpace(o.value) != "0" {
+				c.warn(s.file, o.line, prefix+"launchd only treats exit status 0 as expected")
+			}
+		case "stopsignal":
+			if sig := strings.TrimPrefix(strings.ToUpper(o.value), "SIG"); sig != "TERM" {
+				c.warn(s.file, o.line, prefix+"launchd always stops jobs with SIGTERM")
+			}
+		case "user":
+			c.warn(s.file, o.line, prefix+"launch agents run as the user who loads them; the option is dropped")
+		case "priority":
+			c.warn(s.file, o.line, prefix+"launchd starts jobs in no particular order")
+		case "startsecs", "startretries":
+			c.warn(s.file, o.line, prefix+"launchd has no start-up check; it waits ThrottleInterval (10 seconds) between launches")
+		default:
+			if !ignoredOptions[key] {
+				c.warn(s.file, o.line, prefix+"has no launchd equivalent")
+			}
+		}
+	}
+}
+
+// boolOption reads a supervisord boolean: true/false, yes/no, on/off or
+// 1/0.
+func (c *Config) boolOption(s section, key string, def bool) (bool, error) {
+	o, ok := s.options[key]
+	if !ok {
+		return def, nil
+	}
+	switch strings.ToLower(o.value) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("%s:%d: [%s] %s: %q is not a boolean", s.file, o.line, s.name, key, o.value)
+}
+
+// parseFile reads the sections of one configuration file, which has the
+// syntax of Python's configparser: "key = value" or "key: value" lines,
+// comments starting with ; or #, also after whitespace at the end of a
+// line, and indented continuation lines.
+func parseFile(path string) ([]section, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read supervisord config: %w", err)
+	}
+	defer f.Close()
+
+	var (
+		sections []section
+		cur      *section
+		lastKey  string
+		errs     []error
+	)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		raw := scanner.Text()
+		line := strings.TrimSpace(stripComment(raw))
+		if line == "" {
+			lastKey = ""
+			continue
+		}
+		if raw[0] == ' ' || raw[0] == '\t' {
+			if cur != nil && lastKey != "" {
+				o := cur.options[lastKey]
+				o.value += "\n" + line
+				cur.options[lastKey] = o
+				continue
+			}
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				errs = append(errs, fmt.Errorf("%s:%d: invalid section header %q", path, n, line))
+				continue
+			}
+			sections = append(sections, section{
+				name:    strings.TrimSpace(line[1 : len(line)-1]),
+				file:    path,
+				line:    n,
+				options: make(map[string]option),
+			})
+			cur = &sections[len(sections)-1]
+			lastKey = ""
+			continue
+		}
+		i := strings.IndexAny(line, "=:")
+		switch {
+		case i <= 0:
+			errs = append(errs, fmt.Errorf("%s:%d: expected key = value, got %q", path, n, line))
+		case cur == nil:
+			errs = append(errs, fmt.Errorf("%s:%d: %s is outside a section", path, n, strings.TrimSpace(line[:i])))
+		default:
+			lastKey = strings.ToLower(strings.TrimSpace(line[:i]))
+			cur.options[lastKey] = option{value: strings.TrimSpace(line[i+1:]), line: n}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read supervisord config: %w", err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return sections, nil
+}
+
+// stripComment removes a comment that starts a line, or that follows
+// whitespace.
+func stripComment(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#") {
+		return ""
+	}
+	for i := 1; i < len(line); i++ {
+		if (line[i] == ';' || line[i] == '#') && (line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+var expansionRe = regexp.MustCompile(`%%|%\(([^)]+)\)([-#0 +]*[0-9]*)([sd])`)
+
+// expand replaces %(name)s and %(name)d expansions, with Python's format
+// flags, and %% with %. ENV_X expands to the environment variable X.
+func expand(s string, vars map[string]string) (string, error) {
+	var err error
+	out := expansionRe.ReplaceAllStringFunc(s, func(m string) string {
+		if m == "%%" {
+			return "%"
+		}
+		sub := expansionRe.FindStringSubmatch(m)
+		name, flags, verb := sub[1], sub[2], sub[3]
+		v, ok := vars[name]
+		if !ok && strings.HasPrefix(name, "ENV_") {
+			v, ok = os.LookupEnv(strings.TrimPrefix(name, "ENV_"))
+		}
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("unknown expansion %s", m)
+			}
+			return m
+		}
+		if verb == "d" {
+			n, convErr := strconv.Atoi(v)
+			if convErr != nil {
+				if err == nil {
+					err = fmt.Errorf("%s expands to %q, which is not a number", m, v)
+				}
+				return m
+			}
+			return fmt.Sprintf("%"+flags+"d", n)
+		}
+		return fmt.Sprintf("%"+flags+"s", v)
+	})
+	return out, err
+}
+
+// parseEnvironment parses KEY="value",KEY2=value2.
+func parseEnvironment(s string) (map[string]string, error) {
+	env := make(map[string]string)
+	rest := strings.TrimSpace(s)
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("expected KEY=value, got %q", rest)
+		}
+		key := strings.TrimSpace(rest[:eq])
+		rest = strings.TrimLeft(rest[eq+1:], " \t\n")
+
+		var value string
+		if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+			end := strings.IndexByte(rest[1:], rest[0])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in the value of %s", key)
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = strings.TrimSpace(rest[:end]), rest[end:]
+		}
+		env[key] = value
+
+		rest = strings.TrimSpace(rest)
+		if rest != "" {
+			if rest[0] != ',' {
+				return nil, fmt.Errorf("expected a comma after the value of %s", key)
+			}
+			rest = strings.TrimSpace(rest[1:])
+		}
+	}
+	return env, nil
+}
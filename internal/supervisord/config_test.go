@@ -0,0 +1,144 @@
+package supervisord
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func hasWarning(warnings []Warning, message string) bool {
+	for _, w := range warnings {
+		if strings.Contains(w.Message, message) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "supervisord.conf", `; main config
+[supervisord]
+logfile=/tmp/supervisord.log
+environment=SHARED="base",BASE=1
+
+[program:api]
+command=/usr/bin/api
+    --verbose ; continues the command
+directory=%(here)s/api
+environment=SHARED="a,b", EXTRA='x y'
+autorestart=true
+redirect_stderr=yes
+stdout_logfile=%(here)s/%(program_name)s.log
+stopsignal=INT
+umask=022
+stopwaitsecs=30
+
+[program:worker]
+command=worker --id %(process_num)d
+process_name=%(program_name)s_%(process_num)02d
+numprocs=2
+numprocs_start=1
+autostart=false
+
+[group:all]
+programs=api,worker
+
+[include]
+files = conf.d/*.conf
+`)
+	writeConfig(t, dir, "conf.d/extra.conf", "[program:extra]\ncommand=true\nexitcodes=0,2\n")
+
+	cfg, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	var names []string
+	for _, p := range cfg.Programs {
+		names = append(names, p.Name)
+	}
+	if want := []string{"api", "worker_01", "worker_02", "extra"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("program names = %v, want %v", names, want)
+	}
+
+	api := cfg.Programs[0]
+	if api.Command != "/usr/bin/api\n--verbose" || api.Directory != filepath.Join(dir, "api") {
+		t.Errorf("Command, Directory = %q, %q", api.Command, api.Directory)
+	}
+	wantEnv := map[string]string{"SHARED": "a,b", "EXTRA": "x y", "BASE": "1"}
+	if !reflect.DeepEqual(api.Environment, wantEnv) {
+		t.Errorf("Environment = %v, want %v", api.Environment, wantEnv)
+	}
+	if api.AutoRestart != "true" || !api.RedirectStderr || !api.AutoStart || api.StopWaitSecs != 30 {
+		t.Errorf("api = %+v", api)
+	}
+	if api.Umask == nil || *api.Umask != 022 {
+		t.Errorf("Umask = %v, want 022", api.Umask)
+	}
+	if api.StdoutLogfile != filepath.Join(dir, "api.log") {
+		t.Errorf("StdoutLogfile = %q", api.StdoutLogfile)
+	}
+
+	worker := cfg.Programs[2]
+	if worker.Command != "worker --id 2" || worker.AutoStart || worker.AutoRestart != "unexpected" {
+		t.Errorf("worker = %+v", worker)
+	}
+
+	for _, want := range []string{
+		"stopsignal=INT: launchd always stops jobs with SIGTERM",
+		"[group:all] is not converted",
+		"exitcodes=0,2",
+	} {
+		if !hasWarning(cfg.Warnings, want) {
+			t.Errorf("no warning containing %q in %v", want, cfg.Warnings)
+		}
+	}
+	if hasWarning(cfg.Warnings, "supervisord") || hasWarning(cfg.Warnings, "numprocs") {
+		t.Errorf("server sections or expanded options were reported: %v", cfg.Warnings)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		config, wantErr string
+	}{
+		{"[program:a]\ndirectory=/tmp\n", "has no command"},
+		{"[program:a]\ncommand=a\nnumprocs=2\n", "must include %(process_num)"},
+		{"[program:a]\ncommand=%(nope)s\n", "unknown expansion %(nope)s"},
+		{"[program:a]\ncommand=a\nautorestart=sometimes\n", "is not true, false or unexpected"},
+		{"[program:a]\ncommand=a\nautostart=maybe\n", "is not a boolean"},
+		{"[program:a]\ncommand=a\n[program:a]\ncommand=b\n", "[program:a] is already defined"},
+		{"command=a\n", "outside a section"},
+		{"[program:a]\ncommand=a\nenvironment=A=\"open\n", "unterminated quote"},
+	}
+	for _, tt := range tests {
+		path := writeConfig(t, t.TempDir(), "supervisord.conf", tt.config)
+		_, err := Read(path)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Read(%q) error = %v, want %q", tt.config, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	t.Setenv("SUPERVISORD_TEST", "env")
+	vars := map[string]string{"program_name": "web", "process_num": "3"}
+	got, err := expand("%(program_name)s-%(process_num)03d-%(ENV_SUPERVISORD_TEST)s-100%%", vars)
+	if err != nil || got != "web-003-env-100%" {
+		t.Errorf("expand() = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,183 @@
+package supervisord
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// KeepAlive maps a supervisord autorestart value onto launchd's KeepAlive:
+// "true" restarts always, "unexpected" after a failing exit and "false"
+// never, which leaves KeepAlive unset.
+func KeepAlive(autorestart string) (interface{}, error) {
+	switch autorestart {
+	case "true":
+		return true, nil
+	case "unexpected":
+		return map[string]interface{}{"SuccessfulExit": false}, nil
+	case "false":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%q is not true, false or unexpected", autorestart)
+}
+
+// Plist converts the process into a launchd job with the given label. It
+// runs in the program's directory, or dir if it has none, with env
+// overridden by the program's environment. supervisord runs commands
+// without a shell, searching PATH for a bare program name, so the program
+// is resolved here; launchd's own PATH is minimal.
+//
+// AUTO log files are left unset, for the caller to choose, and NONE
+// discards the output.
+func (p *Program) Plist(label, dir string, env map[string]string) (*plist.LaunchAgentPlist, error) {
+	args, err := splitCommand(p.Command)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p.Name, err)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s: empty command", p.Name)
+	}
+
+	pl := &plist.LaunchAgentPlist{
+		Label:            label,
+		WorkingDirectory: dir,
+		RunAtLoad:        p.AutoStart,
+		ExitTimeOut:      p.StopWaitSecs,
+	}
+	if p.Directory != "" {
+		pl.WorkingDirectory = p.Directory
+	}
+
+	merged := make(map[string]string, len(env)+len(p.Environment))
+	for k, v := range env {
+		merged[k] = v
+	}
+	for k, v := range p.Environment {
+		merged[k] = v
+	}
+	if len(merged) > 0 {
+		pl.EnvironmentVariables = merged
+	}
+
+	if args[0], err = resolveProgram(args[0], pl.WorkingDirectory, merged["PATH"]); err != nil {
+		return nil, fmt.Errorf("%s: %w", p.Name, err)
+	}
+	pl.ProgramArguments = args
+
+	if pl.KeepAlive, err = KeepAlive(p.AutoRestart); err != nil {
+		return nil, fmt.Errorf("%s: autorestart: %w", p.Name, err)
+	}
+	if p.Umask != nil {
+		pl.Umask = *p.Umask
+	}
+
+	pl.StandardOutPath = logfile(p.StdoutLogfile)
+	if p.RedirectStderr {
+		pl.StandardErrorPath = pl.StandardOutPath
+	} else {
+		pl.StandardErrorPath = logfile(p.StderrLogfile)
+	}
+	return pl, nil
+}
+
+// logfile maps a *_logfile value onto a plist path.
+func logfile(v string) string {
+	switch strings.ToUpper(v) {
+	case "", "AUTO":
+		return ""
+	case "NONE":
+		return "/dev/null"
+	}
+	if v == "~" || strings.HasPrefix(v, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return home + v[1:]
+		}
+	}
+	return v
+}
+
+// resolveProgram makes a program path absolute: a relative path against
+// dir, and a bare name by searching path, or this process's PATH if path
+// is empty.
+func resolveProgram(name, dir, path string) (string, error) {
+	if filepath.IsAbs(name) {
+		return name, nil
+	}
+	if strings.Contains(name, "/") {
+		if dir == "" {
+			return "", fmt.Errorf("%s is relative, but the program has no directory", name)
+		}
+		return filepath.Join(dir, name), nil
+	}
+	if path == "" {
+		path = os.Getenv("PATH")
+	}
+	for _, d := range filepath.SplitList(path) {
+		candidate := filepath.Join(d, name)
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %w", name, exec.ErrNotFound)
+}
+
+// splitCommand splits a command line the way Python's shlex.split does:
+// on whitespace, honoring single quotes, double quotes and backslash
+// escapes.
+func splitCommand(s string) ([]string, error) {
+	var (
+		words  []string
+		word   strings.Builder
+		quote  byte
+		inWord bool
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteByte(c)
+			}
+		case c == '\\' && i+1 < len(s):
+			// Inside double quotes, a backslash only escapes \, " and $.
+			if quote == '"' && !strings.ContainsRune(`\"$`, rune(s[i+1])) {
+				word.WriteByte(c)
+				continue
+			}
+			i++
+			word.WriteByte(s[i])
+			inWord = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				word.WriteByte(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
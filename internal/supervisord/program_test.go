@@ -0,0 +1,79 @@
+package supervisord
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProgramPlist(t *testing.T) {
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "api"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	umask := 022
+	p := Program{
+		Name:           "api",
+		Command:        `api --name "my api" 'single quoted' back\ slash`,
+		Environment:    map[string]string{"MODE": "prod"},
+		AutoStart:      true,
+		AutoRestart:    "unexpected",
+		StopWaitSecs:   30,
+		Umask:          &umask,
+		StdoutLogfile:  "NONE",
+		RedirectStderr: true,
+	}
+	env := map[string]string{"MODE": "dev", "PATH": bin}
+	pl, err := p.Plist("com.example.api", "/srv", env)
+	if err != nil {
+		t.Fatalf("Plist() error = %v", err)
+	}
+	wantArgs := []string{filepath.Join(bin, "api"), "--name", "my api", "single quoted", "back slash"}
+	if !reflect.DeepEqual(pl.ProgramArguments, wantArgs) {
+		t.Errorf("ProgramArguments = %q, want %q", pl.ProgramArguments, wantArgs)
+	}
+	if pl.WorkingDirectory != "/srv" || !pl.RunAtLoad || pl.ExitTimeOut != 30 || pl.Umask != 022 {
+		t.Errorf("plist = %+v", pl)
+	}
+	if pl.EnvironmentVariables["MODE"] != "prod" || pl.EnvironmentVariables["PATH"] != bin {
+		t.Errorf("EnvironmentVariables = %v, want the program's MODE over the base", pl.EnvironmentVariables)
+	}
+	if ka, ok := pl.KeepAlive.(map[string]interface{}); !ok || ka["SuccessfulExit"] != false {
+		t.Errorf("KeepAlive = %v, want SuccessfulExit false", pl.KeepAlive)
+	}
+	if pl.StandardOutPath != "/dev/null" || pl.StandardErrorPath != "/dev/null" {
+		t.Errorf("StandardOutPath, StandardErrorPath = %q, %q", pl.StandardOutPath, pl.StandardErrorPath)
+	}
+
+	p.Command = "./bin/api"
+	p.Directory = "/opt/app"
+	if pl, err := p.Plist("com.example.api", "/srv", nil); err != nil || pl.ProgramArguments[0] != "/opt/app/bin/api" || pl.WorkingDirectory != "/opt/app" {
+		t.Errorf("relative command: plist = %+v, %v", pl, err)
+	}
+
+	p.Command = "no-such-program-anywhere"
+	if _, err := p.Plist("com.example.api", "/srv", map[string]string{"PATH": bin}); err == nil {
+		t.Error("Plist() with an unknown program: error = nil")
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	tests := []struct {
+		in   string
+		want interface{}
+	}{
+		{"true", true},
+		{"false", nil},
+		{"unexpected", map[string]interface{}{"SuccessfulExit": false}},
+	}
+	for _, tt := range tests {
+		got, err := KeepAlive(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("KeepAlive(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := KeepAlive("always"); err == nil {
+		t.Error("KeepAlive(always) error = nil")
+	}
+}
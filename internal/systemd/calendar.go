@@ -0,0 +1,144 @@
+package systemd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lu-zhengda/lanchr/internal/cron"
+)
+
+// calendarShorthands expands the named OnCalendar= expressions.
+var calendarShorthands = map[string]string{
+	"minutely":     "*-*-* *:*:00",
+	"hourly":       "*-*-* *:00:00",
+	"daily":        "*-*-* 00:00:00",
+	"weekly":       "Mon *-*-* 00:00:00",
+	"monthly":      "*-*-01 00:00:00",
+	"yearly":       "*-01-01 00:00:00",
+	"annually":     "*-01-01 00:00:00",
+	"quarterly":    "*-01,04,07,10-01 00:00:00",
+	"semiannually": "*-01,07-01 00:00:00",
+}
+
+var weekdayNames = map[string]int{
+	"sun": 0, "sunday": 0,
+	"mon": 1, "monday": 1,
+	"tue": 2, "tuesday": 2,
+	"wed": 3, "wednesday": 3,
+	"thu": 4, "thursday": 4,
+	"fri": 5, "friday": 5,
+	"sat": 6, "saturday": 6,
+}
+
+// weekdayRe matches the weekday component of a calendar expression.
+var weekdayRe = regexp.MustCompile(`^[A-Za-z]+((\.\.|-)[A-Za-z]+)?(,[A-Za-z]+((\.\.|-)[A-Za-z]+)?)*$`)
+
+// parseCalendar converts an OnCalendar= expression ("Mon..Fri *-*-*
+// 09:00:00", "*:0/15", "daily") into StartCalendarInterval entries. launchd
+// has no years, seconds or time zones; those parts are dropped and reported
+// in the returned notes.
+func parseCalendar(expr string) ([]map[string]int, []string, error) {
+	expr = strings.TrimSpace(expr)
+	if full, ok := calendarShorthands[strings.ToLower(expr)]; ok {
+		expr = full
+	}
+	if strings.Contains(expr, "~") {
+		return nil, nil, fmt.Errorf("last-day-of-month (~) has no launchd equivalent")
+	}
+
+	var (
+		notes              []string
+		weekday            = "*"
+		date               = "*-*-*"
+		clock              = "00:00:00"
+		haveDate, haveTime bool
+	)
+	for i, tok := range strings.Fields(expr) {
+		switch {
+		case i == 0 && weekdayRe.MatchString(tok):
+			days, err := convertWeekdays(tok)
+			if err != nil {
+				return nil, nil, err
+			}
+			weekday = days
+		case !haveDate && !haveTime && strings.Contains(tok, "-") && !strings.Contains(tok, ":"):
+			date, haveDate = tok, true
+		case !haveTime && strings.Contains(tok, ":"):
+			clock, haveTime = tok, true
+		case haveDate || haveTime:
+			notes = append(notes, fmt.Sprintf("time zone %s is ignored; launchd uses local time", tok))
+		default:
+			return nil, nil, fmt.Errorf("cannot parse %q in calendar expression %q", tok, expr)
+		}
+	}
+
+	dateParts := strings.Split(date, "-")
+	switch len(dateParts) {
+	case 2:
+		dateParts = append([]string{"*"}, dateParts...)
+	case 3:
+	default:
+		return nil, nil, fmt.Errorf("invalid date %q", date)
+	}
+	if dateParts[0] != "*" {
+		notes = append(notes, fmt.Sprintf("year %s is ignored; launchd has no years", dateParts[0]))
+	}
+	month, day := calendarField(dateParts[1]), calendarField(dateParts[2])
+
+	timeParts := strings.Split(clock, ":")
+	switch len(timeParts) {
+	case 2:
+	case 3:
+		if sec := timeParts[2]; sec != "00" && sec != "0" {
+			notes = append(notes, fmt.Sprintf("seconds %s are ignored; launchd runs on the minute", sec))
+		}
+	default:
+		return nil, nil, fmt.Errorf("invalid time %q", clock)
+	}
+	hour, minute := calendarField(timeParts[0]), calendarField(timeParts[1])
+
+	if weekday != "*" && day != "*" {
+		return nil, nil, fmt.Errorf("launchd cannot require both a day of the month (%s) and a day of the week", day)
+	}
+	spec, err := cron.Parse(strings.Join([]string{minute, hour, day, month, weekday}, " "))
+	if err != nil {
+		return nil, nil, err
+	}
+	return spec.Calendar, notes, nil
+}
+
+// calendarField rewrites a systemd calendar component as a cron field:
+// ranges are written "a..b" in systemd and "a-b" in cron.
+func calendarField(s string) string {
+	return strings.ReplaceAll(s, "..", "-")
+}
+
+// convertWeekdays rewrites a weekday list such as "Mon..Fri,Sun" as a
+// cron weekday field.
+func convertWeekdays(s string) (string, error) {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(strings.ReplaceAll(item, "..", "-"), "-")
+		a, ok := weekdayNames[strings.ToLower(from)]
+		if !ok {
+			return "", fmt.Errorf("unknown weekday %q", from)
+		}
+		if !isRange {
+			out = append(out, strconv.Itoa(a))
+			continue
+		}
+		b, ok := weekdayNames[strings.ToLower(to)]
+		if !ok {
+			return "", fmt.Errorf("unknown weekday %q", to)
+		}
+		if b < a {
+			// systemd ranges may wrap past Sunday, as in Sat..Mon.
+			out = append(out, fmt.Sprintf("%d-6", a), fmt.Sprintf("0-%d", b))
+			continue
+		}
+		out = append(out, fmt.Sprintf("%d-%d", a, b))
+	}
+	return strings.Join(out, ","), nil
+}
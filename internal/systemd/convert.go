@@ -0,0 +1,587 @@
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lu-zhengda/lanchr/internal/cron"
+	"github.com/lu-zhengda/lanchr/internal/envfile"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// Warning reports a directive that was dropped or only approximated.
+type Warning struct {
+	Unit      string
+	Line      int
+	Directive string
+	Message   string
+}
+
+// String formats the warning as "unit:line: Key=Value: message", or
+// "unit: Key: message" for warnings about a plist, which have no line.
+func (w Warning) String() string {
+	if w.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", w.Unit, w.Directive, w.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", w.Unit, w.Line, w.Directive, w.Message)
+}
+
+// resourceLimits maps systemd Limit*= directives to launchd resource
+// limit keys.
+var resourceLimits = map[string]string{
+	"LimitCPU":     "CPU",
+	"LimitFSIZE":   "FileSize",
+	"LimitDATA":    "Data",
+	"LimitSTACK":   "Stack",
+	"LimitCORE":    "Core",
+	"LimitRSS":     "ResidentSetSize",
+	"LimitNOFILE":  "NumberOfFiles",
+	"LimitNPROC":   "NumberOfProcesses",
+	"LimitMEMLOCK": "MemoryLock",
+}
+
+// converter accumulates the plist and warnings of one conversion.
+type converter struct {
+	pl       *plist.LaunchAgentPlist
+	warnings []Warning
+	service  *Unit
+	// stdout and stderr hold StandardOutput= and StandardError= until
+	// both are known, since stderr defaults to stdout.
+	stdout, stderr *Directive
+}
+
+// ToPlist converts a service unit, and the timer that triggers it if any,
+// into a launchd job with the given label. Directives with no launchd
+// equivalent, or only an approximate one, are returned as warnings rather
+// than dropped silently.
+//
+// ExecStart= runs directly, as under systemd: ${VAR} and $VAR are expanded
+// from Environment= and EnvironmentFile=, and the %h, %u, %n, %N, %i and
+// %% specifiers are resolved for the converting user, since launchd does
+// neither.
+func ToPlist(service, timer *Unit, label string) (*plist.LaunchAgentPlist, []Warning, error) {
+	c := &converter{pl: &plist.LaunchAgentPlist{Label: label}, service: service}
+
+	// The environment comes first, since ExecStart= refers to it.
+	for _, d := range service.Directives {
+		if d.Section != "Service" {
+			continue
+		}
+		var err error
+		switch d.Key {
+		case "Environment":
+			err = c.environment(d)
+		case "EnvironmentFile":
+			err = c.environmentFile(d)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %s: %w", service.Name, d.Line, d.Key, err)
+		}
+	}
+
+	var exec []Directive
+	for _, d := range service.Directives {
+		var err error
+		switch d.Section {
+		case "Service":
+			if d.Key == "ExecStart" {
+				if d.Value == "" {
+					exec = nil // an empty assignment resets the list
+				} else {
+					exec = append(exec, d)
+				}
+				continue
+			}
+			err = c.serviceDirective(d)
+		case "Install":
+			c.installDirective(service, d, timer == nil)
+		default:
+			c.warn(service, d, "has no launchd equivalent")
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %s: %w", service.Name, d.Line, d.Key, err)
+		}
+	}
+
+	if len(exec) == 0 {
+		return nil, nil, fmt.Errorf("%s: no ExecStart=", service.Name)
+	}
+	if err := c.execStart(exec[0]); err != nil {
+		return nil, nil, fmt.Errorf("%s:%d: ExecStart: %w", service.Name, exec[0].Line, err)
+	}
+	for _, d := range exec[1:] {
+		c.warn(service, d, "launchd runs a single program; only the first ExecStart= is used")
+	}
+	c.outputs()
+
+	if timer != nil {
+		if err := c.convertTimer(timer); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sort.SliceStable(c.warnings, func(i, j int) bool {
+		if c.warnings[i].Unit != c.warnings[j].Unit {
+			return c.warnings[i].Unit == service.Name
+		}
+		return c.warnings[i].Line < c.warnings[j].Line
+	})
+	return c.pl, c.warnings, nil
+}
+
+func (c *converter) warn(u *Unit, d Directive, format string, args ...interface{}) {
+	c.warnings = append(c.warnings, Warning{
+		Unit:      u.Name,
+		Line:      d.Line,
+		Directive: d.Key + "=" + d.Value,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *converter) setEnv(key, value string) {
+	if c.pl.EnvironmentVariables == nil {
+		c.pl.EnvironmentVariables = make(map[string]string)
+	}
+	c.pl.EnvironmentVariables[key] = value
+}
+
+// environment handles Environment="A=1" "B=2 3".
+func (c *converter) environment(d Directive) error {
+	words, err := splitWords(d.Value)
+	if err != nil {
+		return err
+	}
+	for _, w := range words {
+		key, value, ok := strings.Cut(w, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("%q is not KEY=VALUE", w)
+		}
+		c.setEnv(key, value)
+	}
+	return nil
+}
+
+// environmentFile inlines the variables of an EnvironmentFile=, which
+// launchd has no equivalent for. A file that is missing here, as it often
+// is on another machine, is reported rather than fatal.
+func (c *converter) environmentFile(d Directive) error {
+	path := strings.TrimPrefix(d.Value, "-")
+	env, err := envfile.Read(c.specifiers(path))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) || strings.HasPrefix(d.Value, "-") {
+			c.warn(c.service, d, "could not be read here, so its variables are missing: %v", err)
+			return nil
+		}
+		return err
+	}
+	for k, v := range env {
+		c.setEnv(k, v)
+	}
+	return nil
+}
+
+// execStart sets the program from an ExecStart= command line.
+func (c *converter) execStart(d Directive) error {
+	line := d.Value
+	var prefixes string
+	for len(line) > 0 && strings.ContainsRune("@-:+!", rune(line[0])) {
+		prefixes += line[:1]
+		line = line[1:]
+	}
+	words, err := splitWords(line)
+	if err != nil {
+		return err
+	}
+
+	var args []string
+	for _, w := range words {
+		w = c.specifiers(w)
+		if strings.Contains(prefixes, ":") {
+			args = append(args, w)
+			continue
+		}
+		args = append(args, c.expandVars(d, w)...)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("empty command")
+	}
+
+	if strings.Contains(prefixes, "@") {
+		// "@/bin/prog name args" runs /bin/prog with argv[0] set to name.
+		if len(args) < 2 {
+			return fmt.Errorf("@ needs a program and an argv[0]")
+		}
+		c.pl.Program = args[0]
+		args = args[1:]
+	}
+	c.pl.ProgramArguments = args
+
+	if strings.Contains(prefixes, "-") {
+		c.warn(c.service, d, "the - prefix (ignore a failing exit status) has no launchd equivalent")
+	}
+	if strings.ContainsAny(prefixes, "+!") {
+		c.warn(c.service, d, "the + and ! privilege prefixes have no launchd equivalent")
+	}
+	return nil
+}
+
+// varRe matches what systemd substitutes within a word: ${VAR}, and $$,
+// which stands for a literal $.
+var varRe = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// wordVarRe matches a word that is exactly $VAR.
+var wordVarRe = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)$`)
+
+// expandVars expands ${VAR} within a word and a word that is exactly $VAR,
+// which systemd splits on whitespace, and turns $$ into $. Other uses of $
+// are kept as they are. Unknown variables are left as they are and
+// reported.
+func (c *converter) expandVars(d Directive, w string) []string {
+	env := c.pl.EnvironmentVariables
+	if m := wordVarRe.FindStringSubmatch(w); m != nil {
+		if v, ok := env[m[1]]; ok {
+			return strings.Fields(v)
+		}
+		c.warn(c.service, d, "%s is not set by Environment= or EnvironmentFile= and is left unexpanded", w)
+		return []string{w}
+	}
+	return []string{varRe.ReplaceAllStringFunc(w, func(m string) string {
+		if m == "$$" {
+			return "$"
+		}
+		name := varRe.FindStringSubmatch(m)[1]
+		if v, ok := env[name]; ok {
+			return v
+		}
+		c.warn(c.service, d, "%s is not set by Environment= or EnvironmentFile= and is left unexpanded", m)
+		return m
+	})}
+}
+
+// specifiers resolves the unit specifiers launchd cannot.
+func (c *converter) specifiers(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	name := c.service.Name
+	prefix := strings.TrimSuffix(name, "."+c.service.Kind())
+	instance := ""
+	if i := strings.Index(prefix, "@"); i >= 0 {
+		instance = prefix[i+1:]
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case '%':
+			b.WriteByte('%')
+		case 'n':
+			b.WriteString(name)
+		case 'N':
+			b.WriteString(prefix)
+		case 'i', 'I':
+			b.WriteString(instance)
+		case 'h':
+			home, _ := os.UserHomeDir()
+			b.WriteString(home)
+		case 'u':
+			if u, err := user.Current(); err == nil {
+				b.WriteString(u.Username)
+			}
+		default:
+			b.WriteByte('%')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// expandPath resolves specifiers and a leading ~ in a path directive.
+func (c *converter) expandPath(p string) string {
+	p = c.specifiers(p)
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, _ := os.UserHomeDir()
+		p = home + p[1:]
+	}
+	return p
+}
+
+// serviceDirective maps one [Service] directive.
+func (c *converter) serviceDirective(d Directive) error {
+	if key, ok := resourceLimits[d.Key]; ok {
+		return c.resourceLimit(d, key)
+	}
+	switch d.Key {
+	case "Environment", "EnvironmentFile":
+		// Handled first.
+	case "Type":
+		switch d.Value {
+		case "simple", "exec", "oneshot", "idle":
+		case "notify", "notify-reload", "dbus":
+			c.warn(c.service, d, "launchd does not wait for readiness; treated as Type=simple")
+		default:
+			c.warn(c.service, d, "launchd expects the program to stay in the foreground")
+		}
+	case "WorkingDirectory":
+		c.pl.WorkingDirectory = c.expandPath(strings.TrimPrefix(d.Value, "-"))
+	case "RootDirectory":
+		c.pl.RootDirectory = c.expandPath(d.Value)
+	case "User":
+		c.pl.UserName = d.Value
+	case "Group":
+		c.pl.GroupName = d.Value
+	case "Nice":
+		n, err := strconv.Atoi(d.Value)
+		if err != nil {
+			return fmt.Errorf("invalid nice value %q", d.Value)
+		}
+		c.pl.Nice = n
+	case "UMask":
+		n, err := strconv.ParseInt(d.Value, 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid umask %q", d.Value)
+		}
+		c.pl.Umask = int(n)
+	case "Restart":
+		c.restart(d)
+	case "RestartSec":
+		span, err := parseTimespan(d.Value)
+		if err != nil {
+			return err
+		}
+		c.pl.ThrottleInterval = seconds(span)
+		if c.pl.ThrottleInterval < 10 {
+			c.warn(c.service, d, "launchd waits at least ThrottleInterval between launches, and by default 10 seconds")
+		}
+	case "TimeoutStopSec", "TimeoutSec":
+		span, err := parseTimespan(d.Value)
+		if err != nil {
+			return err
+		}
+		c.pl.ExitTimeOut = seconds(span)
+		if d.Key == "TimeoutSec" {
+			c.warn(c.service, d, "only the stop timeout maps to ExitTimeOut; launchd has no start timeout")
+		}
+	case "StandardOutput":
+		dd := d
+		c.stdout = &dd
+	case "StandardError":
+		dd := d
+		c.stderr = &dd
+	case "StandardInput":
+		switch {
+		case strings.HasPrefix(d.Value, "file:"):
+			c.pl.StandardInPath = c.expandPath(strings.TrimPrefix(d.Value, "file:"))
+		case d.Value == "null":
+		default:
+			c.warn(c.service, d, "only file: and null have a launchd equivalent")
+		}
+	default:
+		c.warn(c.service, d, "has no launchd equivalent")
+	}
+	return nil
+}
+
+// restart maps Restart= onto KeepAlive.
+func (c *converter) restart(d Directive) {
+	switch d.Value {
+	case "no":
+	case "always":
+		c.pl.KeepAlive = true
+	case "on-failure":
+		c.pl.KeepAlive = map[string]interface{}{"SuccessfulExit": false}
+	case "on-success":
+		c.pl.KeepAlive = map[string]interface{}{"SuccessfulExit": true}
+	case "on-abort":
+		c.pl.KeepAlive = map[string]interface{}{"Crashed": true}
+	case "on-abnormal", "on-watchdog":
+		c.pl.KeepAlive = map[string]interface{}{"Crashed": true}
+		c.warn(c.service, d, "approximated as KeepAlive.Crashed; launchd has no timeouts or watchdog")
+	default:
+		c.warn(c.service, d, "unknown restart policy")
+	}
+}
+
+// resourceLimit maps a Limit*= directive, "soft:hard" or one value for
+// both, onto the resource limit dictionaries.
+func (c *converter) resourceLimit(d Directive, key string) error {
+	soft, hard, split := strings.Cut(d.Value, ":")
+	if !split {
+		hard = soft
+	}
+	for _, l := range []struct {
+		value  string
+		limits *map[string]int
+	}{{soft, &c.pl.SoftResourceLimits}, {hard, &c.pl.HardResourceLimits}} {
+		if l.value == "infinity" {
+			continue
+		}
+		n, err := parseSize(l.value)
+		if err != nil {
+			return err
+		}
+		if *l.limits == nil {
+			*l.limits = make(map[string]int)
+		}
+		(*l.limits)[key] = n
+	}
+	return nil
+}
+
+// parseSize parses a number with an optional K, M, G or T suffix (powers
+// of 1024), as systemd resource limits take.
+func parseSize(s string) (int, error) {
+	mult := 1
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		case 'T':
+			mult = 1 << 40
+		}
+		if mult != 1 {
+			s = s[:n-1]
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid limit %q", s)
+	}
+	return n * mult, nil
+}
+
+// outputs maps StandardOutput= and StandardError=. Error output that is
+// unset or "inherit" goes where standard output goes.
+func (c *converter) outputs() {
+	out := c.outputPath(c.stdout)
+	c.pl.StandardOutPath = out
+	if c.stderr == nil || c.stderr.Value == "inherit" {
+		c.pl.StandardErrorPath = out
+		return
+	}
+	c.pl.StandardErrorPath = c.outputPath(c.stderr)
+}
+
+func (c *converter) outputPath(d *Directive) string {
+	if d == nil {
+		return ""
+	}
+	kind, path, _ := strings.Cut(d.Value, ":")
+	switch kind {
+	case "file", "append":
+		return c.expandPath(path)
+	case "truncate":
+		c.warn(c.service, *d, "launchd appends to the file instead of truncating it")
+		return c.expandPath(path)
+	case "null":
+		return "/dev/null"
+	}
+	c.warn(c.service, *d, "launchd can only write output to a file; set StandardOutPath or StandardErrorPath")
+	return ""
+}
+
+// installDirective maps [Install]. Being wanted by a target means the
+// service starts at boot, which is RunAtLoad; with a timer, the timer's
+// [Install] section decides instead.
+func (c *converter) installDirective(u *Unit, d Directive, startsService bool) {
+	switch d.Key {
+	case "WantedBy", "RequiredBy":
+		if startsService {
+			c.pl.RunAtLoad = true
+		}
+	default:
+		c.warn(u, d, "has no launchd equivalent")
+	}
+}
+
+// convertTimer maps the [Timer] section onto the launchd schedule.
+func (c *converter) convertTimer(timer *Unit) error {
+	var (
+		interval   time.Duration
+		intervalAt Directive
+		calendar   []map[string]int
+	)
+	for _, d := range timer.Directives {
+		if d.Section == "Install" {
+			c.installDirective(timer, d, false)
+			continue
+		}
+		if d.Section != "Timer" {
+			c.warn(timer, d, "has no launchd equivalent")
+			continue
+		}
+
+		var err error
+		switch d.Key {
+		case "OnCalendar":
+			var (
+				entries []map[string]int
+				notes   []string
+			)
+			entries, notes, err = parseCalendar(d.Value)
+			calendar = append(calendar, entries...)
+			for _, n := range notes {
+				c.warn(timer, d, "%s", n)
+			}
+		case "OnUnitActiveSec", "OnUnitInactiveSec":
+			var span time.Duration
+			if span, err = parseTimespan(d.Value); err != nil {
+				break
+			}
+			if d.Key == "OnUnitInactiveSec" {
+				c.warn(timer, d, "approximated as StartInterval, which counts from each start rather than each exit")
+			}
+			switch {
+			case interval == 0:
+				interval, intervalAt = span, d
+			case span < interval:
+				c.warn(timer, intervalAt, "launchd has one StartInterval; the shorter %s is used", d.Key)
+				interval, intervalAt = span, d
+			default:
+				c.warn(timer, d, "launchd has one StartInterval; the shorter %s is used", intervalAt.Key)
+			}
+		case "OnBootSec", "OnStartupSec", "OnActiveSec":
+			var span time.Duration
+			if span, err = parseTimespan(d.Value); err == nil {
+				c.pl.RunAtLoad = true
+				if span > 0 {
+					c.warn(timer, d, "approximated as RunAtLoad; launchd cannot delay the first run")
+				}
+			}
+		case "Persistent":
+			c.warn(timer, d, "launchd runs calendar jobs missed during sleep on wake, but not those missed while powered off")
+		case "Unit":
+			if d.Value != c.service.Name {
+				c.warn(timer, d, "the timer triggers another unit than %s", c.service.Name)
+			}
+		default:
+			c.warn(timer, d, "has no launchd equivalent")
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", timer.Name, d.Line, d.Key, err)
+		}
+	}
+
+	c.pl.StartInterval = seconds(interval)
+	if len(calendar) == 0 && interval == 0 && !c.pl.RunAtLoad {
+		return fmt.Errorf("%s: no OnCalendar=, OnUnitActiveSec= or OnBootSec= to schedule the service", timer.Name)
+	}
+	c.pl.StartCalendarInterval = cron.CalendarValue(calendar)
+	return nil
+}
@@ -0,0 +1,248 @@
+package systemd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, name, content string) *Unit {
+	t.Helper()
+	u, err := ParseUnit(name, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func hasWarning(warnings []Warning, directive, message string) bool {
+	for _, w := range warnings {
+		if strings.HasPrefix(w.Directive, directive) && strings.Contains(w.Message, message) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestToPlist(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	envPath := filepath.Join(home, "web.env")
+	if err := os.WriteFile(envPath, []byte("PORT=8080\n# comment\nMODE=prod\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	service := mustParse(t, "web@blue.service", `[Unit]
+Description=Web
+
+[Service]
+Type=notify
+Environment="GREETING=hello world" MODE=dev
+EnvironmentFile=`+envPath+`
+EnvironmentFile=-/nonexistent/web.env
+ExecStart=-/usr/bin/web --port ${PORT} --mode=${MODE} $GREETING --name %i --missing ${NOPE}
+ExecStart=/usr/bin/second
+WorkingDirectory=%h/srv
+User=www
+Group=staff
+Nice=5
+UMask=0027
+Restart=on-abnormal
+RestartSec=2
+TimeoutStopSec=1min
+StandardOutput=file:/var/log/web.log
+StandardError=journal
+LimitNOFILE=1024
+ProtectSystem=strict
+
+[Install]
+WantedBy=multi-user.target
+Alias=www.service
+`)
+	pl, warnings, err := ToPlist(service, nil, "com.example.web")
+	if err != nil {
+		t.Fatalf("ToPlist() error = %v", err)
+	}
+
+	wantArgs := []string{"/usr/bin/web", "--port", "8080", "--mode=prod", "hello", "world", "--name", "blue", "--missing", "${NOPE}"}
+	if !reflect.DeepEqual(pl.ProgramArguments, wantArgs) {
+		t.Errorf("ProgramArguments = %q, want %q", pl.ProgramArguments, wantArgs)
+	}
+	if pl.EnvironmentVariables["MODE"] != "prod" || pl.EnvironmentVariables["GREETING"] != "hello world" {
+		t.Errorf("EnvironmentVariables = %v, want the env file to override Environment=", pl.EnvironmentVariables)
+	}
+	if pl.WorkingDirectory != filepath.Join(home, "srv") {
+		t.Errorf("WorkingDirectory = %q", pl.WorkingDirectory)
+	}
+	if pl.UserName != "www" || pl.GroupName != "staff" || pl.Nice != 5 || pl.Umask != 027 {
+		t.Errorf("UserName, GroupName, Nice, Umask = %q, %q, %d, %v", pl.UserName, pl.GroupName, pl.Nice, pl.Umask)
+	}
+	if ka, ok := pl.KeepAlive.(map[string]interface{}); !ok || ka["Crashed"] != true {
+		t.Errorf("KeepAlive = %v, want Crashed", pl.KeepAlive)
+	}
+	if pl.ThrottleInterval != 2 || pl.ExitTimeOut != 60 {
+		t.Errorf("ThrottleInterval, ExitTimeOut = %d, %d", pl.ThrottleInterval, pl.ExitTimeOut)
+	}
+	if pl.StandardOutPath != "/var/log/web.log" || pl.StandardErrorPath != "" {
+		t.Errorf("StandardOutPath, StandardErrorPath = %q, %q", pl.StandardOutPath, pl.StandardErrorPath)
+	}
+	if pl.SoftResourceLimits["NumberOfFiles"] != 1024 || pl.HardResourceLimits["NumberOfFiles"] != 1024 {
+		t.Errorf("resource limits = %v, %v", pl.SoftResourceLimits, pl.HardResourceLimits)
+	}
+	if !pl.RunAtLoad {
+		t.Error("RunAtLoad = false, want true from WantedBy=")
+	}
+
+	for _, w := range []struct{ directive, message string }{
+		{"Description", "no launchd equivalent"},
+		{"Type", "readiness"},
+		{"EnvironmentFile=-/nonexistent", "could not be read"},
+		{"ExecStart=-/usr/bin/web", "${NOPE} is not set"},
+		{"ExecStart=-/usr/bin/web", "the - prefix"},
+		{"ExecStart=/usr/bin/second", "only the first"},
+		{"Restart", "approximated"},
+		{"RestartSec", "ThrottleInterval"},
+		{"StandardError", "only write output to a file"},
+		{"ProtectSystem", "no launchd equivalent"},
+		{"Alias", "no launchd equivalent"},
+	} {
+		if !hasWarning(warnings, w.directive, w.message) {
+			t.Errorf("no warning for %s containing %q in %v", w.directive, w.message, warnings)
+		}
+	}
+	if hasWarning(warnings, "User", "") || hasWarning(warnings, "WantedBy", "") {
+		t.Errorf("mapped directives were reported: %v", warnings)
+	}
+	for i := 1; i < len(warnings); i++ {
+		if warnings[i].Line < warnings[i-1].Line {
+			t.Errorf("warnings are not in line order: %v", warnings)
+		}
+	}
+}
+
+func TestToPlistDollarEscape(t *testing.T) {
+	service := mustParse(t, "shell.service", `[Service]
+Environment=PORT=8080
+ExecStart=/bin/sh -c "echo $$PATH ${PORT}$$" $$HOME $$$${PORT} $PORT/api
+`)
+	pl, warnings, err := ToPlist(service, nil, "com.example.shell")
+	if err != nil {
+		t.Fatalf("ToPlist() error = %v", err)
+	}
+	// $$ is a literal $, never the start of a variable.
+	// A $VAR that is not a whole word is not substituted either.
+	wantArgs := []string{"/bin/sh", "-c", "echo $PATH 8080$", "$HOME", "$${PORT}", "$PORT/api"}
+	if !reflect.DeepEqual(pl.ProgramArguments, wantArgs) {
+		t.Errorf("ProgramArguments = %q, want %q", pl.ProgramArguments, wantArgs)
+	}
+	if hasWarning(warnings, "ExecStart", "is not set") {
+		t.Errorf("escaped dollars were reported as variables: %v", warnings)
+	}
+}
+
+func TestToPlistTimer(t *testing.T) {
+	service := mustParse(t, "backup.service", `[Service]
+Type=oneshot
+ExecStart=@/usr/bin/restic restic backup
+StandardOutput=append:/tmp/backup.log
+[Install]
+WantedBy=multi-user.target
+`)
+	timer := mustParse(t, "backup.timer", `[Timer]
+OnCalendar=Mon..Wed *-*-* 02:30:15
+OnCalendar=*-*-01 12:00 UTC
+OnUnitActiveSec=6h
+OnUnitInactiveSec=1h
+OnBootSec=5min
+AccuracySec=1s
+[Install]
+WantedBy=timers.target
+`)
+	pl, warnings, err := ToPlist(service, timer, "com.example.backup")
+	if err != nil {
+		t.Fatalf("ToPlist() error = %v", err)
+	}
+	if pl.Program != "/usr/bin/restic" || !reflect.DeepEqual(pl.ProgramArguments, []string{"restic", "backup"}) {
+		t.Errorf("Program, ProgramArguments = %q, %q", pl.Program, pl.ProgramArguments)
+	}
+	if pl.StandardErrorPath != "/tmp/backup.log" {
+		t.Errorf("StandardErrorPath = %q, want standard output's file", pl.StandardErrorPath)
+	}
+	cal, ok := pl.StartCalendarInterval.([]interface{})
+	if !ok || len(cal) != 4 {
+		t.Fatalf("StartCalendarInterval = %v, want 4 entries", pl.StartCalendarInterval)
+	}
+	if last := cal[3].(map[string]interface{}); last["Day"] != 1 || last["Hour"] != 12 {
+		t.Errorf("last calendar entry = %v", last)
+	}
+	if pl.StartInterval != 3600 || !pl.RunAtLoad {
+		t.Errorf("StartInterval, RunAtLoad = %d, %v", pl.StartInterval, pl.RunAtLoad)
+	}
+
+	for _, w := range []struct{ directive, message string }{
+		{"OnCalendar=Mon..Wed", "seconds 15"},
+		{"OnCalendar=*-*-01", "time zone UTC"},
+		{"OnUnitActiveSec", "the shorter OnUnitInactiveSec"},
+		{"OnUnitInactiveSec", "each exit"},
+		{"OnBootSec", "cannot delay"},
+		{"AccuracySec", "no launchd equivalent"},
+	} {
+		if !hasWarning(warnings, w.directive, w.message) {
+			t.Errorf("no warning for %s containing %q in %v", w.directive, w.message, warnings)
+		}
+	}
+}
+
+func TestToPlistErrors(t *testing.T) {
+	tests := []struct {
+		service, timer string
+		wantErr        string
+	}{
+		{"[Service]\nType=simple\n", "", "no ExecStart="},
+		{"[Service]\nExecStart=/bin/a\nExecStart=\n", "", "no ExecStart="},
+		{"[Service]\nExecStart=/bin/a \"open\n", "", "unterminated quote"},
+		{"[Service]\nExecStart=/bin/a\nRestartSec=soon\n", "", "invalid time span"},
+		{"[Service]\nExecStart=/bin/a\n", "[Timer]\nAccuracySec=1s\n", "no OnCalendar="},
+		{"[Service]\nExecStart=/bin/a\n", "[Timer]\nOnCalendar=Mon *-*-01\n", "cannot require both"},
+		{"[Service]\nExecStart=/bin/a\n", "[Timer]\nOnCalendar=*-02~01\n", "last-day-of-month"},
+		{"[Service]\nExecStart=/bin/a\n", "[Timer]\nOnCalendar=Funday 10:00\n", "unknown weekday"},
+	}
+	for _, tt := range tests {
+		var timer *Unit
+		if tt.timer != "" {
+			timer = mustParse(t, "a.timer", tt.timer)
+		}
+		_, _, err := ToPlist(mustParse(t, "a.service", tt.service), timer, "a")
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ToPlist(%q, %q) error = %v, want %q", tt.service, tt.timer, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseCalendar(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []map[string]int
+	}{
+		{"daily", []map[string]int{{"Hour": 0, "Minute": 0}}},
+		{"hourly", []map[string]int{{"Minute": 0}}},
+		{"weekly", []map[string]int{{"Weekday": 1, "Hour": 0, "Minute": 0}}},
+		{"*:0/20", []map[string]int{{"Minute": 0}, {"Minute": 20}, {"Minute": 40}}},
+		{"Sat..Sun 9:00", []map[string]int{{"Weekday": 0, "Hour": 9, "Minute": 0}, {"Weekday": 6, "Hour": 9, "Minute": 0}}},
+		{"*-01,07-01 06:00", []map[string]int{{"Month": 1, "Day": 1, "Hour": 6, "Minute": 0}, {"Month": 7, "Day": 1, "Hour": 6, "Minute": 0}}},
+		{"12-25", []map[string]int{{"Month": 12, "Day": 25, "Hour": 0, "Minute": 0}}},
+	}
+	for _, tt := range tests {
+		got, _, err := parseCalendar(tt.expr)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCalendar(%q) = %v, %v, want %v", tt.expr, got, err, tt.want)
+		}
+	}
+
+	_, notes, err := parseCalendar("2030-*-* 10:00")
+	if err != nil || len(notes) != 1 || !strings.Contains(notes[0], "year 2030") {
+		t.Errorf("parseCalendar with a year = %v, %v, want a note", notes, err)
+	}
+}
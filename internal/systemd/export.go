@@ -0,0 +1,442 @@
+package systemd
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lu-zhengda/lanchr/internal/agent"
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+// ExportOptions control FromPlist.
+type ExportOptions struct {
+	// Name is the base name of the units, without a suffix. It defaults
+	// to the label.
+	Name string
+	// System generates system units, started by multi-user.target and
+	// running as UserName, instead of user units for "systemctl --user".
+	System bool
+}
+
+// weekdayUnitNames are the OnCalendar= names of launchd weekdays 0-6.
+var weekdayUnitNames = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// unsupportedKeys are the modeled plist keys systemd has no equivalent for.
+var unsupportedKeys = []string{
+	"BundleProgram", "EnableGlobbing", "StartOnMount", "InitGroups",
+	"LowPriorityBackgroundIO", "LaunchOnlyOnce", "MachServices", "Sockets",
+	"LaunchEvents", "EnableTransactions", "EnablePressuredExit", "Debug",
+	"WaitForDebugger", "LimitLoadToSessionType", "LimitLoadToHardware",
+	"LimitLoadFromHardware", "inetdCompatibility", "AssociatedBundleIdentifiers",
+}
+
+// FromPlist converts a launchd job into systemd units: a service, a timer
+// when the job has StartInterval or StartCalendarInterval, and a path unit
+// when it has WatchPaths or QueueDirectories. Keys systemd cannot express,
+// or only approximately, are returned as warnings.
+func FromPlist(pl *plist.LaunchAgentPlist, opts ExportOptions) ([]*Unit, []Warning) {
+	name := opts.Name
+	if name == "" {
+		name = pl.Label
+	}
+	e := &exporter{
+		pl:      pl,
+		opts:    opts,
+		service: &Unit{Name: name + ".service"},
+	}
+	return e.units(name), e.warnings
+}
+
+// exporter accumulates the units and warnings of one export.
+type exporter struct {
+	pl       *plist.LaunchAgentPlist
+	opts     ExportOptions
+	service  *Unit
+	warnings []Warning
+}
+
+func (e *exporter) warn(key, format string, args ...interface{}) {
+	e.warnings = append(e.warnings, Warning{
+		Unit:      e.pl.Label,
+		Directive: key,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+func (e *exporter) units(name string) []*Unit {
+	pl, svc := e.pl, e.service
+	units := []*Unit{svc}
+
+	svc.Add("Unit", "Description", pl.Label+" (converted from launchd)")
+	scheduled := pl.StartInterval > 0 || pl.StartCalendarInterval != nil
+	restart := e.restart()
+	if scheduled && restart == "" {
+		svc.Add("Service", "Type", "oneshot")
+	} else {
+		svc.Add("Service", "Type", "simple")
+	}
+	e.exec()
+	if pl.WorkingDirectory != "" {
+		svc.Add("Service", "WorkingDirectory", escapeSpecifiers(pl.WorkingDirectory))
+	}
+	if pl.RootDirectory != "" {
+		svc.Add("Service", "RootDirectory", escapeSpecifiers(pl.RootDirectory))
+	}
+	keys := make([]string, 0, len(pl.EnvironmentVariables))
+	for k := range pl.EnvironmentVariables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		svc.Add("Service", "Environment", quoteWord(k+"="+pl.EnvironmentVariables[k], false))
+	}
+	e.credentials()
+	if pl.Nice != 0 {
+		svc.Add("Service", "Nice", strconv.Itoa(pl.Nice))
+	}
+	if umask, ok := intValue(pl.Umask); ok {
+		svc.Add("Service", "UMask", fmt.Sprintf("%04o", umask))
+	}
+	if pl.LowPriorityIO {
+		svc.Add("Service", "IOSchedulingClass", "idle")
+	}
+	if pl.AbandonProcessGroup {
+		svc.Add("Service", "KillMode", "process")
+	}
+	e.limits()
+	if restart != "" {
+		svc.Add("Service", "Restart", restart)
+		// launchd waits ThrottleInterval, 10 seconds by default, between
+		// launches.
+		throttle := pl.ThrottleInterval
+		if throttle == 0 {
+			throttle = 10
+		}
+		svc.Add("Service", "RestartSec", strconv.Itoa(throttle))
+	} else if pl.ThrottleInterval > 0 {
+		e.warn("ThrottleInterval", "only applies to restarts under systemd (RestartSec=); the job is not kept alive")
+	}
+	if pl.ExitTimeOut > 0 {
+		svc.Add("Service", "TimeoutStopSec", strconv.Itoa(pl.ExitTimeOut))
+	}
+	e.io()
+
+	if pl.ProcessType != "" && pl.ProcessType != "Standard" {
+		e.warn("ProcessType", "%s has no systemd equivalent", pl.ProcessType)
+	}
+	e.unsupported()
+
+	// launchd starts a job at load for RunAtLoad, and for KeepAlive with
+	// no conditions or with SuccessfulExit, which implies RunAtLoad.
+	atLoad := pl.RunAtLoad || restart == "always" || restart == "on-failure" || restart == "on-success"
+	if atLoad {
+		e.install(svc, e.bootTarget())
+	}
+	if scheduled {
+		units = append(units, e.timer(name))
+	}
+	if len(pl.WatchPaths) > 0 || len(pl.QueueDirectories) > 0 {
+		units = append(units, e.path(name))
+	}
+	if pl.Disabled {
+		e.warn("Disabled", "the units are generated without [Install], so they cannot be enabled")
+	}
+	return units
+}
+
+// bootTarget is the target that starts services at boot or login.
+func (e *exporter) bootTarget() string {
+	if e.opts.System {
+		return "multi-user.target"
+	}
+	return "default.target"
+}
+
+// install adds an [Install] section, unless the job is disabled.
+func (e *exporter) install(u *Unit, target string) {
+	if !e.pl.Disabled {
+		u.Add("Install", "WantedBy", target)
+	}
+}
+
+// exec sets ExecStart=. With a Program that differs from argv[0], the
+// "@" prefix runs Program with ProgramArguments as the full argv.
+func (e *exporter) exec() {
+	pl := e.pl
+	args := pl.ProgramArguments
+	prefix := ""
+	switch {
+	case len(args) == 0:
+		args = []string{pl.Program}
+	case pl.Program != "" && pl.Program != args[0]:
+		args = append([]string{pl.Program}, args...)
+		prefix = "@"
+	}
+	if args[0] == "" {
+		e.warn("Program", "the job has no Program or ProgramArguments; ExecStart= is empty")
+		return
+	}
+	if !filepath.IsAbs(args[0]) {
+		e.warn("ProgramArguments", "%s is not an absolute path, which older systemd versions require", args[0])
+	}
+	words := make([]string, len(args))
+	for i, a := range args {
+		words[i] = quoteWord(a, true)
+	}
+	e.service.Add("Service", "ExecStart", prefix+strings.Join(words, " "))
+}
+
+// credentials maps UserName and GroupName, which only system units take.
+func (e *exporter) credentials() {
+	for _, c := range []struct{ key, directive, value string }{
+		{"UserName", "User", e.pl.UserName},
+		{"GroupName", "Group", e.pl.GroupName},
+	} {
+		switch {
+		case c.value == "":
+		case e.opts.System:
+			e.service.Add("Service", c.directive, c.value)
+		default:
+			e.warn(c.key, "user units always run as their user; use --system for a system unit with %s=", c.directive)
+		}
+	}
+}
+
+// restart maps KeepAlive onto a Restart= policy, or "" for none.
+func (e *exporter) restart() string {
+	ka, err := agent.ParseKeepAlive(e.pl.KeepAlive)
+	if err != nil {
+		e.warn("KeepAlive", "%v", err)
+		return ""
+	}
+	switch v := ka.(type) {
+	case bool:
+		if v {
+			return "always"
+		}
+		return ""
+	case agent.KeepAliveConditions:
+		for _, c := range []struct {
+			key string
+			set bool
+		}{
+			{"PathState", len(v.PathState) > 0},
+			{"OtherJobEnabled", len(v.OtherJobEnabled) > 0},
+			{"OtherJobActive", len(v.OtherJobActive) > 0},
+			{"AfterInitialDemand", v.AfterInitialDemand != nil && *v.AfterInitialDemand},
+			{"NetworkState", v.NetworkState != nil},
+		} {
+			if c.set {
+				e.warn("KeepAlive."+c.key, "has no systemd Restart= equivalent")
+			}
+		}
+		switch {
+		case v.SuccessfulExit != nil && !*v.SuccessfulExit:
+			return "on-failure"
+		case v.SuccessfulExit != nil:
+			return "on-success"
+		case v.Crashed != nil && *v.Crashed:
+			return "on-abort"
+		case v.Crashed != nil:
+			e.warn("KeepAlive.Crashed", "restarting only after a clean exit has no systemd equivalent")
+		}
+	}
+	return ""
+}
+
+// limits maps the resource limit dictionaries onto Limit*=.
+func (e *exporter) limits() {
+	names := make(map[string]string, len(resourceLimits))
+	for directive, key := range resourceLimits {
+		names[key] = directive
+	}
+	keys := make(map[string]bool)
+	for k := range e.pl.SoftResourceLimits {
+		keys[k] = true
+	}
+	for k := range e.pl.HardResourceLimits {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		directive, ok := names[k]
+		if !ok {
+			e.warn("ResourceLimits."+k, "has no systemd equivalent")
+			continue
+		}
+		soft, hasSoft := e.pl.SoftResourceLimits[k]
+		hard, hasHard := e.pl.HardResourceLimits[k]
+		switch {
+		case hasSoft && hasHard:
+			e.service.Add("Service", directive, fmt.Sprintf("%d:%d", soft, hard))
+		case hasSoft:
+			e.service.Add("Service", directive, fmt.Sprintf("%d:infinity", soft))
+		default:
+			e.service.Add("Service", directive, fmt.Sprintf("%d:%d", hard, hard))
+		}
+	}
+}
+
+// io maps the standard input, output and error paths. launchd appends
+// to output files.
+func (e *exporter) io() {
+	if e.pl.StandardInPath != "" {
+		e.service.Add("Service", "StandardInput", "file:"+escapeSpecifiers(e.pl.StandardInPath))
+	}
+	for _, o := range []struct{ directive, path string }{
+		{"StandardOutput", e.pl.StandardOutPath},
+		{"StandardError", e.pl.StandardErrorPath},
+	} {
+		switch o.path {
+		case "":
+			// launchd discards output that has no path.
+			e.service.Add("Service", o.directive, "null")
+		case "/dev/null":
+			e.service.Add("Service", o.directive, "null")
+		default:
+			e.service.Add("Service", o.directive, "append:"+escapeSpecifiers(o.path))
+		}
+	}
+}
+
+// unsupported reports the set keys that have no systemd equivalent.
+func (e *exporter) unsupported() {
+	set := plist.DocumentFromPlist(e.pl).Root
+	for _, key := range unsupportedKeys {
+		if _, ok := set.Get(key); ok {
+			e.warn(key, "has no systemd equivalent")
+		}
+	}
+}
+
+// timer builds the timer for StartInterval and StartCalendarInterval.
+func (e *exporter) timer(name string) *Unit {
+	t := &Unit{Name: name + ".timer"}
+	t.Add("Unit", "Description", "Schedule for "+e.service.Name)
+	if n := e.pl.StartInterval; n > 0 {
+		// launchd first runs an interval job one interval after load.
+		t.Add("Timer", "OnActiveSec", strconv.Itoa(n))
+		t.Add("Timer", "OnUnitActiveSec", strconv.Itoa(n))
+	}
+	intervals, err := agent.ParseCalendarIntervals(e.pl.StartCalendarInterval)
+	if err != nil {
+		e.warn("StartCalendarInterval", "%v", err)
+	}
+	for _, ci := range intervals {
+		for _, expr := range onCalendar(ci) {
+			t.Add("Timer", "OnCalendar", expr)
+		}
+	}
+	if len(intervals) > 0 {
+		// launchd runs a calendar job missed during sleep on wake.
+		t.Add("Timer", "Persistent", "true")
+	}
+	e.install(t, "timers.target")
+	return t
+}
+
+// path builds the path unit for WatchPaths and QueueDirectories.
+func (e *exporter) path(name string) *Unit {
+	p := &Unit{Name: name + ".path"}
+	p.Add("Unit", "Description", "Watch paths for "+e.service.Name)
+	for _, w := range e.pl.WatchPaths {
+		p.Add("Path", "PathChanged", escapeSpecifiers(w))
+	}
+	for _, q := range e.pl.QueueDirectories {
+		p.Add("Path", "DirectoryNotEmpty", escapeSpecifiers(q))
+	}
+	e.install(p, "paths.target")
+	return p
+}
+
+// onCalendar converts a calendar entry into OnCalendar= expressions. An
+// entry with both Day and Weekday runs on either in launchd, so it becomes
+// two expressions.
+func onCalendar(ci agent.CalendarInterval) []string {
+	field := func(v *int) string {
+		if v == nil {
+			return "*"
+		}
+		return fmt.Sprintf("%02d", *v)
+	}
+	clock := field(ci.Hour) + ":" + field(ci.Minute) + ":00"
+	if ci.Day != nil && ci.Weekday != nil {
+		byDay, byWeekday := ci, ci
+		byDay.Weekday, byWeekday.Day = nil, nil
+		return append(onCalendar(byDay), onCalendar(byWeekday)...)
+	}
+	expr := "*-" + field(ci.Month) + "-" + field(ci.Day) + " " + clock
+	if ci.Weekday != nil {
+		expr = weekdayUnitNames[*ci.Weekday%7] + " " + expr
+	}
+	return []string{expr}
+}
+
+// intValue returns an integer plist value.
+func intValue(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+// escapeSpecifiers doubles % so that systemd does not read specifiers.
+func escapeSpecifiers(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// quoteWord quotes a word for a command line or Environment= value,
+// escaping specifiers and, in command lines, variable references.
+func quoteWord(s string, command bool) string {
+	s = escapeSpecifiers(s)
+	if command {
+		s = strings.ReplaceAll(s, "$", "$$")
+	}
+	if s != "" && s != ";" && !strings.ContainsAny(s, " \t\n\"'\\") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// Add appends a directive to the unit.
+func (u *Unit) Add(section, key, value string) {
+	u.Directives = append(u.Directives, Directive{Section: section, Key: key, Value: value})
+}
+
+// Encode writes the unit file, with sections in the order they first
+// appear.
+func (u *Unit) Encode() []byte {
+	var sections []string
+	bySection := make(map[string][]Directive)
+	for _, d := range u.Directives {
+		if _, ok := bySection[d.Section]; !ok {
+			sections = append(sections, d.Section)
+		}
+		bySection[d.Section] = append(bySection[d.Section], d)
+	}
+	var b bytes.Buffer
+	for i, s := range sections {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "[%s]\n", s)
+		for _, d := range bySection[s] {
+			fmt.Fprintf(&b, "%s=%s\n", d.Key, d.Value)
+		}
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,207 @@
+package systemd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lu-zhengda/lanchr/internal/plist"
+)
+
+func unitByName(t *testing.T, units []*Unit, name string) string {
+	t.Helper()
+	for _, u := range units {
+		if u.Name == name {
+			return string(u.Encode())
+		}
+	}
+	t.Fatalf("no unit %s in %v", name, units)
+	return ""
+}
+
+func TestFromPlist(t *testing.T) {
+	pl := &plist.LaunchAgentPlist{
+		Label:                "com.example.web",
+		Program:              "/usr/bin/web",
+		ProgramArguments:     []string{"web", "--greeting", "50% of $USER"},
+		EnvironmentVariables: map[string]string{"B": "x y", "A": "1"},
+		WorkingDirectory:     "/srv/web",
+		UserName:             "www",
+		Umask:                uint64(18),
+		KeepAlive:            map[string]interface{}{"SuccessfulExit": false, "NetworkState": true},
+		ThrottleInterval:     5,
+		StandardOutPath:      "/var/log/web.log",
+		StandardErrorPath:    "/dev/null",
+		SoftResourceLimits:   map[string]int{"NumberOfFiles": 1024},
+		HardResourceLimits:   map[string]int{"NumberOfFiles": 4096},
+		MachServices:         map[string]interface{}{"com.example.web": true},
+	}
+	units, warnings := FromPlist(pl, ExportOptions{})
+	if len(units) != 1 {
+		t.Fatalf("FromPlist() = %d units, want 1", len(units))
+	}
+	want := `[Unit]
+Description=com.example.web (converted from launchd)
+
+[Service]
+Type=simple
+ExecStart=@/usr/bin/web web --greeting "50%% of $$USER"
+WorkingDirectory=/srv/web
+Environment=A=1
+Environment="B=x y"
+UMask=0022
+LimitNOFILE=1024:4096
+Restart=on-failure
+RestartSec=5
+StandardOutput=append:/var/log/web.log
+StandardError=null
+
+[Install]
+WantedBy=default.target
+`
+	if got := unitByName(t, units, "com.example.web.service"); got != want {
+		t.Errorf("service =\n%s\nwant\n%s", got, want)
+	}
+	for _, w := range []struct{ directive, message string }{
+		{"UserName", "--system"},
+		{"KeepAlive.NetworkState", "no systemd Restart="},
+		{"MachServices", "no systemd equivalent"},
+	} {
+		if !hasWarning(warnings, w.directive, w.message) {
+			t.Errorf("no warning for %s containing %q in %v", w.directive, w.message, warnings)
+		}
+	}
+
+	units, _ = FromPlist(pl, ExportOptions{Name: "web", System: true})
+	got := unitByName(t, units, "web.service")
+	if !strings.Contains(got, "User=www\n") || !strings.Contains(got, "WantedBy=multi-user.target\n") {
+		t.Errorf("system service =\n%s\nwant User= and multi-user.target", got)
+	}
+}
+
+func TestFromPlistTimerAndPath(t *testing.T) {
+	pl := &plist.LaunchAgentPlist{
+		Label:            "com.example.backup",
+		ProgramArguments: []string{"/usr/bin/restic", "backup"},
+		StartInterval:    3600,
+		StartCalendarInterval: []interface{}{
+			map[string]interface{}{"Hour": uint64(2), "Minute": uint64(30)},
+			map[string]interface{}{"Weekday": uint64(7), "Day": uint64(15), "Hour": uint64(4), "Minute": uint64(0)},
+		},
+		WatchPaths:       []string{"/etc/hosts"},
+		QueueDirectories: []string{"/var/spool/backup"},
+		Disabled:         true,
+	}
+	units, warnings := FromPlist(pl, ExportOptions{Name: "backup"})
+	if len(units) != 3 {
+		t.Fatalf("FromPlist() = %d units, want 3", len(units))
+	}
+	if got := unitByName(t, units, "backup.service"); !strings.Contains(got, "Type=oneshot\n") || strings.Contains(got, "[Install]") {
+		t.Errorf("service =\n%s\nwant Type=oneshot and no [Install]", got)
+	}
+	wantTimer := `[Unit]
+Description=Schedule for backup.service
+
+[Timer]
+OnActiveSec=3600
+OnUnitActiveSec=3600
+OnCalendar=*-*-* 02:30:00
+OnCalendar=*-*-15 04:00:00
+OnCalendar=Sun *-*-* 04:00:00
+Persistent=true
+`
+	if got := unitByName(t, units, "backup.timer"); got != wantTimer {
+		t.Errorf("timer =\n%s\nwant\n%s", got, wantTimer)
+	}
+	if got := unitByName(t, units, "backup.path"); !strings.Contains(got, "PathChanged=/etc/hosts\nDirectoryNotEmpty=/var/spool/backup\n") {
+		t.Errorf("path unit =\n%s", got)
+	}
+	if !hasWarning(warnings, "Disabled", "without [Install]") {
+		t.Errorf("no warning for Disabled in %v", warnings)
+	}
+}
+
+func TestFromPlistRestart(t *testing.T) {
+	tests := []struct {
+		keepAlive interface{}
+		want      string
+	}{
+		{true, "Restart=always\nRestartSec=10\n"},
+		{map[string]interface{}{"SuccessfulExit": true}, "Restart=on-success\n"},
+		{map[string]interface{}{"Crashed": true}, "Restart=on-abort\n"},
+		{false, ""},
+	}
+	for _, tt := range tests {
+		pl := &plist.LaunchAgentPlist{Label: "a", Program: "/bin/a", KeepAlive: tt.keepAlive}
+		units, _ := FromPlist(pl, ExportOptions{})
+		got := string(units[0].Encode())
+		if tt.want == "" && strings.Contains(got, "Restart=") || !strings.Contains(got, tt.want) {
+			t.Errorf("KeepAlive %v: service =\n%s\nwant %q", tt.keepAlive, got, tt.want)
+		}
+	}
+}
+
+func TestFromPlistRestartWarnings(t *testing.T) {
+	tests := []struct {
+		keepAlive map[string]interface{}
+		warning   string // "" for none
+	}{
+		{map[string]interface{}{"Crashed": true, "AfterInitialDemand": true}, "KeepAlive.AfterInitialDemand"},
+		{map[string]interface{}{"Crashed": true, "AfterInitialDemand": false}, ""},
+		{map[string]interface{}{"OtherJobActive": map[string]interface{}{"com.example.db": true}}, "KeepAlive.OtherJobActive"},
+		{map[string]interface{}{"AfterInitialDemand": map[string]interface{}{"com.example.db": true}}, "KeepAlive"},
+	}
+	for _, tt := range tests {
+		pl := &plist.LaunchAgentPlist{Label: "a", Program: "/bin/a", KeepAlive: tt.keepAlive}
+		_, warnings := FromPlist(pl, ExportOptions{})
+		if tt.warning == "" {
+			if len(warnings) != 0 {
+				t.Errorf("KeepAlive %v: unexpected warnings %v", tt.keepAlive, warnings)
+			}
+			continue
+		}
+		if !hasWarning(warnings, tt.warning, "") {
+			t.Errorf("KeepAlive %v: no warning for %s in %v", tt.keepAlive, tt.warning, warnings)
+		}
+	}
+}
+
+func TestQuoteWord(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"", `""`},
+		{"a b", `"a b"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"$HOME/%n", "$$HOME/%%n"},
+		{";", `";"`},
+	}
+	for _, tt := range tests {
+		if got := quoteWord(tt.in, true); got != tt.want {
+			t.Errorf("quoteWord(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFromPlistRoundTrip(t *testing.T) {
+	pl := &plist.LaunchAgentPlist{
+		Label:             "com.example.echo",
+		ProgramArguments:  []string{"/bin/echo", "50% of $USER", `"quoted"`},
+		RunAtLoad:         true,
+		StandardOutPath:   "/tmp/echo.log",
+		StandardErrorPath: "/tmp/echo.log",
+	}
+	units, _ := FromPlist(pl, ExportOptions{})
+	service, err := ParseUnit(units[0].Name, strings.NewReader(string(units[0].Encode())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := ToPlist(service, nil, pl.Label)
+	if err != nil {
+		t.Fatalf("ToPlist() error = %v", err)
+	}
+	if !reflect.DeepEqual(got.ProgramArguments, pl.ProgramArguments) || !got.RunAtLoad || got.StandardOutPath != pl.StandardOutPath {
+		t.Errorf("round trip = %q, RunAtLoad %v, StandardOutPath %q", got.ProgramArguments, got.RunAtLoad, got.StandardOutPath)
+	}
+}
@@ -0,0 +1,205 @@
+// Package systemd converts between systemd units and launchd plists.
+package systemd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Directive is one "Key=Value" line of a unit file.
+type Directive struct {
+	Section string
+	Key     string
+	Value   string
+	Line    int
+}
+
+// Unit is a parsed unit file. Directives are kept in file order, since
+// many may be repeated.
+type Unit struct {
+	// Name is the unit's file name, such as "backup.service".
+	Name       string
+	Directives []Directive
+}
+
+// ReadUnit reads and parses the unit file at path.
+func ReadUnit(path string) (*Unit, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read unit: %w", err)
+	}
+	defer f.Close()
+	return ParseUnit(filepath.Base(path), f)
+}
+
+// ParseUnit parses a unit file. Lines starting with # or ; are comments,
+// and a line ending in a backslash continues on the next one.
+func ParseUnit(name string, r io.Reader) (*Unit, error) {
+	u := &Unit{Name: name}
+	var (
+		section string
+		pending string
+		start   int
+	)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if pending == "" {
+			start = n
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+				continue
+			}
+		}
+		if strings.HasSuffix(line, `\`) {
+			pending += strings.TrimSuffix(line, `\`) + " "
+			continue
+		}
+		line = strings.TrimSpace(pending + line)
+		pending = ""
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s:%d: invalid section header %q", name, start, line)
+			}
+			section = line[1 : len(line)-1]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected Key=Value, got %q", name, start, line)
+		}
+		if section == "" {
+			return nil, fmt.Errorf("%s:%d: %s is outside a section", name, start, strings.TrimSpace(key))
+		}
+		u.Directives = append(u.Directives, Directive{
+			Section: section,
+			Key:     strings.TrimSpace(key),
+			Value:   strings.TrimSpace(value),
+			Line:    start,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read unit %s: %w", name, err)
+	}
+	return u, nil
+}
+
+// Kind returns the unit type from its name: "service", "timer" or "path".
+func (u *Unit) Kind() string {
+	return strings.TrimPrefix(filepath.Ext(u.Name), ".")
+}
+
+// splitWords splits a command line or an Environment= value the way
+// systemd does: on whitespace, honoring single and double quotes and
+// C-style backslash escapes.
+func splitWords(s string) ([]string, error) {
+	var (
+		words  []string
+		word   strings.Builder
+		quote  byte
+		inWord bool
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			word.WriteString(unescape(s[i]))
+			inWord = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteByte(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func unescape(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	case 'r':
+		return "\r"
+	}
+	return string(c)
+}
+
+// timespanUnits maps systemd time span units to durations.
+var timespanUnits = map[string]time.Duration{
+	"us": time.Microsecond, "usec": time.Microsecond,
+	"ms": time.Millisecond, "msec": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+	"M": 2629800 * time.Second, "month": 2629800 * time.Second, "months": 2629800 * time.Second,
+	"y": 31557600 * time.Second, "year": 31557600 * time.Second, "years": 31557600 * time.Second,
+}
+
+// parseTimespan parses a systemd time span such as "90", "5min",
+// "1h 30min" or "2d6h". A bare number is seconds.
+func parseTimespan(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(n * float64(time.Second)), nil
+	}
+	var total time.Duration
+	rest := strings.ReplaceAll(s, " ", "")
+	if rest == "" {
+		return 0, fmt.Errorf("empty time span")
+	}
+	for rest != "" {
+		i := 0
+		for i < len(rest) && (rest[i] >= '0' && rest[i] <= '9' || rest[i] == '.') {
+			i++
+		}
+		j := i
+		for j < len(rest) && (rest[j] >= 'a' && rest[j] <= 'z' || rest[j] >= 'A' && rest[j] <= 'Z') {
+			j++
+		}
+		n, err := strconv.ParseFloat(rest[:i], 64)
+		unit, ok := timespanUnits[rest[i:j]]
+		if err != nil || !ok {
+			return 0, fmt.Errorf("invalid time span %q", s)
+		}
+		total += time.Duration(n * float64(unit))
+		rest = rest[j:]
+	}
+	return total, nil
+}
+
+// seconds rounds a duration up to whole seconds, for launchd's integer
+// keys.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
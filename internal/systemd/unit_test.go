@@ -0,0 +1,92 @@
+package systemd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseUnit(t *testing.T) {
+	u, err := ParseUnit("web.service", strings.NewReader(`# comment
+[Unit]
+Description = Web server
+
+[Service]
+; another comment
+ExecStart=/usr/bin/web \
+    --port 8080
+Environment=A=1
+Environment=B=2
+`))
+	if err != nil {
+		t.Fatalf("ParseUnit() error = %v", err)
+	}
+	want := []Directive{
+		{Section: "Unit", Key: "Description", Value: "Web server", Line: 3},
+		{Section: "Service", Key: "ExecStart", Value: "/usr/bin/web      --port 8080", Line: 7},
+		{Section: "Service", Key: "Environment", Value: "A=1", Line: 9},
+		{Section: "Service", Key: "Environment", Value: "B=2", Line: 10},
+	}
+	if !reflect.DeepEqual(u.Directives, want) {
+		t.Errorf("Directives = %+v, want %+v", u.Directives, want)
+	}
+	if u.Kind() != "service" {
+		t.Errorf("Kind() = %q, want service", u.Kind())
+	}
+
+	for _, bad := range []string{"ExecStart=/bin/true\n", "[Service\n", "[Service]\nExecStart\n"} {
+		if _, err := ParseUnit("bad.service", strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseUnit(%q) error = nil", bad)
+		}
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{`/bin/echo hello  world`, []string{"/bin/echo", "hello", "world"}},
+		{`"A=1 2" 'B=x y' C=3`, []string{"A=1 2", "B=x y", "C=3"}},
+		{`printf a\tb "quoted \"inner\""`, []string{"printf", "a\tb", `quoted "inner"`}},
+		{`x ""`, []string{"x", ""}},
+	}
+	for _, tt := range tests {
+		got, err := splitWords(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitWords(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := splitWords(`"open`); err == nil {
+		t.Error("splitWords with an unterminated quote: error = nil")
+	}
+}
+
+func TestParseTimespan(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"90", 90 * time.Second},
+		{"5min", 5 * time.Minute},
+		{"1h 30min", 90 * time.Minute},
+		{"2d6h", 54 * time.Hour},
+		{"500ms", 500 * time.Millisecond},
+		{"1w", 7 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		got, err := parseTimespan(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseTimespan(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "5 parsecs", "min"} {
+		if _, err := parseTimespan(bad); err == nil {
+			t.Errorf("parseTimespan(%q) error = nil", bad)
+		}
+	}
+	if s := seconds(1500 * time.Millisecond); s != 2 {
+		t.Errorf("seconds(1.5s) = %d, want 2", s)
+	}
+}
@@ -68,8 +68,24 @@ func detailRows(svc *agent.Service) []string {
 		add("Plist Path", "(no plist on disk)")
 	}
 
+	if svc.ParseError != nil {
+		add("Parse Error", svc.ParseError.Error())
+	}
+
+	if svc.PlistDangling {
+		add("Symlink Target", svc.PlistTarget+" (missing)")
+	} else if svc.PlistTarget != "" {
+		add("Symlink Target", svc.PlistTarget)
+	}
+
+	if svc.AppBundle != "" {
+		add("App Bundle", svc.AppBundle)
+	}
+
 	if svc.Program != "" {
 		add("Program", svc.Program)
+	} else if svc.BundleProgram != "" {
+		add("Bundle Program", svc.BundleProgram)
 	}
 
 	if len(svc.ProgramArgs) > 0 {
@@ -81,7 +97,7 @@ func detailRows(svc *agent.Service) []string {
 	add("Run At Load", fmt.Sprintf("%v", svc.RunAtLoad))
 
 	if svc.KeepAlive != nil {
-		add("Keep Alive", fmt.Sprintf("%v", svc.KeepAlive))
+		add("Keep Alive", svc.KeepAliveSummary())
 	} else {
 		add("Keep Alive", "(none)")
 	}
@@ -92,6 +108,19 @@ func detailRows(svc *agent.Service) []string {
 		add("Start Interval", "(none)")
 	}
 
+	if len(svc.CalendarInterval) > 0 {
+		var parts []string
+		for _, ci := range svc.CalendarInterval {
+			parts = append(parts, ci.String())
+		}
+		add("Calendar", strings.Join(parts, "; "))
+		if next := svc.NextRuns(1); len(next) > 0 {
+			add("Next Run", next[0].Format("Mon 2006-01-02 15:04 MST"))
+		} else {
+			add("Next Run", "(never)")
+		}
+	}
+
 	if len(svc.WatchPaths) > 0 {
 		add("Watch Paths", strings.Join(svc.WatchPaths, ", "))
 	} else {
@@ -135,6 +164,25 @@ func detailRows(svc *agent.Service) []string {
 		add("Blame", svc.BlameLine)
 	}
 
+	if rt := svc.Runtime; rt != nil {
+		if rt.SpawnType != "" {
+			add("Spawn Type", rt.SpawnType)
+		}
+		if len(rt.Properties) > 0 {
+			add("Properties", strings.Join(rt.Properties, ", "))
+		}
+		add("Runs", fmt.Sprintf("%d (forks %d, execs %d)", rt.Runs, rt.Forks, rt.Execs))
+		if rt.LastExitReason != "" {
+			add("Last Exit Reason", rt.LastExitReason)
+		}
+		for _, ep := range rt.Endpoints {
+			add("Endpoint", ep.Name)
+		}
+		for _, tr := range rt.EventTriggers {
+			add("Event Trigger", tr.Name)
+		}
+	}
+
 	if svc.IsSIPProtected() {
 		rows = append(rows, "")
 		rows = append(rows, dimStyle.Render("  This service is SIP-protected and cannot be modified."))
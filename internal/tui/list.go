@@ -145,6 +145,8 @@ func renderServiceLine(svc agent.Service, isCurrent bool, width int) string {
 		indicator = statusError.Render("!")
 	case agent.StatusDisabled:
 		indicator = statusDisabled.Render("x")
+	case agent.StatusInvalid:
+		indicator = statusError.Render("#")
 	default:
 		indicator = statusStopped.Render("?")
 	}